@@ -23,6 +23,7 @@ require (
 	github.com/trzsz/ssh_config v1.3.4
 	github.com/trzsz/trzsz-go v1.1.8-0.20240128115521-b72e541d6a18
 	golang.org/x/crypto v0.18.0
+	golang.org/x/net v0.20.0
 	golang.org/x/sys v0.16.0
 	golang.org/x/term v0.16.0
 )
@@ -52,7 +53,6 @@ require (
 	github.com/rivo/uniseg v0.4.6 // indirect
 	golang.org/x/image v0.15.0 // indirect
 	golang.org/x/mod v0.14.0 // indirect
-	golang.org/x/net v0.20.0 // indirect
 	golang.org/x/sync v0.6.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	golang.org/x/tools v0.17.0 // indirect
@@ -26,7 +26,6 @@ SOFTWARE.
 
 import (
 	"fmt"
-	"io"
 	"net"
 	"os"
 	"strings"
@@ -38,9 +37,22 @@ import (
 
 var (
 	agentOnce   sync.Once
+	agentConn   net.Conn
 	agentClient agent.ExtendedAgent
 )
 
+func init() {
+	// registered in advance, since the agent client may be created by the jump host prefetches in the background
+	afterLoginFuncs = append(afterLoginFuncs, func() {
+		if agentConn != nil {
+			agentConn.Close()
+			agentConn = nil
+			agentClient = nil
+			resetAgentSigners()
+		}
+	})
+}
+
 func getAgentAddr(args *sshArgs, param *sshParam) (string, error) {
 	if addr := getOptionConfig(args, "IdentityAgent"); addr != "" {
 		if strings.ToLower(addr) == "none" {
@@ -52,6 +64,9 @@ func getAgentAddr(args *sshArgs, param *sshParam) (string, error) {
 		}
 		return resolveHomeDir(expandedAddr), nil
 	}
+	if isWslWindowsAgentEnabled() {
+		return kWslAgentAddr, nil
+	}
 	if addr := os.Getenv("SSH_AUTH_SOCK"); addr != "" {
 		return resolveHomeDir(addr), nil
 	}
@@ -79,13 +94,8 @@ func getAgentClient(args *sshArgs, param *sshParam) agent.ExtendedAgent {
 			return
 		}
 
-		agentClient = agent.NewClient(conn)
+		agentConn, agentClient = conn, agent.NewClient(conn)
 		debug("new ssh agent client [%s] success", addr)
-
-		afterLoginFuncs = append(afterLoginFuncs, func() {
-			conn.Close()
-			agentClient = nil
-		})
 	})
 	return agentClient
 }
@@ -125,14 +135,14 @@ func forwardAgentRequest(channel ssh.Channel, addr string) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 	go func() {
-		_, _ = io.Copy(conn, channel)
+		_, _ = copyWithPooledBuffer(conn, channel)
 		if unixConn, ok := conn.(*net.UnixConn); ok {
 			_ = unixConn.CloseWrite()
 		}
 		wg.Done()
 	}()
 	go func() {
-		_, _ = io.Copy(channel, conn)
+		_, _ = copyWithPooledBuffer(channel, conn)
 		_ = channel.CloseWrite()
 		wg.Done()
 	}()
@@ -34,5 +34,8 @@ import (
 const defaultAgentAddr = ""
 
 func dialAgent(addr string) (net.Conn, error) {
+	if addr == kWslAgentAddr {
+		return dialWslWindowsAgent()
+	}
 	return net.DialTimeout("unix", addr, time.Second)
 }
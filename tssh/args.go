@@ -46,6 +46,10 @@ type forwardArgs struct {
 	cfgs []*forwardCfg
 }
 
+type remoteArgs struct {
+	cfgs []*forwardCfg
+}
+
 type sshArgs struct {
 	Ver            bool        `arg:"-V,--" help:"show program's version number and exit"`
 	Destination    string      `arg:"positional" help:"alias in ~/.ssh/config, or [user@]hostname[:port]"`
@@ -61,26 +65,71 @@ type sshArgs struct {
 	Port           int         `arg:"-p,--" placeholder:"port" help:"port to connect to on the remote host"`
 	LoginName      string      `arg:"-l,--" placeholder:"login_name" help:"the user to log in as on the remote machine"`
 	Identity       multiStr    `arg:"-i,--" placeholder:"identity_file" help:"identity (private key) for public key auth"`
-	ConfigFile     string      `arg:"-F,--" placeholder:"configfile" help:"an alternative per-user configuration file"`
+	ConfigFile     multiStr    `arg:"-F,--" placeholder:"configfile" help:"an alternative per-user configuration file,\nrepeat to layer more files, the first match wins"`
 	ProxyJump      string      `arg:"-J,--" placeholder:"destination" help:"jump hosts separated by comma characters"`
 	Option         sshOption   `arg:"-o,--" placeholder:"key=value" help:"options in the format used in ~/.ssh/config\ne.g., tssh -o ProxyCommand=\"ssh proxy nc %h %p\""`
 	StdioForward   string      `arg:"-W,--" placeholder:"host:port" help:"forward stdin and stdout to host on port"`
 	DynamicForward bindArgs    `arg:"-D,--" placeholder:"[bind_addr:]port" help:"dynamic port forwarding ( socks5 proxy )"`
 	LocalForward   forwardArgs `arg:"-L,--" placeholder:"[bind_addr:]port:host:hostport" help:"local port forwarding"`
-	RemoteForward  forwardArgs `arg:"-R,--" placeholder:"[bind_addr:]port:host:hostport" help:"remote port forwarding"`
+	RemoteForward  remoteArgs  `arg:"-R,--" placeholder:"[bind_addr:]port:host:hostport" help:"remote port forwarding,\nor a socks5 proxy for the remote side if only [bind_addr:]port"`
 	Reconnect      bool        `arg:"--reconnect" help:"reconnect when background(-f) process exits"`
 	DragFile       bool        `arg:"--dragfile" help:"enable drag files and directories to upload"`
 	TraceLog       bool        `arg:"--tracelog" help:"enable trzsz detect trace logs for debugging"`
 	Relay          bool        `arg:"--relay" help:"force trzsz run as a relay on the jump server"`
 	Debug          bool        `arg:"--debug" help:"verbose mode for debugging, same as ssh's -vvv"`
 	Zmodem         bool        `arg:"--zmodem" help:"enable zmodem lrzsz ( rz / sz ) feature"`
+	Profile        multiStr    `arg:"--profile" placeholder:"name" help:"apply the options of the named profile in ~/.tssh.conf"`
+	Forwards       multiStr    `arg:"--forwards" placeholder:"name" help:"apply the named forward set in ~/.tssh.conf"`
+	As             string      `arg:"--as" placeholder:"user" help:"log in as the user with the host's configuration,\nor 'ask' to choose from the recently used users"`
+	Var            multiStr    `arg:"--var" placeholder:"name=value" help:"the value of the {{name}} placeholder in RemoteCommand"`
+	ReadOnly       bool        `arg:"--read-only" help:"show the remote output only, ignore the keyboard input\nexcept the escape sequence ExConsoleEscape, default: ^]"`
+	Tmux           bool        `arg:"--tmux" help:"attach to the remote tmux session after login, or create it"`
+	TmuxSession    string      `arg:"--tmux-session" placeholder:"name" help:"the remote tmux session name of --tmux, default: tssh"`
+	VPN            multiStr    `arg:"--vpn" placeholder:"subnet" help:"forward the tcp traffic to the subnet through the connection,\nlike sshuttle, repeat for more subnets, linux only"`
+	VpnDNS         bool        `arg:"--vpn-dns" help:"also forward the dns queries through the connection for --vpn"`
+	LoginShell     bool        `arg:"--login-shell" help:"run the remote command in a login shell, e.g., bash -l -c cmd"`
+	Binary         bool        `arg:"--binary" help:"disable the line ending translation of the non-tty stdio on Windows"`
+	Pipe           bool        `arg:"--pipe" help:"stream stdin to the remote command and its stdout back as binary data,\nwithout a pty, showing the progress on stderr"`
 	NewHost        bool        `arg:"--new-host" help:"[tools] add new host to configuration"`
+	NewHosts       string      `arg:"--new-hosts" placeholder:"pattern" help:"[tools] add the hosts named by the pattern, e.g., web%d,\nfor the reachable addresses in the CIDR of the destination"`
+	HostUser       string      `arg:"--user" placeholder:"name" help:"[tools] the user of the hosts added by --new-hosts, default: -l"`
+	Status         bool        `arg:"--status" help:"[tools] probe the configured hosts concurrently, filtered by\nthe destination as a group label, an alias pattern or range"`
+	StatusCheck    string      `arg:"--status-check" placeholder:"level" help:"[tools] the check of --status: tcp (default), auth, uptime"`
+	JSON           bool        `arg:"--json" help:"[tools] print the result of --status, --bench, --list-hosts\nand batch mode in JSON to stdout, the messages are on stderr"`
+	Explain        string      `arg:"--explain" placeholder:"option" help:"[tools] print the syntax and the tssh specific behavior\nof the config option, or 'list' to list all options"`
+	ListHosts      bool        `arg:"--list-hosts" help:"[tools] print the configured hosts without network access,\nfiltered by the destination as a group label or pattern"`
+	History        bool        `arg:"--history" help:"[tools] choose a command recorded by ExRecordHistory to run\nagain, the destination and the command are the keywords"`
+	Bench          bool        `arg:"--bench" help:"[tools] measure the time of each connection phase and\nthe transfer speed to the host"`
 	EncSecret      bool        `arg:"--enc-secret" help:"[tools] encode secret for configuration"`
+	Vault          string      `arg:"--vault" placeholder:"action" help:"[tools] manage the encrypted secrets vault\naction: list, add <name>, del <name>, passwd"`
+	BenchCiphers   bool        `arg:"--benchmark-ciphers" help:"[tools] measure the throughput of each cipher to the host"`
+	Daemon         bool        `arg:"--daemon" help:"[tools] keep master connections to the DaemonHosts in ~/.tssh.conf"`
+	Tunnel         string      `arg:"--tunnel" placeholder:"action" help:"[tools] manage the named tunnels in ~/.tssh.conf\naction: list, start <name>, stop <name>, restart <name>, status [name]"`
+	InstallService string      `arg:"--install-service" placeholder:"tunnel" help:"[tools] install the named tunnel in ~/.tssh.conf as a service"`
+	UninstallSvc   string      `arg:"--uninstall-service" placeholder:"tunnel" help:"[tools] uninstall the service of the named tunnel"`
+	HostsFile      string      `arg:"--hosts-file" placeholder:"path" help:"[tools] read the hosts of batch mode from the file, one per line,\nor from stdin if the path is -, also as the destination -"`
+	Group          multiStr    `arg:"--group" placeholder:"label" help:"[tools] run the tool on the hosts with the group label"`
+	OutputDir      string      `arg:"--output-dir" placeholder:"dir" help:"[tools] save the stdout, stderr and exit status of each host\nand a summary.json to the directory in batch mode"`
+	Retry          int         `arg:"--retry" placeholder:"N" help:"[tools] retry the failed logins N times in batch mode, the started tasks are not retried"`
+	MaxFailures    int         `arg:"--max-failures" placeholder:"N" help:"[tools] stop starting more hosts after N hosts failed in batch mode"`
+	HaltOnError    bool        `arg:"--halt-on-error" help:"[tools] stop starting more hosts after the first failure in batch mode"`
+	Parallel       int         `arg:"--parallel" placeholder:"N" help:"[tools] the number of hosts to run in parallel, default: 10"`
+	RegisterUrl    bool        `arg:"--register-url-handler" help:"[tools] register tssh as the handler of ssh:// links"`
+	Upgrade        bool        `arg:"--upgrade" help:"[tools] upgrade tssh to the latest release"`
+	Channel        string      `arg:"--channel" placeholder:"name" help:"[tools] the release channel to upgrade: stable, beta"`
+	Edit           string      `arg:"--edit" placeholder:"host:path" help:"[tools] edit the remote file in the local editor"`
+	Sudo           bool        `arg:"--sudo" help:"[tools] read and write the file of --edit, or run the --script via sudo"`
+	Script         string      `arg:"--script" placeholder:"path" help:"[tools] run the local script on the destinations in parallel"`
+	ScriptArg      multiStr    `arg:"--script-arg" placeholder:"arg" help:"[tools] the argument of --script, repeat for more arguments"`
+	ClipPut        string      `arg:"--clip-put" placeholder:"path" help:"[tools] write the local clipboard to the remote file"`
+	ClipGet        string      `arg:"--clip-get" placeholder:"path" help:"[tools] copy the small remote file to the local clipboard"`
 	InstallTrzsz   bool        `arg:"--install-trzsz" help:"[tools] install trzsz to the remote server"`
 	InstallPath    string      `arg:"--install-path" placeholder:"path" help:"[tools] install path, default: '~/.local/bin/'"`
 	TrzszVersion   string      `arg:"--trzsz-version" placeholder:"x.x.x" help:"[tools] install the specified version of trzsz"`
 	TrzszBinPath   string      `arg:"--trzsz-bin-path" placeholder:"path" help:"[tools] trzsz binary installation package path"`
 	originalDest   string
+	timings        *connectTimings
+	prefetch       *loginPrefetch
 }
 
 func (sshArgs) Description() string {
@@ -157,3 +206,12 @@ func (f *forwardArgs) UnmarshalText(b []byte) error {
 	f.cfgs = append(f.cfgs, arg)
 	return nil
 }
+
+func (r *remoteArgs) UnmarshalText(b []byte) error {
+	arg, err := parseRemoteForwardArg(string(b))
+	if err != nil {
+		return err
+	}
+	r.cfgs = append(r.cfgs, arg)
+	return nil
+}
@@ -66,8 +66,9 @@ func TestSshArgs(t *testing.T) {
 	assertArgsEqual("-i id_rsa", sshArgs{Identity: multiStr{values: []string{"id_rsa"}}})
 	assertArgsEqual("-i ./id_rsa -i /tmp/id_ed25519",
 		sshArgs{Identity: multiStr{[]string{"./id_rsa", "/tmp/id_ed25519"}}})
-	assertArgsEqual("-Fcfg", sshArgs{ConfigFile: "cfg"})
-	assertArgsEqual("-F /path/to/cfg", sshArgs{ConfigFile: "/path/to/cfg"})
+	assertArgsEqual("-Fcfg", sshArgs{ConfigFile: multiStr{[]string{"cfg"}}})
+	assertArgsEqual("-F /path/to/cfg", sshArgs{ConfigFile: multiStr{[]string{"/path/to/cfg"}}})
+	assertArgsEqual("-F my.cfg -F team.cfg", sshArgs{ConfigFile: multiStr{[]string{"my.cfg", "team.cfg"}}})
 	assertArgsEqual("-Jjump", sshArgs{ProxyJump: "jump"})
 	assertArgsEqual("-J abc,def", sshArgs{ProxyJump: "abc,def"})
 	assertArgsEqual("-o RemoteCommand=none -oServerAliveInterval=5",
@@ -79,9 +80,29 @@ func TestSshArgs(t *testing.T) {
 	assertArgsEqual("--relay", sshArgs{Relay: true})
 	assertArgsEqual("--debug", sshArgs{Debug: true})
 	assertArgsEqual("--zmodem", sshArgs{Zmodem: true})
+	assertArgsEqual("--profile debug", sshArgs{Profile: multiStr{[]string{"debug"}}})
+	assertArgsEqual("--profile a --profile b", sshArgs{Profile: multiStr{[]string{"a", "b"}}})
+	assertArgsEqual("--var service=nginx --var n=1", sshArgs{Var: multiStr{[]string{"service=nginx", "n=1"}}})
+	assertArgsEqual("--tmux --tmux-session dev", sshArgs{Tmux: true, TmuxSession: "dev"})
+	assertArgsEqual("--forwards dbtools", sshArgs{Forwards: multiStr{[]string{"dbtools"}}})
 
 	assertArgsEqual("--new-host", sshArgs{NewHost: true})
 	assertArgsEqual("--enc-secret", sshArgs{EncSecret: true})
+	assertArgsEqual("--vault list", sshArgs{Vault: "list"})
+	assertArgsEqual("--vault add secret", sshArgs{Vault: "add", Destination: "secret"})
+	assertArgsEqual("--benchmark-ciphers host", sshArgs{BenchCiphers: true, Destination: "host"})
+	assertArgsEqual("--as root host", sshArgs{As: "root", Destination: "host"})
+	assertArgsEqual("--daemon", sshArgs{Daemon: true})
+	assertArgsEqual("--register-url-handler", sshArgs{RegisterUrl: true})
+	assertArgsEqual("--edit host:/etc/hosts --sudo", sshArgs{Edit: "host:/etc/hosts", Sudo: true})
+	assertArgsEqual("--clip-put ~/a.pem host", sshArgs{ClipPut: "~/a.pem", Destination: "host"})
+	assertArgsEqual("--clip-get /etc/hosts host", sshArgs{ClipGet: "/etc/hosts", Destination: "host"})
+	assertArgsEqual("--tunnel start db", sshArgs{Tunnel: "start", Destination: "db"})
+	assertArgsEqual("--install-service db", sshArgs{InstallService: "db"})
+	assertArgsEqual("--uninstall-service db", sshArgs{UninstallSvc: "db"})
+	assertArgsEqual("--upgrade --channel beta", sshArgs{Upgrade: true, Channel: "beta"})
+	assertArgsEqual("--install-trzsz --group web --group db --parallel 5",
+		sshArgs{InstallTrzsz: true, Group: multiStr{values: []string{"web", "db"}}, Parallel: 5})
 	assertArgsEqual("--install-trzsz", sshArgs{InstallTrzsz: true})
 	assertArgsEqual("--install-trzsz --install-path /bin", sshArgs{InstallTrzsz: true, InstallPath: "/bin"})
 	assertArgsEqual("--install-trzsz --trzsz-version 1.1.6", sshArgs{InstallTrzsz: true, TrzszVersion: "1.1.6"})
@@ -147,9 +168,9 @@ func TestForwardArgs(t *testing.T) {
 	assertLRForwardNil := func(argument string, bindAddr *string, bindPort int, destHost string, destPort int) {
 		t.Helper()
 		assertLRFwd("-L", argument, sshArgs{LocalForward: forwardArgs{[]*forwardCfg{
-			{argument, bindAddr, bindPort, destHost, destPort}}}})
-		assertLRFwd("-R", argument, sshArgs{RemoteForward: forwardArgs{[]*forwardCfg{
-			{argument, bindAddr, bindPort, destHost, destPort}}}})
+			{argument: argument, bindAddr: bindAddr, bindPort: bindPort, destHost: destHost, destPort: destPort}}}})
+		assertLRFwd("-R", argument, sshArgs{RemoteForward: remoteArgs{[]*forwardCfg{
+			{argument: argument, bindAddr: bindAddr, bindPort: bindPort, destHost: destHost, destPort: destPort}}}})
 	}
 	assertLRForward := func(argument string, bindAddr string, bindPort int, destHost string, destPort int) {
 		t.Helper()
@@ -163,6 +184,13 @@ func TestForwardArgs(t *testing.T) {
 	assertLRForward("/8002/127.0.0.1/9002", "", 8002, "127.0.0.1", 9002)
 	assertLRForwardNil("8003/::1/9003", nil, 8003, "::1", 9003)
 	assertLRForward("*:8004:[fe80::6358:bbae:26f8:7859]:9004", "*", 8004, "fe80::6358:bbae:26f8:7859", 9004)
+	assertLRFwd("-L", "/tmp/pg.sock:/run/postgresql/.s.PGSQL.5432", sshArgs{LocalForward: forwardArgs{[]*forwardCfg{
+		{argument: "/tmp/pg.sock:/run/postgresql/.s.PGSQL.5432", bindSocket: "/tmp/pg.sock", destSocket: "/run/postgresql/.s.PGSQL.5432"}}}})
+	assertLRFwd("-R", "1080", sshArgs{RemoteForward: remoteArgs{[]*forwardCfg{{argument: "1080", bindPort: 1080, dynamic: true}}}})
+	var args sshArgs
+	p, err := arg.NewParser(arg.Config{}, &args)
+	assert.Nil(err)
+	assert.NotNil(p.Parse([]string{"-L", "1080"}))
 }
 
 func TestSshOption(t *testing.T) {
@@ -0,0 +1,94 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var askPassCommand string
+
+// setupAskPass decides whether the secrets should be read by an external askpass program, the same as openssh:
+//
+// ExAskPassCommand is always used if it is configured.
+// SSH_ASKPASS is used if SSH_ASKPASS_REQUIRE is force or prefer,
+// or if stdin is not a terminal and there is a graphical display.
+func setupAskPass(args *sshArgs) func() {
+	previous := askPassCommand
+	reset := func() {
+		askPassCommand = previous
+	}
+
+	if command := getExOptionConfig(args, "ExAskPassCommand"); command != "" {
+		askPassCommand = command
+		return reset
+	}
+
+	askPassCommand = ""
+	command := os.Getenv("SSH_ASKPASS")
+	if command == "" {
+		return reset
+	}
+	switch strings.ToLower(os.Getenv("SSH_ASKPASS_REQUIRE")) {
+	case "never":
+	case "force", "prefer":
+		askPassCommand = command
+	default:
+		if !isTerminal && hasGraphicalDisplay() {
+			askPassCommand = command
+		}
+	}
+	return reset
+}
+
+func hasGraphicalDisplay() bool {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	default:
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+}
+
+func readAskPass(command, prompt string) ([]byte, error) {
+	argv, err := splitCommandLine(resolveHomeDir(command))
+	if err != nil || len(argv) == 0 {
+		return nil, fmt.Errorf("split askpass command [%s] failed: %v", command, err)
+	}
+	debug("read secret by askpass: %s", command)
+	cmd := exec.Command(argv[0], append(argv[1:], strings.TrimSpace(prompt))...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec askpass command [%s] failed: %v", command, err)
+	}
+	return bytes.TrimRight(output, "\r\n"), nil
+}
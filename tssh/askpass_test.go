@@ -0,0 +1,101 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetupAskPass(t *testing.T) {
+	assert := assert.New(t)
+	defer func(terminal bool) { isTerminal = terminal }(isTerminal)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	// there is always a graphical display on Windows and macOS
+	noDisplay := ""
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		noDisplay = "ssh-askpass"
+	}
+	tests := []struct {
+		name     string
+		option   string
+		askpass  string
+		require  string
+		terminal bool
+		display  string
+		expected string
+	}{
+		{name: "not set", terminal: false, display: ":0", expected: ""},
+		{name: "ExAskPassCommand", option: "my-askpass", askpass: "ssh-askpass", require: "never", terminal: true, expected: "my-askpass"},
+		{name: "never", askpass: "ssh-askpass", require: "never", terminal: false, display: ":0", expected: ""},
+		{name: "force", askpass: "ssh-askpass", require: "force", terminal: true, expected: "ssh-askpass"},
+		{name: "prefer", askpass: "ssh-askpass", require: "Prefer", terminal: true, expected: "ssh-askpass"},
+		{name: "terminal", askpass: "ssh-askpass", terminal: true, display: ":0", expected: ""},
+		{name: "no terminal", askpass: "ssh-askpass", terminal: false, display: ":0", expected: "ssh-askpass"},
+		{name: "no display", askpass: "ssh-askpass", terminal: false, expected: noDisplay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SSH_ASKPASS", tt.askpass)
+			t.Setenv("SSH_ASKPASS_REQUIRE", tt.require)
+			t.Setenv("DISPLAY", tt.display)
+			isTerminal = tt.terminal
+			args := &sshArgs{}
+			if tt.option != "" {
+				args.Option = sshOption{map[string][]string{"exaskpasscommand": {tt.option}}}
+			}
+			askPassCommand = "previous"
+			reset := setupAskPass(args)
+			assert.Equal(tt.expected, askPassCommand)
+			reset()
+			assert.Equal("previous", askPassCommand)
+		})
+	}
+	askPassCommand = ""
+}
+
+func TestReadAskPass(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+	assert := assert.New(t)
+	script := filepath.Join(t.TempDir(), "askpass.sh")
+	assert.Nil(os.WriteFile(script, []byte("#!/bin/sh\necho \"secret of $1\"\n"), 0700))
+
+	// the prompt is passed as the argument, and the trailing newline is trimmed
+	secret, err := readAskPass(script, "Password: \r\n")
+	assert.Nil(err)
+	assert.Equal("secret of Password:", string(secret))
+
+	_, err = readAskPass(script+".not_exist", "Password: ")
+	assert.NotNil(err)
+	_, err = readAskPass("", "Password: ")
+	assert.NotNil(err)
+}
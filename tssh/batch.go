@@ -0,0 +1,374 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kDefaultBatchParallel = 10
+
+// batchLoginMutex serializes the logins of the batch hosts, since the login may prompt for passwords,
+// and the log level, askpass and batch mode are set process-wide during the login.
+var batchLoginMutex sync.Mutex
+
+type batchResult struct {
+	host     string
+	message  string
+	err      error
+	duration time.Duration
+	stdout   []byte
+	stderr   []byte
+	exitCode int // -1 if the task failed without an exit status, e.g., login failed
+	skipped  bool
+	// loginFailed is set only if the login failed before the task started, which is safe to retry
+	loginFailed bool
+}
+
+// batchTask runs on the host, and may save the output and the exit code of the remote command to the result.
+type batchTask func(args *sshArgs, client *ssh.Client, result *batchResult) (string, error)
+
+// isBatchHosts returns true if more than one destination, a host range, a hosts file or any group label is specified.
+func isBatchHosts(args *sshArgs) bool {
+	return len(args.Group.values) > 0 || args.Command != "" || isHostRange(args.Destination) ||
+		args.HostsFile != "" || args.Destination == "-"
+}
+
+// readHostsList reads one host per line, only the first field of each line is used, so that the inventory
+// with extra columns works, and the empty lines and the comments starting with # are ignored.
+func readHostsList(reader io.Reader) ([]string, error) {
+	var hosts []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			hosts = append(hosts, fields[0])
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// readHostsFile reads the hosts from the file, or from stdin if the path is -.
+func readHostsFile(path string) ([]string, error) {
+	if path == "-" {
+		return readHostsList(os.Stdin)
+	}
+	file, err := os.Open(resolvePath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readHostsList(file)
+}
+
+// getBatchHosts returns the destinations in the positional arguments, the hosts in the --hosts-file,
+// and the hosts with any of the group labels. The destination - reads the hosts from stdin.
+// The braces and numeric ranges in the destinations are expanded, e.g., web{01..10} and 10.0.0.[1-5].
+func getBatchHosts(args *sshArgs) []string {
+	var hosts []string
+	exists := make(map[string]bool)
+	addHost := func(host string) {
+		if host != "" && !exists[host] {
+			exists[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	addHosts := func(dest string) {
+		expanded, err := expandHostRange(dest)
+		if err != nil {
+			warning("%v", err)
+			addHost(dest)
+			return
+		}
+		for _, host := range expanded {
+			addHost(host)
+		}
+	}
+
+	stdinRead := false
+	addHostsFile := func(path string) {
+		if path == "-" {
+			if stdinRead {
+				return
+			}
+			stdinRead = true
+		}
+		hosts, err := readHostsFile(path)
+		if err != nil {
+			toolsErrorExit("read hosts from [%s] failed: %v", path, err)
+		}
+		for _, host := range hosts {
+			addHosts(host)
+		}
+	}
+
+	for _, dest := range append([]string{args.Destination, args.Command}, args.Argument...) {
+		if dest == "-" {
+			addHostsFile(dest)
+		} else {
+			addHosts(dest)
+		}
+	}
+	if args.HostsFile != "" {
+		addHostsFile(args.HostsFile)
+	}
+
+	if len(args.Group.values) > 0 {
+		for _, host := range getAllHosts() {
+			if hasGroupLabel(host, args.Group.values) {
+				addHost(host.Alias)
+			}
+		}
+	}
+	return hosts
+}
+
+func hasGroupLabel(host *sshHost, labels []string) bool {
+	for _, hostLabel := range strings.Fields(host.GroupLabels) {
+		for _, label := range labels {
+			if strings.EqualFold(hostLabel, label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getBatchParallel(args *sshArgs) int {
+	if args.Parallel > 0 {
+		return args.Parallel
+	}
+	return kDefaultBatchParallel
+}
+
+// runInParallel runs the task with the index from 0 to count-1, at most parallel tasks at the same time.
+func runInParallel(count, parallel int, task func(i int)) {
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, parallel)
+	for i := 0; i < count; i++ {
+		i := i
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			task(i)
+		}()
+	}
+	wg.Wait()
+}
+
+func newBatchArgs(args *sshArgs, host string) *sshArgs {
+	hostArgs := *args
+	hostArgs.Destination = host
+	hostArgs.originalDest = host
+	hostArgs.Command = ""
+	hostArgs.Argument = nil
+	hostArgs.Option = copySshOption(&args.Option)
+	return &hostArgs
+}
+
+// batchLogin logins to the host of the batch, it's a variable to be replaced in tests.
+var batchLogin = func(args *sshArgs) (*ssh.Client, error) {
+	client, _, _, err := sshConnect(args, nil, "")
+	return client, err
+}
+
+// loginBatchHost logins to the host while holding the batchLoginMutex,
+// and returns the time spent on the login, excluding the time waiting for the mutex.
+func loginBatchHost(args *sshArgs) (*ssh.Client, time.Duration, error) {
+	batchLoginMutex.Lock()
+	defer batchLoginMutex.Unlock()
+	beginTime := time.Now()
+	client, err := batchLogin(args)
+	return client, time.Since(beginTime), err
+}
+
+// runBatchTask logins to the host and runs the task on it.
+func runBatchTask(args *sshArgs, host string, task batchTask) *batchResult {
+	beginTime := time.Now()
+	result := &batchResult{host: host}
+	hostArgs := newBatchArgs(args, host)
+	client, _, err := loginBatchHost(hostArgs)
+	if err != nil {
+		result.loginFailed = true
+	} else {
+		result.message, err = task(hostArgs, client, result)
+		client.Close()
+	}
+	result.err = err
+	if err != nil && result.exitCode == 0 {
+		result.exitCode = -1
+	}
+	result.duration = time.Since(beginTime)
+	return result
+}
+
+// getBatchRetryDelay returns 1s, 2s, 4s ... up to 30s for the retry attempts.
+func getBatchRetryDelay(attempt int) time.Duration {
+	delay := time.Second << attempt
+	if attempt > 5 || delay > 30*time.Second {
+		return 30 * time.Second
+	}
+	return delay
+}
+
+// batchRetryDelay returns the delay before the retry attempt, it's a variable to be replaced in tests.
+var batchRetryDelay = getBatchRetryDelay
+
+// getBatchMaxFailures returns the number of failures to stop at, 0 means never stop.
+func getBatchMaxFailures(args *sshArgs) int {
+	if args.HaltOnError {
+		return 1
+	}
+	return args.MaxFailures
+}
+
+// runBatch logins to the hosts one by one, and runs the task on them in parallel.
+// The result of each host is reported as soon as it's done.
+//
+// Only the login failures before the task starts are retried by --retry, while the failures after it started,
+// including a killed script or a dropped connection, are not, since the task may not be idempotent. No more hosts are started after --max-failures
+// hosts failed, or the first failure if --halt-on-error, the running hosts are waited, the rest are skipped.
+func runBatch(tool string, args *sshArgs, hosts []string, task batchTask) []*batchResult {
+	// the progress bars of the parallel hosts would be interleaved, one result line is printed for each host instead
+	toolsProgressDisabled.Store(true)
+	defer toolsProgressDisabled.Store(false)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	failures := 0
+	maxFailures := getBatchMaxFailures(args)
+	results := make([]*batchResult, len(hosts))
+	semaphore := make(chan struct{}, getBatchParallel(args))
+	for i, host := range hosts {
+		i, host := i, host
+		semaphore <- struct{}{}
+		mutex.Lock()
+		halted := maxFailures > 0 && failures >= maxFailures
+		mutex.Unlock()
+		if halted {
+			<-semaphore
+			results[i] = &batchResult{host: host, exitCode: -1, skipped: true,
+				err: fmt.Errorf("skipped since %d hosts failed", maxFailures)}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			var result *batchResult
+			for attempt := 0; ; attempt++ {
+				result = runBatchTask(args, host, task)
+				if !result.loginFailed || attempt >= args.Retry {
+					break
+				}
+				delay := batchRetryDelay(attempt)
+				toolsWarn(tool, "%s: attempt %d failed: %v, retry in %v", host, attempt+1, result.err, delay)
+				time.Sleep(delay)
+			}
+			results[i] = result
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if result.err != nil {
+				failures++
+			}
+			printBatchResult(tool, result)
+		}()
+	}
+	wg.Wait()
+
+	skipped := 0
+	for _, result := range results {
+		if result.skipped {
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		toolsWarn(tool, "%d hosts are skipped since %d hosts failed", skipped, maxFailures)
+	}
+
+	if args.OutputDir != "" {
+		if err := saveBatchResults(args.OutputDir, tool, results); err != nil {
+			toolsWarn(tool, "save the results to %s failed: %v", args.OutputDir, err)
+		} else {
+			toolsInfo(tool, "the results are saved to %s", args.OutputDir)
+		}
+	}
+	if args.JSON {
+		printToolsJSON(newBatchSummary(tool, results, true))
+	}
+	return results
+}
+
+func printBatchResult(tool string, result *batchResult) {
+	duration := result.duration.Round(time.Millisecond)
+	if result.err != nil {
+		toolsWarn(tool, "%s: failed in %v: %v", result.host, duration, result.err)
+	} else {
+		toolsSucc(tool, "%s: %s in %v", result.host, result.message, duration)
+	}
+}
+
+// summarizeBatchResults returns 0 if all the tasks succeeded.
+func summarizeBatchResults(tool string, results []*batchResult) int {
+	var failed []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result.host)
+		}
+	}
+	if len(failed) == 0 {
+		toolsSucc(tool, "all %d hosts succeeded", len(results))
+		return 0
+	}
+	toolsWarn(tool, "%d of %d hosts failed: %s", len(failed), len(results), strings.Join(failed, " "))
+	return 1
+}
+
+func checkBatchHosts(hosts []string) {
+	if len(hosts) == 0 {
+		toolsErrorExit("no host matches the destinations or the group labels")
+	}
+	toolsInfo("Batch", "%d hosts: %s", len(hosts), strings.Join(hosts, " "))
+}
@@ -0,0 +1,137 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type batchHostSummary struct {
+	Host       string `json:"host"`
+	Success    bool   `json:"success"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	ExitCode   int    `json:"exit_code"`
+	Message    string `json:"message,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	StdoutFile string `json:"stdout_file,omitempty"`
+	StderrFile string `json:"stderr_file,omitempty"`
+	StatusFile string `json:"status_file,omitempty"`
+}
+
+type batchSummary struct {
+	Tool      string              `json:"tool"`
+	Time      string              `json:"time"`
+	Total     int                 `json:"total"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Hosts     []*batchHostSummary `json:"hosts"`
+}
+
+// newBatchSummary summarizes the results, with the output of each host if withOutput is true.
+func newBatchSummary(tool string, results []*batchResult, withOutput bool) *batchSummary {
+	summary := &batchSummary{Tool: tool, Time: time.Now().Format(time.RFC3339), Total: len(results)}
+	for _, result := range results {
+		host := &batchHostSummary{
+			Host:       result.host,
+			Success:    result.err == nil,
+			Skipped:    result.skipped,
+			ExitCode:   result.exitCode,
+			Message:    result.message,
+			DurationMs: result.duration.Milliseconds(),
+		}
+		if withOutput {
+			host.Stdout, host.Stderr = string(result.stdout), string(result.stderr)
+		}
+		if result.err != nil {
+			host.Error = result.err.Error()
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		summary.Hosts = append(summary.Hosts, host)
+	}
+	return summary
+}
+
+// getBatchFileName returns the file name prefix of the host, which is unique in the batch.
+func getBatchFileName(host string, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-@", r) {
+			return r
+		}
+		return '_'
+	}, host)
+	if name == "" || name[0] == '.' {
+		name = "_" + name
+	}
+	unique := name
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// saveBatchResults writes the stdout, stderr and exit status of each host to separate files,
+// and a summary.json of all the hosts, for auditing the fleet operations.
+func saveBatchResults(dir, tool string, results []*batchResult) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	summary := newBatchSummary(tool, results, false)
+	used := make(map[string]bool)
+	for i, result := range results {
+		name := getBatchFileName(result.host, used)
+		host := summary.Hosts[i]
+		host.StdoutFile, host.StderrFile, host.StatusFile = name+".stdout", name+".stderr", name+".status"
+		status := fmt.Sprintf("%d\n", result.exitCode)
+		if host.Error != "" {
+			status += host.Error + "\n"
+		}
+		for file, data := range map[string][]byte{
+			host.StdoutFile: result.stdout,
+			host.StderrFile: result.stderr,
+			host.StatusFile: []byte(status),
+		} {
+			if err := os.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "summary.json"), append(data, '\n'), 0644)
+}
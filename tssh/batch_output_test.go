@@ -0,0 +1,103 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBatchFileName(t *testing.T) {
+	assert := assert.New(t)
+	used := make(map[string]bool)
+	assert.Equal("web1", getBatchFileName("web1", used))
+	assert.Equal("root@10.0.0.1_22", getBatchFileName("root@10.0.0.1:22", used))
+	assert.Equal("_.._etc", getBatchFileName("../etc", used))
+	assert.Equal("a_b", getBatchFileName("a/b", used))
+	assert.Equal("a_b-2", getBatchFileName("a:b", used))
+	assert.Equal("WEB1-2", getBatchFileName("WEB1", used))
+}
+
+func TestSaveBatchResults(t *testing.T) {
+	assert := assert.New(t)
+	dir := filepath.Join(t.TempDir(), "results")
+	results := []*batchResult{
+		{host: "web1", message: "exit status 0", duration: 1500 * time.Millisecond, stdout: []byte("ok\n")},
+		{host: "web2", err: fmt.Errorf("exit status 2"), exitCode: 2, stdout: []byte("x"), stderr: []byte("bad\n")},
+		{host: "web3", err: fmt.Errorf("dial tcp failed"), exitCode: -1},
+	}
+	assert.Nil(saveBatchResults(dir, "Script", results))
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		assert.Nil(err)
+		return string(data)
+	}
+	assert.Equal("ok\n", read("web1.stdout"))
+	assert.Equal("", read("web1.stderr"))
+	assert.Equal("0\n", read("web1.status"))
+	assert.Equal("bad\n", read("web2.stderr"))
+	assert.Equal("2\nexit status 2\n", read("web2.status"))
+	assert.Equal("-1\ndial tcp failed\n", read("web3.status"))
+
+	var summary batchSummary
+	assert.Nil(json.Unmarshal([]byte(read("summary.json")), &summary))
+	assert.Equal("Script", summary.Tool)
+	assert.Equal(3, summary.Total)
+	assert.Equal(1, summary.Succeeded)
+	assert.Equal(2, summary.Failed)
+	assert.Equal(&batchHostSummary{Host: "web1", Success: true, Message: "exit status 0", DurationMs: 1500,
+		StdoutFile: "web1.stdout", StderrFile: "web1.stderr", StatusFile: "web1.status"}, summary.Hosts[0])
+	assert.Equal(2, summary.Hosts[1].ExitCode)
+	assert.Equal("dial tcp failed", summary.Hosts[2].Error)
+}
+
+func TestNewBatchSummary(t *testing.T) {
+	assert := assert.New(t)
+	results := []*batchResult{
+		{host: "web1", stdout: []byte("ok\n"), duration: 20 * time.Millisecond},
+		{host: "web2", err: fmt.Errorf("exit status 1"), exitCode: 1, stderr: []byte("bad\n")},
+		{host: "web3", skipped: true, err: fmt.Errorf("skipped")},
+	}
+
+	summary := newBatchSummary("Script", results, true)
+	assert.Equal(1, summary.Succeeded)
+	assert.Equal(2, summary.Failed)
+	data, err := json.Marshal(summary.Hosts)
+	assert.Nil(err)
+	assert.Equal(`[{"host":"web1","success":true,"exit_code":0,"duration_ms":20,"stdout":"ok\n"},`+
+		`{"host":"web2","success":false,"exit_code":1,"error":"exit status 1","duration_ms":0,"stderr":"bad\n"},`+
+		`{"host":"web3","success":false,"skipped":true,"exit_code":0,"error":"skipped","duration_ms":0}]`, string(data))
+
+	summary = newBatchSummary("Script", results, false)
+	assert.Equal("", summary.Hosts[0].Stdout)
+	assert.Equal("", summary.Hosts[1].Stderr)
+}
@@ -0,0 +1,196 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestBatchHosts(t *testing.T) {
+	assert := assert.New(t)
+	args := &sshArgs{Destination: "host1"}
+	assert.False(isBatchHosts(args))
+	assert.Equal([]string{"host1"}, getBatchHosts(args))
+
+	args = &sshArgs{Destination: "host1", Command: "host2", Argument: []string{"host3", "host1"}}
+	assert.True(isBatchHosts(args))
+	assert.Equal([]string{"host1", "host2", "host3"}, getBatchHosts(args))
+
+	args = &sshArgs{Destination: "web{01..03}", Command: "10.0.0.[1-2]", Argument: []string{"web02"}}
+	assert.True(isBatchHosts(args))
+	assert.Equal([]string{"web01", "web02", "web03", "10.0.0.1", "10.0.0.2"}, getBatchHosts(args))
+	assert.True(isBatchHosts(&sshArgs{Destination: "{web,db}1"}))
+
+	host := &sshHost{Alias: "web1", GroupLabels: "Web prod"}
+	assert.True(hasGroupLabel(host, []string{"web"}))
+	assert.True(hasGroupLabel(host, []string{"db", "PROD"}))
+	assert.False(hasGroupLabel(host, []string{"we"}))
+
+	assert.Equal(kDefaultBatchParallel, getBatchParallel(&sshArgs{}))
+	assert.Equal(3, getBatchParallel(&sshArgs{Parallel: 3}))
+}
+
+// fakeSshConn is a ssh.Conn without a server, for the batch tasks which don't use the client.
+type fakeSshConn struct {
+	ssh.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *fakeSshConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func (c *fakeSshConn) Wait() error {
+	<-c.closed
+	return nil
+}
+
+func newFakeSshClient() *ssh.Client {
+	chans, reqs := make(chan ssh.NewChannel), make(chan *ssh.Request)
+	close(chans)
+	close(reqs)
+	return ssh.NewClient(&fakeSshConn{closed: make(chan struct{})}, chans, reqs)
+}
+
+func TestBatchFailurePolicies(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(time.Second, getBatchRetryDelay(0))
+	assert.Equal(4*time.Second, getBatchRetryDelay(2))
+	assert.Equal(30*time.Second, getBatchRetryDelay(5))
+	assert.Equal(30*time.Second, getBatchRetryDelay(100))
+
+	assert.Equal(0, getBatchMaxFailures(&sshArgs{}))
+	assert.Equal(3, getBatchMaxFailures(&sshArgs{MaxFailures: 3}))
+	assert.Equal(1, getBatchMaxFailures(&sshArgs{MaxFailures: 3, HaltOnError: true}))
+
+	// stub the login and the retry delay, so that no network or sleep is involved
+	var mutex sync.Mutex
+	var attempts map[string]int
+	var delays []time.Duration
+	defer func(login func(*sshArgs) (*ssh.Client, error), retryDelay func(int) time.Duration) {
+		batchLogin, batchRetryDelay = login, retryDelay
+	}(batchLogin, batchRetryDelay)
+	batchLogin = func(args *sshArgs) (*ssh.Client, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		attempts[args.Destination]++
+		return nil, fmt.Errorf("login to %s failed", args.Destination)
+	}
+	batchRetryDelay = func(attempt int) time.Duration {
+		mutex.Lock()
+		defer mutex.Unlock()
+		delays = append(delays, getBatchRetryDelay(attempt))
+		return 0
+	}
+	reset := func() {
+		attempts = make(map[string]int)
+		delays = nil
+	}
+	calls := 0
+	task := func(args *sshArgs, client *ssh.Client, result *batchResult) (string, error) {
+		calls++
+		return "", nil
+	}
+	hosts := []string{"host1", "host2", "host3"}
+
+	// continue: all the hosts are tried without a failure limit
+	reset()
+	results := runBatch("Test", &sshArgs{Parallel: 1}, hosts, task)
+	assert.Len(results, 3)
+	for _, result := range results {
+		assert.NotNil(result.err)
+		assert.Equal(-1, result.exitCode)
+		assert.False(result.skipped)
+	}
+	assert.Equal(map[string]int{"host1": 1, "host2": 1, "host3": 1}, attempts)
+	assert.Empty(delays)
+
+	// abort: the rest hosts are skipped after the first failure
+	reset()
+	results = runBatch("Test", &sshArgs{Parallel: 1, HaltOnError: true}, hosts, task)
+	assert.False(results[0].skipped)
+	assert.True(results[1].skipped)
+	assert.True(results[2].skipped)
+	assert.Contains(results[1].err.Error(), "skipped since 1 hosts failed")
+	assert.Equal(map[string]int{"host1": 1}, attempts)
+
+	// retry: each host is retried twice, and the third host is skipped after two failures
+	reset()
+	results = runBatch("Test", &sshArgs{Parallel: 1, MaxFailures: 2, Retry: 2}, hosts, task)
+	assert.False(results[0].skipped)
+	assert.False(results[1].skipped)
+	assert.True(results[2].skipped)
+	assert.Contains(results[2].err.Error(), "skipped since 2 hosts failed")
+	assert.Equal(map[string]int{"host1": 3, "host2": 3}, attempts)
+	assert.Equal([]time.Duration{time.Second, 2 * time.Second, time.Second, 2 * time.Second}, delays)
+	assert.Equal(0, calls)
+
+	// the task which failed after the login, e.g., killed or disconnected, is not retried
+	reset()
+	batchLogin = func(args *sshArgs) (*ssh.Client, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		attempts[args.Destination]++
+		return newFakeSshClient(), nil
+	}
+	task = func(args *sshArgs, client *ssh.Client, result *batchResult) (string, error) {
+		calls++
+		return "", fmt.Errorf("wait for the script failed: connection lost")
+	}
+	results = runBatch("Test", &sshArgs{Parallel: 1, Retry: 2}, hosts[:1], task)
+	assert.Equal(-1, results[0].exitCode)
+	assert.False(results[0].loginFailed)
+	assert.Equal(map[string]int{"host1": 1}, attempts)
+	assert.Empty(delays)
+	assert.Equal(1, calls)
+}
+
+func TestBatchHostsFile(t *testing.T) {
+	assert := assert.New(t)
+	hosts, err := readHostsList(strings.NewReader("# inventory\nweb1 ansible_host=10.0.0.1\n\n  web2  # the second\n#web3\nweb{4..5}\n"))
+	assert.Nil(err)
+	assert.Equal([]string{"web1", "web2", "web{4..5}"}, hosts)
+
+	path := filepath.Join(t.TempDir(), "hosts.txt")
+	assert.Nil(os.WriteFile(path, []byte("web1\nweb{4..5}\ndb1\n"), 0644))
+	args := &sshArgs{Destination: "db1", HostsFile: path}
+	assert.True(isBatchHosts(args))
+	assert.Equal([]string{"db1", "web1", "web4", "web5"}, getBatchHosts(args))
+
+	_, err = readHostsFile(filepath.Join(t.TempDir(), "not_exist.txt"))
+	assert.NotNil(err)
+	assert.True(isBatchHosts(&sshArgs{Destination: "-"}))
+}
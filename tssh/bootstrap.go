@@ -0,0 +1,174 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kBootstrapTimeout = 10 * time.Second
+
+type bootstrapFile struct {
+	local  string
+	remote string
+	mode   os.FileMode
+	data   []byte
+	hash   string
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// getBootstrapRemotePath returns the path relative to the remote home, e.g., ~/.config/nvim/init.lua
+// is uploaded to .config/nvim/init.lua, and the files outside the home are uploaded to the remote home.
+func getBootstrapRemotePath(localPath string) string {
+	if rel, err := filepath.Rel(userHomeDir, localPath); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(localPath)
+}
+
+// getBootstrapFiles reads the local files configured by ExBootstrapFiles, e.g.:
+//
+//	Host *
+//	    #!! ExBootstrapFiles ~/.vimrc ~/.tmux.conf
+//	    #!! ExBootstrapFiles ~/.config/nvim/init.lua
+func getBootstrapFiles(args *sshArgs) ([]*bootstrapFile, error) {
+	var files []*bootstrapFile
+	for _, value := range getAllExOptionConfig(args, "ExBootstrapFiles") {
+		paths, err := splitCommandLine(value)
+		if err != nil {
+			return nil, fmt.Errorf("split ExBootstrapFiles [%s] failed: %v", value, err)
+		}
+		for _, p := range paths {
+			localPath := resolvePath(p)
+			stat, err := os.Stat(localPath)
+			if err != nil {
+				return nil, fmt.Errorf("bootstrap file [%s] error: %v", localPath, err)
+			}
+			if !stat.Mode().IsRegular() {
+				return nil, fmt.Errorf("bootstrap file [%s] is not a regular file", localPath)
+			}
+			data, err := os.ReadFile(localPath)
+			if err != nil {
+				return nil, fmt.Errorf("read bootstrap file [%s] failed: %v", localPath, err)
+			}
+			hash := sha256.Sum256(data)
+			files = append(files, &bootstrapFile{
+				local:  localPath,
+				remote: getBootstrapRemotePath(localPath),
+				mode:   stat.Mode().Perm(),
+				data:   data,
+				hash:   hex.EncodeToString(hash[:]),
+			})
+		}
+	}
+	return files, nil
+}
+
+func runBootstrapCommand(client *ssh.Client, command string, stdin []byte) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	timer := time.AfterFunc(kBootstrapTimeout, func() { session.Close() })
+	defer timer.Stop()
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+	return session.Output(command)
+}
+
+func getRemoteFileHashes(client *ssh.Client, files []*bootstrapFile) (map[string]string, error) {
+	var names []string
+	for _, file := range files {
+		names = append(names, shellQuote(file.remote))
+	}
+	output, err := runBootstrapCommand(client, fmt.Sprintf(`cd ~ && for f in %s; do `+
+		`h=$( (sha256sum "$f" || shasum -a 256 "$f") 2>/dev/null | cut -d' ' -f1); echo "$h $f"; done`,
+		strings.Join(names, " ")), nil)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if hash, name, ok := strings.Cut(scanner.Text(), " "); ok && hash != "" {
+			hashes[name] = hash
+		}
+	}
+	return hashes, nil
+}
+
+func uploadBootstrapFile(client *ssh.Client, file *bootstrapFile) error {
+	tmpPath := shellQuote(file.remote + ".tssh.tmp")
+	command := fmt.Sprintf("cd ~ && mkdir -p %s && cat > %s && chmod %o %s && mv -f %s %s",
+		shellQuote(path.Dir(file.remote)), tmpPath, file.mode, tmpPath, tmpPath, shellQuote(file.remote))
+	if _, err := runBootstrapCommand(client, command, file.data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// bootstrapRemoteFiles uploads the ExBootstrapFiles to the remote home if they are changed.
+func bootstrapRemoteFiles(args *sshArgs, client *ssh.Client) {
+	files, err := getBootstrapFiles(args)
+	if err != nil {
+		warning("%v", err)
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+	hashes, err := getRemoteFileHashes(client, files)
+	if err != nil {
+		warning("check the bootstrap files on the server failed: %v", err)
+		return
+	}
+	for _, file := range files {
+		if hashes[file.remote] == file.hash {
+			debug("bootstrap file [%s] is up to date", file.remote)
+			continue
+		}
+		if err := uploadBootstrapFile(client, file); err != nil {
+			warning("upload bootstrap file [%s] failed: %v", file.local, err)
+			continue
+		}
+		debug("bootstrap file [%s] uploaded to ~/%s", file.local, file.remote)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootstrapFiles(t *testing.T) {
+	assert := assert.New(t)
+	defer func(home string) { userHomeDir = home }(userHomeDir)
+	userHomeDir = t.TempDir()
+
+	assert.Nil(os.WriteFile(filepath.Join(userHomeDir, ".vimrc"), []byte("set nu\n"), 0644))
+	assert.Nil(os.MkdirAll(filepath.Join(userHomeDir, ".config", "nvim"), 0755))
+	assert.Nil(os.WriteFile(filepath.Join(userHomeDir, ".config", "nvim", "init.lua"), []byte("-- nvim\n"), 0600))
+	outside := filepath.Join(t.TempDir(), "it's.conf")
+	assert.Nil(os.WriteFile(outside, []byte("x"), 0644))
+
+	args := &sshArgs{Option: sshOption{map[string][]string{
+		"exbootstrapfiles": {"~/.vimrc ~/.config/nvim/init.lua", `"` + outside + `"`},
+	}}}
+	files, err := getBootstrapFiles(args)
+	assert.Nil(err)
+	assert.Equal(3, len(files))
+	assert.Equal(".vimrc", files[0].remote)
+	assert.Equal([]byte("set nu\n"), files[0].data)
+	assert.Equal(os.FileMode(0644), files[0].mode)
+	assert.Equal(64, len(files[0].hash))
+	assert.Equal(".config/nvim/init.lua", files[1].remote)
+	assert.Equal(os.FileMode(0600), files[1].mode)
+	assert.Equal("it's.conf", files[2].remote)
+	assert.Equal(`'it'\''s.conf'`, shellQuote(files[2].remote))
+
+	args = &sshArgs{Option: sshOption{map[string][]string{"exbootstrapfiles": {"~/.not_exist"}}}}
+	_, err = getBootstrapFiles(args)
+	assert.NotNil(err)
+	args = &sshArgs{Option: sshOption{map[string][]string{"exbootstrapfiles": {"~/.config"}}}}
+	_, err = getBootstrapFiles(args)
+	assert.NotNil(err)
+}
@@ -0,0 +1,138 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+const kStdioBufferSize = 32 * 1024
+
+// the pooled buffers are twice the read size, so that the newline conversion never grows them.
+var stdioBufferPool = sync.Pool{New: func() any {
+	buffer := make([]byte, 2*kStdioBufferSize)
+	return &buffer
+}}
+
+func getStdioBuffer() *[]byte {
+	return stdioBufferPool.Get().(*[]byte)
+}
+
+func putStdioBuffer(buffer *[]byte) {
+	stdioBufferPool.Put(buffer)
+}
+
+// copyWithPooledBuffer is io.Copy without allocating a new buffer for every connection.
+func copyWithPooledBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	buffer := getStdioBuffer()
+	defer putStdioBuffer(buffer)
+	return io.CopyBuffer(dst, src, (*buffer)[:kStdioBufferSize])
+}
+
+// convertCrlfToLf appends src to dst with "\r\n" replaced by "\n".
+// A trailing '\r' is held back in case the next read begins with '\n'.
+func convertCrlfToLf(dst, src []byte, pendingCR bool) ([]byte, bool) {
+	for _, c := range src {
+		if pendingCR {
+			pendingCR = false
+			if c != '\n' {
+				dst = append(dst, '\r')
+			}
+		}
+		if c == '\r' {
+			pendingCR = true
+			continue
+		}
+		dst = append(dst, c)
+	}
+	return dst, pendingCR
+}
+
+// convertLfToCrlf appends src to dst with "\n" replaced by "\r\n".
+func convertLfToCrlf(dst, src []byte) []byte {
+	for _, c := range src {
+		if c == '\n' {
+			dst = append(dst, '\r')
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+const kLargeRelayBufferSize = 256 * 1024
+
+var largeRelayBufferPool = sync.Pool{New: func() any {
+	buffer := make([]byte, kLargeRelayBufferSize)
+	return &buffer
+}}
+
+// relayCopy copies data for the port forwarding.
+//
+// Between two local sockets it goes through io.Copy, and then the kernel splices the data on Linux.
+// Otherwise the buffer grows from 32KB to 256KB after the reads keep filling it up,
+// so that the bulk transfers take fewer syscalls, while the interactive ones still use the small buffer.
+func relayCopy(dst io.Writer, src io.Reader) (written int64, err error) {
+	if isSpliceable(dst) && isSpliceable(src) {
+		return io.Copy(dst, src)
+	}
+
+	buffer := getStdioBuffer()
+	defer putStdioBuffer(buffer)
+	buf := (*buffer)[:kStdioBufferSize]
+	fullReads := 0
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			if werr := writeAll(dst, buf[:n]); werr != nil {
+				return written, werr
+			}
+			written += int64(n)
+		}
+		if rerr != nil {
+			if rerr == io.EOF {
+				return written, nil
+			}
+			return written, rerr
+		}
+		if n < len(buf) {
+			fullReads = 0
+		} else if fullReads++; fullReads >= 4 && len(buf) < kLargeRelayBufferSize {
+			large := largeRelayBufferPool.Get().(*[]byte)
+			defer largeRelayBufferPool.Put(large)
+			buf = *large
+		}
+	}
+}
+
+func isSpliceable(v any) bool {
+	switch v.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		return true
+	default:
+		return false
+	}
+}
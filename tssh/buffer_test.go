@@ -0,0 +1,112 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertNewline(t *testing.T) {
+	assert := assert.New(t)
+
+	assertCrlfToLf := func(expected string, chunks ...string) {
+		t.Helper()
+		var result []byte
+		pendingCR := false
+		for _, chunk := range chunks {
+			result, pendingCR = convertCrlfToLf(result, []byte(chunk), pendingCR)
+		}
+		if pendingCR {
+			result = append(result, '\r')
+		}
+		assert.Equal(expected, string(result))
+	}
+	assertCrlfToLf("", "")
+	assertCrlfToLf("a\nb\n", "a\r\nb\r\n")
+	assertCrlfToLf("a\nb", "a\r", "\nb")
+	assertCrlfToLf("a\rb\r", "a\r", "b\r")
+	assertCrlfToLf("a\r\n", "a\r", "\r", "\n")
+	assertCrlfToLf("\n\n", "\r", "\n\r", "\n")
+
+	assert.Equal("", string(convertLfToCrlf(nil, nil)))
+	assert.Equal("a\r\nb\r\n\r\n", string(convertLfToCrlf(nil, []byte("a\nb\n\n"))))
+
+	buffer := getStdioBuffer()
+	defer putStdioBuffer(buffer)
+	src := make([]byte, kStdioBufferSize)
+	for i := range src {
+		src[i] = '\n'
+	}
+	dst := convertLfToCrlf((*buffer)[:0], src)
+	assert.Equal(2*kStdioBufferSize, len(dst))
+	assert.Equal(&(*buffer)[0], &dst[0])
+}
+
+func TestRelayCopy(t *testing.T) {
+	assert := assert.New(t)
+	data := make([]byte, 3*1024*1024+7)
+	_, err := rand.Read(data)
+	assert.Nil(err)
+
+	var dst bytes.Buffer
+	n, err := relayCopy(&dst, bytes.NewReader(data))
+	assert.Nil(err)
+	assert.Equal(int64(len(data)), n)
+	assert.Equal(data, dst.Bytes())
+
+	dst.Reset()
+	n, err = relayCopy(&dst, iotest.HalfReader(bytes.NewReader(data[:100000])))
+	assert.Nil(err)
+	assert.Equal(int64(100000), n)
+	assert.Equal(data[:100000], dst.Bytes())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write(data)
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.Nil(err)
+	defer conn.Close()
+	assert.True(isSpliceable(conn))
+	dst.Reset()
+	n, err = relayCopy(&dst, io.LimitReader(conn, int64(len(data))))
+	assert.Nil(err)
+	assert.Equal(int64(len(data)), n)
+	assert.Equal(data, dst.Bytes())
+}
@@ -0,0 +1,165 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/trzsz/ssh_config"
+	"golang.org/x/sys/cpu"
+)
+
+var supportedCiphers = []string{
+	"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+	"aes128-ctr", "aes192-ctr", "aes256-ctr",
+	"aes128-cbc", "3des-cbc", "arcfour256", "arcfour128", "arcfour",
+}
+
+var aesPreferredCiphers = []string{
+	"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+	"aes128-ctr", "aes192-ctr", "aes256-ctr",
+}
+
+var chachaPreferredCiphers = []string{
+	"chacha20-poly1305@openssh.com", "aes128-gcm@openssh.com", "aes256-gcm@openssh.com",
+	"aes128-ctr", "aes192-ctr", "aes256-ctr",
+}
+
+// hasAESHardwareSupport reports whether AES-GCM is accelerated by the CPU ( AES-NI, ARMv8 Crypto Extensions, etc. ).
+func hasAESHardwareSupport() bool {
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		return cpu.X86.HasAES && cpu.X86.HasPCLMULQDQ
+	case "arm64":
+		return cpu.ARM64.HasAES && cpu.ARM64.HasPMULL
+	case "s390x":
+		return cpu.S390X.HasAES && cpu.S390X.HasAESGCM
+	case "ppc64", "ppc64le":
+		return cpu.PPC64.IsPOWER8
+	default:
+		return false
+	}
+}
+
+// getDefaultCiphers prefers AES-GCM with hardware acceleration, or chacha20-poly1305 which is faster in software.
+func getDefaultCiphers() []string {
+	if hasAESHardwareSupport() {
+		return aesPreferredCiphers
+	}
+	return chachaPreferredCiphers
+}
+
+// getCiphers returns the ciphers of -o or the config, or the default ciphers if not set.
+// Don't use getOptionConfig, which returns the default value of the ssh_config library if not set.
+func getCiphers(args *sshArgs) []string {
+	defaultCiphers := getDefaultCiphers()
+	value := args.Option.get("Ciphers")
+	if value == "" && hasConfig(args.Destination, "Ciphers") {
+		value = getConfig(args.Destination, "Ciphers")
+	}
+	if value == "" {
+		debug("default ciphers for %s: %v", args.Destination, defaultCiphers)
+		return defaultCiphers
+	}
+	ciphers := parseAlgorithmList(value, defaultCiphers, supportedCiphers)
+	if len(ciphers) == 0 {
+		warning("no supported cipher in [%s], use the default ciphers", value)
+		return defaultCiphers
+	}
+	debug("ciphers for %s: %v", args.Destination, ciphers)
+	return ciphers
+}
+
+func matchAlgorithm(patterns []string, algorithm string) bool {
+	for _, p := range patterns {
+		if p == algorithm {
+			return true
+		}
+		if strings.ContainsAny(p, "*?") {
+			if pattern, err := ssh_config.NewPattern(p); err == nil && pattern.Regex().MatchString(algorithm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseAlgorithmList parses the algorithm list the same as openssh:
+// '+' appends to the default set, '-' removes from the default set,
+// '^' places at the head of the default set, otherwise replaces the default set.
+func parseAlgorithmList(value string, defaults, supported []string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return defaults
+	}
+	prefix := value[0]
+	if prefix == '+' || prefix == '-' || prefix == '^' {
+		value = value[1:]
+	}
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+
+	// keep the order as specified
+	var specified []string
+	for _, p := range patterns {
+		for _, algorithm := range supported {
+			if matchAlgorithm([]string{p}, algorithm) && !matchAlgorithm(specified, algorithm) {
+				specified = append(specified, algorithm)
+			}
+		}
+	}
+
+	var result []string
+	switch prefix {
+	case '+':
+		result = append(result, defaults...)
+		for _, algorithm := range specified {
+			if !matchAlgorithm(defaults, algorithm) {
+				result = append(result, algorithm)
+			}
+		}
+	case '-':
+		for _, algorithm := range defaults {
+			if !matchAlgorithm(patterns, algorithm) {
+				result = append(result, algorithm)
+			}
+		}
+	case '^':
+		result = append(result, specified...)
+		for _, algorithm := range defaults {
+			if !matchAlgorithm(specified, algorithm) {
+				result = append(result, algorithm)
+			}
+		}
+	default:
+		result = specified
+	}
+	return result
+}
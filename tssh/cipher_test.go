@@ -0,0 +1,69 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAlgorithmList(t *testing.T) {
+	assert := assert.New(t)
+	defaults := []string{"aes128-gcm@openssh.com", "chacha20-poly1305@openssh.com", "aes128-ctr"}
+	supported := []string{"aes128-gcm@openssh.com", "chacha20-poly1305@openssh.com", "aes128-ctr", "aes256-ctr", "3des-cbc"}
+
+	assert.Equal(defaults, parseAlgorithmList("", defaults, supported))
+	assert.Equal([]string{"aes256-ctr", "chacha20-poly1305@openssh.com"},
+		parseAlgorithmList("aes256-ctr,chacha20-poly1305@openssh.com,unknown", defaults, supported))
+	assert.Equal([]string{"aes128-ctr", "aes256-ctr"}, parseAlgorithmList("aes*-ctr", defaults, supported))
+	assert.Equal([]string{"aes128-gcm@openssh.com", "chacha20-poly1305@openssh.com", "aes128-ctr", "3des-cbc"},
+		parseAlgorithmList("+3des-cbc,aes128-ctr", defaults, supported))
+	assert.Equal([]string{"chacha20-poly1305@openssh.com"}, parseAlgorithmList("-aes*", defaults, supported))
+	assert.Equal([]string{"chacha20-poly1305@openssh.com", "aes128-gcm@openssh.com", "aes128-ctr"},
+		parseAlgorithmList("^chacha20-poly1305@openssh.com", defaults, supported))
+	assert.Empty(parseAlgorithmList("unknown", defaults, supported))
+
+	args := &sshArgs{Option: sshOption{map[string][]string{"ciphers": {"^aes256-ctr"}}}}
+	ciphers := getCiphers(args)
+	assert.Equal("aes256-ctr", ciphers[0])
+	assert.Equal(len(getDefaultCiphers()), len(ciphers))
+
+	// the default of the ssh_config library is not used if neither -o nor the config sets the ciphers
+	defer func(config *tsshConfig) { userConfig = config }(userConfig)
+	config := filepath.Join(t.TempDir(), "config")
+	assert.Nil(os.WriteFile(config, []byte("Host legacy\n    Ciphers aes128-cbc\n"), 0600))
+	userConfig = &tsshConfig{configPath: config}
+	assert.Equal(getDefaultCiphers(), getCiphers(&sshArgs{Destination: "web"}))
+	assert.NotContains(getCiphers(&sshArgs{Destination: "web"}), "aes128-cbc")
+	assert.Equal([]string{"aes128-cbc"}, getCiphers(&sshArgs{Destination: "legacy"}))
+	if hasAESHardwareSupport() {
+		assert.Equal("aes128-gcm@openssh.com", getCiphers(&sshArgs{Destination: "web"})[0])
+	} else {
+		assert.Equal("chacha20-poly1305@openssh.com", getCiphers(&sshArgs{Destination: "web"})[0])
+	}
+}
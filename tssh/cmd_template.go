@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// commandVarRegexp matches the `{{name}}` placeholders in RemoteCommand, the double braces are required,
+// so that the shell's `${name}`, the brace expansions and `awk '{print}'` are kept as they are.
+var commandVarRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+func getCommandVars(args *sshArgs) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, v := range args.Var.values {
+		name, value, ok := strings.Cut(v, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --var [%s], should be name=value", v)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// getCommandVarNames returns the placeholder names in the order they appear, without duplicates.
+func getCommandVarNames(command string) []string {
+	var names []string
+	for _, match := range commandVarRegexp.FindAllStringSubmatch(command, -1) {
+		if !containsString(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// expandCommandVars replaces the `{{name}}` placeholders in RemoteCommand with the values given by --var,
+// or prompts for the values if not given. The values are shell quoted, so that they are always one argument.
+func expandCommandVars(args *sshArgs, command string, prompt func(name string) (string, error)) (string, error) {
+	names := getCommandVarNames(command)
+	if len(names) == 0 {
+		return command, nil
+	}
+	vars, err := getCommandVars(args)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		if prompt == nil {
+			return "", fmt.Errorf("the variable {{%s}} of RemoteCommand is not given by --var", name)
+		}
+		if vars[name], err = prompt(name); err != nil {
+			return "", err
+		}
+	}
+	return commandVarRegexp.ReplaceAllStringFunc(command, func(s string) string {
+		match := commandVarRegexp.FindStringSubmatch(s)
+		return shellQuote(vars[match[1]])
+	}), nil
+}
+
+func promptCommandVar(name string) (string, error) {
+	return promptTextInput(fmt.Sprintf("RemoteCommand {{%s}}", name), "", "The value will be shell quoted", &inputValidator{
+		func(value string) error {
+			if value == "" {
+				return fmt.Errorf("empty value")
+			}
+			return nil
+		},
+	}), nil
+}
@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandCommandVars(t *testing.T) {
+	assert := assert.New(t)
+	var prompted []string
+	prompt := func(name string) (string, error) {
+		prompted = append(prompted, name)
+		return "it's " + name, nil
+	}
+
+	assert.Equal([]string{"service", "lines"}, getCommandVarNames("journalctl -u {{service}} -n {{ lines }} | grep {{service}}"))
+	assert.Empty(getCommandVarNames(`echo ${HOME} {a,b} {1..3} { echo; } {name} awk '{print}' awk '{print $1}' find . -exec ls {} \;`))
+	assert.Equal([]string{"a", "b"}, getCommandVarNames("{{a}}{{b}}"))
+
+	cmd, err := expandCommandVars(&sshArgs{}, "uptime", nil)
+	assert.Nil(err)
+	assert.Equal("uptime", cmd)
+
+	args := &sshArgs{Var: multiStr{[]string{"service=nginx", "empty="}}}
+	cmd, err = expandCommandVars(args, "systemctl restart {{service}} && echo {{empty}}${HOME} {service}", prompt)
+	assert.Nil(err)
+	assert.Equal("systemctl restart 'nginx' && echo ''${HOME} {service}", cmd)
+	assert.Empty(prompted)
+
+	cmd, err = expandCommandVars(args, "{{lines}}:{{service}}{{lines}}", prompt)
+	assert.Nil(err)
+	assert.Equal(`'it'\''s lines':'nginx''it'\''s lines'`, cmd)
+	assert.Equal([]string{"lines"}, prompted)
+
+	cmd, err = expandCommandVars(&sshArgs{}, `awk '{print}' file && find . -name '*.log' -exec rm {} \;`, nil)
+	assert.Nil(err)
+	assert.Equal(`awk '{print}' file && find . -name '*.log' -exec rm {} \;`, cmd)
+
+	_, err = expandCommandVars(args, "tail -n {{lines}} file", nil)
+	assert.NotNil(err)
+	_, err = expandCommandVars(&sshArgs{Var: multiStr{[]string{"novalue"}}}, "echo {{a}}", prompt)
+	assert.NotNil(err)
+	_, err = expandCommandVars(&sshArgs{}, "echo {{a}}", func(string) (string, error) { return "", fmt.Errorf("canceled") })
+	assert.NotNil(err)
+}
@@ -0,0 +1,366 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const kColorSeqMaxLen = 64
+
+// the default xterm colors of the 16 basic colors.
+var kBasicColors = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var kCubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+func colorDistance(r1, g1, b1, r2, g2, b2 int) int {
+	return (r1-r2)*(r1-r2) + (g1-g2)*(g1-g2) + (b1-b2)*(b1-b2)
+}
+
+func nearestCubeLevel(v int) int {
+	best := 0
+	for i, level := range kCubeLevels {
+		if abs(v-level) < abs(v-kCubeLevels[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// rgbTo256 returns the nearest color in the 6x6x6 cube or the grayscale ramp of the 256 colors.
+func rgbTo256(r, g, b int) int {
+	ri, gi, bi := nearestCubeLevel(r), nearestCubeLevel(g), nearestCubeLevel(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDist := colorDistance(r, g, b, kCubeLevels[ri], kCubeLevels[gi], kCubeLevels[bi])
+
+	gray := (r + g + b) / 3
+	grayIdx := (gray - 3) / 10
+	if grayIdx < 0 {
+		grayIdx = 0
+	} else if grayIdx > 23 {
+		grayIdx = 23
+	}
+	level := 8 + 10*grayIdx
+	if colorDistance(r, g, b, level, level, level) < cubeDist {
+		return 232 + grayIdx
+	}
+	return cube
+}
+
+func color256ToRGB(n int) (int, int, int) {
+	switch {
+	case n < 16:
+		return kBasicColors[n][0], kBasicColors[n][1], kBasicColors[n][2]
+	case n < 232:
+		n -= 16
+		return kCubeLevels[n/36], kCubeLevels[n/6%6], kCubeLevels[n%6]
+	default:
+		level := 8 + 10*(n-232)
+		return level, level, level
+	}
+}
+
+// rgbTo16 returns the index of the nearest basic color.
+func rgbTo16(r, g, b int) int {
+	best, bestDist := 0, -1
+	for i, c := range kBasicColors {
+		if dist := colorDistance(r, g, b, c[0], c[1], c[2]); bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// basicColorParam returns the SGR parameter of the basic color, e.g., 31 for red foreground, 101 for bright red background.
+func basicColorParam(base, idx int) string {
+	if idx >= 8 {
+		return strconv.Itoa(base + 60 + idx - 8)
+	}
+	return strconv.Itoa(base + idx)
+}
+
+// downgradeColor converts the extended color to the target depth, returns nil to drop it.
+func downgradeColor(kind int, rgb []int, idx int, depth int) []string {
+	if depth == 256 {
+		if rgb == nil {
+			return []string{strconv.Itoa(kind), "5", strconv.Itoa(idx)}
+		}
+		return []string{strconv.Itoa(kind), "5", strconv.Itoa(rgbTo256(rgb[0], rgb[1], rgb[2]))}
+	}
+	if kind == 58 { // underline color is not supported by the 16 colors terminals
+		return nil
+	}
+	if rgb == nil {
+		r, g, b := color256ToRGB(idx)
+		rgb = []int{r, g, b}
+	}
+	base := 30
+	if kind == 48 {
+		base = 40
+	}
+	return []string{basicColorParam(base, rgbTo16(rgb[0], rgb[1], rgb[2]))}
+}
+
+func parseColorInts(values []string) ([]int, bool) {
+	ints := make([]int, len(values))
+	for i, v := range values {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 255 {
+			return nil, false
+		}
+		ints[i] = n
+	}
+	return ints, true
+}
+
+// downgradeSGR converts the truecolor ( and 256 colors for depth 16 ) parameters of SGR,
+// both `38;2;r;g;b` and `38:2::r:g:b` forms are supported.
+func downgradeSGR(params string, depth int) (string, bool) {
+	tokens := strings.Split(params, ";")
+	var result []string
+	changed := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		kind, err := strconv.Atoi(strings.SplitN(token, ":", 2)[0])
+		if err != nil || (kind != 38 && kind != 48 && kind != 58) {
+			result = append(result, token)
+			continue
+		}
+		var sub []string
+		consumed := 0
+		if strings.Contains(token, ":") {
+			sub = strings.Split(token, ":")[1:]
+			if len(sub) >= 4 && sub[0] == "2" {
+				sub = append([]string{"2"}, sub[len(sub)-3:]...)
+			}
+		} else if i+1 < len(tokens) {
+			switch tokens[i+1] {
+			case "2":
+				if i+4 < len(tokens) {
+					sub, consumed = tokens[i+1:i+5], 4
+				}
+			case "5":
+				if i+2 < len(tokens) {
+					sub, consumed = tokens[i+1:i+3], 2
+				}
+			}
+		}
+		var converted []string
+		ok := false
+		if len(sub) == 4 && sub[0] == "2" {
+			if rgb, valid := parseColorInts(sub[1:]); valid {
+				converted, ok = downgradeColor(kind, rgb, 0, depth), true
+			}
+		} else if len(sub) == 2 && sub[0] == "5" && depth == 16 {
+			if idx, valid := parseColorInts(sub[1:]); valid {
+				converted, ok = downgradeColor(kind, nil, idx[0], depth), true
+			}
+		}
+		if !ok {
+			result = append(result, token)
+			continue
+		}
+		result = append(result, converted...)
+		i += consumed
+		changed = true
+	}
+	if !changed {
+		return params, false
+	}
+	if len(result) == 0 { // all dropped, keep it a no-op rather than a reset
+		return "", true
+	}
+	return strings.Join(result, ";"), true
+}
+
+// colorWriter translates the colors of the output to the depth the local terminal can render.
+// It writes to stdout after the trzsz filter, so that the transfer frames are never rewritten.
+type colorWriter struct {
+	writer io.WriteCloser
+	depth  int
+	carry  []byte
+	bypass bool
+}
+
+func (c *colorWriter) Write(p []byte) (int, error) {
+	if c.bypass {
+		return c.writer.Write(p)
+	}
+	if err := writeAll(c.writer, c.convert(nil, p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *colorWriter) Close() error {
+	if len(c.carry) > 0 {
+		_ = writeAll(c.writer, c.carry)
+		c.carry = nil
+	}
+	return c.writer.Close()
+}
+
+func (c *colorWriter) convert(dst, src []byte) []byte {
+	data := src
+	if len(c.carry) > 0 {
+		data = append(c.carry, src...)
+		c.carry = nil
+	}
+	for i := 0; i < len(data); {
+		if data[i] != '\x1b' {
+			dst = append(dst, data[i])
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			c.carry = append([]byte(nil), data[i:]...)
+			break
+		}
+		if data[i+1] != '[' {
+			dst = append(dst, data[i])
+			i++
+			continue
+		}
+		j := i + 2
+		for j < len(data) && data[j] >= 0x20 && data[j] <= 0x3f {
+			j++
+		}
+		if j >= len(data) {
+			if j-i < kColorSeqMaxLen {
+				c.carry = append([]byte(nil), data[i:]...)
+				break
+			}
+			dst = append(dst, data[i:]...)
+			break
+		}
+		if data[j] == 'm' {
+			if params, changed := downgradeSGR(string(data[i+2:j]), c.depth); changed {
+				if params != "" {
+					dst = append(dst, fmt.Sprintf("\x1b[%sm", params)...)
+				}
+				i = j + 1
+				continue
+			}
+		}
+		dst = append(dst, data[i:j+1]...)
+		i = j + 1
+	}
+	return dst
+}
+
+// getColorDepth returns the color depth configured by ExColorDowngrade, 0 means no downgrade.
+func getColorDepth(args *sshArgs) (int, error) {
+	switch value := strings.ToLower(getExOptionConfig(args, "ExColorDowngrade")); value {
+	case "", "no", "none":
+		return 0, nil
+	case "256":
+		return 256, nil
+	case "16":
+		return 16, nil
+	default:
+		return 0, fmt.Errorf("unknown ExColorDowngrade [%s], should be one of 256, 16, no", value)
+	}
+}
+
+// setupColorOutput sets the color depth of the output, which is translated by the writer of getStdout.
+func setupColorOutput(args *sshArgs, ss *sshSession) {
+	if !ss.tty {
+		return
+	}
+	depth, err := getColorDepth(args)
+	if err != nil {
+		warning("%v", err)
+		return
+	}
+	ss.colorDepth = depth
+}
+
+// the dark tints for the named ExBackgroundColor, so that the text is still readable.
+var kBackgroundTints = map[string]string{
+	"red":     "#3c0000",
+	"green":   "#002c00",
+	"yellow":  "#333000",
+	"blue":    "#00003c",
+	"magenta": "#300030",
+	"cyan":    "#002c2c",
+	"gray":    "#262626",
+}
+
+var backgroundColorRegexp = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|rgb:[0-9a-fA-F]{2}/[0-9a-fA-F]{2}/[0-9a-fA-F]{2})$`)
+
+// getBackgroundColor returns the ExBackgroundColor, a tint name or an `#rrggbb` color, empty means not set.
+func getBackgroundColor(args *sshArgs) (string, error) {
+	value := getExOptionConfig(args, "ExBackgroundColor")
+	switch strings.ToLower(value) {
+	case "", "no", "none":
+		return "", nil
+	}
+	if tint, ok := kBackgroundTints[strings.ToLower(value)]; ok {
+		return tint, nil
+	}
+	if backgroundColorRegexp.MatchString(value) {
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid ExBackgroundColor [%s], should be #rrggbb or one of red, green, yellow, blue, magenta, cyan, gray", value)
+}
+
+// setBackgroundColor tints the terminal background of the session via OSC 11, e.g.:
+//
+//	Host prod*
+//	    ExBackgroundColor red
+//
+// The default background is restored via OSC 111 on exit.
+func setBackgroundColor(args *sshArgs, ss *sshSession) {
+	if !isTerminal || !ss.tty {
+		return
+	}
+	color, err := getBackgroundColor(args)
+	if err != nil {
+		warning("%v", err)
+		return
+	}
+	if color == "" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\033]11;%s\007", color)
+	onExit(func() {
+		fmt.Fprintf(os.Stderr, "\033]111\007")
+	})
+}
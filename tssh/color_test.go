@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColorConvert(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(196, rgbTo256(255, 0, 0))
+	assert.Equal(16, rgbTo256(0, 0, 0))
+	assert.Equal(231, rgbTo256(255, 255, 255))
+	assert.Equal(244, rgbTo256(128, 128, 128))
+	assert.Equal(9, rgbTo16(255, 10, 10))
+	assert.Equal(0, rgbTo16(10, 10, 10))
+	r, g, b := color256ToRGB(196)
+	assert.Equal([]int{255, 0, 0}, []int{r, g, b})
+
+	assertSGR := func(params string, depth int, expected string, changed bool) {
+		t.Helper()
+		result, ok := downgradeSGR(params, depth)
+		assert.Equal(expected, result)
+		assert.Equal(changed, ok)
+	}
+	assertSGR("0;1;31", 256, "0;1;31", false)
+	assertSGR("38;5;196", 256, "38;5;196", false)
+	assertSGR("38;2;255;0;0", 256, "38;5;196", true)
+	assertSGR("1;48;2;0;0;0;4", 256, "1;48;5;16;4", true)
+	assertSGR("38:2::255:0:0", 256, "38;5;196", true)
+	assertSGR("38:2:255:0:0", 256, "38;5;196", true)
+	assertSGR("38;2;255;0", 256, "38;2;255;0", false)
+	assertSGR("38;2;255;0;0", 16, "91", true)
+	assertSGR("48;5;196;1", 16, "101;1", true)
+	assertSGR("58;2;255;0;0", 16, "", true)
+}
+
+func TestColorOutput(t *testing.T) {
+	assert := assert.New(t)
+	chunks := []string{"a\x1b[38;2;255;", "0;0mred\x1b", "[0m \x1b]0;title\x07\x1b[2J\x1b"}
+	var buf bytes.Buffer
+	output := &colorWriter{writer: nopWriteCloser{&buf}, depth: 256}
+	for _, chunk := range chunks {
+		n, err := output.Write([]byte(chunk))
+		assert.Nil(err)
+		assert.Equal(len(chunk), n)
+	}
+	assert.Nil(output.Close())
+	assert.Equal("a\x1b[38;5;196mred\x1b[0m \x1b]0;title\x07\x1b[2J\x1b", buf.String())
+
+	// the data is written as it is if bypassed, e.g., for the relay of trzsz
+	buf.Reset()
+	output = &colorWriter{writer: nopWriteCloser{&buf}, depth: 16, bypass: true}
+	_, err := output.Write([]byte("\x1b[38;2;255;0;0m"))
+	assert.Nil(err)
+	assert.Equal("\x1b[38;2;255;0;0m", buf.String())
+
+	args := &sshArgs{}
+	depth, err := getColorDepth(args)
+	assert.Nil(err)
+	assert.Equal(0, depth)
+	assert.Nil(args.Option.UnmarshalText([]byte("ExColorDowngrade 16")))
+	depth, err = getColorDepth(args)
+	assert.Nil(err)
+	assert.Equal(16, depth)
+	args = &sshArgs{}
+	assert.Nil(args.Option.UnmarshalText([]byte("ExColorDowngrade 8")))
+	_, err = getColorDepth(args)
+	assert.NotNil(err)
+}
+
+func TestBackgroundColor(t *testing.T) {
+	assert := assert.New(t)
+	getColor := func(option string) (string, error) {
+		args := &sshArgs{}
+		if option != "" {
+			assert.Nil(args.Option.UnmarshalText([]byte("ExBackgroundColor " + option)))
+		}
+		return getBackgroundColor(args)
+	}
+	for option, expected := range map[string]string{
+		"":             "",
+		"none":         "",
+		"Red":          "#3c0000",
+		"#1a0000":      "#1a0000",
+		"#200":         "#200",
+		"rgb:20/00/00": "rgb:20/00/00",
+	} {
+		color, err := getColor(option)
+		assert.Nil(err)
+		assert.Equal(expected, color)
+	}
+	for _, option := range []string{"pink", "#12345", "#1a0000\007"} {
+		_, err := getColor(option)
+		assert.NotNil(err)
+	}
+}
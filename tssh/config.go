@@ -53,6 +53,41 @@ func resolveHomeDir(path string) string {
 	return path
 }
 
+// resolvePath accepts the quoted paths with spaces, and the Windows paths in any form, e.g.,
+// C:\Users\xxx, C:/Users/xxx, /c/Users/xxx, /cygdrive/c/Users/xxx, \\server\share, //server/share
+func resolvePath(path string) string {
+	path = unquotePath(path)
+	if path == "~" {
+		return userHomeDir
+	}
+	if runtime.GOOS == "windows" {
+		path = convertWindowsPath(path)
+	}
+	return resolveHomeDir(path)
+}
+
+func unquotePath(path string) string {
+	if len(path) >= 2 && (path[0] == '"' || path[0] == '\'') && path[len(path)-1] == path[0] {
+		return path[1 : len(path)-1]
+	}
+	return path
+}
+
+func convertWindowsPath(path string) string {
+	drivePath := strings.TrimPrefix(path, "/cygdrive")
+	if len(drivePath) >= 2 && drivePath[0] == '/' && isDriveLetter(drivePath[1]) && (len(drivePath) == 2 || drivePath[2] == '/') {
+		path = strings.ToUpper(drivePath[1:2]) + ":" + drivePath[2:]
+		if len(path) == 2 {
+			path += "/"
+		}
+	}
+	return strings.ReplaceAll(path, "/", "\\")
+}
+
+func isDriveLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
 type sshHost struct {
 	Alias         string
 	Host          string
@@ -63,34 +98,50 @@ type sshHost struct {
 	ProxyJump     string
 	RemoteCommand string
 	GroupLabels   string
+	Shortcut      string
 	Selected      bool
 }
 
 type tsshConfig struct {
-	language            string
-	configPath          string
-	sysConfigPath       string
-	exConfigPath        string
-	defaultUploadPath   string
-	defaultDownloadPath string
-	promptThemeLayout   string
-	promptThemeColors   map[string]string
-	promptPageSize      uint8
-	promptDefaultMode   string
-	promptDetailItems   string
-	promptCursorIcon    string
-	promptSelectedIcon  string
-	setTerminalTitle    string
-	loadConfig          sync.Once
-	loadExConfig        sync.Once
-	loadHosts           sync.Once
-	config              *ssh_config.Config
-	sysConfig           *ssh_config.Config
-	exConfig            *ssh_config.Config
-	loadDefaultColors   sync.Once
-	defaultThemeColors  map[string]string
-	allHosts            []*sshHost
-	wildcardPatterns    []*ssh_config.Pattern
+	language                 string
+	configPath               string
+	extraConfigPaths         []string
+	sysConfigPaths           []string
+	winConfigPath            string
+	exConfigPath             string
+	defaultUploadPath        string
+	defaultDownloadPath      string
+	promptThemeLayout        string
+	promptThemeColors        map[string]string
+	promptPageSize           uint8
+	promptDefaultMode        string
+	promptDetailItems        string
+	promptCursorIcon         string
+	promptSelectedIcon       string
+	setTerminalTitle         string
+	vaultPath                string
+	daemonHosts              string
+	wslWindowsAgent          string
+	wslWindowsConfig         string
+	discoverLanHosts         string
+	ignoreUnsupportedOptions string
+	profiles                 map[string][]string
+	forwardPresets           map[string][]string
+	tunnels                  map[string]string
+	preferredKeys            map[string]string
+	hostTemplates            map[string][]string
+	loadConfig               sync.Once
+	loadExConfig             sync.Once
+	loadHosts                sync.Once
+	configIndex              *configIndex
+	extraConfigIndexes       []*configIndex
+	sysConfigIndexes         []*configIndex
+	winConfigIndex           *configIndex
+	exConfigIndex            *configIndex
+	loadDefaultColors        sync.Once
+	defaultThemeColors       map[string]string
+	allHosts                 []*sshHost
+	wildcardPatterns         []*ssh_config.Pattern
 }
 
 var userConfig = &tsshConfig{}
@@ -130,13 +181,13 @@ func parseTsshConfig() {
 		case name == "language" && userConfig.language == "":
 			userConfig.language = value
 		case name == "configpath" && userConfig.configPath == "":
-			userConfig.configPath = resolveHomeDir(value)
+			userConfig.configPath = resolvePath(value)
 		case name == "exconfigpath" && userConfig.exConfigPath == "":
-			userConfig.exConfigPath = resolveHomeDir(value)
+			userConfig.exConfigPath = resolvePath(value)
 		case name == "defaultuploadpath" && userConfig.defaultUploadPath == "":
-			userConfig.defaultUploadPath = resolveHomeDir(value)
+			userConfig.defaultUploadPath = resolvePath(value)
 		case name == "defaultdownloadpath" && userConfig.defaultDownloadPath == "":
-			userConfig.defaultDownloadPath = resolveHomeDir(value)
+			userConfig.defaultDownloadPath = resolvePath(value)
 		case name == "promptthemelayout" && userConfig.promptThemeLayout == "":
 			userConfig.promptThemeLayout = value
 		case name == "promptthemecolors" && len(userConfig.promptThemeColors) == 0:
@@ -160,6 +211,49 @@ func parseTsshConfig() {
 			userConfig.promptSelectedIcon = value
 		case name == "setterminaltitle" && userConfig.setTerminalTitle == "":
 			userConfig.setTerminalTitle = value
+		case name == "vaultpath" && userConfig.vaultPath == "":
+			userConfig.vaultPath = resolvePath(value)
+		case name == "daemonhosts" && userConfig.daemonHosts == "":
+			userConfig.daemonHosts = value
+		case name == "wslwindowsagent" && userConfig.wslWindowsAgent == "":
+			userConfig.wslWindowsAgent = value
+		case name == "wslwindowsconfig" && userConfig.wslWindowsConfig == "":
+			userConfig.wslWindowsConfig = value
+		case name == "discoverlanhosts" && userConfig.discoverLanHosts == "":
+			userConfig.discoverLanHosts = value
+		case name == "ignoreunsupportedoptions" && userConfig.ignoreUnsupportedOptions == "":
+			userConfig.ignoreUnsupportedOptions = value
+		case strings.HasPrefix(name, "profile.") && len(name) > len("profile."):
+			if userConfig.profiles == nil {
+				userConfig.profiles = make(map[string][]string)
+			}
+			profile := name[len("profile."):]
+			userConfig.profiles[profile] = append(userConfig.profiles[profile], value)
+		case strings.HasPrefix(name, "forwards.") && len(name) > len("forwards."):
+			if userConfig.forwardPresets == nil {
+				userConfig.forwardPresets = make(map[string][]string)
+			}
+			preset := name[len("forwards."):]
+			userConfig.forwardPresets[preset] = append(userConfig.forwardPresets[preset], value)
+		case strings.HasPrefix(name, "tunnel.") && len(name) > len("tunnel."):
+			if userConfig.tunnels == nil {
+				userConfig.tunnels = make(map[string]string)
+			}
+			if tunnel := name[len("tunnel."):]; userConfig.tunnels[tunnel] == "" {
+				userConfig.tunnels[tunnel] = value
+			}
+		case strings.HasPrefix(name, "preferredkey.") && len(name) > len("preferredkey."):
+			if userConfig.preferredKeys == nil {
+				userConfig.preferredKeys = make(map[string]string)
+			}
+			// the last one wins, since the picked keys are appended
+			userConfig.preferredKeys[name[len("preferredkey."):]] = value
+		case strings.HasPrefix(name, "hosttemplate.") && len(name) > len("hosttemplate."):
+			if userConfig.hostTemplates == nil {
+				userConfig.hostTemplates = make(map[string][]string)
+			}
+			template := name[len("hosttemplate."):]
+			userConfig.hostTemplates[template] = append(userConfig.hostTemplates[template], value)
 		}
 	}
 
@@ -182,6 +276,9 @@ func showTsshConfig() {
 	if userConfig.configPath != "" {
 		debug("ConfigPath = %s", userConfig.configPath)
 	}
+	for _, path := range userConfig.extraConfigPaths {
+		debug("ExtraConfigPath = %s", path)
+	}
 	if userConfig.exConfigPath != "" {
 		debug("ExConfigPath = %s", userConfig.exConfigPath)
 	}
@@ -215,9 +312,48 @@ func showTsshConfig() {
 	if userConfig.setTerminalTitle != "" {
 		debug("SetTerminalTitle = %s", userConfig.setTerminalTitle)
 	}
+	if userConfig.vaultPath != "" {
+		debug("VaultPath = %s", userConfig.vaultPath)
+	}
+	if userConfig.daemonHosts != "" {
+		debug("DaemonHosts = %s", userConfig.daemonHosts)
+	}
+	if userConfig.wslWindowsAgent != "" {
+		debug("WslWindowsAgent = %s", userConfig.wslWindowsAgent)
+	}
+	if userConfig.wslWindowsConfig != "" {
+		debug("WslWindowsConfig = %s", userConfig.wslWindowsConfig)
+	}
+	if userConfig.discoverLanHosts != "" {
+		debug("DiscoverLanHosts = %s", userConfig.discoverLanHosts)
+	}
+	if userConfig.ignoreUnsupportedOptions != "" {
+		debug("IgnoreUnsupportedOptions = %s", userConfig.ignoreUnsupportedOptions)
+	}
+	for name, options := range userConfig.profiles {
+		for _, option := range options {
+			debug("Profile.%s = %s", name, option)
+		}
+	}
+	for name, forwards := range userConfig.forwardPresets {
+		for _, forward := range forwards {
+			debug("Forwards.%s = %s", name, forward)
+		}
+	}
+	for name, tunnel := range userConfig.tunnels {
+		debug("Tunnel.%s = %s", name, tunnel)
+	}
+	for alias, key := range userConfig.preferredKeys {
+		debug("PreferredKey.%s = %s", alias, key)
+	}
+	for name, options := range userConfig.hostTemplates {
+		for _, option := range options {
+			debug("HostTemplate.%s = %s", name, option)
+		}
+	}
 }
 
-func initUserConfig(configFile string) error {
+func initUserConfig(configFiles []string) error {
 	var err error
 	userHomeDir, err = os.UserHomeDir()
 	if err != nil {
@@ -230,17 +366,21 @@ func initUserConfig(configFile string) error {
 		warning("Failed to obtain the home directory. Using the current directory as the home directory.")
 	}
 
-	if configFile != "" {
-		userConfig.configPath = resolveHomeDir(configFile)
+	// the first -F replaces ~/.ssh/config, and the others are layered under it
+	for i, configFile := range configFiles {
+		if i == 0 {
+			userConfig.configPath = resolvePath(configFile)
+		} else if strings.ToLower(configFile) != "none" {
+			userConfig.extraConfigPaths = append(userConfig.extraConfigPaths, resolvePath(configFile))
+		}
 	}
 
 	parseTsshConfig()
 
 	if userConfig.configPath == "" {
 		userConfig.configPath = filepath.Join(userHomeDir, ".ssh", "config")
-		if runtime.GOOS != "windows" {
-			userConfig.sysConfigPath = "/etc/ssh/ssh_config"
-		}
+		userConfig.sysConfigPaths = getSystemConfigPaths()
+		userConfig.winConfigPath = getWslWindowsConfigPath()
 	} else if strings.ToLower(userConfig.configPath) == "none" {
 		userConfig.configPath = ""
 	}
@@ -252,6 +392,19 @@ func initUserConfig(configFile string) error {
 	return nil
 }
 
+// getSystemConfigPaths returns the system-wide configs, which are read after the user config as openssh does.
+// The tssh-specific one precedes the openssh one, so admins can set the defaults only tssh understands.
+func getSystemConfigPaths() []string {
+	if runtime.GOOS == "windows" {
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			return nil
+		}
+		return []string{filepath.Join(programData, "tssh", "config"), filepath.Join(programData, "ssh", "ssh_config")}
+	}
+	return []string{"/etc/tssh/config", "/etc/ssh/ssh_config"}
+}
+
 func loadConfig(path string, system bool) *ssh_config.Config {
 	file, err := os.Open(path)
 	if err != nil {
@@ -279,22 +432,83 @@ func (c *tsshConfig) doLoadConfig() {
 	c.loadConfig.Do(func() {
 		ssh_config.SetDefault("IdentityFile", "")
 
-		if c.configPath == "" {
+		if c.configPath == "" && len(c.extraConfigPaths) == 0 {
 			debug("no ssh configuration file path")
 			return
 		}
-		c.config = loadConfig(c.configPath, false)
+		if c.configPath != "" {
+			c.configIndex = loadConfigIndex(c.configPath, false)
+			if c.configIndex != nil {
+				c.addExtraConfigPaths(c.configIndex.getAll("*", "ExExtraConfig"))
+			}
+		}
 
-		if c.sysConfigPath != "" {
-			if !isFileExist(c.sysConfigPath) {
-				debug("system config [%s] does not exist", c.sysConfigPath)
-				return
+		for _, path := range c.extraConfigPaths {
+			if index := loadConfigIndex(path, false); index != nil {
+				c.extraConfigIndexes = append(c.extraConfigIndexes, index)
+			}
+		}
+
+		if c.winConfigPath != "" {
+			if !isFileExist(c.winConfigPath) {
+				debug("windows config [%s] does not exist", c.winConfigPath)
+			} else {
+				c.winConfigIndex = loadConfigIndex(c.winConfigPath, false)
+			}
+		}
+
+		for _, path := range c.sysConfigPaths {
+			if !isFileExist(path) {
+				debug("system config [%s] does not exist", path)
+				continue
+			}
+			if index := loadConfigIndex(path, true); index != nil {
+				c.sysConfigIndexes = append(c.sysConfigIndexes, index)
 			}
-			c.sysConfig = loadConfig(c.sysConfigPath, true)
 		}
 	})
 }
 
+// addExtraConfigPaths appends the ExExtraConfig files of the main config after the extra -F files.
+func (c *tsshConfig) addExtraConfigPaths(values []string) {
+	for _, value := range values {
+		for _, path := range strings.Fields(value) {
+			path = resolvePath(path)
+			if path == c.configPath || containsString(c.extraConfigPaths, path) {
+				continue
+			}
+			c.extraConfigPaths = append(c.extraConfigPaths, path)
+		}
+	}
+}
+
+// getConfigIndexes returns the indexes in the lookup order, the first match wins:
+// the main config, the extra configs in order, the WSL Windows config, and the system configs.
+func (c *tsshConfig) getConfigIndexes() []*configIndex {
+	var indexes []*configIndex
+	if c.configIndex != nil {
+		indexes = append(indexes, c.configIndex)
+	}
+	indexes = append(indexes, c.extraConfigIndexes...)
+	if c.winConfigIndex != nil {
+		indexes = append(indexes, c.winConfigIndex)
+	}
+	indexes = append(indexes, c.sysConfigIndexes...)
+	return indexes
+}
+
+// getConfigs returns the parsed configs in the same order as getConfigIndexes,
+// the configs loaded from the cache are parsed now.
+func (c *tsshConfig) getConfigs() []*ssh_config.Config {
+	var configs []*ssh_config.Config
+	for _, index := range c.getConfigIndexes() {
+		if config := index.getConfig(); config != nil {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
+
 func (c *tsshConfig) doLoadExConfig() {
 	c.loadExConfig.Do(func() {
 		if c.exConfigPath == "" {
@@ -305,39 +519,40 @@ func (c *tsshConfig) doLoadExConfig() {
 			debug("extended config [%s] does not exist", c.exConfigPath)
 			return
 		}
-		c.exConfig = loadConfig(c.exConfigPath, false)
+		c.exConfigIndex = loadConfigIndex(c.exConfigPath, false)
 	})
 }
 
 func getConfig(alias, key string) string {
 	userConfig.doLoadConfig()
 
-	if userConfig.config != nil {
-		if value, _ := userConfig.config.Get(alias, key); value != "" {
+	for _, index := range userConfig.getConfigIndexes() {
+		if value := index.get(alias, key); value != "" {
 			return value
 		}
 	}
 
-	if userConfig.sysConfig != nil {
-		if value, _ := userConfig.sysConfig.Get(alias, key); value != "" {
-			return value
+	return ssh_config.Default(key)
+}
+
+// hasConfig tells whether the key is set for the alias, regardless of the default value.
+func hasConfig(alias, key string) bool {
+	userConfig.doLoadConfig()
+
+	for _, index := range userConfig.getConfigIndexes() {
+		if index.get(alias, key) != "" {
+			return true
 		}
 	}
-
-	return ssh_config.Default(key)
+	return false
 }
 
 func getAllConfig(alias, key string) []string {
 	userConfig.doLoadConfig()
 
 	var values []string
-	if userConfig.config != nil {
-		if vals, _ := userConfig.config.GetAll(alias, key); len(vals) > 0 {
-			values = append(values, vals...)
-		}
-	}
-	if userConfig.sysConfig != nil {
-		if vals, _ := userConfig.sysConfig.GetAll(alias, key); len(vals) > 0 {
+	for _, index := range userConfig.getConfigIndexes() {
+		if vals := index.getAll(alias, key); len(vals) > 0 {
 			values = append(values, vals...)
 		}
 	}
@@ -354,8 +569,8 @@ func getAllConfig(alias, key string) []string {
 func getExConfig(alias, key string) string {
 	userConfig.doLoadExConfig()
 
-	if userConfig.exConfig != nil {
-		value, _ := userConfig.exConfig.Get(alias, key)
+	if userConfig.exConfigIndex != nil {
+		value := userConfig.exConfigIndex.get(alias, key)
 		if value != "" {
 			debug("get extended config [%s] for [%s] success", key, alias)
 			return value
@@ -375,8 +590,8 @@ func getAllExConfig(alias, key string) []string {
 	userConfig.doLoadExConfig()
 
 	var values []string
-	if userConfig.exConfig != nil {
-		if vals, _ := userConfig.exConfig.GetAll(alias, key); len(vals) > 0 {
+	if userConfig.exConfigIndex != nil {
+		if vals := userConfig.exConfigIndex.getAll(alias, key); len(vals) > 0 {
 			values = append(values, vals...)
 		}
 	}
@@ -390,11 +605,18 @@ func getAllExConfig(alias, key string) []string {
 func getAllHosts() []*sshHost {
 	userConfig.loadHosts.Do(func() {
 		userConfig.doLoadConfig()
-		if userConfig.config != nil {
-			userConfig.allHosts = append(userConfig.allHosts, recursiveGetHosts(userConfig.config.Hosts)...)
-		}
-		if userConfig.sysConfig != nil {
-			userConfig.allHosts = append(userConfig.allHosts, recursiveGetHosts(userConfig.sysConfig.Hosts)...)
+		shadowed := make(map[string]bool)
+		for _, config := range userConfig.getConfigs() {
+			hosts := recursiveGetHosts(config.Hosts)
+			for _, host := range hosts {
+				// the same alias in the later configs is shadowed by the former one
+				if !shadowed[host.Alias] {
+					userConfig.allHosts = append(userConfig.allHosts, host)
+				}
+			}
+			for _, host := range hosts {
+				shadowed[host.Alias] = true
+			}
 		}
 		afterLoginFuncs = append(afterLoginFuncs, func() {
 			userConfig.allHosts = nil
@@ -443,6 +665,7 @@ func appendPromptHosts(hosts []*sshHost, cfgHosts ...*ssh_config.Host) []*sshHos
 				ProxyJump:     getConfig(alias, "ProxyJump"),
 				RemoteCommand: getConfig(alias, "RemoteCommand"),
 				GroupLabels:   getGroupLabels(alias),
+				Shortcut:      getExConfig(alias, "ExShortcut"),
 			})
 		}
 	}
@@ -485,6 +708,10 @@ func getExOptionConfig(args *sshArgs, option string) string {
 	return getExConfig(args.Destination, option)
 }
 
+func getAllExOptionConfig(args *sshArgs, option string) []string {
+	return append(args.Option.getAll(option), getAllExConfig(args.Destination, option)...)
+}
+
 var secretEncodeKey = []byte("THE_UNSAFE_KEY_FOR_ENCODING_ONLY")
 
 func encodeSecret(secret []byte) (string, error) {
@@ -528,6 +755,13 @@ func decodeSecret(secret string) (string, error) {
 }
 
 func getSecretConfig(alias, key string) string {
+	if name := getExConfig(alias, "vault"+key); name != "" {
+		secret, err := getVaultSecret(name)
+		if err == nil && secret != "" {
+			return secret
+		}
+		warning("get secret [%s] from vault failed: %v", name, err)
+	}
 	if value := getExConfig(alias, "enc"+key); value != "" {
 		secret, err := decodeSecret(value)
 		if err == nil && secret != "" {
@@ -538,6 +772,10 @@ func getSecretConfig(alias, key string) string {
 	return getExConfig(alias, key)
 }
 
+func hasSecretConfig(alias, key string) bool {
+	return getExConfig(alias, "vault"+key) != "" || getExConfig(alias, "enc"+key) != "" || getExConfig(alias, key) != ""
+}
+
 func getPromptPageSize() int {
 	if userConfig.promptPageSize != 0 {
 		return int(userConfig.promptPageSize)
@@ -548,7 +786,7 @@ func getPromptPageSize() int {
 func getPromptDetailItems() []string {
 	promptDetailItems := userConfig.promptDetailItems
 	if promptDetailItems == "" {
-		promptDetailItems = "Alias Host Port User GroupLabels IdentityFile ProxyCommand ProxyJump RemoteCommand"
+		promptDetailItems = "Alias Shortcut Host Port User GroupLabels IdentityFile ProxyCommand ProxyJump RemoteCommand"
 	}
 	return strings.Fields(promptDetailItems)
 }
@@ -0,0 +1,324 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/trzsz/ssh_config"
+)
+
+// kConfigCacheVersion should be increased when the format of the cache is changed.
+const kConfigCacheVersion = 1
+
+// configCacheMinSize is the total size of a config and its Include files worth caching,
+// since the small configs are parsed faster than the cache is loaded.
+var configCacheMinSize int64 = 64 * 1024
+
+// configCache is the index of a config file and its Include files, it's reused if none of the files
+// is modified and the Include patterns still match the same files, so `tssh host cmd` does not parse
+// the large configs on every startup.
+type configCache struct {
+	Version int
+	Path    string
+	System  bool
+	Files   []cachedFile
+	Globs   []cachedGlob
+	Index   *cachedIndex
+}
+
+type cachedFile struct {
+	Path    string
+	Size    int64
+	ModTime int64
+}
+
+type cachedGlob struct {
+	Pattern string
+	Matches []string
+}
+
+type cachedIndex struct {
+	Hosts []*cachedHost
+}
+
+type cachedHost struct {
+	Patterns []string
+	Nodes    []*cachedNode
+}
+
+type cachedNode struct {
+	Key      string
+	Value    string
+	Include  bool
+	Includes []*cachedIndex
+}
+
+func getConfigCachePath(path string, system bool) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\n%v", path, system)))
+	return filepath.Join(userHomeDir, ".ssh", "tssh-cache", hex.EncodeToString(hash[:8])+".gob")
+}
+
+func newCachedFile(path string) (*cachedFile, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedFile{Path: path, Size: stat.Size(), ModTime: stat.ModTime().UnixNano()}, nil
+}
+
+// getIncludeGlob resolves the Include pattern in the same way as ssh_config.
+func getIncludeGlob(pattern string, system bool) string {
+	switch {
+	case filepath.IsAbs(pattern):
+		return pattern
+	case system:
+		return filepath.Join("/etc/ssh", pattern)
+	}
+	home := os.Getenv("HOME")
+	if u, err := user.Current(); err == nil {
+		home = u.HomeDir
+	}
+	if strings.HasPrefix(pattern, "~/") || strings.HasPrefix(pattern, "~\\") {
+		return filepath.Join(home, pattern[2:])
+	}
+	return filepath.Join(home, ".ssh", pattern)
+}
+
+// getIncludePatterns returns the patterns of the Include directive, which are not exported by ssh_config.
+func getIncludePatterns(include *ssh_config.Include) []string {
+	line := strings.TrimSpace(include.String())
+	if include.Comment != "" {
+		line = strings.TrimSuffix(line, " #"+include.Comment)
+	}
+	line = strings.TrimPrefix(strings.TrimSpace(line[len("Include"):]), "=")
+	return strings.Fields(line)
+}
+
+// newConfigCache records the files and the Include patterns of the config, and the index to be saved.
+func newConfigCache(path string, system bool, config *ssh_config.Config) (*configCache, error) {
+	cache := &configCache{Version: kConfigCacheVersion, Path: path, System: system}
+	file, err := newCachedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cache.Files = append(cache.Files, *file)
+	cache.Index, err = cache.addConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func (c *configCache) addConfig(config *ssh_config.Config) (*cachedIndex, error) {
+	index := &cachedIndex{}
+	for _, host := range config.Hosts {
+		cached := &cachedHost{}
+		for _, pattern := range host.Patterns {
+			if pattern.Not() {
+				cached.Patterns = append(cached.Patterns, "!"+pattern.String())
+			} else {
+				cached.Patterns = append(cached.Patterns, pattern.String())
+			}
+		}
+		for _, node := range host.Nodes {
+			switch t := node.(type) {
+			case *ssh_config.KV:
+				cached.Nodes = append(cached.Nodes, &cachedNode{Key: strings.ToLower(t.Key), Value: t.Value})
+			case *ssh_config.Include:
+				include := &cachedNode{Include: true}
+				for _, pattern := range getIncludePatterns(t) {
+					matches, err := filepath.Glob(getIncludeGlob(pattern, c.System))
+					if err != nil {
+						return nil, err
+					}
+					c.Globs = append(c.Globs, cachedGlob{Pattern: getIncludeGlob(pattern, c.System), Matches: matches})
+				}
+				files := t.GetFiles()
+				for _, path := range sortedKeys(files) {
+					if files[path] == nil {
+						continue
+					}
+					file, err := newCachedFile(path)
+					if err != nil {
+						return nil, err
+					}
+					c.Files = append(c.Files, *file)
+					included, err := c.addConfig(files[path])
+					if err != nil {
+						return nil, err
+					}
+					include.Includes = append(include.Includes, included)
+				}
+				cached.Nodes = append(cached.Nodes, include)
+			}
+		}
+		index.Hosts = append(index.Hosts, cached)
+	}
+	return index, nil
+}
+
+func (c *configCache) getTotalSize() int64 {
+	var size int64
+	for _, file := range c.Files {
+		size += file.Size
+	}
+	return size
+}
+
+// isValid checks whether any of the files is modified, or the Include patterns match the other files.
+func (c *configCache) isValid(path string, system bool) bool {
+	if c.Version != kConfigCacheVersion || c.Path != path || c.System != system || c.Index == nil {
+		return false
+	}
+	for _, file := range c.Files {
+		if current, err := newCachedFile(file.Path); err != nil || *current != file {
+			return false
+		}
+	}
+	for _, glob := range c.Globs {
+		matches, err := filepath.Glob(glob.Pattern)
+		if err != nil || strings.Join(matches, "\n") != strings.Join(glob.Matches, "\n") {
+			return false
+		}
+	}
+	return true
+}
+
+func newConfigIndexFromCache(cached *cachedIndex) (*configIndex, error) {
+	index := newEmptyConfigIndex()
+	for _, host := range cached.Hosts {
+		indexed := &indexedHost{}
+		for _, str := range host.Patterns {
+			pattern, err := ssh_config.NewPattern(str)
+			if err != nil {
+				return nil, err
+			}
+			indexed.patterns = append(indexed.patterns, pattern)
+		}
+		for _, node := range host.Nodes {
+			if !node.Include {
+				indexed.nodes = append(indexed.nodes, &indexedNode{key: node.Key, value: node.Value})
+				continue
+			}
+			include := &indexedNode{include: true}
+			for _, cachedInclude := range node.Includes {
+				included, err := newConfigIndexFromCache(cachedInclude)
+				if err != nil {
+					return nil, err
+				}
+				include.includes = append(include.includes, included)
+			}
+			indexed.nodes = append(indexed.nodes, include)
+		}
+		index.addHost(indexed)
+	}
+	return index, nil
+}
+
+func loadConfigCache(path string, system bool) *configIndex {
+	cachePath := getConfigCachePath(path, system)
+	file, err := os.Open(cachePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var cache configCache
+	if err := gob.NewDecoder(file).Decode(&cache); err != nil {
+		debug("decode config cache [%s] failed: %v", cachePath, err)
+		return nil
+	}
+	if !cache.isValid(path, system) {
+		debug("config cache [%s] of [%s] is outdated", cachePath, path)
+		return nil
+	}
+	index, err := newConfigIndexFromCache(cache.Index)
+	if err != nil {
+		debug("load config cache [%s] failed: %v", cachePath, err)
+		return nil
+	}
+	debug("load config [%s] from cache [%s]", path, cachePath)
+	return index
+}
+
+func saveConfigCache(path string, system bool, config *ssh_config.Config) {
+	cache, err := newConfigCache(path, system, config)
+	if err != nil {
+		debug("cache config [%s] failed: %v", path, err)
+		return
+	}
+	cachePath := getConfigCachePath(path, system)
+	if cache.getTotalSize() < configCacheMinSize {
+		_ = os.Remove(cachePath)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		debug("mkdir [%s] failed: %v", filepath.Dir(cachePath), err)
+		return
+	}
+	file, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".tmp*")
+	if err != nil {
+		debug("create config cache failed: %v", err)
+		return
+	}
+	defer os.Remove(file.Name())
+	if err := gob.NewEncoder(file).Encode(cache); err != nil {
+		file.Close()
+		debug("encode config cache [%s] failed: %v", cachePath, err)
+		return
+	}
+	if err := file.Close(); err != nil {
+		debug("write config cache [%s] failed: %v", cachePath, err)
+		return
+	}
+	if err := os.Rename(file.Name(), cachePath); err != nil {
+		debug("rename config cache [%s] failed: %v", cachePath, err)
+		return
+	}
+	debug("save config [%s] to cache [%s]", path, cachePath)
+}
+
+// loadConfigIndex loads the index from the cache if it's up to date, otherwise parses the config and caches it.
+func loadConfigIndex(path string, system bool) *configIndex {
+	if index := loadConfigCache(path, system); index != nil {
+		index.path, index.system = path, system
+		return index
+	}
+	config := loadConfig(path, system)
+	if config == nil {
+		return nil
+	}
+	saveConfigCache(path, system, config)
+	index := newConfigIndex(config)
+	index.path, index.system = path, system
+	return index
+}
@@ -0,0 +1,236 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/trzsz/ssh_config"
+)
+
+// configIndex speeds up the lookups of large configurations.
+//
+// ssh_config tests every Host block for every lookup, so listing 5,000 hosts takes 5,000 x 5,000 matches.
+// The index maps the plain aliases to their Host blocks, and only the blocks with wildcards or negations
+// are matched one by one. The settings of each alias are collected once and then cached.
+//
+// The index of a config file is saved in the cache directory, see config_cache.go, and the parsed tree
+// is decoded lazily by getConfig, only when all the hosts are listed.
+type configIndex struct {
+	hosts    []*indexedHost
+	literals map[string][]int
+	others   []int
+	mutex    sync.Mutex
+	settings map[string]map[string][]string
+
+	path       string
+	system     bool
+	decodeOnce sync.Once
+	config     *ssh_config.Config
+}
+
+type indexedHost struct {
+	patterns []*ssh_config.Pattern
+	nodes    []*indexedNode
+}
+
+type indexedNode struct {
+	key      string
+	value    string
+	include  bool
+	includes []*configIndex
+}
+
+func newEmptyConfigIndex() *configIndex {
+	return &configIndex{
+		literals: make(map[string][]int),
+		settings: make(map[string]map[string][]string),
+	}
+}
+
+func newConfigIndex(config *ssh_config.Config) *configIndex {
+	index := newEmptyConfigIndex()
+	index.config = config
+	for _, host := range config.Hosts {
+		indexed := &indexedHost{patterns: host.Patterns}
+		for _, node := range host.Nodes {
+			switch t := node.(type) {
+			case *ssh_config.KV:
+				indexed.nodes = append(indexed.nodes, &indexedNode{key: strings.ToLower(t.Key), value: t.Value})
+			case *ssh_config.Include:
+				files := t.GetFiles()
+				include := &indexedNode{include: true}
+				for _, path := range sortedKeys(files) {
+					if files[path] != nil {
+						include.includes = append(include.includes, newConfigIndex(files[path]))
+					}
+				}
+				indexed.nodes = append(indexed.nodes, include)
+			}
+		}
+		index.addHost(indexed)
+	}
+	return index
+}
+
+func (c *configIndex) addHost(host *indexedHost) {
+	i := len(c.hosts)
+	c.hosts = append(c.hosts, host)
+	if aliases := getLiteralAliases(host.patterns); aliases != nil {
+		for _, alias := range aliases {
+			if positions := c.literals[alias]; len(positions) == 0 || positions[len(positions)-1] != i {
+				c.literals[alias] = append(positions, i)
+			}
+		}
+	} else {
+		c.others = append(c.others, i)
+	}
+}
+
+// sortedKeys returns the Include files in order, since the map is not ordered.
+func sortedKeys(files map[string]*ssh_config.Config) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// getLiteralAliases returns nil if the Host block has any wildcard or negated pattern.
+func getLiteralAliases(patterns []*ssh_config.Pattern) []string {
+	aliases := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		alias := pattern.String()
+		if pattern.Not() || strings.ContainsAny(alias, "*?") {
+			return nil
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+func (c *configIndex) collect(alias string, settings map[string][]string) {
+	literals := c.literals[alias]
+	i, j := 0, 0
+	for i < len(literals) || j < len(c.others) {
+		var pos int
+		if j >= len(c.others) || (i < len(literals) && literals[i] < c.others[j]) {
+			pos = literals[i]
+			i++
+		} else {
+			pos = c.others[j]
+			j++
+			if !c.hosts[pos].matches(alias) {
+				continue
+			}
+		}
+		for _, node := range c.hosts[pos].nodes {
+			if node.include {
+				for _, include := range node.includes {
+					include.collect(alias, settings)
+				}
+				continue
+			}
+			settings[node.key] = append(settings[node.key], node.value)
+		}
+	}
+}
+
+// matches is the same as ssh_config.Host.Matches, a negated match ignores the Host block.
+func (h *indexedHost) matches(alias string) bool {
+	found := false
+	for _, pattern := range h.patterns {
+		if pattern.Regex().MatchString(alias) {
+			if pattern.Not() {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// getConfig returns the parsed tree, which is decoded on demand if the index is loaded from the cache.
+func (c *configIndex) getConfig() *ssh_config.Config {
+	c.decodeOnce.Do(func() {
+		if c.config == nil && c.path != "" {
+			c.config = loadConfig(c.path, c.system)
+		}
+	})
+	return c.config
+}
+
+func (c *configIndex) getSettings(alias string) map[string][]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if settings, ok := c.settings[alias]; ok {
+		return settings
+	}
+	settings := make(map[string][]string)
+	c.collect(alias, settings)
+	c.settings[alias] = settings
+	return settings
+}
+
+func (c *configIndex) get(alias, key string) string {
+	if values := c.getSettings(alias)[strings.ToLower(key)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c *configIndex) getAll(alias, key string) []string {
+	return c.getSettings(alias)[strings.ToLower(key)]
+}
+
+func (c *configIndex) hasAlias(alias string) bool {
+	if len(c.literals[alias]) > 0 {
+		return true
+	}
+	for _, host := range c.hosts {
+		for _, node := range host.nodes {
+			for _, include := range node.includes {
+				if include.hasAlias(alias) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isConfiguredAlias checks the plain aliases only, without listing all the hosts.
+func isConfiguredAlias(alias string) bool {
+	userConfig.doLoadConfig()
+	for _, index := range userConfig.getConfigIndexes() {
+		if index.hasAlias(alias) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trzsz/ssh_config"
+)
+
+func TestConfigIndex(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	included := filepath.Join(dir, "included")
+	assert.Nil(os.WriteFile(included, []byte(`
+Host inc
+    HostName inc.example.com
+    LocalForward 1001 127.0.0.1:1001
+Host a*
+    User included
+`), 0600))
+
+	config, err := ssh_config.Decode(strings.NewReader(fmt.Sprintf(`
+Include %s
+Host a b
+    HostName ab.example.com
+    LocalForward 2001 127.0.0.1:2001
+Host !b *
+    Port 2222
+    LocalForward 3001 127.0.0.1:3001
+Host b
+    HostName b.example.com
+    User b
+Host *
+    User default
+    IdentityFile ~/.ssh/id_rsa
+`, filepath.ToSlash(included))))
+	assert.Nil(err)
+	index := newConfigIndex(config)
+	assert.True(index.hasAlias("a"))
+	assert.True(index.hasAlias("inc"))
+	assert.False(index.hasAlias("c"))
+
+	for _, alias := range []string{"a", "b", "c", "inc", "abc", "unknown"} {
+		for _, key := range []string{"HostName", "User", "Port", "LocalForward", "identityfile", "Missing"} {
+			expected, _ := config.Get(alias, key)
+			assert.Equal(expected, index.get(alias, key), "%s %s", alias, key)
+			expectedAll, _ := config.GetAll(alias, key)
+			assert.Equal(expectedAll, index.getAll(alias, key), "%s %s", alias, key)
+		}
+	}
+}
+
+func TestConfigIndexScale(t *testing.T) {
+	assert := assert.New(t)
+	var buf strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&buf, "Host host%d\n    HostName 10.0.%d.%d\n", i, i/256, i%256)
+	}
+	buf.WriteString("Host *\n    User root\n")
+	config, err := ssh_config.Decode(strings.NewReader(buf.String()))
+	assert.Nil(err)
+
+	index := newConfigIndex(config)
+	for i := 0; i < 5000; i++ {
+		alias := fmt.Sprintf("host%d", i)
+		assert.Equal(fmt.Sprintf("10.0.%d.%d", i/256, i%256), index.get(alias, "HostName"))
+		assert.Equal("root", index.get(alias, "User"))
+	}
+}
+
+func TestConfigCache(t *testing.T) {
+	assert := assert.New(t)
+	defer func(home string, size int64) { userHomeDir, configCacheMinSize = home, size }(userHomeDir, configCacheMinSize)
+	userHomeDir, configCacheMinSize = t.TempDir(), 0
+	dir := t.TempDir()
+	assert.Nil(os.Mkdir(filepath.Join(dir, "conf.d"), 0700))
+	assert.Nil(os.WriteFile(filepath.Join(dir, "conf.d", "web"), []byte(`
+Host web
+    HostName web.example.com
+`), 0600))
+	path := filepath.Join(dir, "config")
+	assert.Nil(os.WriteFile(path, []byte(fmt.Sprintf(`
+Include %s/conf.d/*
+Host !db *
+    User admin
+Host db
+    HostName db.example.com
+`, filepath.ToSlash(dir))), 0600))
+
+	index := loadConfigIndex(path, false)
+	assert.NotNil(index.config)
+	assert.FileExists(getConfigCachePath(path, false))
+
+	// loaded from the cache, and the tree is not parsed until it's required
+	cached := loadConfigIndex(path, false)
+	assert.Nil(cached.config)
+	for _, alias := range []string{"web", "db", "other"} {
+		for _, key := range []string{"HostName", "User"} {
+			assert.Equal(index.get(alias, key), cached.get(alias, key), "%s %s", alias, key)
+		}
+	}
+	assert.True(cached.hasAlias("web"))
+	assert.Equal(2, len(cached.getConfig().Hosts)-1)
+
+	// a new file matched by the Include pattern
+	assert.Nil(os.WriteFile(filepath.Join(dir, "conf.d", "cache"), []byte("Host cache\n    HostName cache.example.com\n"), 0600))
+	index = loadConfigIndex(path, false)
+	assert.NotNil(index.config)
+	assert.Equal("cache.example.com", index.get("cache", "HostName"))
+	assert.Nil(loadConfigIndex(path, false).config)
+
+	// an Include file is modified
+	assert.Nil(os.WriteFile(filepath.Join(dir, "conf.d", "web"), []byte("Host web\n    HostName web2.example.com\n"), 0600))
+	index = loadConfigIndex(path, false)
+	assert.NotNil(index.config)
+	assert.Equal("web2.example.com", index.get("web", "HostName"))
+
+	// the small configs are not cached
+	configCacheMinSize = 1024 * 1024
+	assert.Nil(os.Remove(getConfigCachePath(path, false)))
+	assert.NotNil(loadConfigIndex(path, false).config)
+	assert.NoFileExists(getConfigCachePath(path, false))
+}
@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvePath(t *testing.T) {
+	assert := assert.New(t)
+	defer func(home string) { userHomeDir = home }(userHomeDir)
+	userHomeDir = "/home/tssh"
+
+	assert.Equal("/home/tssh", resolvePath("~"))
+	assert.Equal(filepath.Join("/home/tssh", ".ssh/id_rsa"), resolvePath("~/.ssh/id_rsa"))
+	assert.Equal(filepath.Join("/home/tssh", "my keys/id_rsa"), resolvePath(`"~/my keys/id_rsa"`))
+	assert.Equal("/tmp/a b", resolvePath("'/tmp/a b'"))
+	assert.Equal(`"/tmp/a`, resolvePath(`"/tmp/a`))
+
+	assert.Equal(`C:\Users\tssh\.ssh\id_rsa`, convertWindowsPath(`C:\Users\tssh\.ssh\id_rsa`))
+	assert.Equal(`C:\Users\tssh\.ssh\id_rsa`, convertWindowsPath(`C:/Users/tssh/.ssh/id_rsa`))
+	assert.Equal(`C:\Users\tssh`, convertWindowsPath(`/c/Users/tssh`))
+	assert.Equal(`D:\data`, convertWindowsPath(`/cygdrive/d/data`))
+	assert.Equal(`E:\`, convertWindowsPath(`/e`))
+	assert.Equal(`\\server\share\id_rsa`, convertWindowsPath(`//server/share/id_rsa`))
+	assert.Equal(`\\server\share\id_rsa`, convertWindowsPath(`\\server\share\id_rsa`))
+	assert.Equal(`\tmp\keys`, convertWindowsPath(`/tmp/keys`))
+	assert.Equal(`~\.ssh\config`, convertWindowsPath(`~/.ssh/config`))
+}
+
+func TestLayeredConfigs(t *testing.T) {
+	assert := assert.New(t)
+	defer func(config *tsshConfig) { userConfig = config }(userConfig)
+	dir := t.TempDir()
+	personal := filepath.Join(dir, "personal")
+	team := filepath.Join(dir, "team")
+	shared := filepath.Join(dir, "shared")
+	assert.Nil(os.WriteFile(personal, []byte(`
+ExExtraConfig `+shared+`
+Host web
+    User alice
+`), 0600))
+	assert.Nil(os.WriteFile(team, []byte(`
+Host web
+    HostName web.example.com
+    User deploy
+    LocalForward 8080 127.0.0.1:80
+Host db
+    HostName db.example.com
+`), 0600))
+	assert.Nil(os.WriteFile(shared, []byte(`
+Host web
+    LocalForward 9090 127.0.0.1:90
+Host cache
+    HostName cache.example.com
+`), 0600))
+
+	userConfig = &tsshConfig{}
+	assert.Nil(initUserConfig([]string{personal, team}))
+	assert.Equal(personal, userConfig.configPath)
+
+	assert.Equal("alice", getConfig("web", "User"))
+	assert.Equal("web.example.com", getConfig("web", "HostName"))
+	assert.Equal("db.example.com", getConfig("db", "HostName"))
+	assert.Equal("cache.example.com", getConfig("cache", "HostName"))
+	assert.Equal([]string{"8080 127.0.0.1:80", "9090 127.0.0.1:90"}, getAllConfig("web", "LocalForward"))
+	assert.True(isConfiguredAlias("cache"))
+	assert.False(isConfiguredAlias("unknown"))
+
+	var aliases []string
+	for _, host := range getAllHosts() {
+		aliases = append(aliases, host.Alias)
+	}
+	assert.Equal([]string{"web", "db", "cache"}, aliases)
+}
+
+func TestSystemConfigs(t *testing.T) {
+	assert := assert.New(t)
+	defer func(config *tsshConfig) { userConfig = config }(userConfig)
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user")
+	tsshSys := filepath.Join(dir, "tssh_config")
+	sshSys := filepath.Join(dir, "ssh_config")
+	assert.Nil(os.WriteFile(user, []byte(`
+Host web
+    Ciphers aes256-gcm@openssh.com
+`), 0600))
+	assert.Nil(os.WriteFile(tsshSys, []byte(`
+Host *
+    Ciphers chacha20-poly1305@openssh.com
+    ExAutoAttach tmux
+`), 0600))
+	assert.Nil(os.WriteFile(sshSys, []byte(`
+Host *
+    Ciphers aes128-ctr
+    ExAutoAttach screen
+    Port 2222
+`), 0600))
+
+	userConfig = &tsshConfig{configPath: user, sysConfigPaths: []string{tsshSys, filepath.Join(dir, "missing"), sshSys}}
+	assert.Equal("aes256-gcm@openssh.com", getConfig("web", "Ciphers"))
+	assert.Equal("chacha20-poly1305@openssh.com", getConfig("db", "Ciphers"))
+	assert.Equal("tmux", getConfig("web", "ExAutoAttach"))
+	assert.Equal("2222", getConfig("web", "Port"))
+}
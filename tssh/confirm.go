@@ -0,0 +1,220 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+const kConfirmHostLabel = "prod"
+
+// confirmGuard asks for a local confirmation before the typed command line which matches
+// ExConfirmPatterns is sent to the hosts labeled prod, e.g.:
+//
+//	Host prod*
+//	    GroupLabels prod
+//	    ExConfirmPatterns rm\s+-\S*r\S*\s+/(\s|$)
+//	    ExConfirmPatterns ^\s*(shutdown|reboot|halt)\b
+//	    ExConfirmPatterns (?i)drop\s+table
+//
+// Only the typed characters are followed, the lines recalled from the history or completed
+// by tab are not seen by tssh. Nothing is checked on the alternate screen, e.g., in vim.
+type confirmGuard struct {
+	typedLine
+	reader   io.Reader
+	output   io.Writer
+	alias    string
+	patterns []*regexp.Regexp
+	buffer   []byte
+	pending  []byte
+	mutex    sync.Mutex
+	screen   outputTracker
+}
+
+func (g *confirmGuard) match() *regexp.Regexp {
+	g.mutex.Lock()
+	altScreen := g.screen.altScreen
+	g.mutex.Unlock()
+	if altScreen {
+		return nil
+	}
+	for _, pattern := range g.patterns {
+		if pattern.Match(g.line) {
+			return pattern
+		}
+	}
+	return nil
+}
+
+// typedLine follows the command line typed by the keyboard, the cursor moves are not followed.
+type typedLine struct {
+	line    []byte
+	escapes outputTracker
+	ss3     bool
+}
+
+func (t *typedLine) editLine(c byte) {
+	if t.ss3 {
+		t.ss3 = false
+		return
+	}
+	if !t.escapes.isGround() || c == 0x1b {
+		t.ss3 = t.escapes.state == escapeEsc && c == 'O'
+		t.escapes.track([]byte{c})
+		return
+	}
+	switch c {
+	case '\b', 0x7f:
+		if len(t.line) > 0 {
+			_, size := utf8.DecodeLastRune(t.line)
+			t.line = t.line[:len(t.line)-size]
+		}
+	case 0x03, 0x15: // Ctrl+C and Ctrl+U clear the line
+		t.line = t.line[:0]
+	case 0x17: // Ctrl+W deletes the last word
+		i := len(t.line)
+		for i > 0 && t.line[i-1] == ' ' {
+			i--
+		}
+		for i > 0 && t.line[i-1] != ' ' {
+			i--
+		}
+		t.line = t.line[:i]
+	default:
+		if c >= 0x20 {
+			t.line = append(t.line, c)
+		}
+	}
+}
+
+// confirm asks the user whether to send the command line, any answer other than y is a no.
+func (g *confirmGuard) confirm(pattern *regexp.Regexp) bool {
+	fmt.Fprintf(g.output, "\r\n\033[0;33mThe command matches ExConfirmPatterns [%s], run it on %s? [y/N] \033[0m",
+		pattern.String(), g.alias)
+	for len(g.pending) == 0 {
+		n, err := g.reader.Read(g.buffer)
+		if n > 0 {
+			g.pending = g.buffer[:n]
+		} else if err != nil {
+			fmt.Fprintf(g.output, "\r\n")
+			return false
+		}
+	}
+	answer := g.pending[0]
+	g.pending = g.pending[1:]
+	yes := answer == 'y' || answer == 'Y'
+	if yes {
+		fmt.Fprintf(g.output, "y\r\n")
+	} else {
+		fmt.Fprintf(g.output, "\033[0;33mCancelled\033[0m\r\n")
+	}
+	return yes
+}
+
+func (g *confirmGuard) Read(p []byte) (int, error) {
+	if len(g.pending) == 0 {
+		n, err := g.reader.Read(g.buffer)
+		if n <= 0 {
+			return 0, err
+		}
+		g.pending = g.buffer[:n]
+	}
+	n := 0
+	for n < len(g.pending) && n < len(p) {
+		c := g.pending[n]
+		if (c == '\r' || c == '\n') && g.escapes.isGround() {
+			if n > 0 {
+				// send the former input before asking for the confirmation
+				break
+			}
+			g.pending = g.pending[1:]
+			if pattern := g.match(); pattern != nil && !g.confirm(pattern) {
+				c = 0x03 // cancel the command line by Ctrl+C
+			}
+			g.line = g.line[:0]
+			p[0] = c
+			return 1, nil
+		}
+		g.editLine(c)
+		p[n] = c
+		n++
+	}
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+type confirmOutput struct {
+	reader io.Reader
+	guard  *confirmGuard
+}
+
+func (o *confirmOutput) Read(p []byte) (int, error) {
+	n, err := o.reader.Read(p)
+	if n > 0 {
+		o.guard.mutex.Lock()
+		o.guard.screen.track(p[:n])
+		o.guard.mutex.Unlock()
+	}
+	return n, err
+}
+
+func getConfirmPatterns(args *sshArgs) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, value := range getAllExOptionConfig(args, "ExConfirmPatterns") {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			warning("invalid ExConfirmPatterns [%s]: %v", value, err)
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// wrapConfirmPatterns guards the keyboard input of the interactive session on the hosts labeled prod.
+func wrapConfirmPatterns(args *sshArgs, ss *sshSession, stdin io.Reader) io.Reader {
+	if !hasGroupLabel(&sshHost{GroupLabels: getGroupLabels(args.Destination)}, []string{kConfirmHostLabel}) {
+		return stdin
+	}
+	patterns := getConfirmPatterns(args)
+	if len(patterns) == 0 {
+		return stdin
+	}
+	guard := &confirmGuard{
+		reader:   stdin,
+		output:   os.Stderr,
+		alias:    args.Destination,
+		patterns: patterns,
+		buffer:   make([]byte, kStdioBufferSize),
+	}
+	ss.serverOut = &confirmOutput{ss.serverOut, guard}
+	debug("%d command patterns will be confirmed before sending to %s", len(patterns), args.Destination)
+	return guard
+}
@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmGuard(t *testing.T) {
+	assert := assert.New(t)
+	newGuard := func(input string) (*confirmGuard, *bytes.Buffer) {
+		var output bytes.Buffer
+		return &confirmGuard{
+			reader:   strings.NewReader(input),
+			output:   &output,
+			alias:    "prod1",
+			patterns: []*regexp.Regexp{regexp.MustCompile(`rm\s+-\S*r\S*\s+/(\s|$)`), regexp.MustCompile(`(?i)drop\s+table`)},
+			buffer:   make([]byte, 100),
+		}, &output
+	}
+	readAll := func(guard *confirmGuard) string {
+		buf, err := io.ReadAll(guard)
+		assert.Nil(err)
+		return string(buf)
+	}
+
+	guard, output := newGuard("ls -l /\rrm -rf /tmp/x\r")
+	assert.Equal("ls -l /\rrm -rf /tmp/x\r", readAll(guard))
+	assert.Equal("", output.String())
+
+	guard, output = newGuard("rm -rf /\rn")
+	assert.Equal("rm -rf /\x03", readAll(guard))
+	assert.Contains(output.String(), "run it on prod1? [y/N]")
+	assert.Contains(output.String(), "Cancelled")
+
+	guard, output = newGuard("DROP  TABLE users;\ry")
+	assert.Equal("DROP  TABLE users;\r", readAll(guard))
+	assert.Contains(output.String(), "[(?i)drop\\s+table]")
+
+	// the editing keys and the escape sequences
+	guard, output = newGuard("rm -rf /x\x7f\x7f\r")
+	assert.Equal("rm -rf /x\x7f\x7f\r", readAll(guard))
+	assert.Equal("", output.String())
+	guard, _ = newGuard("")
+	for _, c := range []byte("drop table\x15ls\x1b[Adrop\x17-l \x1bOBx") {
+		guard.editLine(c)
+	}
+	assert.Equal("-l x", string(guard.line))
+
+	// nothing is checked on the alternate screen
+	guard, output = newGuard("rm -rf /\r")
+	guard.screen.track([]byte("\033[?1049h"))
+	assert.Equal("rm -rf /\r", readAll(guard))
+	assert.Equal("", output.String())
+}
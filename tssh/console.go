@@ -0,0 +1,183 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const kDefaultConsoleEscape = "^]"
+
+// getConsoleCommand returns the ExConsoleCommand to run on the console gateway, e.g.:
+//
+//	Host server1
+//	    ProxyJump consolegw
+//	    #!! ExConsoleCommand console -f server1
+//
+// It's ignored if a command or a RemoteCommand is specified.
+func getConsoleCommand(args *sshArgs, param *sshParam) (string, error) {
+	command := getExOptionConfig(args, "ExConsoleCommand")
+	if command == "" || args.Command != "" || strings.ToLower(args.Option.get("RemoteCommand")) == "none" {
+		return "", nil
+	}
+	expandedCmd, err := expandTokens(command, args, param, "%CdhikLlnpru")
+	if err != nil {
+		return "", fmt.Errorf("expand ExConsoleCommand [%s] failed: %v", command, err)
+	}
+	return expandedCmd, nil
+}
+
+// parseConsoleEscape supports the caret notation, e.g., ^] means ctrl + ].
+func parseConsoleEscape(escape string) []byte {
+	var buf []byte
+	for i := 0; i < len(escape); i++ {
+		if escape[i] == '^' && i+1 < len(escape) {
+			i++
+			if escape[i] == '?' {
+				buf = append(buf, 0x7f)
+			} else {
+				buf = append(buf, escape[i]&0x1f)
+			}
+			continue
+		}
+		buf = append(buf, escape[i])
+	}
+	return buf
+}
+
+// consoleReader passes everything through to the console transparently,
+// except the escape sequence, which disconnects from the console.
+type consoleReader struct {
+	reader   io.Reader
+	escape   []byte
+	matched  int
+	pending  []byte
+	buffer   []byte
+	onEscape func()
+}
+
+func (r *consoleReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		n, err := r.reader.Read(r.buffer)
+		for _, b := range r.buffer[:n] {
+			if b != r.escape[r.matched] && r.matched > 0 {
+				r.pending = append(r.pending, r.escape[:r.matched]...)
+				r.matched = 0
+			}
+			if b == r.escape[r.matched] {
+				r.matched++
+				if r.matched == len(r.escape) {
+					r.onEscape()
+					// the session is closed, there is nothing more to read
+					select {}
+				}
+				continue
+			}
+			r.pending = append(r.pending, b)
+		}
+		if err != nil {
+			r.pending = append(r.pending, r.escape[:r.matched]...)
+			r.matched = 0
+			if len(r.pending) > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func wrapConsoleStdin(args *sshArgs, ss *sshSession) io.Reader {
+	stdin := getStdinReader()
+	if args.ReadOnly {
+		return wrapReadOnly(args, ss, stdin)
+	}
+	if !ss.console {
+		return stdin
+	}
+	escape := getExOptionConfig(args, "ExConsoleEscape")
+	if escape == "" {
+		escape = kDefaultConsoleEscape
+	}
+	if strings.ToLower(escape) == "none" {
+		return stdin
+	}
+	fmt.Fprintf(os.Stderr, "\033[0;36mConnected to the console via %s, the escape sequence is %s\033[0m\r\n", args.Destination, escape)
+	return &consoleReader{
+		reader: stdin,
+		escape: parseConsoleEscape(escape),
+		buffer: make([]byte, kStdioBufferSize),
+		onEscape: func() {
+			fmt.Fprintf(os.Stderr, "\r\n\033[0;36mDisconnected from the console by the escape sequence\033[0m\r\n")
+			ss.session.Close()
+		},
+	}
+}
+
+// readOnlyReader drops all the input, the escape sequence is handled by the underlying consoleReader.
+type readOnlyReader struct {
+	reader io.Reader
+	buffer []byte
+}
+
+func (r *readOnlyReader) Read(p []byte) (int, error) {
+	for {
+		if _, err := r.reader.Read(r.buffer); err != nil {
+			// don't close the input of the session, keep showing the output until it exits
+			select {}
+		}
+	}
+}
+
+// wrapReadOnly ignores the keyboard input of --read-only, except the escape sequence to disconnect.
+func wrapReadOnly(args *sshArgs, ss *sshSession, stdin io.Reader) io.Reader {
+	escape := getExOptionConfig(args, "ExConsoleEscape")
+	if escape == "" {
+		escape = kDefaultConsoleEscape
+	}
+	if strings.ToLower(escape) == "none" {
+		fmt.Fprintf(os.Stderr, "\033[0;36mRead-only session to %s, the keyboard input is ignored\033[0m\r\n", args.Destination)
+		return &readOnlyReader{reader: stdin, buffer: make([]byte, kStdioBufferSize)}
+	}
+	fmt.Fprintf(os.Stderr, "\033[0;36mRead-only session to %s, the keyboard input is ignored, press %s to disconnect\033[0m\r\n",
+		args.Destination, escape)
+	return &readOnlyReader{
+		reader: &consoleReader{
+			reader: stdin,
+			escape: parseConsoleEscape(escape),
+			buffer: make([]byte, kStdioBufferSize),
+			onEscape: func() {
+				fmt.Fprintf(os.Stderr, "\r\n\033[0;36mDisconnected from the read-only session by the escape sequence\033[0m\r\n")
+				ss.session.Close()
+			},
+		},
+		buffer: make([]byte, kStdioBufferSize),
+	}
+}
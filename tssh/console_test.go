@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsoleReader(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]byte{0x1d}, parseConsoleEscape("^]"))
+	assert.Equal([]byte{'\r', '~', '.'}, parseConsoleEscape("\r~."))
+	assert.Equal([]byte{0x05, 'c', '.'}, parseConsoleEscape("^Ec."))
+	assert.Equal([]byte{0x7f}, parseConsoleEscape("^?"))
+
+	readAll := func(input, escape string) (string, bool) {
+		escaped := make(chan struct{})
+		reader := &consoleReader{
+			reader:   strings.NewReader(input),
+			escape:   parseConsoleEscape(escape),
+			buffer:   make([]byte, 3),
+			onEscape: func() { close(escaped) },
+		}
+		output := make(chan string, 1)
+		go func() {
+			data, _ := io.ReadAll(reader)
+			output <- string(data)
+		}()
+		select {
+		case data := <-output:
+			return data, false
+		case <-escaped:
+			return "", true
+		case <-time.After(3 * time.Second):
+			assert.Fail("read timeout")
+			return "", false
+		}
+	}
+
+	data, escaped := readAll("ls\x03\x1b[A\r", "^]")
+	assert.Equal("ls\x03\x1b[A\r", data)
+	assert.False(escaped)
+	data, escaped = readAll("a~b\r~c\r~", "\r~.")
+	assert.Equal("a~b\r~c\r~", data)
+	assert.False(escaped)
+	_, escaped = readAll("abc\x05c.def", "^Ec.")
+	assert.True(escaped)
+	_, escaped = readAll("\r\r~.", "\r~.")
+	assert.True(escaped)
+}
+
+func TestReadOnlyReader(t *testing.T) {
+	assert := assert.New(t)
+	readOnly := func(input string) (int, bool) {
+		escaped := make(chan struct{})
+		reader := &readOnlyReader{
+			reader: &consoleReader{
+				reader:   strings.NewReader(input),
+				escape:   parseConsoleEscape("^]"),
+				buffer:   make([]byte, 3),
+				onEscape: func() { close(escaped) },
+			},
+			buffer: make([]byte, 3),
+		}
+		output := make(chan int, 1)
+		go func() {
+			n, _ := reader.Read(make([]byte, 100))
+			output <- n
+		}()
+		select {
+		case n := <-output:
+			return n, false
+		case <-escaped:
+			return 0, true
+		case <-time.After(100 * time.Millisecond):
+			return 0, false
+		}
+	}
+
+	n, escaped := readOnly("rm -rf /\r")
+	assert.Equal(0, n)
+	assert.False(escaped)
+	n, escaped = readOnly("ls\r\x1d")
+	assert.Equal(0, n)
+	assert.True(escaped)
+}
@@ -52,10 +52,12 @@ type controlMaster struct {
 	args    []string
 	cmd     *exec.Cmd
 	ptmx    *os.File
+	holdIn  bool
 	stdout  io.ReadCloser
 	stderr  io.ReadCloser
 	exited  atomic.Bool
 	success atomic.Bool
+	exitCh  <-chan struct{}
 }
 
 func (c *controlMaster) handleStderr() {
@@ -159,6 +161,11 @@ func (c *controlMaster) start(args *sshArgs) error {
 		c.ptmx = pty
 		cancel := c.fillPassword(args, expectCount)
 		defer cancel()
+	} else if c.holdIn {
+		// keep stdin open until exit, so that the remote command won't read EOF
+		if _, err = c.cmd.StdinPipe(); err != nil {
+			return fmt.Errorf("stdin pipe failed: %v", err)
+		}
 	}
 	if c.stdout, err = c.cmd.StdoutPipe(); err != nil {
 		return fmt.Errorf("stdout pipe failed: %v", err)
@@ -176,11 +183,12 @@ func (c *controlMaster) start(args *sshArgs) error {
 
 	c.handleStderr()
 	exitCh := c.checkExit()
+	c.exitCh = exitCh
 	doneCh := c.handleStdout()
 
 	defer func() {
 		if !c.exited.Load() {
-			onExitFuncs = append(onExitFuncs, func() {
+			onExit(func() {
 				c.quit(exitCh)
 			})
 		}
@@ -238,13 +246,14 @@ func getOpenSSH() (string, error) {
 	return sshPath, nil
 }
 
-func startControlMaster(args *sshArgs) error {
+func newControlMaster(args *sshArgs, options []string, command string) (*controlMaster, error) {
 	sshPath, err := getOpenSSH()
 	if err != nil {
-		return fmt.Errorf("can't find openssh program: %v", err)
+		return nil, fmt.Errorf("can't find openssh program: %v", err)
 	}
 
 	cmdArgs := []string{"-T", "-oRemoteCommand=none", "-oConnectTimeout=10"}
+	cmdArgs = append(cmdArgs, options...)
 
 	if args.Debug {
 		cmdArgs = append(cmdArgs, "-v")
@@ -258,8 +267,9 @@ func startControlMaster(args *sshArgs) error {
 	if args.Port != 0 {
 		cmdArgs = append(cmdArgs, "-p", strconv.Itoa(args.Port))
 	}
-	if args.ConfigFile != "" {
-		cmdArgs = append(cmdArgs, "-F", args.ConfigFile)
+	if len(args.ConfigFile.values) > 0 {
+		// openssh accepts only one -F, the extra configs are not layered
+		cmdArgs = append(cmdArgs, "-F", args.ConfigFile.values[0])
 	}
 	if args.ProxyJump != "" {
 		cmdArgs = append(cmdArgs, "-J", args.ProxyJump)
@@ -296,14 +306,21 @@ func startControlMaster(args *sshArgs) error {
 	} else {
 		cmdArgs = append(cmdArgs, args.Destination)
 	}
-	// 10 seconds is enough for tssh to connect
-	cmdArgs = append(cmdArgs, "echo ok; sleep 10")
+	cmdArgs = append(cmdArgs, command)
 
 	if enableDebugLogging {
 		debug("control master: %s %s", sshPath, strings.Join(cmdArgs, " "))
 	}
 
-	ctrlMaster := &controlMaster{path: sshPath, args: cmdArgs}
+	return &controlMaster{path: sshPath, args: cmdArgs}, nil
+}
+
+func startControlMaster(args *sshArgs) error {
+	// 10 seconds is enough for tssh to connect
+	ctrlMaster, err := newControlMaster(args, nil, "echo ok; sleep 10")
+	if err != nil {
+		return err
+	}
 	if err := ctrlMaster.start(args); err != nil {
 		return err
 	}
@@ -316,7 +333,9 @@ func connectViaControl(args *sshArgs, param *sshParam) *ssh.Client {
 	ctrlPath := getOptionConfig(args, "ControlPath")
 
 	switch strings.ToLower(ctrlPath) {
-	case "", "none":
+	case "":
+		return connectViaDaemon(args, param)
+	case "none":
 		return nil
 	}
 
@@ -325,7 +344,7 @@ func connectViaControl(args *sshArgs, param *sshParam) *ssh.Client {
 		warning("expand ControlPath [%s] failed: %v", socket, err)
 		return nil
 	}
-	socket = resolveHomeDir(socket)
+	socket = resolvePath(socket)
 
 	switch strings.ToLower(ctrlMaster) {
 	case "yes", "ask":
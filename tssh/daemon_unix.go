@@ -0,0 +1,227 @@
+//go:build !windows
+
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kDaemonMaxRestartDelay = 60 * time.Second
+
+// the remote command exits on EOF, so it won't be left on the server after the master exits
+const kDaemonMasterCommand = "echo ok; cat >/dev/null"
+
+type daemonHost struct {
+	alias  string
+	args   *sshArgs
+	socket string
+}
+
+type tsshDaemon struct {
+	mutex   sync.Mutex
+	stopped atomic.Bool
+}
+
+func getDaemonSocket(args *sshArgs, param *sshParam) (string, error) {
+	hash, err := expandTokens("%C", args, param, "%C")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userHomeDir, ".ssh", "tssh-daemon", hash), nil
+}
+
+func isDaemonSocketAlive(socket string) bool {
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// connectViaDaemon attaches to the master connection maintained by `tssh --daemon` if there is one.
+func connectViaDaemon(args *sshArgs, param *sshParam) *ssh.Client {
+	socket, err := getDaemonSocket(args, param)
+	if err != nil || !isFileExist(socket) {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		debug("dial daemon socket [%s] failed: %v", socket, err)
+		return nil
+	}
+
+	ncc, chans, reqs, err := NewControlClientConn(conn)
+	if err != nil {
+		conn.Close()
+		debug("new conn from daemon socket [%s] failed: %v", socket, err)
+		return nil
+	}
+
+	debug("login to [%s] via daemon socket [%s] success", args.Destination, socket)
+	return ssh.NewClient(ncc, chans, reqs)
+}
+
+func getDaemonHosts(args *sshArgs) []string {
+	if args.Destination != "" {
+		return []string{args.Destination}
+	}
+	return strings.Fields(strings.ReplaceAll(userConfig.daemonHosts, ",", " "))
+}
+
+func newDaemonHost(args *sshArgs, alias string) (*daemonHost, error) {
+	hostArgs := &sshArgs{
+		Destination:  alias,
+		originalDest: alias,
+		Debug:        args.Debug,
+		ConfigFile:   args.ConfigFile,
+		Option:       copySshOption(&args.Option),
+	}
+	param, err := getSshParam(hostArgs)
+	if err != nil {
+		return nil, err
+	}
+	socket, err := getDaemonSocket(hostArgs, param)
+	if err != nil {
+		return nil, err
+	}
+	return &daemonHost{alias: alias, args: hostArgs, socket: socket}, nil
+}
+
+func (d *tsshDaemon) startMaster(host *daemonHost) (*controlMaster, error) {
+	// start the masters one by one, so that the password prompts won't mix up
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.stopped.Load() {
+		return nil, fmt.Errorf("daemon stopped")
+	}
+
+	if isFileExist(host.socket) {
+		_ = os.Remove(host.socket)
+	}
+	options := []string{"-oControlMaster=yes", "-oControlPath=" + host.socket, "-oControlPersist=no"}
+	if getOptionConfig(host.args, "ServerAliveInterval") == "" {
+		options = append(options, "-oServerAliveInterval=30")
+	}
+	ctrlMaster, err := newControlMaster(host.args, options, kDaemonMasterCommand)
+	if err != nil {
+		return nil, err
+	}
+	ctrlMaster.holdIn = true
+	if err := ctrlMaster.start(host.args); err != nil {
+		return nil, err
+	}
+	return ctrlMaster, nil
+}
+
+func (d *tsshDaemon) maintain(host *daemonHost) {
+	delay := time.Second
+	for {
+		beginTime := time.Now()
+		ctrlMaster, err := d.startMaster(host)
+		if d.stopped.Load() {
+			return
+		}
+		if err != nil {
+			toolsWarn("Daemon", "connect to [%s] failed: %v", host.alias, err)
+		} else {
+			toolsSucc("Daemon", "master connection to [%s] is ready", host.alias)
+			<-ctrlMaster.exitCh
+			if d.stopped.Load() {
+				return
+			}
+			toolsWarn("Daemon", "master connection to [%s] exited", host.alias)
+		}
+
+		if time.Since(beginTime) > kDaemonMaxRestartDelay {
+			delay = time.Second
+		} else if delay *= 2; delay > kDaemonMaxRestartDelay {
+			delay = kDaemonMaxRestartDelay
+		}
+		toolsInfo("Daemon", "reconnect to [%s] in %v", host.alias, delay)
+		time.Sleep(delay)
+	}
+}
+
+func execDaemon(args *sshArgs) (int, bool) {
+	aliases := getDaemonHosts(args)
+	if len(aliases) == 0 {
+		toolsErrorExit("no host to maintain, configure DaemonHosts in ~/.tssh.conf, e.g., DaemonHosts = host1 host2")
+	}
+	if _, err := getOpenSSH(); err != nil {
+		toolsErrorExit("can't find openssh program: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(userHomeDir, ".ssh", "tssh-daemon"), 0700); err != nil {
+		toolsErrorExit("create daemon socket directory failed: %v", err)
+	}
+
+	var hosts []*daemonHost
+	for _, alias := range aliases {
+		host, err := newDaemonHost(args, alias)
+		if err != nil {
+			toolsWarn("Daemon", "skip [%s]: %v", alias, err)
+			continue
+		}
+		if isDaemonSocketAlive(host.socket) {
+			toolsWarn("Daemon", "skip [%s]: it's already maintained by another daemon", alias)
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return 1, true
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	daemon := &tsshDaemon{}
+	for _, host := range hosts {
+		go daemon.maintain(host)
+	}
+	toolsInfo("Daemon", "maintaining master connections to %d hosts, press Ctrl+C to stop", len(hosts))
+
+	<-sigCh
+	// the running masters will be stopped by cleanupOnExit
+	daemon.stopped.Store(true)
+	daemon.mutex.Lock()
+	toolsInfo("Daemon", "stopping")
+	return 0, true
+}
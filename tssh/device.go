@@ -0,0 +1,150 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const kDevicePromptMaxLen = 256
+
+type deviceType struct {
+	term     string
+	commands []string
+	prompt   *regexp.Regexp
+}
+
+var kDeviceTypes = map[string]*deviceType{
+	"cisco": {
+		term:     "vt100",
+		commands: []string{"terminal length 0", "terminal width 0"},
+		prompt:   regexp.MustCompile(`^[\w.()/:-]+[>#] ?$`),
+	},
+	"juniper": {
+		term:     "vt100",
+		commands: []string{"set cli screen-length 0", "set cli screen-width 0"},
+		prompt:   regexp.MustCompile(`^[\w.@-]+[>#%] ?$`),
+	},
+	"huawei": {
+		term:     "vt100",
+		commands: []string{"screen-length 0 temporary"},
+		prompt:   regexp.MustCompile(`^[<\[]~?[\w.@/-]+[>\]] ?$`),
+	},
+}
+
+// getDeviceType returns the network device type configured by ExDeviceType.
+func getDeviceType(args *sshArgs) (*deviceType, error) {
+	name := strings.ToLower(getExOptionConfig(args, "ExDeviceType"))
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	device, ok := kDeviceTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown ExDeviceType [%s], should be one of cisco, juniper, huawei", name)
+	}
+	return device, nil
+}
+
+// deviceOutput normalizes the line endings of the network devices, and turns off
+// the paging by sending the commands of the device type once the prompts appear.
+type deviceOutput struct {
+	reader   io.Reader
+	writer   io.Writer
+	device   *deviceType
+	commands []string
+	buffer   []byte
+	pending  []byte
+	line     []byte
+	lastByte byte
+}
+
+func (d *deviceOutput) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		n, err := d.reader.Read(d.buffer)
+		if n > 0 {
+			d.pending = d.normalize(d.pending[:0], d.buffer[:n])
+			d.detectPrompt()
+		}
+		if err != nil {
+			if len(d.pending) > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// normalize converts the bare \n to \r\n, and the repeated \r before \n to a single one.
+func (d *deviceOutput) normalize(dst, src []byte) []byte {
+	for _, b := range src {
+		switch {
+		case b == '\r' && d.lastByte == '\r':
+			continue
+		case b == '\n':
+			if d.lastByte != '\r' {
+				dst = append(dst, '\r')
+			}
+			d.line = d.line[:0]
+		case b != '\r' && len(d.line) < kDevicePromptMaxLen:
+			d.line = append(d.line, b)
+		}
+		dst = append(dst, b)
+		d.lastByte = b
+	}
+	return dst
+}
+
+func (d *deviceOutput) detectPrompt() {
+	if len(d.commands) == 0 || !d.device.prompt.Match(d.line) {
+		return
+	}
+	command := d.commands[0]
+	d.commands = d.commands[1:]
+	debug("device prompt detected: %s, send: %s", d.line, command)
+	if err := writeAll(d.writer, []byte(command+"\r")); err != nil {
+		warning("send device command [%s] failed: %v", command, err)
+		d.commands = nil
+	}
+	d.line = d.line[:0]
+}
+
+func wrapDeviceOutput(ss *sshSession) {
+	if ss.device == nil || !ss.tty {
+		return
+	}
+	ss.serverOut = &deviceOutput{
+		reader:   ss.serverOut,
+		writer:   ss.serverIn,
+		device:   ss.device,
+		commands: ss.device.commands,
+		buffer:   make([]byte, kStdioBufferSize),
+	}
+}
@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestDeviceOutput(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(device string) *sshArgs {
+		return &sshArgs{Option: sshOption{map[string][]string{"exdevicetype": {device}}}}
+	}
+	device, err := getDeviceType(newArgs("Cisco"))
+	assert.Nil(err)
+	assert.Equal("vt100", device.term)
+	device, err = getDeviceType(newArgs("none"))
+	assert.Nil(err)
+	assert.Nil(device)
+	_, err = getDeviceType(newArgs("unknown"))
+	assert.NotNil(err)
+
+	var serverIn bytes.Buffer
+	ss := &sshSession{
+		serverIn:  nopWriteCloser{&serverIn},
+		serverOut: &chunkReader{[]string{"banner\n\r\r\nRouter", "# ", "terminal length 0\r\r\nRouter# ", "x\r", "\ny\n"}},
+		tty:       true,
+		device:    kDeviceTypes["cisco"],
+	}
+	wrapDeviceOutput(ss)
+	output, err := io.ReadAll(ss.serverOut)
+	assert.Nil(err)
+	assert.Equal("banner\r\n\r\nRouter# terminal length 0\r\nRouter# x\r\ny\r\n", string(output))
+	assert.Equal("terminal length 0\rterminal width 0\r", serverIn.String())
+
+	assert.True(kDeviceTypes["juniper"].prompt.MatchString("user@router> "))
+	assert.True(kDeviceTypes["huawei"].prompt.MatchString("<HUAWEI>"))
+	assert.True(kDeviceTypes["huawei"].prompt.MatchString("[~HUAWEI-GigabitEthernet0/0/1]"))
+	assert.False(kDeviceTypes["cisco"].prompt.MatchString(" --More-- "))
+}
@@ -127,11 +127,52 @@ func getSetEnvs(args *sshArgs) ([]*sshEnv, error) {
 	return envs, nil
 }
 
+func isLocaleEnv(name string) bool {
+	return name == "LANG" || name == "LANGUAGE" || strings.HasPrefix(name, "LC_")
+}
+
+// getLocaleEnvs returns the LANG and LC_ALL forced by ExLocale, which replace the local locale envs sent by SendEnv.
+func getLocaleEnvs(args *sshArgs) []*sshEnv {
+	locale := getExOptionConfig(args, "ExLocale")
+	if locale == "" {
+		return nil
+	}
+	return []*sshEnv{{"LANG", locale}, {"LC_ALL", locale}}
+}
+
+func replaceLocaleEnvs(envs, localeEnvs []*sshEnv) []*sshEnv {
+	if localeEnvs == nil {
+		return envs
+	}
+	var replaced []*sshEnv
+	for _, env := range envs {
+		if !isLocaleEnv(env.name) {
+			replaced = append(replaced, env)
+		}
+	}
+	return append(replaced, localeEnvs...)
+}
+
+// getTermName returns the TERM sent to the server, ExTerm forces it for the hosts which misrender modern terminfo entries.
+func getTermName(args *sshArgs, device *deviceType) string {
+	if term := getExOptionConfig(args, "ExTerm"); term != "" {
+		return term
+	}
+	if device != nil {
+		return device.term
+	}
+	if term := os.Getenv("TERM"); term != "" {
+		return term
+	}
+	return "xterm-256color"
+}
+
 func sendAndSetEnv(args *sshArgs, session *ssh.Session) error {
 	envs, err := getSendEnvs(args)
 	if err != nil {
 		return err
 	}
+	envs = replaceLocaleEnvs(envs, getLocaleEnvs(args))
 	for _, env := range envs {
 		if err := session.Setenv(env.name, env.value); err != nil {
 			debug("send env failed: %s = \"%s\"", env.name, env.value)
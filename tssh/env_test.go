@@ -0,0 +1,74 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTermAndLocaleOverrides(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options ...string) *sshArgs {
+		args := &sshArgs{}
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return args
+	}
+
+	t.Setenv("TERM", "xterm-kitty")
+	assert.Equal("xterm-kitty", getTermName(newArgs(), nil))
+	assert.Equal("vt100", getTermName(newArgs(), &deviceType{term: "vt100"}))
+	assert.Equal("vt220", getTermName(newArgs("ExTerm vt220"), &deviceType{term: "vt100"}))
+	t.Setenv("TERM", "")
+	assert.Equal("xterm-256color", getTermName(newArgs(), nil))
+
+	assert.Nil(getLocaleEnvs(newArgs()))
+	localeEnvs := getLocaleEnvs(newArgs("ExLocale C"))
+	assert.Equal([]*sshEnv{{"LANG", "C"}, {"LC_ALL", "C"}}, localeEnvs)
+
+	envs := []*sshEnv{{"LANG", "en_US.UTF-8"}, {"LC_CTYPE", "UTF-8"}, {"EDITOR", "vim"}, {"LANGUAGE", "en"}}
+	assert.Equal(envs, replaceLocaleEnvs(envs, nil))
+	assert.Equal([]*sshEnv{{"EDITOR", "vim"}, {"LANG", "C"}, {"LC_ALL", "C"}}, replaceLocaleEnvs(envs, localeEnvs))
+}
+
+func TestNestedSession(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv(kTrzszFilterEnv, "")
+	assert.False(isNestedSession())
+	t.Setenv(kTrzszFilterEnv, kTsshVersion)
+	assert.True(isNestedSession())
+
+	// the variable is trusted only if it's sent by the connection of the current session
+	t.Setenv("SSH_CONNECTION", "10.0.0.1 54321 10.0.0.2 22")
+	t.Setenv(kTrzszFilterEnv, kTsshVersion+":54321")
+	assert.True(isNestedSession())
+	t.Setenv(kTrzszFilterEnv, kTsshVersion+":12345")
+	assert.False(isNestedSession())
+	t.Setenv("SSH_CONNECTION", "")
+	assert.False(isNestedSession())
+}
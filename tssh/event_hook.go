@@ -0,0 +1,66 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+)
+
+// runEventHook runs the ExEventHook local command with the event in the environment variables, e.g.:
+//
+//	Host *
+//	    ExEventHook ~/.ssh/tssh-notify.sh
+//
+// TSSH_EVENT is the event name, such as transfer_done and transfer_failed, TSSH_HOST is the destination,
+// and the other TSSH_ variables depend on the event. The output of the hook goes to stderr.
+func runEventHook(args *sshArgs, event string, envs map[string]string) {
+	hook := getExOptionConfig(args, "ExEventHook")
+	if hook == "" {
+		return
+	}
+	argv, err := splitCommandLine(resolveHomeDir(hook))
+	if err != nil || len(argv) == 0 {
+		warning("split ExEventHook [%s] failed: %v", hook, err)
+		return
+	}
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(), "TSSH_EVENT="+event, "TSSH_HOST="+args.Destination)
+	for _, name := range names {
+		cmd.Env = append(cmd.Env, name+"="+envs[name])
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	debug("run event hook [%s] for %s", hook, event)
+	if err := cmd.Run(); err != nil {
+		warning("run ExEventHook [%s] for %s failed: %v", hook, event, err)
+	}
+}
@@ -303,19 +303,27 @@ func (e *sshExpect) wrapOutput(reader io.Reader, writer io.Writer, ch chan []byt
 	}
 }
 
-func (e *sshExpect) waitForPattern(pattern string, caseSends *caseSendList) error {
+func (e *sshExpect) waitForPattern(pattern string, caseSends *caseSendList, timeout time.Duration) error {
 	expr := quoteExpectPattern(pattern)
 	re, err := regexp.Compile(expr)
 	if err != nil {
 		warning("compile expect expr [%s] failed: %v", expr, err)
 		return err
 	}
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
 	var builder strings.Builder
 	for {
 		var buf []byte
 		select {
 		case <-e.ctx.Done():
 			return e.ctx.Err()
+		case <-timeoutCh:
+			return errExpectStepTimeout
 		case buf = <-e.out:
 		case buf = <-e.err:
 		}
@@ -391,7 +399,7 @@ func (e *sshExpect) execInteractions(writer io.Writer, expectCount int) {
 				warning("Invalid ExpectCaseSendText%d: %v", idx, err)
 			}
 		}
-		if err := e.waitForPattern(pattern, caseSends); err != nil {
+		if err := e.waitForPattern(pattern, caseSends, 0); err != nil {
 			return
 		}
 		if e.ctx.Err() != nil {
@@ -432,9 +440,17 @@ func getExpectTimeout(args *sshArgs, prefix string) int {
 
 func execExpectInteractions(args *sshArgs, ss *sshSession) {
 	expectCount := getExpectCount(args, "")
-	if expectCount <= 0 {
+	script, err := loadExpectScript(args)
+	if err != nil {
+		warning("%v", err)
+	}
+	if expectCount <= 0 && script == nil {
 		return
 	}
+	if expectCount > 0 && script != nil {
+		warning("ExpectScript is ignored since ExpectCount is configured")
+		script = nil
+	}
 
 	outReader, outWriter := io.Pipe()
 	errReader, errWriter := io.Pipe()
@@ -442,6 +458,9 @@ func execExpectInteractions(args *sshArgs, ss *sshSession) {
 	var ctx context.Context
 	var cancel context.CancelFunc
 	expectTimeout := getExpectTimeout(args, "")
+	if script != nil && getExOptionConfig(args, "ExpectTimeout") == "" {
+		expectTimeout = 0 // each step of the script has its own timeout
+	}
 	if expectTimeout > 0 {
 		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(expectTimeout)*time.Second)
 	} else {
@@ -458,7 +477,11 @@ func execExpectInteractions(args *sshArgs, ss *sshSession) {
 	go expect.wrapOutput(ss.serverOut, outWriter, expect.out)
 	go expect.wrapOutput(ss.serverErr, errWriter, expect.err)
 
-	expect.execInteractions(ss.serverIn, expectCount)
+	if script != nil {
+		script.exec(expect, ss.serverIn)
+	} else {
+		expect.execInteractions(ss.serverIn, expectCount)
+	}
 
 	if ctx.Err() == context.DeadlineExceeded {
 		warning("expect timeout after %d seconds", expectTimeout)
@@ -0,0 +1,170 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errExpectStepTimeout = errors.New("expect step timeout")
+
+type expectStep struct {
+	pattern string
+	kind    string
+	input   string
+	timeout time.Duration
+}
+
+type expectScript struct {
+	path  string
+	steps []*expectStep
+}
+
+// parseExpectScript parses the login chat script, e.g.:
+//
+//	# wait up to 10 seconds for each of the following patterns
+//	timeout 10
+//	expect "*Select a server:*"
+//	send "3\r"
+//	expect "*assword:*"
+//	send-pass <the secret encoded by tssh --enc-secret>
+//	expect "*Verification code:*"
+//	send-otp "oathtool --totp -b xxxxx"
+//
+// Each expect starts a new step, and a send without an expect is sent immediately.
+func parseExpectScript(reader io.Reader) ([]*expectStep, error) {
+	var steps []*expectStep
+	timeout := kDefaultExpectTimeout * time.Second
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyword, value, _ := strings.Cut(line, " ")
+		keyword = strings.ToLower(keyword)
+		value = unquotePath(strings.TrimSpace(value))
+		if value == "" {
+			return nil, fmt.Errorf("line %d: %s requires an argument", lineNo, keyword)
+		}
+		switch keyword {
+		case "timeout":
+			seconds, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid timeout [%s]: %v", lineNo, value, err)
+			}
+			timeout = time.Duration(seconds) * time.Second
+		case "expect":
+			steps = append(steps, &expectStep{pattern: value, timeout: timeout})
+		case "send", "send-pass", "send-otp", "send-enc-otp":
+			if len(steps) == 0 || steps[len(steps)-1].kind != "" {
+				steps = append(steps, &expectStep{timeout: timeout})
+			}
+			steps[len(steps)-1].kind = keyword
+			steps[len(steps)-1].input = value
+		default:
+			return nil, fmt.Errorf("line %d: unknown keyword [%s]", lineNo, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// loadExpectScript loads the script file configured by ExpectScript.
+func loadExpectScript(args *sshArgs) (*expectScript, error) {
+	path := getExOptionConfig(args, "ExpectScript")
+	if path == "" {
+		return nil, nil
+	}
+	path = resolvePath(path)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open ExpectScript [%s] failed: %v", path, err)
+	}
+	defer file.Close()
+	steps, err := parseExpectScript(file)
+	if err != nil {
+		return nil, fmt.Errorf("parse ExpectScript [%s] failed: %v", path, err)
+	}
+	return &expectScript{path, steps}, nil
+}
+
+func (s *expectStep) getSender(expect *sshExpect) *expectSender {
+	switch s.kind {
+	case "send":
+		return newTextSender(expect, s.input)
+	case "send-pass":
+		secret, err := decodeSecret(s.input)
+		if err != nil {
+			warning("decode send-pass [%s] failed: %v", s.input, err)
+			return nil
+		}
+		return newPassSender(expect, secret)
+	case "send-otp":
+		return newPassSender(expect, getOtpCommandOutput(s.input))
+	case "send-enc-otp":
+		command, err := decodeSecret(s.input)
+		if err != nil {
+			warning("decode send-enc-otp [%s] failed: %v", s.input, err)
+			return nil
+		}
+		return newPassSender(expect, getOtpCommandOutput(command))
+	}
+	return nil
+}
+
+func (s *expectScript) exec(expect *sshExpect, writer io.Writer) {
+	for idx, step := range s.steps {
+		id := fmt.Sprintf("script %d", idx+1)
+		if step.pattern != "" {
+			debug("expect %s pattern: %s", id, step.pattern)
+			err := expect.waitForPattern(step.pattern, &caseSendList{expect, writer, nil}, step.timeout)
+			if err == errExpectStepTimeout {
+				warning("expect [%s] timeout after %v in %s", step.pattern, step.timeout, s.path)
+				return
+			}
+			if err != nil || expect.ctx.Err() != nil {
+				return
+			}
+		}
+		if step.kind == "" {
+			continue
+		}
+		if !step.getSender(expect).sendInput(writer, id) {
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExpectScript(t *testing.T) {
+	assert := assert.New(t)
+	steps, err := parseExpectScript(strings.NewReader(`
+# menu based bastion
+send "\r"
+timeout 5
+expect "*Select a server:*"
+send 3\r
+expect "*assword:*"
+send-pass encoded
+timeout 0
+expect '*$ '
+`))
+	assert.Nil(err)
+	assert.Equal([]*expectStep{
+		{"", "send", `\r`, 30 * time.Second},
+		{"*Select a server:*", "send", `3\r`, 5 * time.Second},
+		{"*assword:*", "send-pass", "encoded", 5 * time.Second},
+		{"*$ ", "", "", 0},
+	}, steps)
+
+	_, err = parseExpectScript(strings.NewReader("expect"))
+	assert.NotNil(err)
+	_, err = parseExpectScript(strings.NewReader("timeout abc"))
+	assert.NotNil(err)
+	_, err = parseExpectScript(strings.NewReader("sleep 1"))
+	assert.NotNil(err)
+}
+
+func TestExecExpectScript(t *testing.T) {
+	assert := assert.New(t)
+	steps, err := parseExpectScript(strings.NewReader(`
+timeout 1
+expect "*Select:*"
+send "2\r"
+expect "*Next:*"
+send "never\r"
+`))
+	assert.Nil(err)
+	// shorten the parsed timeout, so that the last expect doesn't wait for a second
+	for _, step := range steps {
+		assert.Equal(time.Second, step.timeout)
+		step.timeout = 50 * time.Millisecond
+	}
+
+	expect := &sshExpect{ctx: context.Background(), out: make(chan []byte, 10)}
+	expect.out <- []byte("1) web\r\n2) db\r\nSelect: ")
+	var writer bytes.Buffer
+	beginTime := time.Now()
+	(&expectScript{"test", steps}).exec(expect, &writer)
+	assert.Equal("2\r", writer.String())
+	assert.GreaterOrEqual(time.Since(beginTime), 50*time.Millisecond)
+}
@@ -0,0 +1,278 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type optionDoc struct {
+	name   string
+	syntax string
+	doc    string
+}
+
+// kOptionDocs documents the openssh options with tssh specific behaviors, and the tssh extended options.
+var kOptionDocs = []*optionDoc{
+	// openssh options
+	{"BatchMode", "BatchMode yes|no", "Never prompt for anything, including the destination chooser and the passwords.\nThe typo confirmation of the destination is skipped as well."},
+	{"Ciphers", "Ciphers [+-^]cipher[,cipher...]", "The ciphers in order of preference. If not set, AES-GCM is preferred if the CPU accelerates AES, or ChaCha20 otherwise, and the CBC ciphers are not offered.\nThe +, - and ^ prefixes append, remove and prepend to the default list as openssh does."},
+	{"ClearAllForwardings", "ClearAllForwardings yes|no", "Clear all the local, remote and dynamic forwardings of the config and the command line."},
+	{"ControlMaster", "ControlMaster yes|no|ask|auto|autoask", "Share the connection by the openssh master, tssh starts `ssh` as the master if the socket doesn't exist.\nNot supported on Windows."},
+	{"ControlPath", "ControlPath path|none", "The socket of the shared connection, the tokens such as %h, %p, %r and %C are expanded.\nIf it's not set, the connection of `tssh --daemon` is used if any."},
+	{"DynamicForward", "DynamicForward [bind_address:]port", "Serve a SOCKS proxy, and also an HTTP proxy and a PAC file on the same port by ExDynamicHttpProxy and ExDynamicPac."},
+	{"ForwardAgent", "ForwardAgent yes|no", "Forward the local agent to the server, the same as -A."},
+	{"GatewayPorts", "GatewayPorts yes|no", "Allow the remote hosts to connect to the local forwarded ports, the same as -g."},
+	{"GlobalKnownHostsFile", "GlobalKnownHostsFile path [path...]|none", "The system known hosts files, which are read only."},
+	{"HostName", "HostName host", "The real host name or IP address to log in, the tokens such as %h are expanded.\nMore candidates could be tried by ExHostNames."},
+	{"IdentityAgent", "IdentityAgent path|none", "The socket of the agent, or the named pipe on Windows, the tokens are expanded.\nSSH_AUTH_SOCK is used if it's not set."},
+	{"IdentityFile", "IdentityFile path", "The private key to log in, could be set multiple times.\nThe passphrase could be configured by Passphrase, or saved in the keychain by UseKeychain on macOS."},
+	{"KbdInteractiveAuthentication", "KbdInteractiveAuthentication yes|no", "Whether to use the keyboard interactive authentication, the questions could be answered by ExPromptRule."},
+	{"LocalCommand", "LocalCommand command", "Run the local command after login if PermitLocalCommand is yes, the tokens are expanded."},
+	{"LocalForward", "LocalForward [bind_address:]port|local_socket host:hostport|remote_socket", "Forward the local port or UNIX socket to the remote host or socket, the clients could be limited by ExForwardAllow.\nThe tokens such as %d, %h, %n and %r are expanded in the socket paths."},
+	{"LogLevel", "LogLevel QUIET|FATAL|ERROR|INFO|VERBOSE|DEBUG|DEBUG1|DEBUG2|DEBUG3", "QUIET, FATAL and ERROR hide the warnings of tssh, and DEBUG shows the debug logs as -v does."},
+	{"NumberOfPasswordPrompts", "NumberOfPasswordPrompts count", "The number of times to ask for the password, 3 by default."},
+	{"PasswordAuthentication", "PasswordAuthentication yes|no", "Whether to use the password authentication, the password could be configured by Password."},
+	{"PermitLocalCommand", "PermitLocalCommand yes|no", "Whether to run the LocalCommand."},
+	{"PermitRemoteOpen", "PermitRemoteOpen host:port [host:port...]|any|none", "The destinations allowed to be connected by the remote dynamic forwarding."},
+	{"Port", "Port port", "The port to log in, 22 by default, or the port of the SRV record if ExSrvLookup is yes."},
+	{"ProxyCommand", "ProxyCommand command|none", "The command to connect to the server, the tokens such as %h and %p are expanded."},
+	{"ProxyJump", "ProxyJump [user@]host[:port][,[user@]host[:port]...]|none", "The jump hosts to connect through in order, each of them uses its own configuration in ~/.ssh/config."},
+	{"PubkeyAuthentication", "PubkeyAuthentication yes|no", "Whether to use the public key authentication."},
+	{"RemoteCommand", "RemoteCommand command|none", "The command to run on the server, the tokens are expanded, and the {{name}} placeholders are replaced by --var name=value, or asked interactively if not given."},
+	{"RemoteForward", "RemoteForward [bind_address:]port|remote_socket host:hostport|local_socket", "Forward the remote port or UNIX socket to the local host or socket, or serve a SOCKS proxy on the remote port without host:hostport.\nThe tokens such as %d, %h, %n and %r are expanded in the socket paths."},
+	{"RequestTTY", "RequestTTY yes|no|force|auto", "Whether to request a pty for the session, auto requests it if there is no command and stdin is a terminal."},
+	{"SendEnv", "SendEnv pattern [pattern...]", "The local environment variables to send, the locale ones are replaced by ExLocale if it's set."},
+	{"ServerAliveCountMax", "ServerAliveCountMax count", "The keep alive messages without response before disconnecting, 3 by default."},
+	{"ServerAliveInterval", "ServerAliveInterval seconds", "The interval to send the keep alive messages, 10 seconds by default, which is 0 for openssh."},
+	{"SetEnv", "SetEnv name=value [name=value...]", "The environment variables to set on the server."},
+	{"StreamLocalBindUnlink", "StreamLocalBindUnlink yes|no", "Remove the existing unix socket before listening on it for the forwarding."},
+	{"StrictHostKeyChecking", "StrictHostKeyChecking yes|no|ask|accept-new", "How to check the unknown host keys, the host keys could also be pinned by ExPinnedHostKey."},
+	{"User", "User user", "The user to log in, which could be overridden by -l, user@host or --as."},
+	{"UserKnownHostsFile", "UserKnownHostsFile path [path...]|none", "The user known hosts files, the new host keys are added to the first one."},
+	// tssh options
+	{"GroupLabels", "GroupLabels label [label...]", "The labels to group the hosts, for the search of the chooser, --status, --list-hosts and the batch tools.\nThe hosts labeled prod are protected by ExConfirmPatterns and ExPasteConfirm."},
+	{"EnableTrzsz", "EnableTrzsz yes|no", "Whether to support trz / tsz, yes by default."},
+	{"EnableZmodem", "EnableZmodem yes|no", "Whether to support rz / sz by the local lrzsz, the same as --zmodem."},
+	{"EnableDragFile", "EnableDragFile yes|no", "Whether to upload the files dragged into the terminal, the same as --dragfile."},
+	{"UseKeychain", "UseKeychain yes|no", "Save the passphrase of the private key in the keychain, only supported on macOS."},
+	{"Password", "Password password", "The password to log in, it's recommended to be encoded by `tssh --enc-secret` as encPassword,\nor stored in the vault as vaultPassword."},
+	{"Passphrase", "Passphrase passphrase", "The passphrase of the private keys, recommended to be encoded as encPassphrase."},
+	{"ExpectCount", "ExpectCount count", "The number of the interactions after login, configured by ExpectPattern<N> and ExpectSend*<N>."},
+	{"ExpectTimeout", "ExpectTimeout seconds", "The timeout of each expected pattern, 30 seconds by default."},
+	{"ExpectPattern", "ExpectPattern<N> pattern", "The output to wait for in the N-th interaction after login."},
+	{"ExpectSendPass", "ExpectSendPass<N> encoded_password", "Send the password encoded by `tssh --enc-secret` after ExpectPattern<N> is matched."},
+	{"ExpectSendText", "ExpectSendText<N> text", "Send the text after ExpectPattern<N> is matched, \\r is the enter key."},
+	{"ExpectSendOtp", "ExpectSendOtp<N> command", "Send the output of the command, e.g., `oathtool --totp -b xxx`, after ExpectPattern<N> is matched."},
+	{"ExpectSendEncOtp", "ExpectSendEncOtp<N> encoded_command", "The same as ExpectSendOtp<N>, but the command is encoded by `tssh --enc-secret`."},
+	{"ExpectCaseSendPass", "ExpectCaseSendPass<N> pattern encoded_password", "Send the password when the pattern is matched while waiting for ExpectPattern<N>, could be set multiple times."},
+	{"ExpectCaseSendText", "ExpectCaseSendText<N> pattern text", "Send the text when the pattern is matched while waiting for ExpectPattern<N>, could be set multiple times."},
+	// tssh extended options
+	{"ExAskPassCommand", "ExAskPassCommand command", "The external program to read the secrets, it's always used if configured.\nOtherwise SSH_ASKPASS is used as openssh does."},
+	{"ExAutoAttach", "ExAutoAttach tmux|screen|no", "Attach to the multiplexer session after login, or create it, so the work is not lost when the connection drops."},
+	{"ExBackgroundColor", "ExBackgroundColor #rrggbb|red|green|yellow|blue|magenta|cyan|gray", "Tint the terminal background during the session, the named colors are dark tints to keep the text readable."},
+	{"ExBootstrapFiles", "ExBootstrapFiles path [path...]", "Upload the local files, e.g., ~/.vimrc, to the same paths under the remote home after login if they are changed."},
+	{"ExColorDowngrade", "ExColorDowngrade 256|16|no", "Translate the true colors of the remote output for the local terminal which can't render them."},
+	{"ExConfirmPatterns", "ExConfirmPatterns regexp", "Ask for a confirmation before sending the typed command line which matches the pattern to the hosts labeled prod.\nCould be set multiple times."},
+	{"ExConsoleCommand", "ExConsoleCommand command", "The command to run on the console gateway, e.g., `console -f server1`, with ProxyJump as the gateway."},
+	{"ExConsoleEscape", "ExConsoleEscape keys", "The escape keys of the console session, ^] by default."},
+	{"ExDeviceType", "ExDeviceType cisco|juniper|huawei", "Turn off the paging of the network device and normalize the line endings of the output."},
+	{"ExDnsCacheTTL", "ExDnsCacheTTL seconds", "Cache the resolved addresses of ExDnsServers, 60 seconds by default."},
+	{"ExDnsServers", "ExDnsServers server [server...]", "Resolve the host by the dns servers, such as 1.1.1.1:53 or a DoH URL like https://cloudflare-dns.com/dns-query."},
+	{"ExDynamicHttpProxy", "ExDynamicHttpProxy yes|no", "Serve an HTTP proxy on the DynamicForward port as well, for the apps which can't speak SOCKS."},
+	{"ExDynamicPac", "ExDynamicPac pattern[, pattern...]|all", "Serve /proxy.pac on the DynamicForward port, which routes the matched hosts through the proxy."},
+	{"ExEventHook", "ExEventHook command", "Run the local command on the events such as transfer_done, with TSSH_EVENT, TSSH_HOST and other TSSH_ variables."},
+	{"ExExtraConfig", "ExExtraConfig path", "The extra config files layered under the main config, only read from the main config."},
+	{"ExForwardAllow", "ExForwardAllow [port] cidr[, cidr...]", "Limit the clients allowed to connect to the -L and -D listeners, the loopback addresses are always allowed."},
+	{"ExForwards", "ExForwards name [name...]", "Attach the forwarding presets defined by Forwards.<name> in ~/.tssh.conf."},
+	{"ExHostNames", "ExHostNames host[:port] [host[:port]...]", "The candidate addresses to try after HostName, a candidate without a port uses the port of the login."},
+	{"ExHostNamesMode", "ExHostNamesMode sequential|parallel", "Try the HostName and the ExHostNames candidates one by one, or all at once and use the first connected."},
+	{"ExIdentityPicker", "ExIdentityPicker yes|no", "Let the user pick the key to try first when the authentication fails, in case it's never offered due to MaxAuthTries."},
+	{"ExIdleAction", "ExIdleAction close|lock", "Close or lock the session after ExIdleTimeout, lock requires ExIdleLockPassword."},
+	{"ExIdleLockPassword", "ExIdleLockPassword password", "The passphrase to unlock the idle session, recommended to be encoded as encExIdleLockPassword."},
+	{"ExIdleTimeout", "ExIdleTimeout minutes|duration", "The time without keyboard input before ExIdleAction, e.g., 15 or 90s.\nThe running trz / tsz and queued transfers keep the session from being idle."},
+	{"ExLocale", "ExLocale locale", "Send LANG and LC_ALL as the locale, e.g., en_US.UTF-8, which replace the local locale envs sent by SendEnv."},
+	{"ExOidcCertCommand", "ExOidcCertCommand command", "The command which reads the public key from stdin and prints the certificate, instead of ExOidcSignURL."},
+	{"ExOidcClientID", "ExOidcClientID id", "The client id of the OIDC based SSH CA."},
+	{"ExOidcClientSecret", "ExOidcClientSecret secret", "The client secret of the OIDC based SSH CA, optional, recommended to be encoded as encExOidcClientSecret."},
+	{"ExOidcIssuer", "ExOidcIssuer url", "The OIDC issuer to sign in, the certificate of the generated key is kept in memory only."},
+	{"ExOidcSignURL", "ExOidcSignURL url", "The URL to sign the public key with the ID token, like the OIDC provisioner of step-ca."},
+	{"ExPasteConfirm", "ExPasteConfirm yes|no", "Ask for a confirmation before sending the multi-line pastes to the hosts labeled prod."},
+	{"ExPasteProtect", "ExPasteProtect yes|no", "Wrap the pastes in the bracketed paste if the remote side enabled it but the local terminal doesn't send it."},
+	{"ExPasteRate", "ExPasteRate bytes", "Limit the bytes per second of the pastes which are not bracketed."},
+	{"ExPinnedHostKey", "ExPinnedHostKey SHA256:fingerprint", "Only accept the host keys of the fingerprints, could be set multiple times."},
+	{"ExPortKnock", "ExPortKnock port[/tcp|/udp] [port...]", "Knock the ports in order before connecting."},
+	{"ExPortKnockDelay", "ExPortKnockDelay milliseconds", "The delay between the knocks and before connecting, 200 by default."},
+	{"ExPreferredKey", "ExPreferredKey fingerprint", "The key to offer first, it's saved in ~/.tssh.conf as PreferredKey.<alias> by the identity picker."},
+	{"ExPromptRule", "ExPromptRule regexp secret:<key>|env:<name>|cmd:<command>|text:<text>|ask", "Answer the keyboard interactive questions matched by the regexp, could be set multiple times."},
+	{"ExRecordHistory", "ExRecordHistory yes|no", "Record the commands run on the host to ~/.ssh/tssh-history.jsonl, for `tssh --history`."},
+	{"ExRemoteShell", "ExRemoteShell shell [args...]", "Run the remote command by the shell with -c, e.g., `bash -l`, -l is added by --login-shell."},
+	{"ExShortcut", "ExShortcut name", "A short name to log in to the host, e.g., `tssh 1`, which is shown in the chooser.\nThe configured aliases take precedence, and the names with any of `.:@[]` are taken as hosts rather than shortcuts."},
+	{"ExSrvLookup", "ExSrvLookup yes|no", "Look up _ssh._tcp.<host> for the target and port if the port is not specified."},
+	{"ExStatusLine", "ExStatusLine yes|no", "Show the host, the user and the verified host key on the bottom row, which the remote side can't forge."},
+	{"ExStderr", "ExStderr separate|merge", "Write the remote stderr lines to stdout in the non-tty mode, without breaking any line."},
+	{"ExStderrColor", "ExStderrColor color", "The color of the stderr lines if writing to a terminal, e.g., red."},
+	{"ExStderrPrefix", "ExStderrPrefix prefix", "The prefix of each stderr line, followed by a space."},
+	{"ExTerm", "ExTerm term", "The TERM sent to the server, for the hosts which misrender the modern terminfo entries."},
+	{"ExTmuxControlMode", "ExTmuxControlMode auto|yes|no", "Attach by `tmux -CC` for ExAutoAttach tmux, auto enables it in iTerm2 only."},
+	{"ExTmuxSession", "ExTmuxSession name", "The session name of ExAutoAttach, tssh by default."},
+	{"ExTmuxTitles", "ExTmuxTitles yes|no", "Set the tmux window titles as the local terminal title."},
+	{"ExTransferKey", "ExTransferKey keys", "The keys to show the transfer queue menu, e.g., ^T^T, to get, put and sync files in the background."},
+	{"ExTransferParallel", "ExTransferParallel count", "Split the large queued transfers into chunks over the sessions."},
+	{"ExTransferParallelSize", "ExTransferParallelSize size", "Only split the files not smaller than the size, 64M by default."},
+	{"ExTransferRate", "ExTransferRate bytes|unlimited", "The bytes per second of the queued transfers outside the windows of ExTransferSchedule."},
+	{"ExTransferSchedule", "ExTransferSchedule HH:MM-HH:MM bytes|unlimited", "The rate of the queued transfers in the time window, 0 defers them, could be set multiple times. trz / tsz are not scheduled."},
+	{"ExTransferScheduleSize", "ExTransferScheduleSize size", "Only schedule the transfers not smaller than the size."},
+	{"ExTransparentProxy", "ExTransparentProxy port", "Accept the connections redirected by iptables to the port, and proxy them through ssh, only supported on Linux."},
+	{"ExTrzszCheck", "ExTrzszCheck no|hint|ask", "Check whether trz / tsz is installed on the server after login, and show a hint or offer to install it.\nThe offer is skipped in BatchMode."},
+	{"ExTrzszDownloadProxy", "ExTrzszDownloadProxy ssh|socks5://host:port|http://host:port", "The proxy to download trzsz for --install-trzsz, ssh downloads from the network of the server."},
+	{"ExTrzszMirror", "ExTrzszMirror url", "The mirror of the trzsz releases, with the same layout as GitHub releases, and <url>/latest with the latest version."},
+	{"ExTrzszProgress", "ExTrzszProgress auto|yes|no", "Report the transfers of trz / tsz to stderr where the progress bar is not shown."},
+	{"ExTrzszTunnel", "ExTrzszTunnel yes|no", "Whether trz / tsz transfer through the tunnel port of the server, no always transfers through the terminal."},
+	{"ExTrzszTunnelFallback", "ExTrzszTunnelFallback quiet|warn", "Warn when the transfer falls back to the terminal."},
+	{"ExTrzszTunnelHost", "ExTrzszTunnelHost address", "The address of the tunnel listener on the server, 127.0.0.1 by default."},
+	{"ExTrzszTunnelPorts", "ExTrzszTunnelPorts port|begin-end [...]", "The tunnel ports to connect, the others go through the terminal at once."},
+	{"ExTrzszTunnelRetries", "ExTrzszTunnelRetries count", "The retries after the first tunnel connection attempt fails, 0 by default."},
+	{"ExTrzszTunnelTimeout", "ExTrzszTunnelTimeout duration", "The timeout of each tunnel connection attempt, 1s by default."},
+	{"ExVaultAddr", "ExVaultAddr url", "The address of HashiCorp Vault for ExVaultRole, VAULT_ADDR by default."},
+	{"ExVaultMount", "ExVaultMount path", "The mount path of the Vault SSH secrets engine, ssh by default."},
+	{"ExVaultRole", "ExVaultRole role", "Sign the key, or get the one-time password by ExVaultType otp, from the Vault SSH secrets engine."},
+	{"ExVaultType", "ExVaultType otp", "Get the one-time password from Vault to log in, instead of signing the key."},
+	{"ExWatermark", "ExWatermark yes|minutes", "Print a marker with the alias, the host and the time into the scrollback on connect, and every N minutes."},
+	{"ExZmodemOptions", "ExZmodemOptions window=N escape=yes|no binary=yes|no|auto buffer=N", "Tune the local lrzsz launched for rz / sz, for the embedded lrzsz builds."},
+}
+
+// kExOptionNames lists the tssh extended options, each of which should be documented in kOptionDocs.
+// Add the new extended option here when it's read by getExConfig or getExOptionConfig.
+var kExOptionNames = []string{
+	"ExAskPassCommand", "ExAutoAttach", "ExBackgroundColor", "ExBootstrapFiles", "ExColorDowngrade",
+	"ExConfirmPatterns", "ExConsoleCommand", "ExConsoleEscape", "ExDeviceType", "ExDnsCacheTTL", "ExDnsServers",
+	"ExDynamicHttpProxy", "ExDynamicPac", "ExEventHook", "ExExtraConfig", "ExForwardAllow", "ExForwards",
+	"ExHostNames", "ExHostNamesMode", "ExIdentityPicker", "ExIdleAction", "ExIdleLockPassword", "ExIdleTimeout",
+	"ExLocale", "ExOidcCertCommand", "ExOidcClientID", "ExOidcClientSecret", "ExOidcIssuer", "ExOidcSignURL",
+	"ExPasteConfirm", "ExPasteProtect", "ExPasteRate", "ExPinnedHostKey", "ExPortKnock", "ExPortKnockDelay",
+	"ExPreferredKey", "ExPromptRule", "ExRecordHistory", "ExRemoteShell", "ExShortcut", "ExSrvLookup",
+	"ExStatusLine", "ExStderr", "ExStderrColor", "ExStderrPrefix", "ExTerm", "ExTmuxControlMode",
+	"ExTmuxSession", "ExTmuxTitles", "ExTransferKey", "ExTransferParallel", "ExTransferParallelSize",
+	"ExTransferRate", "ExTransferSchedule", "ExTransferScheduleSize", "ExTransparentProxy", "ExTrzszCheck",
+	"ExTrzszDownloadProxy", "ExTrzszMirror", "ExTrzszProgress", "ExTrzszTunnel", "ExTrzszTunnelFallback",
+	"ExTrzszTunnelHost", "ExTrzszTunnelPorts", "ExTrzszTunnelRetries", "ExTrzszTunnelTimeout", "ExVaultAddr",
+	"ExVaultMount", "ExVaultRole", "ExVaultType", "ExWatermark", "ExZmodemOptions", "ExpectCaseSendPass",
+	"ExpectCaseSendText", "ExpectCount", "ExpectPattern", "ExpectSendEncOtp", "ExpectSendOtp", "ExpectSendPass",
+	"ExpectSendText", "ExpectTimeout",
+}
+
+// getOptionDoc returns the doc of the option case-insensitively, the index suffix of the expect options,
+// e.g., ExpectPattern2, and the enc / vault prefix of the secret options, e.g., encPassword, are accepted.
+func getOptionDoc(name string) (doc *optionDoc, secret string) {
+	find := func(name string) *optionDoc {
+		for _, doc := range kOptionDocs {
+			if strings.EqualFold(doc.name, name) {
+				return doc
+			}
+		}
+		return nil
+	}
+	if doc := find(name); doc != nil {
+		return doc, ""
+	}
+	if trimmed := strings.TrimRight(name, "0123456789"); trimmed != name {
+		if doc := find(trimmed); doc != nil && strings.HasPrefix(strings.ToLower(trimmed), "expect") {
+			return doc, ""
+		}
+	}
+	for _, prefix := range []string{"enc", "vault"} {
+		if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			if doc, _ := getOptionDoc(name[len(prefix):]); doc != nil {
+				return doc, prefix
+			}
+		}
+	}
+	return nil, ""
+}
+
+// getSimilarOptions returns the option names which are likely mistyped as the name.
+func getSimilarOptions(name string) []string {
+	var names []string
+	name = strings.ToLower(name)
+	for _, doc := range kOptionDocs {
+		option := strings.ToLower(doc.name)
+		if strings.Contains(option, name) || editDistance(name, option) <= 2 {
+			names = append(names, doc.name)
+		}
+	}
+	return names
+}
+
+func printOptionDoc(writer io.Writer, doc *optionDoc, secret string) {
+	fmt.Fprintf(writer, "%s\n\n    %s\n\n", doc.name, doc.syntax)
+	for _, line := range strings.Split(doc.doc, "\n") {
+		fmt.Fprintf(writer, "%s\n", line)
+	}
+	switch secret {
+	case "enc":
+		fmt.Fprintf(writer, "enc%s is the value encoded by `tssh --enc-secret`.\n", doc.name)
+	case "vault":
+		fmt.Fprintf(writer, "vault%s is the name of the secret stored by `tssh --vault add <name>`.\n", doc.name)
+	}
+	if strings.HasPrefix(doc.name, "Ex") {
+		fmt.Fprintf(writer, "It could be written as `#!! %s` in ~/.ssh/config to be ignored by openssh.\n", doc.syntax)
+	}
+}
+
+// execExplain prints the syntax and the tssh specific behavior of the option, e.g., tssh --explain ProxyJump
+func execExplain(args *sshArgs) (int, bool) {
+	if strings.ToLower(args.Explain) == "list" {
+		var names []string
+		for _, doc := range kOptionDocs {
+			names = append(names, doc.name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stdout, "%s\n", name)
+		}
+		return 0, true
+	}
+	doc, secret := getOptionDoc(args.Explain)
+	if doc == nil {
+		if option, hint, ok := getUnsupportedOption(args.Explain); ok {
+			toolsWarn("Explain", "%s is an OpenSSH option which tssh does not support, it is ignored", option)
+			if hint != "" {
+				toolsWarn("Explain", "%s", hint)
+			}
+			return 0, true
+		}
+		if similar := getSimilarOptions(args.Explain); len(similar) > 0 {
+			toolsErrorExit("unknown option [%s], did you mean %s?", args.Explain, strings.Join(similar, ", "))
+		}
+		toolsErrorExit("unknown option [%s], `tssh --explain list` lists all the options", args.Explain)
+	}
+	printOptionDoc(os.Stdout, doc, secret)
+	return 0, true
+}
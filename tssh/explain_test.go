@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionDoc(t *testing.T) {
+	assert := assert.New(t)
+	getName := func(name string) string {
+		doc, secret := getOptionDoc(name)
+		if doc == nil {
+			return ""
+		}
+		return secret + doc.name
+	}
+	assert.Equal("ProxyJump", getName("ProxyJump"))
+	assert.Equal("ProxyJump", getName("proxyjump"))
+	assert.Equal("ExTrzszTunnel", getName("extrzsztunnel"))
+	assert.Equal("ExpectPattern", getName("ExpectPattern2"))
+	assert.Equal("ExpectSendText", getName("ExpectSendText12"))
+	assert.Equal("encPassword", getName("encPassword"))
+	assert.Equal("vaultPassword", getName("vaultPassword"))
+	assert.Equal("encExIdleLockPassword", getName("encExIdleLockPassword"))
+	assert.Equal("", getName("Port2"))
+	assert.Equal("", getName("ProxyJmp"))
+	assert.Equal([]string{"ProxyJump"}, getSimilarOptions("ProxyJmp"))
+	assert.Equal([]string{"ExTrzszTunnel", "ExTrzszTunnelFallback", "ExTrzszTunnelHost", "ExTrzszTunnelPorts",
+		"ExTrzszTunnelRetries", "ExTrzszTunnelTimeout"}, getSimilarOptions("trzsztunnel"))
+
+	var output bytes.Buffer
+	doc, secret := getOptionDoc("encExOidcClientSecret")
+	printOptionDoc(&output, doc, secret)
+	assert.Equal("ExOidcClientSecret\n\n    ExOidcClientSecret secret\n\n"+
+		"The client secret of the OIDC based SSH CA, optional, recommended to be encoded as encExOidcClientSecret.\n"+
+		"encExOidcClientSecret is the value encoded by `tssh --enc-secret`.\n"+
+		"It could be written as `#!! ExOidcClientSecret secret` in ~/.ssh/config to be ignored by openssh.\n", output.String())
+}
+
+func TestOptionDocsComplete(t *testing.T) {
+	assert := assert.New(t)
+	names := make(map[string]bool)
+	for _, doc := range kOptionDocs {
+		assert.False(names[doc.name], doc.name)
+		names[doc.name] = true
+		assert.True(strings.HasPrefix(doc.syntax, doc.name), doc.name)
+	}
+	// all the extended options should be documented, and all the documented extended options should be listed
+	exNames := make(map[string]bool)
+	for _, name := range kExOptionNames {
+		assert.False(exNames[name], name)
+		exNames[name] = true
+		assert.True(names[name], name)
+	}
+	for name := range names {
+		if strings.HasPrefix(name, "Ex") {
+			assert.True(exNames[name], name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// getHostCandidates returns the addresses to connect to, the HostName first, followed by
+// the ExHostNames candidates in order, e.g., an internal IP, a VPN IP, and a public address:
+//
+//	Host office
+//	    HostName 192.168.1.10
+//	    ExHostNames 10.8.0.10 office.example.com:2222
+//	    ExHostNamesMode parallel
+//
+// A candidate without a port uses the port of the login.
+func getHostCandidates(args *sshArgs, param *sshParam) []string {
+	addrs := []string{param.addr}
+	for _, value := range getAllExOptionConfig(args, "ExHostNames") {
+		for _, candidate := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+			addr := candidate
+			if _, _, err := net.SplitHostPort(candidate); err != nil {
+				addr = joinHostPort(strings.Trim(candidate, "[]"), param.port)
+			}
+			if !containsString(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+type dialResult struct {
+	conn net.Conn
+	addr string
+	err  error
+}
+
+// dialHostCandidates tries the candidates one by one, or all at once if ExHostNamesMode is parallel,
+// and returns the first connection which is established, along with its address.
+func dialHostCandidates(args *sshArgs, addrs []string, dial func(addr string) (net.Conn, error)) (net.Conn, string, error) {
+	if len(addrs) == 1 {
+		conn, err := dial(addrs[0])
+		return conn, addrs[0], err
+	}
+
+	var errs []string
+	if strings.ToLower(getExOptionConfig(args, "ExHostNamesMode")) != "parallel" {
+		for _, addr := range addrs {
+			conn, err := dial(addr)
+			if err == nil {
+				return conn, addr, nil
+			}
+			debug("dial candidate [%s] failed: %v", addr, err)
+			errs = append(errs, fmt.Sprintf("[%s] %v", addr, err))
+		}
+		return nil, "", fmt.Errorf("all the candidates failed: %s", strings.Join(errs, ", "))
+	}
+
+	results := make(chan *dialResult, len(addrs))
+	for _, addr := range addrs {
+		go func(addr string) {
+			conn, err := dial(addr)
+			results <- &dialResult{conn, addr, err}
+		}(addr)
+	}
+	var winner *dialResult
+	for i := 0; i < len(addrs); i++ {
+		result := <-results
+		if result.err != nil {
+			debug("dial candidate [%s] failed: %v", result.addr, result.err)
+			errs = append(errs, fmt.Sprintf("[%s] %v", result.addr, result.err))
+			continue
+		}
+		winner = result
+		break
+	}
+	if winner == nil {
+		return nil, "", fmt.Errorf("all the candidates failed: %s", strings.Join(errs, ", "))
+	}
+	// close the connections which are established later
+	go func(pending int) {
+		for i := 0; i < pending; i++ {
+			if result := <-results; result.conn != nil {
+				result.conn.Close()
+			}
+		}
+	}(len(addrs) - len(errs) - 1)
+	return winner.conn, winner.addr, nil
+}
+
+// useHostCandidate updates the login address to the candidate which is connected.
+func useHostCandidate(param *sshParam, addr string) {
+	if addr == param.addr {
+		return
+	}
+	debug("connected to the candidate [%s] instead of [%s]", addr, param.addr)
+	param.addr = addr
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		param.host, param.port = host, port
+	}
+}
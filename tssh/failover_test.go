@@ -0,0 +1,74 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostCandidates(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options ...string) *sshArgs {
+		args := &sshArgs{}
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return args
+	}
+	param := &sshParam{host: "192.168.1.10", port: "22", addr: "192.168.1.10:22"}
+	assert.Equal([]string{"192.168.1.10:22"}, getHostCandidates(newArgs(), param))
+	assert.Equal([]string{"192.168.1.10:22", "10.8.0.10:22", "office.example.com:2222", "[::1]:22"},
+		getHostCandidates(newArgs("ExHostNames 10.8.0.10, office.example.com:2222 192.168.1.10 [::1]"), param))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	good := listener.Addr().String()
+	dial := func(addr string) (net.Conn, error) {
+		if addr != good {
+			return nil, fmt.Errorf("unreachable")
+		}
+		return net.Dial("tcp", addr)
+	}
+
+	for _, args := range []*sshArgs{newArgs(), newArgs("ExHostNamesMode parallel")} {
+		conn, addr, err := dialHostCandidates(args, []string{"10.0.0.1:22", good, "10.0.0.2:22"}, dial)
+		assert.Nil(err)
+		assert.Equal(good, addr)
+		conn.Close()
+
+		_, _, err = dialHostCandidates(args, []string{"10.0.0.1:22", "10.0.0.2:22"}, dial)
+		assert.NotNil(err)
+		assert.Contains(err.Error(), "[10.0.0.1:22] unreachable")
+		assert.Contains(err.Error(), "[10.0.0.2:22] unreachable")
+	}
+
+	useHostCandidate(param, "office.example.com:2222")
+	assert.Equal(&sshParam{host: "office.example.com", port: "2222", addr: "office.example.com:2222"}, param)
+}
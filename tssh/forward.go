@@ -48,11 +48,14 @@ type bindCfg struct {
 }
 
 type forwardCfg struct {
-	argument string
-	bindAddr *string
-	bindPort int
-	destHost string
-	destPort int
+	argument   string
+	bindAddr   *string
+	bindPort   int
+	destHost   string
+	destPort   int
+	bindSocket string
+	destSocket string
+	dynamic    bool
 }
 
 var spaceRegexp = regexp.MustCompile(`\s+`)
@@ -108,36 +111,103 @@ func parseForwardCfg(s string) (*forwardCfg, error) {
 		return nil, fmt.Errorf("invalid forward config: %s", s)
 	}
 
+	if f := parseSocketForward(s, tokens[0], tokens[1]); f != nil {
+		return f, nil
+	}
+
 	bindCfg, err := parseBindCfg(tokens[0])
 	if err != nil {
 		return nil, fmt.Errorf("invalid forward config: %s", s)
 	}
 
-	newForwardCfg := func(host string, port string) (*forwardCfg, error) {
-		dPort, err := strconv.Atoi(port)
+	host, port, err := parseForwardDest(tokens[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid forward config: %s", s)
+	}
+	return &forwardCfg{argument: s, bindAddr: bindCfg.addr, bindPort: bindCfg.port, destHost: host, destPort: port}, nil
+}
+
+// parseRemoteForwardCfg also accepts `RemoteForward [bind_address:]port`, which makes tssh a SOCKS proxy for the remote side.
+func parseRemoteForwardCfg(s string) (*forwardCfg, error) {
+	if tokens := strings.Fields(s); len(tokens) == 1 {
+		if b, err := parseBindCfg(tokens[0]); err == nil {
+			return &forwardCfg{argument: strings.TrimSpace(s), bindAddr: b.addr, bindPort: b.port, dynamic: true}, nil
+		}
+	}
+	return parseForwardCfg(s)
+}
+
+func parseForwardDest(dest string) (string, int, error) {
+	newForwardDest := func(host string, port string) (string, int, error) {
+		p, err := strconv.Atoi(port)
 		if err != nil {
-			return nil, fmt.Errorf("invalid forward config [%s]: %v", s, err)
+			return "", 0, err
 		}
-		return &forwardCfg{s, bindCfg.addr, bindCfg.port, host, dPort}, nil
+		return host, p, nil
 	}
 
-	dest := tokens[1]
-	tokens = strings.Split(dest, "/")
+	tokens := strings.Split(dest, "/")
 	if len(tokens) == 2 && portOnlyRegexp.MatchString(tokens[1]) {
-		return newForwardCfg(tokens[0], tokens[1])
+		return newForwardDest(tokens[0], tokens[1])
 	}
 
 	match := ipv6AndPortRegexp.FindStringSubmatch(dest)
 	if len(match) == 3 {
-		return newForwardCfg(match[1], match[2])
+		return newForwardDest(match[1], match[2])
 	}
 
 	tokens = strings.Split(dest, ":")
 	if len(tokens) == 2 && portOnlyRegexp.MatchString(tokens[1]) {
-		return newForwardCfg(tokens[0], tokens[1])
+		return newForwardDest(tokens[0], tokens[1])
+	}
+
+	return "", 0, fmt.Errorf("invalid forward destination: %s", dest)
+}
+
+// isSocketPath tells the UNIX socket paths from the `[bind_address/]port` and `host/hostport` forms.
+func isSocketPath(s string) bool {
+	for _, prefix := range []string{"/", "~/", "./", "../", "%d/"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
 	}
+	return false
+}
 
-	return nil, fmt.Errorf("invalid forward config: %s", s)
+// parseSocketForward parses the forwards with UNIX sockets on either or both sides, e.g.,
+// `/tmp/local.sock host:port`, `[bind_address:]port /run/remote.sock`, `/tmp/local.sock /run/remote.sock`.
+// It returns nil if neither side is a socket path, or the other side is invalid.
+func parseSocketForward(argument, bind, dest string) *forwardCfg {
+	bindSocket, destSocket := isSocketPath(bind), isSocketPath(dest)
+	if !bindSocket && !destSocket {
+		return nil
+	}
+	f := &forwardCfg{argument: argument}
+	if bindSocket {
+		if _, err := parseBindCfg(bind); err == nil {
+			return nil
+		}
+		f.bindSocket = bind
+	} else {
+		b, err := parseBindCfg(bind)
+		if err != nil {
+			return nil
+		}
+		f.bindAddr, f.bindPort = b.addr, b.port
+	}
+	if destSocket {
+		if _, _, err := parseForwardDest(dest); err == nil {
+			return nil
+		}
+		f.destSocket = dest
+	} else {
+		host, port, err := parseForwardDest(dest)
+		if err != nil {
+			return nil
+		}
+		f.destHost, f.destPort = host, port
+	}
+	return f
 }
 
 func parseForwardArg(s string) (*forwardCfg, error) {
@@ -156,7 +226,7 @@ func parseForwardArg(s string) (*forwardCfg, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid forward specification [%s]: %v", s, err)
 		}
-		return &forwardCfg{s, bindAddr, bPort, destHost, dPort}, nil
+		return &forwardCfg{argument: s, bindAddr: bindAddr, bindPort: bPort, destHost: destHost, destPort: dPort}, nil
 	}
 
 	tokens := strings.Split(s, "/")
@@ -192,34 +262,57 @@ func parseForwardArg(s string) (*forwardCfg, error) {
 		return newForwardCfg(&tokens[0], tokens[1], tokens[2], tokens[3])
 	}
 
+	for i, c := range s {
+		if c != ':' {
+			continue
+		}
+		if f := parseSocketForward(s, s[:i], s[i+1:]); f != nil {
+			return f, nil
+		}
+	}
+
 	return nil, fmt.Errorf("invalid forward specification: %s", s)
 }
 
+// parseRemoteForwardArg also accepts `-R [bind_address:]port`, which makes tssh a SOCKS proxy for the remote side.
+func parseRemoteForwardArg(s string) (*forwardCfg, error) {
+	if b, err := parseBindCfg(s); err == nil {
+		return &forwardCfg{argument: b.argument, bindAddr: b.addr, bindPort: b.port, dynamic: true}, nil
+	}
+	return parseForwardArg(s)
+}
+
 func isGatewayPorts(args *sshArgs) bool {
 	return args.Gateway || strings.ToLower(getConfig(args.Destination, "GatewayPorts")) == "yes"
 }
 
 func listenOnLocal(args *sshArgs, addr *string, port string) (listeners []net.Listener) {
-	listen := func(network, address string) {
+	listen := func(network, host string) {
+		address := joinHostPort(host, port)
 		listener, err := net.Listen(network, address)
 		if err != nil {
 			debug("forward listen on local '%s' failed: %v", address, err)
 		} else {
-			debug("forward listen on local '%s' success", address)
+			debug("forward listen on local '%s' success", listener.Addr())
+			closeOnTerminate(listener)
 			listeners = append(listeners, listener)
+			// listen on the same port allocated by the system for the other addresses
+			if tcpAddr, ok := listener.Addr().(*net.TCPAddr); ok && port == "0" {
+				port = strconv.Itoa(tcpAddr.Port)
+			}
 		}
 	}
 	if addr == nil && isGatewayPorts(args) || addr != nil && (*addr == "" || *addr == "*") {
-		listen("tcp4", joinHostPort("0.0.0.0", port))
-		listen("tcp6", joinHostPort("::", port))
+		listen("tcp4", "0.0.0.0")
+		listen("tcp6", "::")
 		return
 	}
 	if addr == nil {
-		listen("tcp4", joinHostPort("127.0.0.1", port))
-		listen("tcp6", joinHostPort("::1", port))
+		listen("tcp4", "127.0.0.1")
+		listen("tcp6", "::1")
 		return
 	}
-	listen("tcp", joinHostPort(*addr, port))
+	listen("tcp", *addr)
 	return
 }
 
@@ -230,6 +323,7 @@ func listenOnRemote(args *sshArgs, client *ssh.Client, addr *string, port string
 			debug("forward listen on remote '%s' failed: %v", address, err)
 		} else {
 			debug("forward listen on remote '%s' success", address)
+			closeOnTerminate(listener)
 			listeners = append(listeners, listener)
 		}
 	}
@@ -259,12 +353,12 @@ func stdioForward(client *ssh.Client, addr string) (*sync.WaitGroup, error) {
 
 	done := make(chan struct{}, 2)
 	go func() {
-		_, _ = io.Copy(conn, os.Stdin)
+		_, _ = relayCopy(conn, os.Stdin)
 		done <- struct{}{}
 		wg.Done()
 	}()
 	go func() {
-		_, _ = io.Copy(os.Stdout, conn)
+		_, _ = relayCopy(os.Stdout, conn)
 		done <- struct{}{}
 		wg.Done()
 	}()
@@ -279,20 +373,28 @@ func (d sshResolver) Resolve(ctx context.Context, name string) (context.Context,
 	return ctx, []byte{}, nil
 }
 
-func dynamicForward(client *ssh.Client, b *bindCfg, args *sshArgs) {
+func dynamicForward(client *ssh.Client, b *bindCfg, args *sshArgs) bool {
+	dial := func(network, addr string) (net.Conn, error) {
+		return dialWithTimeout(client, network, addr, 10*time.Second)
+	}
 	server, err := socks5.New(&socks5.Config{
 		Resolver: &sshResolver{},
 		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialWithTimeout(client, network, addr, 10*time.Second)
+			return dial(network, addr)
 		},
 		Logger: log.New(io.Discard, "", log.LstdFlags),
 	})
 	if err != nil {
 		warning("dynamic forward failed: %v", err)
-		return
+		return false
 	}
+	httpServer := getDynamicHttp(args, dial)
 
-	for _, listener := range listenOnLocal(args, b.addr, strconv.Itoa(b.port)) {
+	listeners := wrapForwardACL(args, b.port, listenOnLocal(args, b.addr, strconv.Itoa(b.port)))
+	if httpServer != nil {
+		httpServer.showPacUsage(listeners)
+	}
+	for _, listener := range listeners {
 		go func(listener net.Listener) {
 			defer listener.Close()
 			for {
@@ -305,13 +407,59 @@ func dynamicForward(client *ssh.Client, b *bindCfg, args *sshArgs) {
 					continue
 				}
 				go func() {
-					if err := server.ServeConn(conn); err != nil {
+					if err := serveDynamicConn(server, httpServer, conn); err != nil {
 						debug("dynamic forward serve failed: %v", err)
 					}
 				}()
 			}
 		}(listener)
 	}
+	return len(listeners) > 0
+}
+
+// remoteDynamicForward serves SOCKS on the remote listeners, and connects to the
+// destinations from the local side, which are limited by PermitRemoteOpen.
+func remoteDynamicForward(client *ssh.Client, f *forwardCfg, args *sshArgs) bool {
+	permit, err := parsePermitRemoteOpen(getOptionConfig(args, "PermitRemoteOpen"))
+	if err != nil {
+		warning("remote dynamic forward failed: %v", err)
+		return false
+	}
+	server, err := socks5.New(&socks5.Config{
+		Rules:    permit,
+		Resolver: &sshResolver{},
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, 10*time.Second)
+		},
+		Logger: log.New(io.Discard, "", log.LstdFlags),
+	})
+	if err != nil {
+		warning("remote dynamic forward failed: %v", err)
+		return false
+	}
+
+	listeners := listenOnRemote(args, client, f.bindAddr, strconv.Itoa(f.bindPort))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			defer listener.Close()
+			for {
+				conn, err := listener.Accept()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					debug("remote dynamic forward accept failed: %v", err)
+					continue
+				}
+				go func() {
+					if err := server.ServeConn(conn); err != nil {
+						debug("remote dynamic forward serve failed: %v", err)
+					}
+				}()
+			}
+		}(listener)
+	}
+	return len(listeners) > 0
 }
 
 func netForward(local, remote net.Conn) {
@@ -320,19 +468,51 @@ func netForward(local, remote net.Conn) {
 
 	done := make(chan struct{}, 2)
 	go func() {
-		_, _ = io.Copy(local, remote)
+		_, _ = relayCopy(local, remote)
 		done <- struct{}{}
 	}()
 	go func() {
-		_, _ = io.Copy(remote, local)
+		_, _ = relayCopy(remote, local)
 		done <- struct{}{}
 	}()
 	<-done
 }
 
-func localForward(client *ssh.Client, f *forwardCfg, args *sshArgs) {
-	remoteAddr := joinHostPort(f.destHost, strconv.Itoa(f.destPort))
-	for _, listener := range listenOnLocal(args, f.bindAddr, strconv.Itoa(f.bindPort)) {
+// listenOnLocalSocket removes the stale socket file first if StreamLocalBindUnlink is yes, as openssh does.
+func listenOnLocalSocket(args *sshArgs, path string) (listeners []net.Listener) {
+	path = resolvePath(path)
+	if strings.ToLower(getOptionConfig(args, "StreamLocalBindUnlink")) == "yes" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			debug("remove the stale socket [%s] failed: %v", path, err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		warning("forward listen on local socket '%s' failed: %v", path, err)
+		return nil
+	}
+	debug("forward listen on local socket '%s' success", path)
+	closeOnTerminate(listener)
+	return []net.Listener{listener}
+}
+
+func localForward(client *ssh.Client, f *forwardCfg, args *sshArgs) bool {
+	network, remoteAddr := "tcp", joinHostPort(f.destHost, strconv.Itoa(f.destPort))
+	if f.destSocket != "" {
+		network, remoteAddr = "unix", f.destSocket
+	}
+	var listeners []net.Listener
+	if f.bindSocket != "" {
+		listeners = listenOnLocalSocket(args, f.bindSocket)
+	} else {
+		listeners = wrapForwardACL(args, f.bindPort, listenOnLocal(args, f.bindAddr, strconv.Itoa(f.bindPort)))
+	}
+	if f.bindSocket == "" && f.bindPort == 0 && len(listeners) > 0 {
+		if tcpAddr, ok := listeners[0].Addr().(*net.TCPAddr); ok {
+			exportAllocatedPort(tcpAddr.Port, remoteAddr)
+		}
+	}
+	for _, listener := range listeners {
 		go func(listener net.Listener) {
 			defer listener.Close()
 			for {
@@ -344,7 +524,7 @@ func localForward(client *ssh.Client, f *forwardCfg, args *sshArgs) {
 					debug("local forward accept failed: %v", err)
 					continue
 				}
-				remote, err := dialWithTimeout(client, "tcp", remoteAddr, 10*time.Second)
+				remote, err := dialWithTimeout(client, network, remoteAddr, 10*time.Second)
 				if err != nil {
 					debug("local forward dial [%s] failed: %v", remoteAddr, err)
 					local.Close()
@@ -354,11 +534,46 @@ func localForward(client *ssh.Client, f *forwardCfg, args *sshArgs) {
 			}
 		}(listener)
 	}
+	return len(listeners) > 0
+}
+
+var allocatedPorts []string
+
+// exportAllocatedPort prints the port allocated for `-L 0:host:port`,
+// and exports it to LocalCommand as TSSH_LOCAL_FORWARD_PORT ( the first one )
+// and TSSH_LOCAL_FORWARD_PORTS ( all of them, e.g. `41234:127.0.0.1:5432 41235:127.0.0.1:6379` ).
+func exportAllocatedPort(port int, remoteAddr string) {
+	if envbleWarningLogging {
+		fmt.Fprintf(os.Stderr, "Allocated port %d for local forward to %s\r\n", port, remoteAddr)
+	}
+	if len(allocatedPorts) == 0 {
+		_ = os.Setenv("TSSH_LOCAL_FORWARD_PORT", strconv.Itoa(port))
+	}
+	allocatedPorts = append(allocatedPorts, fmt.Sprintf("%d:%s", port, remoteAddr))
+	_ = os.Setenv("TSSH_LOCAL_FORWARD_PORTS", strings.Join(allocatedPorts, " "))
 }
 
-func remoteForward(client *ssh.Client, f *forwardCfg, args *sshArgs) {
-	localAddr := joinHostPort(f.destHost, strconv.Itoa(f.destPort))
-	for _, listener := range listenOnRemote(args, client, f.bindAddr, strconv.Itoa(f.bindPort)) {
+func remoteForward(client *ssh.Client, f *forwardCfg, args *sshArgs) bool {
+	if f.dynamic {
+		return remoteDynamicForward(client, f, args)
+	}
+	network, localAddr := "tcp", joinHostPort(f.destHost, strconv.Itoa(f.destPort))
+	if f.destSocket != "" {
+		network, localAddr = "unix", resolvePath(f.destSocket)
+	}
+	var listeners []net.Listener
+	if f.bindSocket != "" {
+		if listener, err := client.ListenUnix(f.bindSocket); err != nil {
+			warning("forward listen on remote socket '%s' failed: %v", f.bindSocket, err)
+		} else {
+			debug("forward listen on remote socket '%s' success", f.bindSocket)
+			closeOnTerminate(listener)
+			listeners = append(listeners, listener)
+		}
+	} else {
+		listeners = listenOnRemote(args, client, f.bindAddr, strconv.Itoa(f.bindPort))
+	}
+	for _, listener := range listeners {
 		go func(listener net.Listener) {
 			defer listener.Close()
 			for {
@@ -370,7 +585,7 @@ func remoteForward(client *ssh.Client, f *forwardCfg, args *sshArgs) {
 					debug("remote forward accept failed: %v", err)
 					continue
 				}
-				local, err := net.DialTimeout("tcp", localAddr, 10*time.Second)
+				local, err := net.DialTimeout(network, localAddr, 10*time.Second)
 				if err != nil {
 					debug("remote forward dial [%s] failed: %v", localAddr, err)
 					remote.Close()
@@ -380,6 +595,27 @@ func remoteForward(client *ssh.Client, f *forwardCfg, args *sshArgs) {
 			}
 		}(listener)
 	}
+	return len(listeners) > 0
+}
+
+// expandForwardSockets expands the tokens in the UNIX socket paths of the forward, e.g., %d/.pg.sock
+func expandForwardSockets(f *forwardCfg, args *sshArgs, param *sshParam) (*forwardCfg, error) {
+	if f.bindSocket == "" && f.destSocket == "" {
+		return f, nil
+	}
+	expanded := *f
+	var err error
+	if f.bindSocket != "" {
+		if expanded.bindSocket, err = expandTokens(f.bindSocket, args, param, "%CdhikLlnpru"); err != nil {
+			return nil, err
+		}
+	}
+	if f.destSocket != "" {
+		if expanded.destSocket, err = expandTokens(f.destSocket, args, param, "%CdhikLlnpru"); err != nil {
+			return nil, err
+		}
+	}
+	return &expanded, nil
 }
 
 func sshForward(client *ssh.Client, args *sshArgs, param *sshParam) error {
@@ -388,9 +624,16 @@ func sshForward(client *ssh.Client, args *sshArgs, param *sshParam) error {
 		return nil
 	}
 
+	// forward presets
+	presets := getForwardPresets(args)
+
+	// the summary of the forwards, which is logged after reconnected
+	summary := &forwardSummary{client: client, args: args}
+	defer summary.log()
+
 	// dynamic forward
 	for _, b := range args.DynamicForward.binds {
-		dynamicForward(client, b, args)
+		summary.dynamic(b, dynamicForward(client, b, args))
 	}
 	for _, s := range getAllOptionConfig(args, "DynamicForward") {
 		b, err := parseBindCfg(s)
@@ -398,43 +641,71 @@ func sshForward(client *ssh.Client, args *sshArgs, param *sshParam) error {
 			warning("dynamic forward failed: %v", err)
 			continue
 		}
-		dynamicForward(client, b, args)
+		summary.dynamic(b, dynamicForward(client, b, args))
+	}
+	for _, preset := range presets {
+		for _, b := range preset.dynamics {
+			summary.dynamic(b, dynamicForward(client, b, args))
+		}
 	}
 
-	// local forward
-	for _, f := range args.LocalForward.cfgs {
-		localForward(client, f, args)
+	// transparent proxy
+	for _, s := range getAllExOptionConfig(args, "ExTransparentProxy") {
+		b, err := parseBindCfg(s)
+		if err != nil {
+			warning("transparent proxy failed: %v", err)
+			continue
+		}
+		summary.add("ExTransparentProxy", b.argument, transparentForward(client, b, args))
+	}
+
+	// sshuttle-style vpn
+	if len(args.VPN.values) > 0 {
+		summary.add("--vpn", strings.Join(args.VPN.values, ","), startVpn(client, args, param))
 	}
+
+	// local forward, the tokens are expanded in the socket paths only, the same as openssh
+	locals := append([]*forwardCfg(nil), args.LocalForward.cfgs...)
 	for _, s := range getAllOptionConfig(args, "LocalForward") {
-		es, err := expandTokens(s, args, param, "%CdhikLlnpru")
+		f, err := parseForwardCfg(s)
 		if err != nil {
-			warning("expand LocalForward [%s] failed: %v", s, err)
+			warning("local forward failed: %v", err)
 			continue
 		}
-		f, err := parseForwardCfg(es)
+		locals = append(locals, f)
+	}
+	for _, preset := range presets {
+		locals = append(locals, preset.locals...)
+	}
+	for _, f := range locals {
+		ef, err := expandForwardSockets(f, args, param)
 		if err != nil {
-			warning("local forward failed: %v", err)
+			warning("expand LocalForward [%s] failed: %v", f.argument, err)
 			continue
 		}
-		localForward(client, f, args)
+		summary.local(ef, localForward(client, ef, args))
 	}
 
 	// remote forward
-	for _, f := range args.RemoteForward.cfgs {
-		remoteForward(client, f, args)
-	}
+	remotes := append([]*forwardCfg(nil), args.RemoteForward.cfgs...)
 	for _, s := range getAllOptionConfig(args, "RemoteForward") {
-		es, err := expandTokens(s, args, param, "%CdhikLlnpru")
+		f, err := parseRemoteForwardCfg(s)
 		if err != nil {
-			warning("expand RemoteForward [%s] failed: %v", s, err)
+			warning("remote forward failed: %v", err)
 			continue
 		}
-		f, err := parseForwardCfg(es)
+		remotes = append(remotes, f)
+	}
+	for _, preset := range presets {
+		remotes = append(remotes, preset.remotes...)
+	}
+	for _, f := range remotes {
+		ef, err := expandForwardSockets(f, args, param)
 		if err != nil {
-			warning("remote forward failed: %v", err)
+			warning("expand RemoteForward [%s] failed: %v", f.argument, err)
 			continue
 		}
-		remoteForward(client, f, args)
+		summary.remote(ef, remoteForward(client, ef, args))
 	}
 
 	return nil
@@ -0,0 +1,146 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// forwardACL limits the clients which are allowed to connect to the -L and -D listeners.
+// It's configured by ExForwardAllow in ~/.ssh/config, which can be set multiple times, e.g.:
+//
+//	ExForwardAllow 10.0.1.0/24, 192.168.1.5
+//	ExForwardAllow 8080 192.168.2.0/24
+//
+// A line starts with a port only applies to the forwarding binds on that port.
+// The loopback addresses are always allowed.
+type forwardACL struct {
+	nets []*net.IPNet
+}
+
+func parseAllowedNet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR [%s]", s)
+		}
+		return ipNet, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP [%s]", s)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func parseForwardACL(values []string, bindPort int) (*forwardACL, error) {
+	var acl *forwardACL
+	for _, value := range values {
+		fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) > 0 && portOnlyRegexp.MatchString(fields[0]) {
+			if port, _ := strconv.Atoi(fields[0]); port != bindPort {
+				continue
+			}
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if acl == nil {
+			acl = &forwardACL{}
+		}
+		for _, field := range fields {
+			ipNet, err := parseAllowedNet(field)
+			if err != nil {
+				return nil, fmt.Errorf("ExForwardAllow [%s] has %v", value, err)
+			}
+			acl.nets = append(acl.nets, ipNet)
+		}
+	}
+	return acl, nil
+}
+
+func (acl *forwardACL) isAllowed(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	if tcpAddr.IP.IsLoopback() {
+		return true
+	}
+	for _, ipNet := range acl.nets {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+type aclListener struct {
+	net.Listener
+	acl *forwardACL
+}
+
+func (l *aclListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.acl.isAllowed(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		warning("forward on %s rejected the connection from %s", l.Addr(), conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
+// wrapForwardACL rejects the connections which are not allowed by ExForwardAllow.
+// If the ExForwardAllow is invalid, no listener is returned, rather than exposing the forwarding.
+func wrapForwardACL(args *sshArgs, bindPort int, listeners []net.Listener) []net.Listener {
+	acl, err := parseForwardACL(getAllExOptionConfig(args, "ExForwardAllow"), bindPort)
+	if err != nil {
+		warning("%v", err)
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		return nil
+	}
+	if acl == nil {
+		return listeners
+	}
+	wrapped := make([]net.Listener, 0, len(listeners))
+	for _, listener := range listeners {
+		wrapped = append(wrapped, &aclListener{listener, acl})
+	}
+	return wrapped
+}
@@ -0,0 +1,68 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardACL(t *testing.T) {
+	assert := assert.New(t)
+	addr := func(ip string) net.Addr {
+		return &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345}
+	}
+
+	acl, err := parseForwardACL(nil, 8080)
+	assert.Nil(err)
+	assert.Nil(acl)
+	acl, err = parseForwardACL([]string{"9090 10.0.0.0/8"}, 8080)
+	assert.Nil(err)
+	assert.Nil(acl)
+
+	values := []string{"10.0.1.0/24, 192.168.1.5", "8080 192.168.2.0/24 fd00::/8"}
+	acl, err = parseForwardACL(values, 8080)
+	assert.Nil(err)
+	assert.True(acl.isAllowed(addr("10.0.1.100")))
+	assert.True(acl.isAllowed(addr("192.168.1.5")))
+	assert.True(acl.isAllowed(addr("192.168.2.8")))
+	assert.True(acl.isAllowed(addr("fd12::1")))
+	assert.True(acl.isAllowed(addr("127.0.0.1")))
+	assert.True(acl.isAllowed(addr("::1")))
+	assert.False(acl.isAllowed(addr("10.0.2.1")))
+	assert.False(acl.isAllowed(addr("192.168.1.6")))
+
+	acl, err = parseForwardACL(values, 1080)
+	assert.Nil(err)
+	assert.False(acl.isAllowed(addr("192.168.2.8")))
+	assert.True(acl.isAllowed(addr("192.168.1.5")))
+
+	_, err = parseForwardACL([]string{"10.0.0.0/33"}, 8080)
+	assert.NotNil(err)
+	_, err = parseForwardACL([]string{"office"}, 8080)
+	assert.NotNil(err)
+}
@@ -0,0 +1,196 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/armon/go-socks5"
+)
+
+// dynamicHttp serves the HTTP proxy and the PAC file on the port of the dynamic forward, e.g.:
+//
+//	Host bastion
+//	    DynamicForward 1080
+//	    ExDynamicHttpProxy yes
+//	    ExDynamicPac *.corp.example.com, 10.*
+//
+// The connections starting with the SOCKS version 4 or 5 are served as SOCKS, and the others as HTTP.
+// ExDynamicHttpProxy accepts the CONNECT and the plain http requests, for the apps can't speak SOCKS.
+// ExDynamicPac serves `/proxy.pac` which routes the hosts matching the patterns through the proxy,
+// or all hosts by `all`, so that only the URL has to be set in the browser or the system settings.
+type dynamicHttp struct {
+	dial     func(network, addr string) (net.Conn, error)
+	proxy    bool
+	pac      bool
+	patterns []string
+}
+
+func getDynamicHttp(args *sshArgs, dial func(network, addr string) (net.Conn, error)) *dynamicHttp {
+	d := &dynamicHttp{dial: dial, proxy: strings.ToLower(getExOptionConfig(args, "ExDynamicHttpProxy")) == "yes"}
+	if value := getExOptionConfig(args, "ExDynamicPac"); value != "" && strings.ToLower(value) != "no" {
+		d.pac = true
+		if strings.ToLower(value) != "all" && strings.ToLower(value) != "yes" {
+			for _, pattern := range strings.Split(value, ",") {
+				if pattern = strings.TrimSpace(pattern); pattern != "" {
+					d.patterns = append(d.patterns, pattern)
+				}
+			}
+		}
+	}
+	if !d.proxy && !d.pac {
+		return nil
+	}
+	return d
+}
+
+// showPacUsage prints how to set up the browser with the PAC file.
+func (d *dynamicHttp) showPacUsage(listeners []net.Listener) {
+	if !d.pac || len(listeners) == 0 {
+		return
+	}
+	port := 0
+	if addr, ok := listeners[0].Addr().(*net.TCPAddr); ok {
+		port = addr.Port
+	}
+	fmt.Fprintf(os.Stderr, "\033[0;36mThe proxy auto-config URL is http://127.0.0.1:%d/proxy.pac, set it as the automatic\r\n"+
+		"proxy configuration in the browser or the system network settings, or start chrome with:\r\n"+
+		"  chrome --proxy-pac-url=http://127.0.0.1:%d/proxy.pac\033[0m\r\n", port, port)
+}
+
+// getPacScript routes the matched hosts through the SOCKS proxy, and the HTTP proxy if enabled.
+func (d *dynamicHttp) getPacScript(proxyHost string) string {
+	proxy := fmt.Sprintf("SOCKS5 %s; SOCKS %s", proxyHost, proxyHost)
+	if d.proxy {
+		proxy += "; PROXY " + proxyHost
+	}
+	var builder strings.Builder
+	builder.WriteString("function FindProxyForURL(url, host) {\n")
+	if len(d.patterns) == 0 {
+		builder.WriteString(fmt.Sprintf("  return %s;\n}\n", strconv.Quote(proxy)))
+		return builder.String()
+	}
+	conditions := make([]string, 0, len(d.patterns))
+	for _, pattern := range d.patterns {
+		conditions = append(conditions, fmt.Sprintf("shExpMatch(host, %s)", strconv.Quote(pattern)))
+	}
+	builder.WriteString(fmt.Sprintf("  if (%s) {\n    return %s;\n  }\n", strings.Join(conditions, " ||\n      "), strconv.Quote(proxy)))
+	builder.WriteString("  return \"DIRECT\";\n}\n")
+	return builder.String()
+}
+
+// serveDynamicConn serves the connection as SOCKS or HTTP by the first byte.
+func serveDynamicConn(server *socks5.Server, d *dynamicHttp, conn net.Conn) error {
+	if d == nil {
+		return server.ServeConn(conn)
+	}
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	first, err := reader.Peek(1)
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	buffered := &bufferedConn{conn, reader}
+	if first[0] == 4 || first[0] == 5 {
+		return server.ServeConn(buffered)
+	}
+	return d.serveHttp(buffered, reader)
+}
+
+func writeHttpResponse(conn net.Conn, status int, contentType, body string) {
+	fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Type: %s\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+		status, http.StatusText(status), contentType, len(body), body)
+}
+
+func (d *dynamicHttp) serveHttp(conn net.Conn, reader *bufio.Reader) error {
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if request.Method == http.MethodConnect && d.proxy {
+		remote, err := d.dial("tcp", request.Host)
+		if err != nil {
+			writeHttpResponse(conn, http.StatusBadGateway, "text/plain", err.Error()+"\n")
+			conn.Close()
+			return err
+		}
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+			conn.Close()
+			remote.Close()
+			return err
+		}
+		netForward(conn, remote)
+		return nil
+	}
+
+	if request.URL.IsAbs() && d.proxy {
+		host := request.URL.Host
+		if request.URL.Port() == "" {
+			host = joinHostPort(request.URL.Hostname(), "80")
+		}
+		remote, err := d.dial("tcp", host)
+		if err != nil {
+			writeHttpResponse(conn, http.StatusBadGateway, "text/plain", err.Error()+"\n")
+			conn.Close()
+			return err
+		}
+		// one request per connection, the following ones are in the absolute form which may not be supported
+		request.Header.Del("Proxy-Connection")
+		request.Header.Del("Proxy-Authorization")
+		request.Header.Set("Connection", "close")
+		request.Close = true
+		if err := request.Write(remote); err != nil {
+			conn.Close()
+			remote.Close()
+			return err
+		}
+		netForward(conn, remote)
+		return nil
+	}
+
+	defer conn.Close()
+	if d.pac && request.Method == http.MethodGet && request.URL.Path == "/proxy.pac" {
+		proxyHost := request.Host
+		if _, _, err := net.SplitHostPort(proxyHost); err != nil {
+			proxyHost = conn.LocalAddr().String()
+		}
+		writeHttpResponse(conn, http.StatusOK, "application/x-ns-proxy-autoconfig", d.getPacScript(proxyHost))
+		return nil
+	}
+	writeHttpResponse(conn, http.StatusNotFound, "text/plain", "not found\n")
+	return nil
+}
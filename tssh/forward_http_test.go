@@ -0,0 +1,150 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/armon/go-socks5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/proxy"
+)
+
+func TestDynamicPacScript(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options ...string) *sshArgs {
+		var args sshArgs
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return &args
+	}
+	assert.Nil(getDynamicHttp(newArgs(), nil))
+	assert.Nil(getDynamicHttp(newArgs("ExDynamicPac no"), nil))
+
+	d := getDynamicHttp(newArgs("ExDynamicPac all"), nil)
+	assert.Equal("function FindProxyForURL(url, host) {\n"+
+		"  return \"SOCKS5 127.0.0.1:1080; SOCKS 127.0.0.1:1080\";\n}\n", d.getPacScript("127.0.0.1:1080"))
+
+	d = getDynamicHttp(newArgs("ExDynamicPac *.corp.example.com, 10.*", "ExDynamicHttpProxy yes"), nil)
+	assert.Equal("function FindProxyForURL(url, host) {\n"+
+		"  if (shExpMatch(host, \"*.corp.example.com\") ||\n      shExpMatch(host, \"10.*\")) {\n"+
+		"    return \"SOCKS5 127.0.0.1:1080; SOCKS 127.0.0.1:1080; PROXY 127.0.0.1:1080\";\n  }\n"+
+		"  return \"DIRECT\";\n}\n", d.getPacScript("127.0.0.1:1080"))
+}
+
+func TestDynamicHttpProxy(t *testing.T) {
+	assert := assert.New(t)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s", r.URL.Path)
+	}))
+	defer backend.Close()
+
+	var dialed []string
+	dial := func(network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return net.Dial(network, addr)
+	}
+	server, err := socks5.New(&socks5.Config{
+		Resolver: &sshResolver{},
+		Dial:     func(ctx context.Context, network, addr string) (net.Conn, error) { return dial(network, addr) },
+		Logger:   log.New(io.Discard, "", log.LstdFlags),
+	})
+	assert.Nil(err)
+	d := &dynamicHttp{dial: dial, proxy: true, pac: true, patterns: []string{"*.example.com"}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = serveDynamicConn(server, d, conn) }()
+		}
+	}()
+	proxyAddr := listener.Addr().String()
+
+	get := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		assert.Nil(err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.Nil(err)
+		return string(body)
+	}
+
+	// pac
+	pac := get(http.DefaultClient, "http://"+proxyAddr+"/proxy.pac")
+	assert.Contains(pac, "SOCKS5 "+proxyAddr)
+	assert.Contains(pac, "PROXY "+proxyAddr)
+
+	// plain http proxy
+	proxyURL, _ := url.Parse("http://" + proxyAddr)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	assert.Equal("hello /plain", get(client, backend.URL+"/plain"))
+
+	// socks5 proxy
+	dialer, err := proxy.SOCKS5("tcp", proxyAddr, nil, proxy.Direct)
+	assert.Nil(err)
+	client = &http.Client{Transport: &http.Transport{Dial: dialer.Dial}}
+	assert.Equal("hello /socks", get(client, backend.URL+"/socks"))
+
+	// connect
+	conn, err := net.Dial("tcp", proxyAddr)
+	assert.Nil(err)
+	defer conn.Close()
+	backendAddr := strings.TrimPrefix(backend.URL, "http://")
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", backendAddr, backendAddr)
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	assert.Nil(err)
+	assert.Equal("HTTP/1.1 200 Connection Established\r\n", line)
+	_, _ = reader.ReadString('\n')
+	fmt.Fprintf(conn, "GET /connect HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", backendAddr)
+	resp, err := http.ReadResponse(reader, nil)
+	assert.Nil(err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal("hello /connect", string(body))
+
+	assert.Equal([]string{backendAddr, backendAddr, backendAddr}, dialed)
+
+	// not found
+	resp, err = http.Get("http://" + proxyAddr + "/other")
+	assert.Nil(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,124 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/armon/go-socks5"
+)
+
+// permitRemoteOpen limits the destinations the remote side may connect to
+// through `RemoteForward [bind_address:]port`, as PermitRemoteOpen of openssh:
+//
+//	PermitRemoteOpen any
+//	PermitRemoteOpen none
+//	PermitRemoteOpen db.internal:5432 10.0.0.8:* *:443 [::1]:8080
+//
+// The check is enforced locally, so a compromised server can't reach anything else.
+type permitRemoteOpen struct {
+	none    bool
+	targets []*permitTarget
+}
+
+type permitTarget struct {
+	host string
+	port int // 0 means any port
+}
+
+func parsePermitTarget(s string) (*permitTarget, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || host == "" || port == "" {
+		return nil, fmt.Errorf("invalid PermitRemoteOpen [%s]", s)
+	}
+	target := &permitTarget{host: strings.ToLower(host)}
+	if port != "*" {
+		target.port, err = strconv.Atoi(port)
+		if err != nil || target.port <= 0 || target.port > 65535 {
+			return nil, fmt.Errorf("invalid PermitRemoteOpen port [%s]", s)
+		}
+	}
+	return target, nil
+}
+
+// parsePermitRemoteOpen returns nil if any destination is permitted.
+func parsePermitRemoteOpen(value string) (*permitRemoteOpen, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) == 1 && strings.ToLower(fields[0]) == "any" {
+		return nil, nil
+	}
+	if len(fields) == 1 && strings.ToLower(fields[0]) == "none" {
+		return &permitRemoteOpen{none: true}, nil
+	}
+	permit := &permitRemoteOpen{}
+	for _, field := range fields {
+		target, err := parsePermitTarget(field)
+		if err != nil {
+			return nil, err
+		}
+		permit.targets = append(permit.targets, target)
+	}
+	return permit, nil
+}
+
+func (p *permitRemoteOpen) isPermitted(host string, port int) bool {
+	if p == nil {
+		return true
+	}
+	if p.none {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, target := range p.targets {
+		if (target.host == "*" || target.host == host) && (target.port == 0 || target.port == port) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow implements socks5.RuleSet.
+func (p *permitRemoteOpen) Allow(ctx context.Context, req *socks5.Request) (context.Context, bool) {
+	if p == nil {
+		return ctx, true
+	}
+	if req.Command != socks5.ConnectCommand {
+		warning("PermitRemoteOpen rejected the socks command %d", req.Command)
+		return ctx, false
+	}
+	host := req.DestAddr.FQDN
+	if host == "" {
+		host = req.DestAddr.IP.String()
+	}
+	if !p.isPermitted(host, req.DestAddr.Port) {
+		warning("PermitRemoteOpen rejected the remote open to %s", joinHostPort(host, strconv.Itoa(req.DestAddr.Port)))
+		return ctx, false
+	}
+	return ctx, true
+}
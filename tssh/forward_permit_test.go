@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/armon/go-socks5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermitRemoteOpen(t *testing.T) {
+	assert := assert.New(t)
+	permit, err := parsePermitRemoteOpen("")
+	assert.Nil(err)
+	assert.True(permit.isPermitted("anything", 22))
+	permit, err = parsePermitRemoteOpen("ANY")
+	assert.Nil(err)
+	assert.Nil(permit)
+
+	permit, err = parsePermitRemoteOpen("none")
+	assert.Nil(err)
+	assert.False(permit.isPermitted("localhost", 80))
+
+	permit, err = parsePermitRemoteOpen("db.internal:5432 10.0.0.8:* *:443 [::1]:8080")
+	assert.Nil(err)
+	assert.True(permit.isPermitted("DB.internal", 5432))
+	assert.False(permit.isPermitted("db.internal", 5433))
+	assert.True(permit.isPermitted("10.0.0.8", 22))
+	assert.True(permit.isPermitted("example.com", 443))
+	assert.True(permit.isPermitted("::1", 8080))
+	assert.False(permit.isPermitted("::1", 8081))
+
+	for _, value := range []string{"db.internal", "db:abc", "db:0", ":80", "any none"} {
+		_, err = parsePermitRemoteOpen(value)
+		assert.NotNil(err, value)
+	}
+
+	allow := func(command uint8, fqdn string, ip net.IP, port int) bool {
+		_, ok := permit.Allow(context.Background(), &socks5.Request{
+			Command: command, DestAddr: &socks5.AddrSpec{FQDN: fqdn, IP: ip, Port: port}})
+		return ok
+	}
+	assert.True(allow(socks5.ConnectCommand, "db.internal", nil, 5432))
+	assert.True(allow(socks5.ConnectCommand, "", net.ParseIP("10.0.0.8"), 3306))
+	assert.False(allow(socks5.ConnectCommand, "", net.ParseIP("10.0.0.9"), 3306))
+	assert.False(allow(socks5.AssociateCommand, "db.internal", nil, 5432))
+}
+
+func TestParseRemoteDynamicForward(t *testing.T) {
+	assert := assert.New(t)
+	addr := "0.0.0.0"
+	cfg, err := parseRemoteForwardCfg(" 0.0.0.0:1080 ")
+	assert.Nil(err)
+	assert.Equal(&forwardCfg{argument: "0.0.0.0:1080", bindAddr: &addr, bindPort: 1080, dynamic: true}, cfg)
+	cfg, err = parseRemoteForwardCfg("8080 localhost:80")
+	assert.Nil(err)
+	assert.False(cfg.dynamic)
+	_, err = parseForwardCfg("1080")
+	assert.NotNil(err)
+
+	preset, err := parseForwardPreset("socks", []string{"RemoteForward 1080"})
+	assert.Nil(err)
+	assert.True(preset.remotes[0].dynamic)
+}
@@ -0,0 +1,117 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// forwardPreset is a named set of forwardings, defined in ~/.tssh.conf, one forwarding per line, e.g.:
+//
+//	Forwards.dbtools = LocalForward 5432 127.0.0.1:5432
+//	Forwards.dbtools = LocalForward 6379 127.0.0.1:6379
+//	Forwards.dbtools = DynamicForward 1080
+//
+// They are attached to hosts by `ExForwards dbtools` in ~/.ssh/config, or applied by `--forwards dbtools`.
+type forwardPreset struct {
+	dynamics []*bindCfg
+	locals   []*forwardCfg
+	remotes  []*forwardCfg
+}
+
+func getForwardPresetNames(args *sshArgs) []string {
+	var names []string
+	seen := make(map[string]bool)
+	values := append(append([]string(nil), args.Forwards.values...), getAllExOptionConfig(args, "ExForwards")...)
+	for _, value := range values {
+		for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			name = strings.ToLower(name)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func parseForwardPreset(name string, lines []string) (*forwardPreset, error) {
+	preset := &forwardPreset{}
+	for _, line := range lines {
+		var option sshOption
+		if err := option.UnmarshalText([]byte(line)); err != nil {
+			return nil, fmt.Errorf("forwards [%s] has %v", name, err)
+		}
+		for key, values := range option.options {
+			value := values[0]
+			switch key {
+			case "dynamicforward":
+				b, err := parseBindCfg(value)
+				if err != nil {
+					return nil, fmt.Errorf("forwards [%s] has invalid DynamicForward [%s]: %v", name, value, err)
+				}
+				preset.dynamics = append(preset.dynamics, b)
+			case "localforward", "remoteforward":
+				parse := parseForwardCfg
+				if key == "remoteforward" {
+					parse = parseRemoteForwardCfg
+				}
+				f, err := parse(value)
+				if err != nil {
+					return nil, fmt.Errorf("forwards [%s] has invalid forwarding [%s]: %v", name, line, err)
+				}
+				if key == "localforward" {
+					preset.locals = append(preset.locals, f)
+				} else {
+					preset.remotes = append(preset.remotes, f)
+				}
+			default:
+				return nil, fmt.Errorf("forwards [%s] has unsupported option [%s]", name, line)
+			}
+		}
+	}
+	return preset, nil
+}
+
+// getForwardPresets returns the forward presets which should be applied to the current login.
+func getForwardPresets(args *sshArgs) []*forwardPreset {
+	var presets []*forwardPreset
+	for _, name := range getForwardPresetNames(args) {
+		lines, ok := userConfig.forwardPresets[name]
+		if !ok {
+			warning("forwards [%s] is not defined in ~/.tssh.conf", name)
+			continue
+		}
+		preset, err := parseForwardPreset(name, lines)
+		if err != nil {
+			warning("%v", err)
+			continue
+		}
+		debug("apply forwards [%s]", name)
+		presets = append(presets, preset)
+	}
+	return presets
+}
@@ -0,0 +1,58 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardPresets(t *testing.T) {
+	assert := assert.New(t)
+	originalPresets := userConfig.forwardPresets
+	defer func() { userConfig.forwardPresets = originalPresets }()
+	userConfig.forwardPresets = map[string][]string{
+		"dbtools": {"LocalForward 5432 127.0.0.1:5432", "LocalForward=6379 127.0.0.1:6379"},
+		"proxy":   {"DynamicForward 1080", "RemoteForward 8080 127.0.0.1:80"},
+		"invalid": {"RequestTTY yes"},
+	}
+
+	args := &sshArgs{Forwards: multiStr{[]string{"DBTools,proxy", "dbtools"}}}
+	assert.Equal([]string{"dbtools", "proxy"}, getForwardPresetNames(args))
+
+	presets := getForwardPresets(args)
+	assert.Len(presets, 2)
+	assert.Len(presets[0].locals, 2)
+	assert.Equal(5432, presets[0].locals[0].bindPort)
+	assert.Equal(6379, presets[0].locals[1].bindPort)
+	assert.Len(presets[1].dynamics, 1)
+	assert.Len(presets[1].remotes, 1)
+	assert.Equal("127.0.0.1", presets[1].remotes[0].destHost)
+
+	assert.Empty(getForwardPresets(&sshArgs{Forwards: multiStr{[]string{"invalid", "unknown"}}}))
+	_, err := parseForwardPreset("bad", []string{"LocalForward abc"})
+	assert.NotNil(err)
+}
@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kReconnectCountEnv = "TRZSZ-SSH-RECONNECTS"
+
+// getReconnectCount returns how many times the --reconnect monitor has restarted the background process.
+func getReconnectCount() int {
+	count, _ := strconv.Atoi(os.Getenv(kReconnectCountEnv))
+	return count
+}
+
+// forwardSummary records whether the forwards are established. After --reconnect re-establishes the
+// session, the summary is logged, and the remote forwards which failed are retried for a while, since
+// the server may not have released the ports of the broken session yet.
+type forwardSummary struct {
+	client   *ssh.Client
+	args     *sshArgs
+	restored []string
+	failed   []string
+}
+
+const (
+	kRemoteForwardRetryTimes    = 10
+	kRemoteForwardRetryInterval = 3 * time.Second
+)
+
+func (s *forwardSummary) add(flag, argument string, ok bool) {
+	if ok {
+		s.restored = append(s.restored, flag+" "+argument)
+	} else {
+		s.failed = append(s.failed, flag+" "+argument)
+	}
+}
+
+func (s *forwardSummary) dynamic(b *bindCfg, ok bool) {
+	s.add("-D", b.argument, ok)
+}
+
+func (s *forwardSummary) local(f *forwardCfg, ok bool) {
+	s.add("-L", f.argument, ok)
+}
+
+func (s *forwardSummary) remote(f *forwardCfg, ok bool) {
+	s.add("-R", f.argument, ok)
+	if !ok && getReconnectCount() > 0 {
+		go s.retryRemote(f)
+	}
+}
+
+func (s *forwardSummary) retryRemote(f *forwardCfg) {
+	for i := 0; i < kRemoteForwardRetryTimes; i++ {
+		time.Sleep(kRemoteForwardRetryInterval)
+		if remoteForward(s.client, f, s.args) {
+			fmt.Fprintf(os.Stderr, "Restored the remote forward -R %s after retrying %d times\r\n", f.argument, i+1)
+			return
+		}
+	}
+	warning("remote forward -R %s is not restored after retrying %d times", f.argument, kRemoteForwardRetryTimes)
+}
+
+func (s *forwardSummary) log() {
+	if len(s.restored) == 0 && len(s.failed) == 0 {
+		return
+	}
+	count := getReconnectCount()
+	if count == 0 {
+		debug("forwards established: [%s], failed: [%s]", strings.Join(s.restored, ", "), strings.Join(s.failed, ", "))
+		return
+	}
+	msg := fmt.Sprintf("Reconnected #%d, restored %d forwards", count, len(s.restored))
+	if len(s.restored) > 0 {
+		msg += ": " + strings.Join(s.restored, ", ")
+	}
+	if len(s.failed) > 0 {
+		msg += fmt.Sprintf("; %d failed: %s", len(s.failed), strings.Join(s.failed, ", "))
+	}
+	fmt.Fprintf(os.Stderr, "%s\r\n", msg)
+}
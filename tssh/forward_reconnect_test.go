@@ -0,0 +1,47 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForwardSummary(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv(kReconnectCountEnv, "")
+	assert.Equal(0, getReconnectCount())
+	t.Setenv(kReconnectCountEnv, "3")
+	assert.Equal(3, getReconnectCount())
+
+	summary := &forwardSummary{}
+	summary.dynamic(&bindCfg{argument: "1080"}, true)
+	summary.local(&forwardCfg{argument: "8080:localhost:80"}, true)
+	summary.local(&forwardCfg{argument: "5432:db:5432"}, false)
+	assert.Equal([]string{"-D 1080", "-L 8080:localhost:80"}, summary.restored)
+	assert.Equal([]string{"-L 5432:db:5432"}, summary.failed)
+	summary.log()
+}
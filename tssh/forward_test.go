@@ -25,6 +25,10 @@ SOFTWARE.
 package tssh
 
 import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -86,7 +90,7 @@ func TestParseForwardCfg(t *testing.T) {
 		t.Helper()
 		cfg, err := parseForwardCfg(arg)
 		assert.Nil(err)
-		assert.Equal(&forwardCfg{arg, bindAddr, bindPort, destHost, destPort}, cfg)
+		assert.Equal(&forwardCfg{argument: arg, bindAddr: bindAddr, bindPort: bindPort, destHost: destHost, destPort: destPort}, cfg)
 	}
 	assertForwardCfg := func(arg string, bindAddr string, bindPort int, destHost string, destPort int) {
 		t.Helper()
@@ -151,7 +155,7 @@ func TestParseForwardArg(t *testing.T) {
 		t.Helper()
 		cfg, err := parseForwardArg(arg)
 		assert.Nil(err)
-		assert.Equal(&forwardCfg{arg, bindAddr, bindPort, destHost, destPort}, cfg)
+		assert.Equal(&forwardCfg{argument: arg, bindAddr: bindAddr, bindPort: bindPort, destHost: destHost, destPort: destPort}, cfg)
 	}
 	assertForwardCfg := func(arg string, bindAddr string, bindPort int, destHost string, destPort int) {
 		t.Helper()
@@ -209,3 +213,119 @@ func TestParseForwardArg(t *testing.T) {
 	assertArgError("127.0.0.1:8000:[::1]]:9000", "invalid forward specification: 127.0.0.1:8000:[::1]]:9000")
 	assertArgError("127.0.0.1:8000:[:\t:1]:9000", "invalid forward specification: 127.0.0.1:8000:[:\t:1]:9000")
 }
+
+func TestParseSocketForward(t *testing.T) {
+	assert := assert.New(t)
+	localhost := "127.0.0.1"
+	assertForward := func(cfg *forwardCfg, err error, expected *forwardCfg) {
+		t.Helper()
+		assert.Nil(err)
+		assert.Equal(expected, cfg)
+	}
+
+	cfg, err := parseForwardCfg("/tmp/pg.sock db:5432")
+	assertForward(cfg, err, &forwardCfg{argument: "/tmp/pg.sock db:5432", bindSocket: "/tmp/pg.sock", destHost: "db", destPort: 5432})
+	cfg, err = parseForwardCfg("127.0.0.1:8080 /run/app.sock")
+	assertForward(cfg, err, &forwardCfg{argument: "127.0.0.1:8080 /run/app.sock", bindAddr: &localhost, bindPort: 8080, destSocket: "/run/app.sock"})
+	cfg, err = parseForwardCfg("~/.gnupg/S.gpg-agent /run/user/1000/gnupg/S.gpg-agent")
+	assertForward(cfg, err, &forwardCfg{argument: "~/.gnupg/S.gpg-agent /run/user/1000/gnupg/S.gpg-agent",
+		bindSocket: "~/.gnupg/S.gpg-agent", destSocket: "/run/user/1000/gnupg/S.gpg-agent"})
+
+	cfg, err = parseForwardArg("/tmp/pg.sock:[::1]:5432")
+	assertForward(cfg, err, &forwardCfg{argument: "/tmp/pg.sock:[::1]:5432", bindSocket: "/tmp/pg.sock", destHost: "::1", destPort: 5432})
+	cfg, err = parseForwardArg("127.0.0.1:8080:/run/app.sock")
+	assertForward(cfg, err, &forwardCfg{argument: "127.0.0.1:8080:/run/app.sock", bindAddr: &localhost, bindPort: 8080, destSocket: "/run/app.sock"})
+	cfg, err = parseForwardArg("8080:/run/app.sock")
+	assertForward(cfg, err, &forwardCfg{argument: "8080:/run/app.sock", bindPort: 8080, destSocket: "/run/app.sock"})
+
+	_, err = parseForwardArg("/tmp/pg.sock:db")
+	assert.NotNil(err)
+	_, err = parseForwardCfg("/tmp/pg.sock db:abc")
+	assert.NotNil(err)
+}
+
+func TestExpandForwardSockets(t *testing.T) {
+	assert := assert.New(t)
+	defer func(home string) { userHomeDir = home }(userHomeDir)
+	userHomeDir = "/home/penny"
+	args := &sshArgs{Destination: "db1"}
+	param := &sshParam{host: "10.0.0.1", port: "22", user: "admin"}
+
+	cfg, err := parseForwardCfg("%d/.pg-%n.sock /run/%r/.s.PGSQL.5432")
+	assert.Nil(err)
+	expanded, err := expandForwardSockets(cfg, args, param)
+	assert.Nil(err)
+	assert.Equal("/home/penny/.pg-db1.sock", expanded.bindSocket)
+	assert.Equal("/run/admin/.s.PGSQL.5432", expanded.destSocket)
+	assert.Equal("%d/.pg-%n.sock", cfg.bindSocket)
+
+	cfg, err = parseForwardArg("8080:/tmp/%h:%p.sock")
+	assert.Nil(err)
+	expanded, err = expandForwardSockets(cfg, args, param)
+	assert.Nil(err)
+	assert.Equal("/tmp/10.0.0.1:22.sock", expanded.destSocket)
+
+	// the host and port forms are not expanded
+	cfg, err = parseForwardCfg("8080 db:5432")
+	assert.Nil(err)
+	expanded, err = expandForwardSockets(cfg, args, param)
+	assert.Nil(err)
+	assert.Same(cfg, expanded)
+
+	cfg, err = parseForwardCfg("/tmp/%z.sock db:5432")
+	assert.Nil(err)
+	_, err = expandForwardSockets(cfg, args, param)
+	assert.NotNil(err)
+}
+
+func TestListenOnLocalSocket(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "forward.sock")
+	assert.Nil(os.WriteFile(path, nil, 0600))
+	assert.Empty(listenOnLocalSocket(&sshArgs{}, path))
+
+	args := &sshArgs{Option: sshOption{map[string][]string{"streamlocalbindunlink": {"yes"}}}}
+	listeners := listenOnLocalSocket(args, path)
+	assert.Len(listeners, 1)
+	defer listeners[0].Close()
+	go func() {
+		if conn, err := listeners[0].Accept(); err == nil {
+			_, _ = conn.Write([]byte("ok"))
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	assert.Nil(err)
+	defer conn.Close()
+	buf, err := io.ReadAll(conn)
+	assert.Nil(err)
+	assert.Equal("ok", string(buf))
+}
+
+func TestLocalForwardAutoPort(t *testing.T) {
+	assert := assert.New(t)
+	listeners := listenOnLocal(&sshArgs{}, nil, "0")
+	assert.NotEmpty(listeners)
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+	port := listeners[0].Addr().(*net.TCPAddr).Port
+	assert.NotZero(port)
+	for _, listener := range listeners {
+		assert.Equal(port, listener.Addr().(*net.TCPAddr).Port)
+	}
+
+	originalPorts := allocatedPorts
+	defer func() {
+		allocatedPorts = originalPorts
+		os.Unsetenv("TSSH_LOCAL_FORWARD_PORT")
+		os.Unsetenv("TSSH_LOCAL_FORWARD_PORTS")
+	}()
+	allocatedPorts = nil
+	exportAllocatedPort(41234, "127.0.0.1:5432")
+	exportAllocatedPort(41235, "[::1]:6379")
+	assert.Equal("41234", os.Getenv("TSSH_LOCAL_FORWARD_PORT"))
+	assert.Equal("41234:127.0.0.1:5432 41235:[::1]:6379", os.Getenv("TSSH_LOCAL_FORWARD_PORTS"))
+}
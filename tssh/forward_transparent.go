@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// transparentForward accepts the connections redirected by iptables on Linux, and connects to their
+// original destinations through the ssh connection, so that the traffic of an application is proxied
+// without configuring its proxy settings, e.g.:
+//
+//	Host bastion
+//	    ExTransparentProxy 12345
+//
+// The connections of the applications run by a dedicated group are redirected to the port by:
+//
+//	sudo groupadd tssh
+//	sudo iptables -t nat -A OUTPUT -p tcp -m owner --gid-owner tssh -j REDIRECT --to-ports 12345
+//	sg tssh -c 'curl http://10.0.0.1/'
+func transparentForward(client *ssh.Client, b *bindCfg, args *sshArgs) bool {
+	if !isTransparentProxySupported() {
+		warning("ExTransparentProxy is only supported on Linux")
+		return false
+	}
+	listeners := wrapForwardACL(args, b.port, listenOnLocal(args, b.addr, strconv.Itoa(b.port)))
+	if len(listeners) > 0 {
+		port := b.port
+		if addr, ok := listeners[0].Addr().(*net.TCPAddr); ok {
+			port = addr.Port
+		}
+		fmt.Fprint(os.Stderr, getTransparentProxyUsage(port))
+	}
+	serveTransparentListeners(client, listeners)
+	return len(listeners) > 0
+}
+
+func serveTransparentListeners(client *ssh.Client, listeners []net.Listener) {
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			defer listener.Close()
+			for {
+				conn, err := listener.Accept()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					debug("transparent proxy accept failed: %v", err)
+					continue
+				}
+				go serveTransparentConn(client, conn)
+			}
+		}(listener)
+	}
+}
+
+func serveTransparentConn(client *ssh.Client, conn net.Conn) {
+	dest, err := getOriginalDestination(conn)
+	if err != nil {
+		debug("transparent proxy get the original destination failed: %v", err)
+		conn.Close()
+		return
+	}
+	if dest == conn.LocalAddr().String() {
+		debug("transparent proxy refuse the connection to itself: %s", dest)
+		conn.Close()
+		return
+	}
+	remote, err := dialWithTimeout(client, "tcp", dest, 10*time.Second)
+	if err != nil {
+		debug("transparent proxy dial [%s] failed: %v", dest, err)
+		conn.Close()
+		return
+	}
+	debug("transparent proxy %s to %s", conn.RemoteAddr(), dest)
+	netForward(conn, remote)
+}
+
+func getTransparentProxyUsage(port int) string {
+	return fmt.Sprintf("\033[0;36mThe transparent proxy is listening on %d, redirect the applications run by a group to it:\r\n"+
+		"  sudo groupadd tssh\r\n"+
+		"  sudo iptables -t nat -A OUTPUT -p tcp -m owner --gid-owner tssh -j REDIRECT --to-ports %d\r\n"+
+		"  sg tssh -c 'your application'\033[0m\r\n", port, port)
+}
@@ -0,0 +1,82 @@
+//go:build linux
+
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// kSoOriginalDst is SO_ORIGINAL_DST and IP6T_SO_ORIGINAL_DST of netfilter.
+const kSoOriginalDst = 80
+
+func isTransparentProxySupported() bool {
+	return true
+}
+
+// getOriginalDestination returns the destination before redirected by iptables.
+func getOriginalDestination(conn net.Conn) (string, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return "", fmt.Errorf("not a tcp connection")
+	}
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+	var dest string
+	var sockErr error
+	ipv6 := false
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+		ipv6 = true
+	}
+	if err := rawConn.Control(func(fd uintptr) {
+		if ipv6 {
+			var info *unix.IPv6MTUInfo
+			if info, sockErr = unix.GetsockoptIPv6MTUInfo(int(fd), unix.SOL_IPV6, kSoOriginalDst); sockErr == nil {
+				port := (*[2]byte)(unsafe.Pointer(&info.Addr.Port))
+				dest = net.JoinHostPort(net.IP(info.Addr.Addr[:]).String(), strconv.Itoa(int(port[0])<<8|int(port[1])))
+			}
+			return
+		}
+		var mreq *unix.IPv6Mreq
+		if mreq, sockErr = unix.GetsockoptIPv6Mreq(int(fd), unix.SOL_IP, kSoOriginalDst); sockErr == nil {
+			addr := mreq.Multiaddr
+			dest = net.JoinHostPort(net.IP(addr[4:8]).String(), strconv.Itoa(int(addr[2])<<8|int(addr[3])))
+		}
+	}); err != nil {
+		return "", err
+	}
+	if sockErr != nil {
+		return "", fmt.Errorf("getsockopt SO_ORIGINAL_DST failed: %v", sockErr)
+	}
+	return dest, nil
+}
@@ -0,0 +1,60 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransparentProxy(t *testing.T) {
+	assert := assert.New(t)
+	assert.Contains(getTransparentProxyUsage(12345), "--gid-owner tssh -j REDIRECT --to-ports 12345")
+
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	_, err := getOriginalDestination(local)
+	assert.NotNil(err)
+
+	if !isTransparentProxySupported() {
+		return
+	}
+	// without the iptables redirect, there is no original destination or it's the local address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	assert.Nil(err)
+	defer client.Close()
+	conn, err := listener.Accept()
+	assert.Nil(err)
+	defer conn.Close()
+	if dest, err := getOriginalDestination(conn); err == nil {
+		assert.Equal(listener.Addr().String(), dest)
+	}
+}
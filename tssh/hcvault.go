@@ -0,0 +1,228 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type hcVaultClient struct {
+	addr  string
+	token string
+	mount string
+	role  string
+}
+
+type hcVaultResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []string       `json:"errors"`
+}
+
+func getHcVaultClient(args *sshArgs) *hcVaultClient {
+	role := getExOptionConfig(args, "ExVaultRole")
+	if role == "" {
+		return nil
+	}
+	addr := getExOptionConfig(args, "ExVaultAddr")
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		warning("ExVaultRole is set but neither ExVaultAddr nor VAULT_ADDR is set")
+		return nil
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if data, err := os.ReadFile(filepath.Join(userHomeDir, ".vault-token")); err == nil {
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	if token == "" {
+		warning("ExVaultRole is set but neither VAULT_TOKEN nor ~/.vault-token is set")
+		return nil
+	}
+	mount := getExOptionConfig(args, "ExVaultMount")
+	if mount == "" {
+		mount = "ssh"
+	}
+	return &hcVaultClient{addr: strings.TrimRight(addr, "/"), token: token, mount: strings.Trim(mount, "/"), role: role}
+}
+
+func (c *hcVaultClient) request(method, path string, body any) (map[string]any, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.addr+"/v1/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result hcVaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decode vault response failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("vault response status code %d: %s", resp.StatusCode, strings.Join(result.Errors, ", "))
+		}
+		return nil, fmt.Errorf("vault response status code %d", resp.StatusCode)
+	}
+	return result.Data, nil
+}
+
+// renewToken extends the lifetime of the vault token, it's fine to fail if the token is not renewable.
+func (c *hcVaultClient) renewToken() {
+	if _, err := c.request(http.MethodPost, "auth/token/renew-self", map[string]any{}); err != nil {
+		debug("renew vault token failed: %v", err)
+	} else {
+		debug("renew vault token success")
+	}
+}
+
+func (c *hcVaultClient) signPublicKey(user string) (ssh.Signer, error) {
+	c.renewToken()
+	pubKey, priKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(priKey)
+	if err != nil {
+		return nil, err
+	}
+	sshPubKey, err := ssh.NewPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.request(http.MethodPost, fmt.Sprintf("%s/sign/%s", c.mount, c.role), map[string]any{
+		"public_key":       string(ssh.MarshalAuthorizedKey(sshPubKey)),
+		"valid_principals": user,
+		"cert_type":        "user",
+	})
+	if err != nil {
+		return nil, err
+	}
+	signedKey, _ := data["signed_key"].(string)
+	if signedKey == "" {
+		return nil, fmt.Errorf("no signed key in vault response")
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signedKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse signed key failed: %v", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("signed key is not a certificate")
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+func (c *hcVaultClient) createOTP(host, user string) (string, error) {
+	c.renewToken()
+	ip := host
+	if net.ParseIP(host) == nil {
+		addrs, err := net.LookupHost(host)
+		if err != nil || len(addrs) == 0 {
+			return "", fmt.Errorf("lookup host [%s] failed: %v", host, err)
+		}
+		ip = addrs[0]
+	}
+	data, err := c.request(http.MethodPost, fmt.Sprintf("%s/creds/%s", c.mount, c.role), map[string]any{
+		"ip":       ip,
+		"username": user,
+	})
+	if err != nil {
+		return "", err
+	}
+	otp, _ := data["key"].(string)
+	if otp == "" {
+		return "", fmt.Errorf("no otp key in vault response")
+	}
+	return otp, nil
+}
+
+func isHcVaultOTP(args *sshArgs) bool {
+	return strings.ToLower(getExOptionConfig(args, "ExVaultType")) == "otp"
+}
+
+func getHcVaultSigner(args *sshArgs, param *sshParam) *sshSigner {
+	if isHcVaultOTP(args) {
+		return nil
+	}
+	client := getHcVaultClient(args)
+	if client == nil {
+		return nil
+	}
+	signer, err := client.signPublicKey(param.user)
+	if err != nil {
+		warning("sign public key by vault role [%s] failed: %v", client.role, err)
+		return nil
+	}
+	debug("sign public key by vault role [%s] success", client.role)
+	return &sshSigner{path: "vault:" + client.role, pubKey: signer.PublicKey(), signer: signer}
+}
+
+func getHcVaultPassword(args *sshArgs, host, user string) string {
+	if !isHcVaultOTP(args) {
+		return ""
+	}
+	client := getHcVaultClient(args)
+	if client == nil {
+		return ""
+	}
+	otp, err := client.createOTP(host, user)
+	if err != nil {
+		warning("create otp by vault role [%s] failed: %v", client.role, err)
+		return ""
+	}
+	debug("create otp by vault role [%s] success", client.role)
+	return otp
+}
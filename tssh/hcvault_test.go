@@ -0,0 +1,169 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGetHcVaultClient(t *testing.T) {
+	assert := assert.New(t)
+	defer func(home string) { userHomeDir = home }(userHomeDir)
+	userHomeDir = t.TempDir()
+	newArgs := func(options map[string][]string) *sshArgs {
+		return &sshArgs{Destination: "test", Option: sshOption{options}}
+	}
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+
+	assert.Nil(getHcVaultClient(newArgs(nil)))
+	assert.Nil(getHcVaultClient(newArgs(map[string][]string{"exvaultrole": {"dev"}})))
+	t.Setenv("VAULT_ADDR", "https://vault.example.com/")
+	assert.Nil(getHcVaultClient(newArgs(map[string][]string{"exvaultrole": {"dev"}})))
+
+	// the token is read from ~/.vault-token if VAULT_TOKEN is not set
+	assert.Nil(os.WriteFile(filepath.Join(userHomeDir, ".vault-token"), []byte("file-token\n"), 0600))
+	assert.Equal(&hcVaultClient{addr: "https://vault.example.com", token: "file-token", mount: "ssh", role: "dev"},
+		getHcVaultClient(newArgs(map[string][]string{"exvaultrole": {"dev"}})))
+
+	t.Setenv("VAULT_TOKEN", "env-token")
+	assert.Equal(&hcVaultClient{addr: "http://127.0.0.1:8200", token: "env-token", mount: "ssh-client", role: "ops"},
+		getHcVaultClient(newArgs(map[string][]string{"exvaultrole": {"ops"},
+			"exvaultaddr": {"http://127.0.0.1:8200"}, "exvaultmount": {"/ssh-client/"}})))
+}
+
+func TestHcVaultRequests(t *testing.T) {
+	assert := assert.New(t)
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	assert.Nil(err)
+
+	// signKey signs the posted public key by the CA, as the vault ssh secrets engine does
+	signKey := func(t *testing.T, body map[string]any) map[string]any {
+		pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(body["public_key"].(string)))
+		assert.Nil(err)
+		cert := &ssh.Certificate{Key: pubKey, CertType: ssh.UserCert, ValidPrincipals: []string{body["valid_principals"].(string)},
+			ValidBefore: ssh.CertTimeInfinity}
+		assert.Nil(cert.SignCert(rand.Reader, caSigner))
+		return map[string]any{"data": map[string]any{"signed_key": string(ssh.MarshalAuthorizedKey(cert))}}
+	}
+
+	tests := []struct {
+		name     string
+		otp      bool
+		status   int
+		response func(t *testing.T, body map[string]any) map[string]any
+		raw      string
+		errMsg   string
+	}{
+		{name: "sign", response: signKey},
+		{name: "sign denied", status: http.StatusForbidden,
+			response: func(*testing.T, map[string]any) map[string]any {
+				return map[string]any{"errors": []string{"permission denied"}}
+			}, errMsg: "vault response status code 403: permission denied"},
+		{name: "sign no error message", status: http.StatusInternalServerError,
+			errMsg: "vault response status code 500"},
+		{name: "sign no signed key", response: func(*testing.T, map[string]any) map[string]any {
+			return map[string]any{"data": map[string]any{}}
+		}, errMsg: "no signed key in vault response"},
+		{name: "sign not a certificate", response: func(t *testing.T, body map[string]any) map[string]any {
+			return map[string]any{"data": map[string]any{"signed_key": body["public_key"]}}
+		}, errMsg: "signed key is not a certificate"},
+		{name: "sign invalid json", raw: "{", errMsg: "decode vault response failed: unexpected EOF"},
+		{name: "otp", otp: true, response: func(t *testing.T, body map[string]any) map[string]any {
+			assert.Equal("127.0.0.1", body["ip"])
+			assert.Equal("admin", body["username"])
+			return map[string]any{"data": map[string]any{"key": "otp-key"}}
+		}},
+		{name: "otp no key", otp: true, response: func(*testing.T, map[string]any) map[string]any {
+			return map[string]any{"data": map[string]any{"key_type": "otp"}}
+		}, errMsg: "no otp key in vault response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path)
+				assert.Equal("test-token", r.Header.Get("X-Vault-Token"))
+				if r.URL.Path == "/v1/auth/token/renew-self" {
+					// the token is not renewable, which is fine
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				var body map[string]any
+				assert.Nil(json.NewDecoder(r.Body).Decode(&body))
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				if tt.response == nil {
+					_, _ = w.Write([]byte(tt.raw))
+					return
+				}
+				assert.Nil(json.NewEncoder(w).Encode(tt.response(t, body)))
+			}))
+			defer server.Close()
+
+			client := &hcVaultClient{addr: server.URL, token: "test-token", mount: "ssh", role: "dev"}
+			if tt.otp {
+				otp, err := client.createOTP("127.0.0.1", "admin")
+				assert.Equal([]string{"/v1/auth/token/renew-self", "/v1/ssh/creds/dev"}, paths)
+				if tt.errMsg != "" {
+					assert.EqualError(err, tt.errMsg)
+					return
+				}
+				assert.Nil(err)
+				assert.Equal("otp-key", otp)
+				return
+			}
+			signer, err := client.signPublicKey("admin")
+			assert.Equal([]string{"/v1/auth/token/renew-self", "/v1/ssh/sign/dev"}, paths)
+			if tt.errMsg != "" {
+				assert.EqualError(err, tt.errMsg)
+				return
+			}
+			assert.Nil(err)
+			cert, ok := signer.PublicKey().(*ssh.Certificate)
+			assert.True(ok)
+			assert.Equal([]string{"admin"}, cert.ValidPrincipals)
+			assert.Equal(caSigner.PublicKey().Marshal(), cert.SignatureKey.Marshal())
+		})
+	}
+
+	// the server is not reachable
+	client := &hcVaultClient{addr: "http://127.0.0.1:1", token: "test-token", mount: "ssh", role: "dev"}
+	_, err = client.signPublicKey("admin")
+	assert.NotNil(err)
+}
@@ -0,0 +1,262 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const kMaxHistoryChoices = 20
+
+const kMaxPromptLineSize = 256
+
+// the line typed after such a prompt, e.g., `[sudo] password for alice: `, is a secret typed without echo.
+var secretPromptRegexp = regexp.MustCompile(`(?i)\b(password|passphrase|passcode|pin|token|otp|verification code)\b[^:]*:\s*$`)
+
+type historyEntry struct {
+	Time    int64  `json:"time"`
+	Host    string `json:"host"`
+	Command string `json:"command"`
+}
+
+func getHistoryPath() string {
+	return filepath.Join(userHomeDir, ".ssh", "tssh-history.jsonl")
+}
+
+// isHistoryEnabled returns whether to record the commands run on the host, e.g.:
+//
+//	Host web*
+//	    #!! ExRecordHistory yes
+func isHistoryEnabled(args *sshArgs) bool {
+	return strings.ToLower(getExOptionConfig(args, "ExRecordHistory")) == "yes"
+}
+
+// recordHistory appends the command to the history file, the command starting
+// with a space is not recorded, the same as HISTCONTROL=ignorespace of bash.
+func recordHistory(host, command string) {
+	if strings.TrimSpace(command) == "" || strings.HasPrefix(command, " ") {
+		return
+	}
+	data, err := json.Marshal(&historyEntry{Time: time.Now().Unix(), Host: host, Command: command})
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(getHistoryPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		debug("open %s failed: %v", getHistoryPath(), err)
+		return
+	}
+	defer file.Close()
+	if stat, err := file.Stat(); err == nil && stat.Mode().Perm() != 0600 {
+		// the file created by an older version may be readable by others
+		_ = file.Chmod(0600)
+	}
+	if err := writeAll(file, append(data, '\n')); err != nil {
+		debug("write %s failed: %v", getHistoryPath(), err)
+	}
+}
+
+// loadHistory returns the recorded commands, the latest first.
+func loadHistory() []*historyEntry {
+	file, err := os.Open(getHistoryPath())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var entries []*historyEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Command == "" {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
+
+// searchHistory returns the latest distinct commands of which the host or the command
+// contains all the keywords, case-insensitively.
+func searchHistory(entries []*historyEntry, keywords []string) []*historyEntry {
+	var result []*historyEntry
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		text := strings.ToLower(entry.Host + " " + entry.Command)
+		matched := true
+		for _, keyword := range keywords {
+			if !strings.Contains(text, strings.ToLower(keyword)) {
+				matched = false
+				break
+			}
+		}
+		key := entry.Host + "\x00" + entry.Command
+		if !matched || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, entry)
+	}
+	return result
+}
+
+func formatHistoryEntry(entry *historyEntry) string {
+	return fmt.Sprintf("%s  %s  %s", time.Unix(entry.Time, 0).Format("2006-01-02 15:04"), entry.Host, entry.Command)
+}
+
+// historyRecorder records the command lines typed in the interactive session,
+// nothing is recorded on the alternate screen, e.g., in vim, or after a password prompt.
+type historyRecorder struct {
+	typedLine
+	reader     io.Reader
+	host       string
+	mutex      sync.Mutex
+	screen     outputTracker
+	promptLine []byte
+	record     func(host, command string)
+}
+
+// trackOutput tracks the screen mode and the last line of the output, without the escape sequences.
+func (r *historyRecorder) trackOutput(buf []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, c := range buf {
+		ground := r.screen.isGround()
+		r.screen.track(buf[i : i+1])
+		switch {
+		case c == '\r' || c == '\n':
+			r.promptLine = r.promptLine[:0]
+		case ground && c >= 0x20 && c != 0x7f:
+			if len(r.promptLine) >= kMaxPromptLineSize {
+				r.promptLine = append(r.promptLine[:0], r.promptLine[len(r.promptLine)-kMaxPromptLineSize/2:]...)
+			}
+			r.promptLine = append(r.promptLine, c)
+		}
+	}
+}
+
+// isSecretLine returns whether the line is being typed on the alternate screen or after a password prompt.
+func (r *historyRecorder) isSecretLine() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.screen.altScreen || secretPromptRegexp.Match(r.promptLine)
+}
+
+func (r *historyRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	for _, c := range p[:n] {
+		if (c == '\r' || c == '\n') && r.escapes.isGround() {
+			if len(r.line) > 0 && !r.isSecretLine() {
+				r.record(r.host, string(r.line))
+			}
+			r.line = r.line[:0]
+			continue
+		}
+		r.editLine(c)
+	}
+	return n, err
+}
+
+type historyOutput struct {
+	reader   io.Reader
+	recorder *historyRecorder
+}
+
+func (o *historyOutput) Read(p []byte) (int, error) {
+	n, err := o.reader.Read(p)
+	if n > 0 {
+		o.recorder.trackOutput(p[:n])
+	}
+	return n, err
+}
+
+// wrapCommandHistory records the command lines typed in the interactive session if ExRecordHistory is yes.
+func wrapCommandHistory(args *sshArgs, ss *sshSession, stdin io.Reader) io.Reader {
+	if !isHistoryEnabled(args) {
+		return stdin
+	}
+	recorder := &historyRecorder{reader: stdin, host: args.Destination, record: recordHistory}
+	ss.serverOut = &historyOutput{ss.serverOut, recorder}
+	return recorder
+}
+
+// execHistory chooses a recorded command to run again, e.g., `tssh --history web3 nginx`
+// lists the commands run on web3 with nginx, the destination and the command are the keywords.
+func execHistory(args *sshArgs) (int, bool) {
+	var keywords []string
+	if args.Destination != "" {
+		keywords = append(keywords, args.Destination)
+	}
+	if args.Command != "" {
+		keywords = append(keywords, args.Command)
+	}
+	keywords = append(keywords, args.Argument...)
+	entries := searchHistory(loadHistory(), keywords)
+
+	if args.JSON {
+		if entries == nil {
+			entries = []*historyEntry{}
+		}
+		printToolsJSON(entries)
+		return 0, true
+	}
+	if len(entries) == 0 {
+		toolsErrorExit("no command is found in the history, record it by `ExRecordHistory yes`")
+	}
+	if !isTerminal {
+		for _, entry := range entries {
+			fmt.Fprintf(os.Stdout, "%s\n", formatHistoryEntry(entry))
+		}
+		return 0, true
+	}
+
+	if len(entries) > kMaxHistoryChoices {
+		entries = entries[:kMaxHistoryChoices]
+	}
+	items := make([]string, len(entries))
+	for i, entry := range entries {
+		items[i] = formatHistoryEntry(entry)
+	}
+	choice := promptList("Run the command again", "The latest first, add keywords to narrow down", items)
+	for i, item := range items {
+		if item == choice {
+			args.Destination, args.Command, args.Argument = entries[i].Host, entries[i].Command, nil
+			return 0, false
+		}
+	}
+	return 0, true
+}
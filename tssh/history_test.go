@@ -0,0 +1,129 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandHistory(t *testing.T) {
+	assert := assert.New(t)
+	defer func(home string) { userHomeDir = home }(userHomeDir)
+	userHomeDir = t.TempDir()
+	assert.Nil(os.MkdirAll(filepath.Join(userHomeDir, ".ssh"), 0700))
+
+	recordHistory("web3", "systemctl restart nginx")
+	recordHistory("web3", "uptime")
+	recordHistory("db1", "uptime")
+	recordHistory("web3", " secret command")
+	recordHistory("web3", "  ")
+	recordHistory("web3", "systemctl restart nginx")
+
+	entries := loadHistory()
+	assert.Len(entries, 4)
+	assert.Equal("web3", entries[0].Host)
+	assert.Equal("systemctl restart nginx", entries[0].Command)
+	assert.Equal("db1", entries[1].Host)
+
+	commands := func(keywords ...string) []string {
+		var result []string
+		for _, entry := range searchHistory(entries, keywords) {
+			result = append(result, entry.Host+": "+entry.Command)
+		}
+		return result
+	}
+	assert.Equal([]string{"web3: systemctl restart nginx", "db1: uptime", "web3: uptime"}, commands())
+	assert.Equal([]string{"web3: systemctl restart nginx", "web3: uptime"}, commands("WEB3"))
+	assert.Equal([]string{"web3: systemctl restart nginx"}, commands("web3", "nginx"))
+	assert.Empty(commands("db1", "nginx"))
+}
+
+func TestHistoryRecorder(t *testing.T) {
+	assert := assert.New(t)
+	var recorded []string
+	input := "ls -l\rcd /tmpx\x7f\x7f\ruptime\x03\rvim a\r\r:wq\r\x1b[Awho\r"
+	output := io.MultiReader(strings.NewReader("\033[?1049h"), strings.NewReader("\033[?1049l"))
+	recorder := &historyRecorder{reader: strings.NewReader(input), host: "web", record: func(host, command string) {
+		recorded = append(recorded, host+": "+command)
+	}}
+	screen := &historyOutput{output, recorder}
+
+	buf := make([]byte, 1)
+	var data bytes.Buffer
+	for {
+		n, err := recorder.Read(buf)
+		data.Write(buf[:n])
+		if err != nil {
+			break
+		}
+		// enter the alternate screen after vim starts, and leave it after :wq
+		switch data.String() {
+		case "ls -l\rcd /tmpx\x7f\x7f\ruptime\x03\rvim a\r", "ls -l\rcd /tmpx\x7f\x7f\ruptime\x03\rvim a\r\r:wq\r":
+			_, _ = screen.Read(make([]byte, 8))
+		}
+	}
+	assert.Equal(input, data.String())
+	assert.Equal([]string{"web: ls -l", "web: cd /tm", "web: vim a", "web: who"}, recorded)
+}
+
+func TestHistorySecretPrompt(t *testing.T) {
+	assert := assert.New(t)
+	var recorded []string
+	recorder := &historyRecorder{host: "web", record: func(host, command string) {
+		recorded = append(recorded, command)
+	}}
+	typeLine := func(output, line string) {
+		recorder.trackOutput([]byte(output))
+		recorder.reader = strings.NewReader(line + "\r")
+		_, _ = io.ReadAll(recorder)
+	}
+	typeLine("alice@web:~$ ", "sudo -i")
+	typeLine("\r\n[sudo] password for alice: ", "s3cret")
+	typeLine("\r\n\033[01;32mroot@web\033[00m:~# ", "mysql -p")
+	typeLine("\r\nEnter \033[1mpassword\033[0m: ", "db-s3cret")
+	typeLine("\r\nmysql> ", "select 1;")
+	typeLine("\r\nVerification code:", "123456")
+	typeLine("\r\nTyping: ", "not a secret")
+	assert.Equal([]string{"sudo -i", "mysql -p", "select 1;", "not a secret"}, recorded)
+
+	defer func(home string) { userHomeDir = home }(userHomeDir)
+	userHomeDir = t.TempDir()
+	assert.Nil(os.MkdirAll(filepath.Join(userHomeDir, ".ssh"), 0700))
+	assert.Nil(os.WriteFile(getHistoryPath(), nil, 0644))
+	recordHistory("web", "uptime")
+	if runtime.GOOS != "windows" {
+		stat, err := os.Stat(getHistoryPath())
+		assert.Nil(err)
+		assert.Equal(os.FileMode(0600), stat.Mode().Perm())
+	}
+}
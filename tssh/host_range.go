@@ -0,0 +1,200 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const kMaxHostRange = 1024
+
+// isHostRange returns whether the destination contains braces or numeric ranges.
+func isHostRange(dest string) bool {
+	hosts, err := expandHostRange(dest)
+	return err != nil || len(hosts) > 1
+}
+
+// expandHostRange expands the braces and the numeric ranges in the destination, e.g.,
+//
+//	web{01..03}   => web01 web02 web03
+//	{web,db}1     => web1 db1
+//	10.0.0.[1-3]  => 10.0.0.1 10.0.0.2 10.0.0.3
+//	10.0.0.[1,5]  => 10.0.0.1 10.0.0.5
+//
+// The brackets which are not numeric ranges, e.g., [::1]:22, are kept as they are.
+func expandHostRange(dest string) ([]string, error) {
+	for i := 0; i < len(dest); i++ {
+		var closing byte
+		switch dest[i] {
+		case '{':
+			closing = '}'
+		case '[':
+			closing = ']'
+		default:
+			continue
+		}
+		j := strings.IndexByte(dest[i+1:], closing)
+		if j < 0 {
+			continue
+		}
+		j += i + 1
+		items, err := expandRangeItems(dest[i+1:j], dest[i] == '{')
+		if err != nil {
+			return nil, fmt.Errorf("invalid range in [%s]: %v", dest, err)
+		}
+		if items == nil {
+			continue
+		}
+		rests, err := expandHostRange(dest[j+1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(items)*len(rests) > kMaxHostRange {
+			return nil, fmt.Errorf("[%s] expands to more than %d hosts", dest, kMaxHostRange)
+		}
+		hosts := make([]string, 0, len(items)*len(rests))
+		for _, item := range items {
+			for _, rest := range rests {
+				hosts = append(hosts, dest[:i]+item+rest)
+			}
+		}
+		return hosts, nil
+	}
+	return []string{dest}, nil
+}
+
+// expandRangeItems returns nil if the content is not a range, e.g., the IPv6 address in brackets.
+func expandRangeItems(content string, brace bool) ([]string, error) {
+	if brace {
+		if begin, end, ok := strings.Cut(content, ".."); ok {
+			return expandNumericRange(begin, end)
+		}
+		if strings.Contains(content, ",") {
+			return strings.Split(content, ","), nil
+		}
+		return nil, nil
+	}
+
+	var items []string
+	for _, part := range strings.Split(content, ",") {
+		begin, end, ok := strings.Cut(part, "-")
+		if !ok {
+			end = begin
+		}
+		if !isDigits(begin) || !isDigits(end) {
+			return nil, nil
+		}
+		numbers, err := expandNumericRange(begin, end)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, numbers...)
+	}
+	return items, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// expandNumericRange keeps the leading zeros, e.g., 01..10 => 01 02 ... 10.
+func expandNumericRange(begin, end string) ([]string, error) {
+	if !isDigits(begin) || !isDigits(end) {
+		return nil, fmt.Errorf("%s..%s is not a numeric range", begin, end)
+	}
+	first, err := strconv.Atoi(begin)
+	if err != nil {
+		return nil, err
+	}
+	last, err := strconv.Atoi(end)
+	if err != nil {
+		return nil, err
+	}
+	if first > last {
+		return nil, fmt.Errorf("%s is greater than %s", begin, end)
+	}
+	if last-first >= kMaxHostRange {
+		return nil, fmt.Errorf("%s..%s has more than %d numbers", begin, end, kMaxHostRange)
+	}
+	width := 0
+	if len(begin) > 1 && begin[0] == '0' || len(end) > 1 && end[0] == '0' {
+		width = len(begin)
+		if len(end) > width {
+			width = len(end)
+		}
+	}
+	items := make([]string, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		items = append(items, fmt.Sprintf("%0*d", width, n))
+	}
+	return items, nil
+}
+
+// matchHostRange returns whether the alias or the host name is one of the expanded keyword.
+func matchHostRange(alias, host string, expanded []string) bool {
+	for _, name := range expanded {
+		if strings.EqualFold(name, alias) || strings.EqualFold(name, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandKeywords expands the search keywords which are host ranges, nil for the plain keywords.
+func expandKeywords(keywords []string) [][]string {
+	expanded := make([][]string, len(keywords))
+	for i, keyword := range keywords {
+		if hosts, err := expandHostRange(keyword); err == nil && len(hosts) > 1 {
+			expanded[i] = hosts
+		}
+	}
+	return expanded
+}
+
+// checkHostRangeDest returns an error if the destination is a host range, but it's neither for the batch tools
+// nor for choosing a host interactively, e.g., `tssh web{01..03} uptime`, rather than login to the literal name.
+func checkHostRangeDest(args *sshArgs, interactive bool) error {
+	if !isHostRange(args.Destination) {
+		return nil
+	}
+	if args.Command != "" {
+		return fmt.Errorf("the host range %s can't run a command, use --script to run it on all the hosts", args.Destination)
+	}
+	if !interactive {
+		return fmt.Errorf("the host range %s can only be used with the batch tools or to choose a host interactively",
+			args.Destination)
+	}
+	return nil
+}
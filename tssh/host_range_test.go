@@ -0,0 +1,74 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandHostRange(t *testing.T) {
+	assert := assert.New(t)
+	assertExpand := func(dest string, expected ...string) {
+		t.Helper()
+		hosts, err := expandHostRange(dest)
+		assert.Nil(err)
+		assert.Equal(expected, hosts)
+	}
+	assertExpand("web1", "web1")
+	assertExpand("web{01..03}", "web01", "web02", "web03")
+	assertExpand("web{8..10}.example.com", "web8.example.com", "web9.example.com", "web10.example.com")
+	assertExpand("{web,db}{1..2}", "web1", "web2", "db1", "db2")
+	assertExpand("10.0.0.[1-3]", "10.0.0.1", "10.0.0.2", "10.0.0.3")
+	assertExpand("10.0.[0,2].[1-2,9]", "10.0.0.1", "10.0.0.2", "10.0.0.9", "10.0.2.1", "10.0.2.2", "10.0.2.9")
+	assertExpand("root@[::1]:22", "root@[::1]:22")
+	assertExpand("web{a}", "web{a}")
+	assertExpand("web{1..2", "web{1..2")
+
+	for _, dest := range []string{"web{3..1}", "web{a..c}", "web[1-5000]", "{1..100}{1..100}"} {
+		_, err := expandHostRange(dest)
+		assert.NotNil(err, dest)
+	}
+
+	assert.False(isHostRange("web1"))
+	assert.False(isHostRange("[::1]"))
+	assert.True(isHostRange("web[1-2]"))
+	assert.True(isHostRange("web{3..1}"))
+
+	assert.True(matchHost(&sshHost{Alias: "web02", Host: "10.0.0.2"}, []string{"web{01..03}"}))
+	assert.True(matchHost(&sshHost{Alias: "web", Host: "10.0.0.2"}, []string{"10.0.0.[1-3]"}))
+	assert.False(matchHost(&sshHost{Alias: "web020", Host: "10.0.0.20"}, []string{"web{01..03}"}))
+	assert.False(matchHost(&sshHost{Alias: "web02", Host: "10.0.0.2", GroupLabels: "db"}, []string{"web{01..03}", "prod"}))
+}
+
+func TestCheckHostRangeDest(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(checkHostRangeDest(&sshArgs{Destination: "web01", Command: "uptime"}, false))
+	assert.Nil(checkHostRangeDest(&sshArgs{Destination: "[::1]:22"}, false))
+	assert.Nil(checkHostRangeDest(&sshArgs{Destination: "web{01..03}"}, true))
+	assert.ErrorContains(checkHostRangeDest(&sshArgs{Destination: "web{01..03}", Command: "uptime"}, true), "use --script")
+	assert.ErrorContains(checkHostRangeDest(&sshArgs{Destination: "10.0.0.[1-3]"}, false), "batch tools")
+}
@@ -0,0 +1,172 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// identityAttempt keeps the keys of a login attempt in the order they are offered, and which of them are offered.
+// The signers are shared by the jump hosts and the batch hosts, so the offered keys are tracked per attempt.
+type identityAttempt struct {
+	mutex   sync.Mutex
+	signers []*sshSigner
+	offered map[*sshSigner]bool
+}
+
+// identityAttempts keeps the last login attempt for each destination.
+var identityAttempts = make(map[string]*identityAttempt)
+var identityAttemptsMutex sync.Mutex
+
+func newIdentityAttempt(dest string, signers []*sshSigner) *identityAttempt {
+	attempt := &identityAttempt{signers: signers, offered: make(map[*sshSigner]bool)}
+	identityAttemptsMutex.Lock()
+	defer identityAttemptsMutex.Unlock()
+	identityAttempts[dest] = attempt
+	return attempt
+}
+
+func getIdentityAttempt(dest string) *identityAttempt {
+	identityAttemptsMutex.Lock()
+	defer identityAttemptsMutex.Unlock()
+	return identityAttempts[dest]
+}
+
+func (a *identityAttempt) isOffered(signer *sshSigner) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.offered[signer]
+}
+
+// offeredSigner marks the key as offered in the attempt, when its public key is sent to the server.
+type offeredSigner struct {
+	*sshSigner
+	attempt *identityAttempt
+}
+
+func (s *offeredSigner) PublicKey() ssh.PublicKey {
+	s.attempt.mutex.Lock()
+	defer s.attempt.mutex.Unlock()
+	s.attempt.offered[s.sshSigner] = true
+	return s.pubKey
+}
+
+func getPreferredKey(args *sshArgs) string {
+	if fingerprint := getExOptionConfig(args, "ExPreferredKey"); fingerprint != "" {
+		return fingerprint
+	}
+	return userConfig.preferredKeys[strings.ToLower(args.Destination)]
+}
+
+// sortByPreferredKey moves the preferred key to the front, and keeps the order of the others.
+func sortByPreferredKey(signers []*sshSigner, fingerprint string) []*sshSigner {
+	sorted := make([]*sshSigner, 0, len(signers))
+	for _, signer := range signers {
+		if ssh.FingerprintSHA256(signer.pubKey) == fingerprint {
+			sorted = append(sorted, signer)
+		}
+	}
+	if len(sorted) == 0 {
+		debug("the preferred key [%s] is not available", fingerprint)
+		return signers
+	}
+	for _, signer := range signers {
+		if ssh.FingerprintSHA256(signer.pubKey) != fingerprint {
+			sorted = append(sorted, signer)
+		}
+	}
+	return sorted
+}
+
+func isAuthFailure(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "unable to authenticate") || strings.Contains(msg, "too many authentication failures")
+}
+
+func getIdentityDesc(signer *sshSigner) string {
+	return fmt.Sprintf("%s %s %s", ssh.FingerprintSHA256(signer.pubKey), signer.pubKey.Type(), signer.path)
+}
+
+// pickIdentityOnAuthFailure shows the offered keys, and lets the user pick the key to try first,
+// in case the right key is never offered due to the MaxAuthTries of the server. It's enabled by
+//
+//	Host *
+//	    #!! ExIdentityPicker yes
+func pickIdentityOnAuthFailure(args *sshArgs, err error) string {
+	if !isTerminal || isBatchMode(args) || strings.ToLower(getExOptionConfig(args, "ExIdentityPicker")) != "yes" {
+		return ""
+	}
+	attempt := getIdentityAttempt(args.Destination)
+	if attempt == nil || len(attempt.signers) < 2 || !isAuthFailure(err) {
+		return ""
+	}
+	fmt.Fprintf(os.Stderr, "\033[0;33m%v\033[0m\r\n", err)
+	fmt.Fprintf(os.Stderr, "The keys offered to %s:\r\n", args.Destination)
+	var items []string
+	for _, signer := range attempt.signers {
+		if attempt.isOffered(signer) {
+			fmt.Fprintf(os.Stderr, "  %s\r\n", getIdentityDesc(signer))
+		}
+		items = append(items, getIdentityDesc(signer))
+	}
+	picked := promptList("Pick the key to try first", "Retry login with the picked key in front of the others", items)
+	fingerprint, _, _ := strings.Cut(picked, " ")
+	return fingerprint
+}
+
+// rememberPreferredKey asks to write the picked key to ~/.tssh.conf as PreferredKey.<alias>
+func rememberPreferredKey(alias, fingerprint string) {
+	if userConfig.preferredKeys[strings.ToLower(alias)] == fingerprint {
+		return
+	}
+	path := filepath.Join(userHomeDir, ".tssh.conf")
+	if !promptBoolInput(fmt.Sprintf("Remember the key for %s in %s", alias, path), "The key will be tried first for the next login", true) {
+		return
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		warning("open %s failed: %v", path, err)
+		return
+	}
+	defer file.Close()
+	if err := ensureNewline(file); err != nil {
+		warning("write %s failed: %v", path, err)
+		return
+	}
+	if err := writeAll(file, []byte(fmt.Sprintf("PreferredKey.%s = %s\n", alias, fingerprint))); err != nil {
+		warning("write %s failed: %v", path, err)
+		return
+	}
+	if userConfig.preferredKeys == nil {
+		userConfig.preferredKeys = make(map[string]string)
+	}
+	userConfig.preferredKeys[strings.ToLower(alias)] = fingerprint
+}
@@ -0,0 +1,70 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSortByPreferredKey(t *testing.T) {
+	assert := assert.New(t)
+	var signers []*sshSigner
+	for i := 0; i < 3; i++ {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		assert.Nil(err)
+		pubKey, err := ssh.NewPublicKey(pub)
+		assert.Nil(err)
+		signers = append(signers, &sshSigner{path: fmt.Sprintf("id_%d", i), pubKey: pubKey})
+	}
+
+	sorted := sortByPreferredKey(signers, ssh.FingerprintSHA256(signers[2].pubKey))
+	assert.Equal([]*sshSigner{signers[2], signers[0], signers[1]}, sorted)
+	sorted = sortByPreferredKey(signers, "SHA256:unknown")
+	assert.Equal(signers, sorted)
+
+	args := &sshArgs{Destination: "Web", Option: sshOption{map[string][]string{}}}
+	defer func(keys map[string]string) { userConfig.preferredKeys = keys }(userConfig.preferredKeys)
+	userConfig.preferredKeys = map[string]string{"web": "SHA256:web"}
+	assert.Equal("SHA256:web", getPreferredKey(args))
+	args.Option.options["expreferredkey"] = []string{"SHA256:picked"}
+	assert.Equal("SHA256:picked", getPreferredKey(args))
+
+	// the offered keys are tracked per attempt, though the signers are shared
+	attempt1 := newIdentityAttempt("web", signers)
+	attempt2 := newIdentityAttempt("web", signers)
+	assert.Same(attempt2, getIdentityAttempt("web"))
+	signer := &offeredSigner{signers[0], attempt1}
+	assert.Equal(signers[0].pubKey, signer.PublicKey())
+	assert.True(attempt1.isOffered(signers[0]))
+	assert.False(attempt1.isOffered(signers[1]))
+	assert.False(attempt2.isOffered(signers[0]))
+	assert.True(isAuthFailure(fmt.Errorf("ssh: unable to authenticate, attempted methods [none publickey]")))
+}
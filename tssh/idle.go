@@ -0,0 +1,241 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idleMonitor closes or locks the interactive session after no keyboard input for ExIdleTimeout, e.g.:
+//
+//	Host prod*
+//	    ExIdleTimeout 15
+//	    ExIdleAction lock
+//	    encExIdleLockPassword xxxxxx
+//
+// While locked, the remote output is held back, and the input only goes to the passphrase prompt.
+// The session is closed after 3 wrong passphrases. The timer restarts instead of firing while
+// trz / tsz or the queued transfers are running, so that a long transfer is not interrupted.
+type idleMonitor struct {
+	stdin    io.Reader
+	output   io.Writer
+	timeout  time.Duration
+	password string
+	onClose  func()
+	timer    *time.Timer
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	locked   bool
+	closed   bool
+	input    []byte
+	failures int
+	busy     []func() bool
+}
+
+const kIdleLockMaxFailures = 3
+
+// parseIdleTimeout accepts the minutes, or a duration such as 90s and 1h.
+func parseIdleTimeout(value string) (time.Duration, error) {
+	if minutes, err := strconv.Atoi(value); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return duration, nil
+	}
+	return 0, fmt.Errorf("invalid ExIdleTimeout [%s]", value)
+}
+
+func newIdleMonitor(stdin io.Reader, output io.Writer, timeout time.Duration, password string, onClose func()) *idleMonitor {
+	m := &idleMonitor{stdin: stdin, output: output, timeout: timeout, password: password, onClose: onClose}
+	m.cond = sync.NewCond(&m.mutex)
+	m.timer = time.AfterFunc(timeout, m.onIdle)
+	return m
+}
+
+func (m *idleMonitor) describe() string {
+	if m.timeout%time.Minute == 0 {
+		return fmt.Sprintf("%d minutes", m.timeout/time.Minute)
+	}
+	return m.timeout.String()
+}
+
+// addBusy restarts the timer instead of firing while the busy returns true.
+func (m *idleMonitor) addBusy(busy func() bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.busy = append(m.busy, busy)
+}
+
+func (m *idleMonitor) onIdle() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.locked || m.closed {
+		return
+	}
+	for _, busy := range m.busy {
+		if busy() {
+			m.timer.Reset(m.timeout)
+			return
+		}
+	}
+	if m.password == "" {
+		m.closeLocked(fmt.Sprintf("Disconnected after %s without input", m.describe()))
+		return
+	}
+	m.locked = true
+	m.input = m.input[:0]
+	// switch to the alternate screen to hide the session
+	fmt.Fprintf(m.output, "\033[?1049h\033[H\033[2J\033[0;33mLocked after %s without input.\033[0m\r\n"+
+		"Enter the passphrase to unlock: ", m.describe())
+}
+
+func (m *idleMonitor) closeLocked(reason string) {
+	if m.locked {
+		fmt.Fprintf(m.output, "\033[?1049l")
+	}
+	m.closed = true
+	m.locked = false
+	m.cond.Broadcast()
+	fmt.Fprintf(m.output, "\r\n\033[0;33m%s\033[0m\r\n", reason)
+	go m.onClose()
+}
+
+func (m *idleMonitor) unlockInput(buf []byte) {
+	for _, c := range buf {
+		switch c {
+		case '\r', '\n':
+			if subtle.ConstantTimeCompare(m.input, []byte(m.password)) == 1 {
+				m.locked = false
+				m.failures = 0
+				m.timer.Reset(m.timeout)
+				fmt.Fprintf(m.output, "\033[?1049l")
+				m.cond.Broadcast()
+				return
+			}
+			m.failures++
+			m.input = m.input[:0]
+			if m.failures >= kIdleLockMaxFailures {
+				m.closeLocked(fmt.Sprintf("Disconnected after %d wrong passphrases", m.failures))
+				return
+			}
+			fmt.Fprintf(m.output, "\r\nWrong passphrase, try again: ")
+		case '\b', 0x7f:
+			if len(m.input) > 0 {
+				m.input = m.input[:len(m.input)-1]
+			}
+		case 0x03, 0x15: // Ctrl+C and Ctrl+U clear the input
+			m.input = m.input[:0]
+		default:
+			m.input = append(m.input, c)
+		}
+	}
+}
+
+func (m *idleMonitor) Read(p []byte) (int, error) {
+	for {
+		n, err := m.stdin.Read(p)
+		m.mutex.Lock()
+		if m.closed {
+			m.mutex.Unlock()
+			return 0, io.EOF
+		}
+		if n > 0 && m.locked {
+			m.unlockInput(p[:n])
+			m.mutex.Unlock()
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if n > 0 {
+			m.timer.Reset(m.timeout)
+		}
+		m.mutex.Unlock()
+		return n, err
+	}
+}
+
+// holdOutput blocks the remote output while the session is locked.
+func (m *idleMonitor) holdOutput() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for m.locked {
+		m.cond.Wait()
+	}
+}
+
+type idleOutput struct {
+	reader  io.Reader
+	monitor *idleMonitor
+}
+
+func (o *idleOutput) Read(p []byte) (int, error) {
+	o.monitor.holdOutput()
+	n, err := o.reader.Read(p)
+	o.monitor.holdOutput()
+	return n, err
+}
+
+// wrapIdleTimeout watches the keyboard input of the interactive session if ExIdleTimeout is set.
+func wrapIdleTimeout(args *sshArgs, ss *sshSession, stdin io.Reader) io.Reader {
+	value := getExOptionConfig(args, "ExIdleTimeout")
+	if value == "" || strings.ToLower(value) == "none" || value == "0" {
+		return stdin
+	}
+	timeout, err := parseIdleTimeout(value)
+	if err != nil {
+		warning("%v", err)
+		return stdin
+	}
+
+	password := ""
+	switch action := strings.ToLower(getExOptionConfig(args, "ExIdleAction")); action {
+	case "", "close":
+	case "lock":
+		if password = getSecretConfig(args.Destination, "ExIdleLockPassword"); password == "" {
+			warning("ExIdleAction lock requires ExIdleLockPassword, the idle session will be closed instead")
+		}
+	default:
+		warning("unknown ExIdleAction [%s], the idle session will be closed", action)
+	}
+
+	monitor := newIdleMonitor(stdin, os.Stderr, timeout, password, func() { ss.session.Close() })
+	onExit(func() { monitor.timer.Stop() })
+	ss.serverOut = &idleOutput{ss.serverOut, monitor}
+	ss.idle = monitor
+	if password != "" {
+		debug("the session will be locked after %s without input", monitor.describe())
+	} else {
+		debug("the session will be closed after %s without input", monitor.describe())
+	}
+	return monitor
+}
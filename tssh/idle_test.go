@@ -0,0 +1,170 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) Close() error {
+	return nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func TestParseIdleTimeout(t *testing.T) {
+	assert := assert.New(t)
+	timeout, err := parseIdleTimeout("15")
+	assert.Nil(err)
+	assert.Equal(15*time.Minute, timeout)
+	timeout, err = parseIdleTimeout("90s")
+	assert.Nil(err)
+	assert.Equal(90*time.Second, timeout)
+	for _, value := range []string{"-1", "abc", "0s"} {
+		_, err = parseIdleTimeout(value)
+		assert.NotNil(err, value)
+	}
+}
+
+func TestIdleMonitorClose(t *testing.T) {
+	assert := assert.New(t)
+	stdinReader, stdinWriter := io.Pipe()
+	output := &syncBuffer{}
+	closed := make(chan struct{})
+	monitor := newIdleMonitor(stdinReader, output, 50*time.Millisecond, "", func() { close(closed) })
+	defer monitor.timer.Stop()
+
+	go func() { _, _ = stdinWriter.Write([]byte("ls\r")) }()
+	buf := make([]byte, 10)
+	n, err := monitor.Read(buf)
+	assert.Nil(err)
+	assert.Equal("ls\r", string(buf[:n]))
+
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		assert.Fail("the idle session is not closed")
+	}
+	assert.Contains(output.String(), "Disconnected after 50ms without input")
+}
+
+func TestIdleMonitorBusy(t *testing.T) {
+	assert := assert.New(t)
+	output := &syncBuffer{}
+	closed := make(chan struct{})
+	monitor := newIdleMonitor(strings.NewReader(""), output, 50*time.Millisecond, "", func() { close(closed) })
+	defer monitor.timer.Stop()
+
+	// the timer restarts while transferring, and fires after the transfer is done
+	var transferring atomic.Bool
+	transferring.Store(true)
+	monitor.addBusy(transferring.Load)
+	select {
+	case <-closed:
+		assert.Fail("the session is closed while transferring")
+	case <-time.After(200 * time.Millisecond):
+	}
+	transferring.Store(false)
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		assert.Fail("the idle session is not closed after the transfer")
+	}
+}
+
+func TestIdleMonitorLock(t *testing.T) {
+	assert := assert.New(t)
+	stdinReader, stdinWriter := io.Pipe()
+	output := &syncBuffer{}
+	closed := make(chan struct{})
+	monitor := newIdleMonitor(stdinReader, output, 50*time.Millisecond, "secret", func() { close(closed) })
+	defer monitor.timer.Stop()
+	remote := &idleOutput{strings.NewReader("remote output"), monitor}
+
+	assert.Eventually(func() bool {
+		return strings.Contains(output.String(), "Enter the passphrase to unlock")
+	}, 3*time.Second, 10*time.Millisecond)
+
+	outputDone := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 100)
+		n, _ := remote.Read(buf)
+		outputDone <- string(buf[:n])
+	}()
+
+	inputDone := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 100)
+		n, _ := monitor.Read(buf)
+		inputDone <- string(buf[:n])
+	}()
+
+	_, _ = stdinWriter.Write([]byte("wrong\r"))
+	assert.Eventually(func() bool {
+		return strings.Contains(output.String(), "Wrong passphrase")
+	}, 3*time.Second, 10*time.Millisecond)
+	select {
+	case <-outputDone:
+		assert.Fail("the remote output is not held back while locked")
+	default:
+	}
+
+	// don't lock again before the input below
+	monitor.mutex.Lock()
+	monitor.timeout = time.Hour
+	monitor.mutex.Unlock()
+	_, _ = stdinWriter.Write([]byte("secrex\x7ft\r"))
+	assert.Equal("remote output", <-outputDone)
+	_, _ = stdinWriter.Write([]byte("pwd\r"))
+	assert.Equal("pwd\r", <-inputDone)
+	select {
+	case <-closed:
+		assert.Fail("the unlocked session is closed")
+	default:
+	}
+}
@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type jumpHostKey struct {
+	parent    *ssh.Client
+	transport string
+	proxy     string
+}
+
+type jumpHostConn struct {
+	ready  chan struct{}
+	client *ssh.Client
+	err    error
+}
+
+// jumpHostCache keeps the authenticated jump host connections within one invocation,
+// so that the targets behind the same bastion don't dial and authenticate it again.
+var jumpHostCache = struct {
+	sync.Mutex
+	conns map[jumpHostKey]*jumpHostConn
+}{conns: make(map[jumpHostKey]*jumpHostConn)}
+
+func removeJumpHostConn(key jumpHostKey, conn *jumpHostConn) {
+	jumpHostCache.Lock()
+	defer jumpHostCache.Unlock()
+	if jumpHostCache.conns[key] == conn {
+		delete(jumpHostCache.conns, key)
+	}
+}
+
+// connectJumpHost connects to the jump host through the parent, or through the proxy transport if no parent.
+func connectJumpHost(parent *ssh.Client, transport, proxy string, prefetch *loginPrefetch) (*ssh.Client, error) {
+	key := jumpHostKey{parent, transport, proxy}
+	jumpHostCache.Lock()
+	if conn, ok := jumpHostCache.conns[key]; ok {
+		jumpHostCache.Unlock()
+		<-conn.ready
+		if conn.err == nil {
+			debug("reuse the connection to jump host [%s]", proxy)
+		}
+		return conn.client, conn.err
+	}
+	conn := &jumpHostConn{ready: make(chan struct{})}
+	jumpHostCache.conns[key] = conn
+	jumpHostCache.Unlock()
+
+	conn.client, _, _, conn.err = sshConnect(&sshArgs{Destination: proxy, ProxyJump: transport, prefetch: prefetch}, parent, proxy)
+	close(conn.ready)
+	if conn.err != nil {
+		// don't keep the failure, the next target could try again
+		removeJumpHostConn(key, conn)
+		return nil, conn.err
+	}
+
+	go func() {
+		_ = conn.client.Wait()
+		removeJumpHostConn(key, conn)
+	}()
+	return conn.client, nil
+}
+
+// connectJumpHosts connects to the jump hosts one by one, since each hop dials through the former one.
+// The config, the keys and the known_hosts of the later hops are loaded in the background meanwhile.
+func connectJumpHosts(transport string, proxies []string) (*ssh.Client, error) {
+	prefetches := make([]*loginPrefetch, len(proxies))
+	for i := 1; i < len(proxies); i++ {
+		prefetches[i] = prefetchLogin(&sshArgs{Destination: proxies[i]})
+	}
+	// don't leave the prefetches running, e.g., the reused hops don't wait for them
+	defer func() {
+		for _, prefetch := range prefetches {
+			if prefetch != nil {
+				<-prefetch.done
+			}
+		}
+	}()
+
+	var client *ssh.Client
+	for i, proxy := range proxies {
+		hopStart := time.Now()
+		var err error
+		if i > 0 {
+			transport = ""
+		}
+		client, err = connectJumpHost(client, transport, proxy, prefetches[i])
+		if err != nil {
+			return nil, err
+		}
+		debug("jump host %d/%d [%s] is ready, took %v", i+1, len(proxies), proxy, time.Since(hopStart))
+	}
+	return client, nil
+}
@@ -0,0 +1,165 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// isProxyTransport returns whether the ProxyJump is a proxy to reach the first hop, e.g.:
+//
+//	Host server1
+//	    ProxyJump socks5://127.0.0.1:1080,jumpserver
+//	Host server2
+//	    ProxyJump tunnel://office
+//
+// socks://, socks5:// and http:// are the proxies, and tunnel://name is the -D socks5 proxy of
+// the named tunnel in ~/.tssh.conf, i.e., a tunnel run by `tssh --tunnel start name`.
+func isProxyTransport(proxy string) bool {
+	for _, scheme := range []string{"socks://", "socks5://", "socks5h://", "http://", "tunnel://"} {
+		if strings.HasPrefix(strings.ToLower(proxy), scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitProxyTransport separates the proxy transport from the jump hosts.
+func splitProxyTransport(proxies []string) (string, []string, error) {
+	for i, proxy := range proxies {
+		if isProxyTransport(proxy) && i > 0 {
+			return "", nil, fmt.Errorf("ProxyJump [%s] should be the first one of the jump hosts", proxy)
+		}
+	}
+	if len(proxies) > 0 && isProxyTransport(proxies[0]) {
+		return proxies[0], proxies[1:], nil
+	}
+	return "", proxies, nil
+}
+
+func getProxyTransportURL(transport string) (*url.URL, error) {
+	proxyURL, err := url.Parse(transport)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid ProxyJump [%s]", transport)
+	}
+	proxyURL.Scheme = strings.ToLower(proxyURL.Scheme)
+	if proxyURL.Scheme == "tunnel" {
+		addr, err := getTunnelSocksAddr(proxyURL.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "socks5", Host: addr}, nil
+	}
+	if proxyURL.Port() == "" {
+		port := "1080"
+		if proxyURL.Scheme == "http" {
+			port = "80"
+		}
+		proxyURL.Host = joinHostPort(proxyURL.Hostname(), port)
+	}
+	return proxyURL, nil
+}
+
+// dialProxyTransport connects to the address through the ProxyJump proxy transport.
+func dialProxyTransport(transport, addr string, timeout time.Duration) (net.Conn, error) {
+	proxyURL, err := getProxyTransportURL(transport)
+	if err != nil {
+		return nil, err
+	}
+	debug("dial [%s] via proxy [%s]", addr, proxyURL.Redacted())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if proxyURL.Scheme == "http" {
+		return dialHttpConnect(ctx, proxyURL, addr)
+	}
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, &net.Dialer{})
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 proxy [%s] dial [%s] failed: %v", proxyURL.Host, addr, err)
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// dialHttpConnect connects to the address through the http proxy by the CONNECT method.
+func dialHttpConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("dial http proxy [%s] failed: %v", proxyURL.Host, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credential := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		request += fmt.Sprintf("Proxy-Authorization: Basic %s\r\n", credential)
+	}
+	if _, err := conn.Write([]byte(request + "\r\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy [%s] write CONNECT failed: %v", proxyURL.Host, err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy [%s] read CONNECT response failed: %v", proxyURL.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy [%s] CONNECT [%s] failed: %s", proxyURL.Host, addr, resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return &bufferedConn{conn, reader}, nil
+}
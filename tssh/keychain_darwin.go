@@ -0,0 +1,59 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// the same keychain item as the ssh-add of macOS, so the passphrases are shared with it.
+const kKeychainService = "OpenSSH"
+
+func getKeychainPassphrase(path string) []byte {
+	out, err := exec.Command("security", "find-generic-password", "-s", kKeychainService, "-a", path, "-w").Output()
+	if err != nil {
+		debug("no passphrase for [%s] in keychain: %v", path, err)
+		return nil
+	}
+	return bytes.TrimSuffix(out, []byte("\n"))
+}
+
+func saveKeychainPassphrase(path string, passphrase []byte) error {
+	if strings.ContainsAny(path, "\"\r\n") {
+		return fmt.Errorf("unsupported path for keychain: %s", path)
+	}
+	// write the command to stdin, and encode the passphrase in hex, to keep it out of the process list
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a \"%s\" -l \"SSH: %s\" -X %s\n",
+		kKeychainService, path, path, hex.EncodeToString(passphrase)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
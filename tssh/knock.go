@@ -0,0 +1,117 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kDefaultPortKnockDelay = 200 * time.Millisecond
+
+type knockStep struct {
+	network string
+	port    string
+}
+
+// getPortKnockSteps parses ExPortKnock, a list of ports separated by spaces or commas, e.g.:
+//
+//	ExPortKnock 7000 8000/udp 9000/tcp
+func getPortKnockSteps(args *sshArgs) ([]knockStep, error) {
+	var steps []knockStep
+	for _, knock := range strings.Fields(strings.ReplaceAll(getExOptionConfig(args, "ExPortKnock"), ",", " ")) {
+		step := knockStep{network: "tcp", port: knock}
+		if idx := strings.IndexByte(knock, '/'); idx >= 0 {
+			step.port = knock[:idx]
+			step.network = strings.ToLower(knock[idx+1:])
+		}
+		if step.network != "tcp" && step.network != "udp" {
+			return nil, fmt.Errorf("invalid protocol in ExPortKnock [%s], should be tcp or udp", knock)
+		}
+		if port, err := strconv.ParseUint(step.port, 10, 16); err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid port in ExPortKnock [%s]", knock)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// getPortKnockDelay returns the delay in milliseconds between knocks and before dialing.
+func getPortKnockDelay(args *sshArgs) time.Duration {
+	delay := getExOptionConfig(args, "ExPortKnockDelay")
+	if delay == "" {
+		return kDefaultPortKnockDelay
+	}
+	ms, err := strconv.ParseUint(delay, 10, 32)
+	if err != nil {
+		warning("invalid ExPortKnockDelay [%s], use the default value %d", delay, kDefaultPortKnockDelay.Milliseconds())
+		return kDefaultPortKnockDelay
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// knockPorts executes the ExPortKnock sequence on the host of addr, which is about to be dialed,
+// so that the ExHostNames candidates are knocked as well.
+// The knocks are sent through the jump host if client is not nil, and only tcp is supported in that case.
+func knockPorts(args *sshArgs, addr string, client *ssh.Client) error {
+	steps, err := getPortKnockSteps(args)
+	if err != nil || len(steps) == 0 {
+		return err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address [%s]: %v", addr, err)
+	}
+	delay := getPortKnockDelay(args)
+	for _, step := range steps {
+		addr := joinHostPort(host, step.port)
+		debug("knock [%s] on %s", addr, step.network)
+		var conn net.Conn
+		switch {
+		case client != nil && step.network == "udp":
+			return fmt.Errorf("knock [%s] on udp through the jump host is not supported", addr)
+		case client != nil:
+			conn, _ = dialWithTimeout(client, "tcp", addr, delay+time.Second)
+		case step.network == "udp":
+			if conn, err = net.Dial("udp", addr); err != nil {
+				return fmt.Errorf("knock [%s] on udp failed: %v", addr, err)
+			}
+			_, _ = conn.Write([]byte{0})
+		default:
+			// the knocking ports are usually closed or dropped, so the errors are ignored
+			conn, _ = dialTcpWithResolver(args, addr, delay+time.Second)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+		time.Sleep(delay)
+	}
+	return nil
+}
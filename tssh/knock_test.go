@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortKnock(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(knock, delay string) *sshArgs {
+		options := map[string][]string{"exportknock": {knock}}
+		if delay != "" {
+			options["exportknockdelay"] = []string{delay}
+		}
+		return &sshArgs{Option: sshOption{options}}
+	}
+
+	steps, err := getPortKnockSteps(newArgs("7000 8000/udp,9000/TCP", ""))
+	assert.Nil(err)
+	assert.Equal([]knockStep{{"tcp", "7000"}, {"udp", "8000"}, {"tcp", "9000"}}, steps)
+	_, err = getPortKnockSteps(newArgs("7000/icmp", ""))
+	assert.NotNil(err)
+	_, err = getPortKnockSteps(newArgs("70000", ""))
+	assert.NotNil(err)
+	assert.Equal(kDefaultPortKnockDelay, getPortKnockDelay(newArgs("", "")))
+	assert.Equal(50*time.Millisecond, getPortKnockDelay(newArgs("", "50")))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	tcpKnocked := make(chan struct{}, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+			tcpKnocked <- struct{}{}
+		}
+	}()
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer packetConn.Close()
+	udpKnocked := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 10)
+		if _, _, err := packetConn.ReadFrom(buf); err == nil {
+			udpKnocked <- struct{}{}
+		}
+	}()
+
+	tcpPort := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	udpPort := strconv.Itoa(packetConn.LocalAddr().(*net.UDPAddr).Port)
+	assert.Nil(knockPorts(newArgs(udpPort+"/udp "+tcpPort, "10"), "127.0.0.1:22", nil))
+	for _, knocked := range []chan struct{}{tcpKnocked, udpKnocked} {
+		select {
+		case <-knocked:
+		case <-time.After(3 * time.Second):
+			assert.Fail("knock timeout")
+		}
+	}
+}
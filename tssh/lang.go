@@ -32,27 +32,31 @@ import (
 )
 
 var english = map[string]string{
-	"tools/help":     "-- Press Enter to accept the default options provided in brackets. Ctrl+C to exit.",
-	"newhost/title":  "================================= Add New Host =================================",
-	"newhost/config": "-- Generally, just press Enter to use the default configuration path in brackets.",
-	"newhost/alias":  "-- Give the server an alias as you like.",
-	"newhost/host":   "-- Enter the IP address or domain name of the server.",
-	"newhost/port":   "-- Enter the server port, the default is 22.",
-	"newhost/user":   "-- Enter your login username.",
-	"newhost/passwd": "-- Public key authentication or no need to remember password, press Enter to skip.",
-	"newhost/login":  "-- Added successfully, enter Y or Yes (case insensitive) to log in immediately.",
+	"tools/help":       "-- Press Enter to accept the default options provided in brackets. Ctrl+C to exit.",
+	"newhost/title":    "================================= Add New Host =================================",
+	"newhost/config":   "-- Generally, just press Enter to use the default configuration path in brackets.",
+	"newhost/include":  "-- Choose the configuration file to add the new host to.",
+	"newhost/template": "-- Choose a template from HostTemplate.<name> in ~/.tssh.conf to apply its options.",
+	"newhost/alias":    "-- Give the server an alias as you like.",
+	"newhost/host":     "-- Enter the IP address or domain name of the server.",
+	"newhost/port":     "-- Enter the server port, the default is 22.",
+	"newhost/user":     "-- Enter your login username.",
+	"newhost/passwd":   "-- Public key authentication or no need to remember password, press Enter to skip.",
+	"newhost/login":    "-- Added successfully, enter Y or Yes (case insensitive) to log in immediately.",
 }
 
 var chinese = map[string]string{
-	"tools/help":     "-- 可以直接按回车键接受括号内提供的默认选项，使用 Ctrl+C 可以立即退出",
-	"newhost/title":  "================================ 新增服务器配置 ================================",
-	"newhost/config": "-- SSH 配置文件路径，一般直接按回车键使用括号内的默认值即可。",
-	"newhost/alias":  "-- 随便给服务器起个别名，如设置为 xxx 则可以使用 tssh xxx 快速登录此服务器。",
-	"newhost/host":   "-- 请输入服务器 IP。如果是使用域名登录的，也可以输入服务器域名。",
-	"newhost/port":   "-- 请输入服务器端口，默认是22。",
-	"newhost/user":   "-- 请输入登录用户名。",
-	"newhost/passwd": "-- 使用公私钥登录，或者无需记住密码，请直接按回车跳过。",
-	"newhost/login":  "-- 新服务器配置已成功写入，输入 Y 或 Yes（ 不区分大小写 ）可以立即登录。",
+	"tools/help":       "-- 可以直接按回车键接受括号内提供的默认选项，使用 Ctrl+C 可以立即退出",
+	"newhost/title":    "================================ 新增服务器配置 ================================",
+	"newhost/config":   "-- SSH 配置文件路径，一般直接按回车键使用括号内的默认值即可。",
+	"newhost/include":  "-- 请选择新服务器配置要写入的配置文件。",
+	"newhost/template": "-- 请选择 ~/.tssh.conf 中 HostTemplate.<name> 定义的模板，新服务器配置将带上模板中的配置项。",
+	"newhost/alias":    "-- 随便给服务器起个别名，如设置为 xxx 则可以使用 tssh xxx 快速登录此服务器。",
+	"newhost/host":     "-- 请输入服务器 IP。如果是使用域名登录的，也可以输入服务器域名。",
+	"newhost/port":     "-- 请输入服务器端口，默认是22。",
+	"newhost/user":     "-- 请输入登录用户名。",
+	"newhost/passwd":   "-- 使用公私钥登录，或者无需记住密码，请直接按回车跳过。",
+	"newhost/login":    "-- 新服务器配置已成功写入，输入 Y 或 Yes（ 不区分大小写 ）可以立即登录。",
 }
 
 func getText(key string) string {
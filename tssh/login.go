@@ -29,9 +29,11 @@ import (
 	"bytes"
 	"crypto/x509"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/user"
@@ -73,19 +75,32 @@ type sshParam struct {
 	addr    string
 	proxy   []string
 	command string
+	hostKey ssh.PublicKey
 }
 
 type sshSession struct {
-	client    *ssh.Client
-	session   *ssh.Session
-	serverIn  io.WriteCloser
-	serverOut io.Reader
-	serverErr io.Reader
-	cmd       string
-	tty       bool
+	client       *ssh.Client
+	session      *ssh.Session
+	serverIn     io.WriteCloser
+	serverOut    io.Reader
+	serverErr    io.Reader
+	cmd          string
+	tty          bool
+	console      bool
+	device       *deviceType
+	status       *statusLine
+	watermark    *watermark
+	colorDepth   int
+	color        *colorWriter
+	transfers    *transferQueue
+	idle         *idleMonitor
+	transferMenu *transferMenu
 }
 
 func (s *sshSession) Close() {
+	if s.watermark != nil {
+		s.watermark.close()
+	}
 	if s.serverIn != nil {
 		s.serverIn.Close()
 	}
@@ -104,7 +119,27 @@ func joinHostPort(host, port string) string {
 	return fmt.Sprintf("%s:%s", host, port)
 }
 
+// parseSshURL parses the `ssh://[user[;params]@]host[:port][/]` URL, the connection parameters are ignored.
+func parseSshURL(dest string) (user, host, port string, ok bool) {
+	if len(dest) < len("ssh://") || !strings.EqualFold(dest[:len("ssh://")], "ssh://") {
+		return
+	}
+	u, err := url.Parse("ssh://" + dest[len("ssh://"):])
+	if err != nil {
+		return
+	}
+	if u.User != nil {
+		user, _, _ = strings.Cut(u.User.Username(), ";")
+	}
+	return user, u.Hostname(), u.Port(), true
+}
+
 func parseDestination(dest string) (user, host, port string) {
+	// ssh url
+	if user, host, port, ok := parseSshURL(dest); ok {
+		return user, host, port
+	}
+
 	// user
 	idx := strings.Index(dest, "@")
 	if idx >= 0 {
@@ -175,11 +210,13 @@ func getSshParam(args *sshArgs) (*sshParam, error) {
 		param.port = destPort
 	} else {
 		port := getConfig(destHost, "Port")
-		if port != "" {
-			param.port = port
-		} else {
-			param.port = "22"
+		if port == "" {
+			port = "22"
 		}
+		if !hasConfig(destHost, "Port") {
+			param.host, port = resolveSrvRecord(args, param.host, port)
+		}
+		param.port = port
 	}
 
 	// login addr
@@ -242,6 +279,11 @@ func ensureNewline(file *os.File) error {
 }
 
 func writeKnownHost(path, host string, remote net.Addr, key ssh.PublicKey) error {
+	if dir := filepath.Dir(path); !isFileExist(dir) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
 	if err != nil {
 		return err
@@ -250,6 +292,7 @@ func writeKnownHost(path, host string, remote net.Addr, key ssh.PublicKey) error
 	if err := ensureNewline(file); err != nil {
 		return err
 	}
+	defer resetKnownHosts()
 	return knownhosts.WriteKnownHost(file, host, remote, key)
 }
 
@@ -266,10 +309,15 @@ func addHostKey(path, host string, remote net.Addr, key ssh.PublicKey, ask bool)
 			fmt.Fprintf(os.Stderr, "\r\n\033[0;31mThe public key of the remote server has changed after login.\033[0m\r\n")
 			return fmt.Errorf("host key changed")
 		}
+		if batchMode {
+			return fmt.Errorf("host key verification failed: BatchMode is enabled and the host key of '%s' is unknown", host)
+		}
 
 		fingerprint := ssh.FingerprintSHA256(key)
+		md5Fingerprint := "MD5:" + ssh.FingerprintLegacyMD5(key)
 		fmt.Fprintf(os.Stderr, "The authenticity of host '%s' can't be established.\r\n"+
-			"%s key fingerprint is %s.\r\n", host, key.Type(), fingerprint)
+			"%s key fingerprint is %s.\r\n%s key fingerprint is %s.\r\n%s\r\n", host, key.Type(), fingerprint,
+			key.Type(), md5Fingerprint, strings.ReplaceAll(fingerprintRandomArt(key, "SHA256"), "\n", "\r\n"))
 
 		stdin, closer, err := getKeyboardInput()
 		if err != nil {
@@ -285,9 +333,15 @@ func addHostKey(path, host string, remote net.Addr, key ssh.PublicKey, ask bool)
 				return err
 			}
 			input = strings.TrimSpace(input)
-			if input == fingerprint {
+			if input == fingerprint || strings.EqualFold(input, md5Fingerprint) {
+				fmt.Fprintf(os.Stderr, "\033[0;32mThe fingerprint matches.\033[0m\r\n")
 				break
 			}
+			if strings.HasPrefix(input, "SHA256:") || strings.HasPrefix(strings.ToUpper(input), "MD5:") {
+				fmt.Fprintf(os.Stderr, "\033[0;31mThe fingerprint does not match the host key!\033[0m\r\n"+
+					"Please type 'yes', 'no' or the fingerprint: ")
+				continue
+			}
 			input = strings.ToLower(input)
 			if input == "yes" {
 				break
@@ -309,28 +363,62 @@ func addHostKey(path, host string, remote net.Addr, key ssh.PublicKey, ask bool)
 	return nil
 }
 
-func getHostKeyCallback(args *sshArgs, param *sshParam) (ssh.HostKeyCallback, knownhosts.HostKeyCallback, error) {
-	primaryPath := ""
+func checkPinnedHostKey(pinnedKeys []string, key ssh.PublicKey) error {
+	sha256Fingerprint := ssh.FingerprintSHA256(key)
+	md5Fingerprint := "MD5:" + ssh.FingerprintLegacyMD5(key)
+	for _, pinnedKey := range pinnedKeys {
+		for _, fingerprint := range strings.Fields(pinnedKey) {
+			if !strings.ContainsRune(fingerprint, ':') {
+				fingerprint = "SHA256:" + fingerprint
+			}
+			if fingerprint == sha256Fingerprint || strings.EqualFold(fingerprint, md5Fingerprint) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("host key %s does not match the pinned fingerprints", sha256Fingerprint)
+}
+
+// splitKnownHostsFiles splits the known hosts files separated by whitespace, the path may be double quoted.
+func splitKnownHostsFiles(value string) []string {
 	var files []string
+	var buf strings.Builder
+	quoted := false
+	for _, c := range value {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ' ' || c == '\t'):
+			if buf.Len() > 0 {
+				files = append(files, buf.String())
+				buf.Reset()
+			}
+		default:
+			buf.WriteRune(c)
+		}
+	}
+	if buf.Len() > 0 {
+		files = append(files, buf.String())
+	}
+	return files
+}
+
+// getKnownHostsFiles returns the readable known_hosts files, and the primary one to add the new host keys to.
+func getKnownHostsFiles(args *sshArgs, param *sshParam, report func(string, ...any)) (primaryPath string, files []string, err error) {
 	addKnownHostsFiles := func(key string, user bool) error {
 		knownHostsFiles := getOptionConfig(args, key)
-		if knownHostsFiles == "" || user && strings.ToLower(knownHostsFiles) == "none" {
+		if knownHostsFiles == "" || strings.ToLower(knownHostsFiles) == "none" {
 			debug("%s is empty or none", key)
 			return nil
 		}
-		for _, path := range strings.Fields(knownHostsFiles) {
-			var resolvedPath string
-			if user {
-				expandedPath, err := expandTokens(path, args, param, "%CdhikLlnpru")
-				if err != nil {
-					return fmt.Errorf("expand UserKnownHostsFile [%s] failed: %v", path, err)
-				}
-				resolvedPath = resolveHomeDir(expandedPath)
-				if primaryPath == "" {
-					primaryPath = resolvedPath
-				}
-			} else {
-				resolvedPath = path
+		for _, path := range splitKnownHostsFiles(knownHostsFiles) {
+			expandedPath, err := expandTokens(path, args, param, "%CdhikLlnpru")
+			if err != nil {
+				return fmt.Errorf("expand %s [%s] failed: %v", key, path, err)
+			}
+			resolvedPath := resolvePath(expandedPath)
+			if user && primaryPath == "" {
+				primaryPath = resolvedPath
 			}
 			if !isFileExist(resolvedPath) {
 				debug("%s [%s] does not exist", key, resolvedPath)
@@ -338,7 +426,7 @@ func getHostKeyCallback(args *sshArgs, param *sshParam) (ssh.HostKeyCallback, kn
 			}
 			if !canReadFile(resolvedPath) {
 				if user {
-					warning("%s [%s] can't be read", key, resolvedPath)
+					report("%s [%s] can't be read", key, resolvedPath)
 				} else {
 					debug("%s [%s] can't be read", key, resolvedPath)
 				}
@@ -350,18 +438,41 @@ func getHostKeyCallback(args *sshArgs, param *sshParam) (ssh.HostKeyCallback, kn
 		return nil
 	}
 	if err := addKnownHostsFiles("UserKnownHostsFile", true); err != nil {
-		return nil, nil, err
+		return "", nil, err
 	}
 	if err := addKnownHostsFiles("GlobalKnownHostsFile", false); err != nil {
+		return "", nil, err
+	}
+	return primaryPath, files, nil
+}
+
+func getHostKeyCallback(args *sshArgs, param *sshParam) (ssh.HostKeyCallback, knownhosts.HostKeyCallback, error) {
+	primaryPath, files, err := getKnownHostsFiles(args, param, warning)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	kh, err := knownhosts.New(files...)
+	if primaryPath != "" {
+		debug("new host keys will be added to: %s", primaryPath)
+	}
+
+	kh, err := loadKnownHosts(files)
 	if err != nil {
 		return nil, nil, fmt.Errorf("new knownhosts failed: %v", err)
 	}
 
+	pinnedHostKeys := getAllExOptionConfig(args, "ExPinnedHostKey")
+
 	cb := func(host string, remote net.Addr, key ssh.PublicKey) error {
+		if len(pinnedHostKeys) > 0 {
+			if err := checkPinnedHostKey(pinnedHostKeys, key); err != nil {
+				fmt.Fprintf(os.Stderr, "\033[0;31mThe %s key of '%s' is not pinned by ExPinnedHostKey.\033[0m\r\n"+
+					"The fingerprint sent by the remote host is %s.\r\n", key.Type(), host, ssh.FingerprintSHA256(key))
+				return err
+			}
+			debug("host key of [%s] matches the pinned fingerprint", host)
+			return nil
+		}
 		err := kh(host, remote, key)
 		if err == nil {
 			return nil
@@ -401,14 +512,24 @@ func getHostKeyCallback(args *sshArgs, param *sshParam) (ssh.HostKeyCallback, kn
 		}
 	}
 
-	return cb, kh, err
+	// remember the verified host key, which is shown in the status line
+	verified := func(host string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(host, remote, key)
+		if err == nil {
+			param.hostKey = key
+		}
+		return err
+	}
+
+	return verified, kh, err
 }
 
 type sshSigner struct {
-	path   string
-	priKey []byte
-	pubKey ssh.PublicKey
-	signer ssh.Signer
+	path     string
+	priKey   []byte
+	pubKey   ssh.PublicKey
+	signer   ssh.Signer
+	keychain bool
 }
 
 func (s *sshSigner) PublicKey() ssh.PublicKey {
@@ -419,6 +540,17 @@ func (s *sshSigner) initSigner() error {
 	if s.signer != nil {
 		return nil
 	}
+	if s.keychain {
+		if passphrase := getKeychainPassphrase(s.path); passphrase != nil {
+			signer, err := ssh.ParsePrivateKeyWithPassphrase(s.priKey, passphrase)
+			if err == nil {
+				debug("use the passphrase in keychain for [%s]", s.path)
+				s.signer = signer
+				return nil
+			}
+			debug("the passphrase in keychain for [%s] is incorrect: %v", s.path, err)
+		}
+	}
 	prompt := fmt.Sprintf("Enter passphrase for key '%s': ", s.path)
 	for i := 0; i < 3; i++ {
 		secret, err := readSecret(prompt)
@@ -435,6 +567,13 @@ func (s *sshSigner) initSigner() error {
 		if err != nil {
 			return err
 		}
+		if s.keychain {
+			if err := saveKeychainPassphrase(s.path, secret); err != nil {
+				warning("save the passphrase of [%s] to keychain failed: %v", s.path, err)
+			} else {
+				debug("the passphrase of [%s] is saved to keychain", s.path)
+			}
+		}
 		return nil
 	}
 	return fmt.Errorf("passphrase incorrect")
@@ -501,13 +640,13 @@ func canReadFile(path string) bool {
 }
 
 func getSigner(dest string, path string) *sshSigner {
-	path = resolveHomeDir(path)
-	privateKey, err := os.ReadFile(path)
-	if err != nil {
-		warning("read private key [%s] failed: %v", path, err)
+	path = resolvePath(path)
+	key := loadPrivateKey(path)
+	if key.readErr != nil {
+		warning("read private key [%s] failed: %v", path, key.readErr)
 		return nil
 	}
-	signer, err := ssh.ParsePrivateKey(privateKey)
+	privateKey, signer, err := key.data, key.signer, key.parseErr
 	if err != nil {
 		if e, ok := err.(*ssh.PassphraseMissingError); ok {
 			if passphrase := getSecretConfig(dest, "Passphrase"); passphrase != "" {
@@ -525,6 +664,13 @@ func getSigner(dest string, path string) *sshSigner {
 }
 
 func readSecret(prompt string) (secret []byte, err error) {
+	if batchMode {
+		return nil, fmt.Errorf("BatchMode is enabled, refuse to prompt: %s", strings.TrimSpace(prompt))
+	}
+	if askPassCommand != "" {
+		return readAskPass(askPassCommand, prompt)
+	}
+
 	fmt.Fprintf(os.Stderr, "%s", prompt)
 	defer fmt.Fprintf(os.Stderr, "\r\n")
 
@@ -537,17 +683,49 @@ func readSecret(prompt string) (secret []byte, err error) {
 	return term.ReadPassword(int(stdin.Fd()))
 }
 
+func getNumberOfPasswordPrompts(args *sshArgs) int {
+	if batchMode {
+		return 0
+	}
+	value := getOptionConfig(args, "NumberOfPasswordPrompts")
+	if value == "" {
+		return 3
+	}
+	prompts, err := strconv.Atoi(value)
+	if err != nil || prompts < 0 {
+		warning("invalid NumberOfPasswordPrompts [%s], use the default value 3", value)
+		return 3
+	}
+	return prompts
+}
+
 func getPasswordAuthMethod(args *sshArgs, host, user string) ssh.AuthMethod {
 	if strings.ToLower(getOptionConfig(args, "PasswordAuthentication")) == "no" {
 		debug("disable auth method: password authentication")
 		return nil
 	}
 
+	// the configured password or the vault otp is tried first without prompting
+	hasPassword := isHcVaultOTP(args) || hasSecretConfig(args.Destination, "Password")
+	maxTries := getNumberOfPasswordPrompts(args)
+	if hasPassword {
+		maxTries++
+	}
+	if maxTries == 0 {
+		debug("disable auth method: password authentication due to NumberOfPasswordPrompts is 0")
+		return nil
+	}
+
 	idx := 0
+	prompted := false
 	rememberPassword := false
 	return ssh.RetryableAuthMethod(ssh.PasswordCallback(func() (string, error) {
 		idx++
 		if idx == 1 {
+			if password := getHcVaultPassword(args, host, user); password != "" {
+				debug("trying the vault otp for %s", args.Destination)
+				return password, nil
+			}
 			if password := getSecretConfig(args.Destination, "Password"); password != "" {
 				rememberPassword = true
 				debug("trying the password configuration for %s", args.Destination)
@@ -556,12 +734,16 @@ func getPasswordAuthMethod(args *sshArgs, host, user string) ssh.AuthMethod {
 		} else if idx == 2 && rememberPassword {
 			debug("the password configuration for %s is incorrect", args.Destination)
 		}
+		if prompted {
+			fmt.Fprint(os.Stderr, "Permission denied, please try again.\r\n")
+		}
 		secret, err := readSecret(fmt.Sprintf("%s@%s's password: ", user, host))
 		if err != nil {
 			return "", err
 		}
+		prompted = true
 		return string(secret), nil
-	}), 3)
+	}), maxTries)
 }
 
 func getOtpCommandOutput(command string) string {
@@ -626,8 +808,24 @@ func getKeyboardInteractiveAuthMethod(args *sshArgs, host, user string) ssh.Auth
 		return nil
 	}
 
+	maxTries := getNumberOfPasswordPrompts(args)
+	allowPrompt := maxTries > 0
+	if !allowPrompt {
+		maxTries = 1 // the configured answers can still be tried once
+	}
+
+	readInput := func(question string) (string, error) {
+		secret, err := readSecret(fmt.Sprintf("(%s@%s) %s", user, host, strings.ReplaceAll(question, "\n", "\r\n")))
+		return string(secret), err
+	}
+	var askInput func(string) (string, error)
+	if allowPrompt {
+		askInput = readInput
+	}
+
 	idx := 0
 	questionSet := make(map[string]struct{})
+	rules := getPromptRules(args)
 	return ssh.RetryableAuthMethod(ssh.KeyboardInteractive(
 		func(name, instruction string, questions []string, echos []bool) ([]string, error) {
 			var answers []string
@@ -635,28 +833,41 @@ func getKeyboardInteractiveAuthMethod(args *sshArgs, host, user string) ssh.Auth
 				idx++
 				if _, ok := questionSet[question]; !ok {
 					questionSet[question] = struct{}{}
-					answer := readQuestionAnswerConfig(args.Destination, idx, question)
+					answer, ok, err := answerByPromptRules(args, rules, question, askInput)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						answers = append(answers, answer)
+						continue
+					}
+					answer = readQuestionAnswerConfig(args.Destination, idx, question)
 					if answer != "" {
 						answers = append(answers, answer)
 						continue
 					}
 				}
-				secret, err := readSecret(fmt.Sprintf("(%s@%s) %s", user, host, strings.ReplaceAll(question, "\n", "\r\n")))
+				if !allowPrompt {
+					return nil, fmt.Errorf("no answer configured for question '%s' and NumberOfPasswordPrompts is 0", question)
+				}
+				answer, err := readInput(question)
 				if err != nil {
 					return nil, err
 				}
-				answers = append(answers, string(secret))
+				answers = append(answers, answer)
 			}
 			return answers, nil
-		}), 3)
+		}), maxTries)
 }
 
+var kDefaultIdentityNames = []string{"id_rsa", "id_ecdsa", "id_ecdsa_sk", "id_ed25519", "id_ed25519_sk", "identity"}
+
 var getDefaultSigners = func() func() []*sshSigner {
 	var once sync.Once
 	var signers []*sshSigner
 	return func() []*sshSigner {
 		once.Do(func() {
-			for _, name := range []string{"id_rsa", "id_ecdsa", "id_ecdsa_sk", "id_ed25519", "id_ed25519_sk", "identity"} {
+			for _, name := range kDefaultIdentityNames {
 				path := filepath.Join(userHomeDir, ".ssh", name)
 				if !isFileExist(path) {
 					continue
@@ -670,29 +881,55 @@ var getDefaultSigners = func() func() []*sshSigner {
 	}
 }()
 
+// getIdentityFiles returns the identities of -i and IdentityFile, the default ones are used if empty.
+func getIdentityFiles(args *sshArgs, param *sshParam, report func(string, ...any)) []string {
+	identities := args.Identity.values
+	for _, identity := range getAllOptionConfig(args, "IdentityFile") {
+		expandedIdentity, err := expandTokens(identity, args, param, "%CdhikLlnpru")
+		if err != nil {
+			report("expand IdentityFile [%s] failed: %v", identity, err)
+			continue
+		}
+		identities = append(identities, expandedIdentity)
+	}
+	return identities
+}
+
 func getPublicKeysAuthMethod(args *sshArgs, param *sshParam) ssh.AuthMethod {
 	if strings.ToLower(getOptionConfig(args, "PubkeyAuthentication")) == "no" {
 		debug("disable auth method: public key authentication")
 		return nil
 	}
 
-	var pubKeySigners []ssh.Signer
+	var sshSigners []*sshSigner
 	fingerprints := make(map[string]struct{})
+	useKeychain := strings.ToLower(getOptionConfig(args, "UseKeychain")) == "yes"
 	addPubKeySigners := func(signers []*sshSigner) {
 		for _, signer := range signers {
-			fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+			if signer.priKey != nil {
+				signer.keychain = useKeychain
+			}
+			fingerprint := ssh.FingerprintSHA256(signer.pubKey)
 			if _, ok := fingerprints[fingerprint]; !ok {
 				if enableDebugLogging {
 					debug("will attempt key: %s %s %s", signer.path, signer.pubKey.Type(), ssh.FingerprintSHA256(signer.pubKey))
 				}
 				fingerprints[fingerprint] = struct{}{}
-				pubKeySigners = append(pubKeySigners, signer)
+				sshSigners = append(sshSigners, signer)
 			}
 		}
 	}
 
+	if signer := getHcVaultSigner(args, param); signer != nil {
+		addPubKeySigners([]*sshSigner{signer})
+	}
+
+	if signer := getOidcCertSigner(args); signer != nil {
+		addPubKeySigners([]*sshSigner{signer})
+	}
+
 	if agentClient := getAgentClient(args, param); agentClient != nil {
-		signers, err := agentClient.Signers()
+		signers, err := getAgentSigners(agentClient)
 		if err != nil {
 			warning("get ssh agent signers failed: %v", err)
 		} else {
@@ -702,16 +939,7 @@ func getPublicKeysAuthMethod(args *sshArgs, param *sshParam) ssh.AuthMethod {
 		}
 	}
 
-	identities := args.Identity.values
-	for _, identity := range getAllOptionConfig(args, "IdentityFile") {
-		expandedIdentity, err := expandTokens(identity, args, param, "%CdhikLlnpru")
-		if err != nil {
-			warning("expand IdentityFile [%s] failed: %v", identity, err)
-			continue
-		}
-		identities = append(identities, expandedIdentity)
-	}
-
+	identities := getIdentityFiles(args, param, warning)
 	if len(identities) == 0 {
 		addPubKeySigners(getDefaultSigners())
 	} else {
@@ -722,9 +950,17 @@ func getPublicKeysAuthMethod(args *sshArgs, param *sshParam) ssh.AuthMethod {
 		}
 	}
 
-	if len(pubKeySigners) == 0 {
+	if len(sshSigners) == 0 {
 		return nil
 	}
+	if fingerprint := getPreferredKey(args); fingerprint != "" {
+		sshSigners = sortByPreferredKey(sshSigners, fingerprint)
+	}
+	attempt := newIdentityAttempt(args.Destination, sshSigners)
+	pubKeySigners := make([]ssh.Signer, 0, len(sshSigners))
+	for _, signer := range sshSigners {
+		pubKeySigners = append(pubKeySigners, &offeredSigner{signer, attempt})
+	}
 	return ssh.PublicKeys(pubKeySigners...)
 }
 
@@ -890,7 +1126,11 @@ func parseRemoteCommand(args *sshArgs, param *sshParam) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("expand RemoteCommand [%s] failed: %v", command, err)
 	}
-	return expandedCmd, nil
+	var prompt func(string) (string, error)
+	if isTerminal && !isBatchMode(args) {
+		prompt = promptCommandVar
+	}
+	return expandCommandVars(args, expandedCmd, prompt)
 }
 
 func parseCmdAndTTY(args *sshArgs, param *sshParam) (cmd string, tty bool, err error) {
@@ -898,11 +1138,19 @@ func parseCmdAndTTY(args *sshArgs, param *sshParam) (cmd string, tty bool, err e
 	if err != nil {
 		return
 	}
+	if args.Command != "" && isHistoryEnabled(args) {
+		recordHistory(args.Destination, cmd)
+	}
+	cmd = wrapRemoteShell(args, cmd)
 
 	if args.DisableTTY && args.ForceTTY {
 		err = fmt.Errorf("cannot specify -t with -T")
 		return
 	}
+	if args.Pipe {
+		err = checkPipeArgs(args, cmd)
+		return
+	}
 	if args.DisableTTY {
 		tty = false
 		return
@@ -912,7 +1160,7 @@ func parseCmdAndTTY(args *sshArgs, param *sshParam) (cmd string, tty bool, err e
 		return
 	}
 
-	requestTTY := getConfig(args.Destination, "RequestTTY")
+	requestTTY := getOptionConfig(args, "RequestTTY")
 	switch strings.ToLower(requestTTY) {
 	case "", "auto":
 		tty = isTerminal && (cmd == "")
@@ -996,8 +1244,47 @@ func setupLogLevel(args *sshArgs) func() {
 	return reset
 }
 
+// explainConnError tells the server closing the connection apart from a wrong password.
+// A closed connection during login usually means MaxAuthTries is exceeded or the client is banned.
+// It's explained for the direct tcp dial only, as the proxy command or the jump host may close it as well.
+func explainConnError(err error, direct bool) error {
+	if err == nil || !direct {
+		return err
+	}
+	msg := err.Error()
+	if errors.Is(err, io.EOF) || strings.HasSuffix(msg, "EOF") || strings.Contains(msg, "connection reset by peer") {
+		return fmt.Errorf("%v (connection closed by the server, maybe too many authentication failures or this client is banned)", err)
+	}
+	return err
+}
+
+var batchMode bool
+
+func isBatchMode(args *sshArgs) bool {
+	return strings.ToLower(getOptionConfig(args, "BatchMode")) == "yes"
+}
+
+// setupBatchMode makes anything that would prompt the user fail immediately, for cron jobs and CI pipelines.
+func setupBatchMode(args *sshArgs) func() {
+	previous := batchMode
+	reset := func() {
+		batchMode = previous
+	}
+	batchMode = isBatchMode(args)
+	if batchMode {
+		debug("batch mode is enabled for %s", args.Destination)
+	}
+	return reset
+}
+
 func sshConnect(args *sshArgs, client *ssh.Client, proxy string) (*ssh.Client, *sshParam, bool, error) {
-	param, err := getSshParam(args)
+	var param *sshParam
+	var err error
+	if args.prefetch != nil {
+		param, err = args.prefetch.getSshParam(args)
+	} else {
+		param, err = getSshParam(args)
+	}
 	if err != nil {
 		return nil, nil, false, err
 	}
@@ -1005,6 +1292,14 @@ func sshConnect(args *sshArgs, client *ssh.Client, proxy string) (*ssh.Client, *
 	resetLogLevel := setupLogLevel(args)
 	defer resetLogLevel()
 
+	resetAskPass := setupAskPass(args)
+	defer resetAskPass()
+
+	resetBatchMode := setupBatchMode(args)
+	defer resetBatchMode()
+
+	warnUnsupportedOptions(args)
+
 	if client := connectViaControl(args, param); client != nil {
 		return client, param, true, nil
 	}
@@ -1014,7 +1309,11 @@ func sshConnect(args *sshArgs, client *ssh.Client, proxy string) (*ssh.Client, *
 	if err != nil {
 		return nil, param, false, err
 	}
+	if args.timings != nil {
+		cb = args.timings.wrapHostKeyCallback(cb)
+	}
 	config := &ssh.ClientConfig{
+		Config:            ssh.Config{Ciphers: getCiphers(args)},
 		User:              param.user,
 		Auth:              authMethods,
 		Timeout:           10 * time.Second,
@@ -1026,18 +1325,39 @@ func sshConnect(args *sshArgs, client *ssh.Client, proxy string) (*ssh.Client, *
 		},
 	}
 
+	newClient := func(conn net.Conn, dialStart time.Time, direct bool) (*ssh.Client, error) {
+		handshakeStart := time.Now()
+		ncc, chans, reqs, err := ssh.NewClientConn(conn, param.addr, config)
+		if err != nil {
+			return nil, explainConnError(err, direct)
+		}
+		debug("login to [%s] success, dial took %v, handshake and auth took %v", args.Destination,
+			handshakeStart.Sub(dialStart), time.Since(handshakeStart))
+		if args.timings != nil {
+			args.timings.dialStart, args.timings.dialDone, args.timings.authDone = dialStart, handshakeStart, time.Now()
+		}
+		return ssh.NewClient(ncc, chans, reqs), nil
+	}
+
 	proxyConnect := func(client *ssh.Client, proxy string) (*ssh.Client, *sshParam, bool, error) {
 		debug("login to [%s], addr: %s", args.Destination, param.addr)
-		conn, err := dialWithTimeout(client, "tcp", param.addr, 10*time.Second)
+		dialStart := time.Now()
+		conn, addr, err := dialHostCandidates(args, getHostCandidates(args, param), func(addr string) (net.Conn, error) {
+			if err := knockPorts(args, addr, client); err != nil {
+				return nil, fmt.Errorf("port knock failed: %v", err)
+			}
+			return dialWithTimeout(client, "tcp", addr, 10*time.Second)
+		})
 		if err != nil {
 			return nil, param, false, fmt.Errorf("proxy [%s] dial tcp [%s] failed: %v", proxy, param.addr, err)
 		}
-		ncc, chans, reqs, err := ssh.NewClientConn(&connWithTimeout{conn, config.Timeout, true}, param.addr, config)
+		useHostCandidate(param, addr)
+		config.HostKeyAlgorithms = kh.HostKeyAlgorithms(param.addr)
+		client, err = newClient(&connWithTimeout{conn, config.Timeout, true}, dialStart, false)
 		if err != nil {
 			return nil, param, false, fmt.Errorf("proxy [%s] new conn [%s] failed: %v", proxy, param.addr, err)
 		}
-		debug("login to [%s] success", args.Destination)
-		return ssh.NewClient(ncc, chans, reqs), param, false, nil
+		return client, param, false, nil
 	}
 
 	// has parent client
@@ -1048,41 +1368,57 @@ func sshConnect(args *sshArgs, client *ssh.Client, proxy string) (*ssh.Client, *
 	// proxy command
 	if param.command != "" {
 		debug("login to [%s], addr: %s", args.Destination, param.addr)
+		dialStart := time.Now()
 		conn, cmd, err := execProxyCommand(args, param)
 		if err != nil {
 			return nil, param, false, fmt.Errorf("exec proxy command [%s] failed: %v", cmd, err)
 		}
-		ncc, chans, reqs, err := ssh.NewClientConn(conn, param.addr, config)
+		client, err := newClient(conn, dialStart, false)
 		if err != nil {
 			return nil, param, false, fmt.Errorf("proxy command [%s] new conn [%s] failed: %v", cmd, param.addr, err)
 		}
-		debug("login to [%s] success", args.Destination)
-		return ssh.NewClient(ncc, chans, reqs), param, false, nil
+		return client, param, false, nil
+	}
+
+	transport, proxies, err := splitProxyTransport(param.proxy)
+	if err != nil {
+		return nil, param, false, err
 	}
 
-	// no proxy
-	if len(param.proxy) == 0 {
+	// no proxy, or only a proxy transport
+	if len(proxies) == 0 {
 		debug("login to [%s], addr: %s", args.Destination, param.addr)
-		conn, err := net.DialTimeout("tcp", param.addr, config.Timeout)
+		dialStart := time.Now()
+		conn, addr, err := dialHostCandidates(args, getHostCandidates(args, param), func(addr string) (net.Conn, error) {
+			if err := knockPorts(args, addr, nil); err != nil {
+				return nil, fmt.Errorf("port knock failed: %v", err)
+			}
+			if transport != "" {
+				return dialProxyTransport(transport, addr, config.Timeout)
+			}
+			return dialTcpWithResolver(args, addr, config.Timeout)
+		})
 		if err != nil {
 			return nil, param, false, fmt.Errorf("dial tcp [%s] failed: %v", param.addr, err)
 		}
-		ncc, chans, reqs, err := ssh.NewClientConn(&connWithTimeout{conn, config.Timeout, true}, param.addr, config)
+		recordDialedAddr(conn.RemoteAddr())
+		useHostCandidate(param, addr)
+		config.HostKeyAlgorithms = kh.HostKeyAlgorithms(param.addr)
+		client, err := newClient(&connWithTimeout{conn, config.Timeout, true}, dialStart, transport == "")
 		if err != nil {
 			return nil, param, false, fmt.Errorf("new conn [%s] failed: %v", param.addr, err)
 		}
-		debug("login to [%s] success", args.Destination)
-		return ssh.NewClient(ncc, chans, reqs), param, false, nil
+		return client, param, false, nil
 	}
 
 	// has proxies
-	var proxyClient *ssh.Client
-	for _, proxy = range param.proxy {
-		proxyClient, _, _, err = sshConnect(&sshArgs{Destination: proxy}, proxyClient, proxy)
-		if err != nil {
-			return nil, param, false, err
-		}
+	chainStart := time.Now()
+	proxyClient, err := connectJumpHosts(transport, proxies)
+	if err != nil {
+		return nil, param, false, err
 	}
+	proxy = proxies[len(proxies)-1]
+	debug("connect to jump hosts %v took %v", param.proxy, time.Since(chainStart))
 	return proxyConnect(proxyClient, proxy)
 }
 
@@ -1154,6 +1490,7 @@ func sshLogin(args *sshArgs) (ss *sshSession, err error) {
 			ss.Close()
 		} else {
 			sshLoginSuccess.Store(true)
+			recordLoginUser(args)
 			// execute local command if necessary
 			execLocalCommand(args, param)
 		}
@@ -1163,7 +1500,18 @@ func sshLogin(args *sshArgs) (ss *sshSession, err error) {
 	var control bool
 	ss.client, param, control, err = sshConnect(args, nil, "")
 	if err != nil {
-		return
+		fingerprint := pickIdentityOnAuthFailure(args, err)
+		if fingerprint == "" {
+			return
+		}
+		if args.Option.options == nil {
+			args.Option.options = make(map[string][]string)
+		}
+		args.Option.options["expreferredkey"] = []string{fingerprint}
+		if ss.client, param, control, err = sshConnect(args, nil, ""); err != nil {
+			return
+		}
+		rememberPreferredKey(args.Destination, fingerprint)
 	}
 
 	// parse cmd and tty
@@ -1172,6 +1520,35 @@ func sshLogin(args *sshArgs) (ss *sshSession, err error) {
 		return
 	}
 
+	// connect to the console via the gateway
+	if ss.cmd == "" {
+		if ss.cmd, err = getConsoleCommand(args, param); err != nil {
+			return
+		}
+		if ss.cmd != "" {
+			ss.console = true
+			ss.tty = isTerminal && !args.DisableTTY
+		}
+	}
+
+	// attach to the remote tmux session
+	if ss.cmd == "" && args.Tmux {
+		ss.cmd = getTmuxCommand(args, getTmuxSession(args))
+		ss.tty = isTerminal && !args.DisableTTY
+	}
+
+	// resume the multiplexer session for the interactive login
+	if ss.cmd == "" && ss.tty {
+		if ss.cmd, err = getAutoAttachCommand(args); err != nil {
+			return
+		}
+	}
+
+	// network device mode
+	if ss.device, err = getDeviceType(args); err != nil {
+		return
+	}
+
 	// keep alive
 	if !control {
 		keepAlive(ss.client, args)
@@ -1201,9 +1578,11 @@ func sshLogin(args *sshArgs) (ss *sshSession, err error) {
 		return
 	}
 
-	// send and set env
-	if err = sendAndSetEnv(args, ss.session); err != nil {
-		return
+	// send and set env, the network devices may close the session on unsupported requests
+	if ss.device == nil {
+		if err = sendAndSetEnv(args, ss.session); err != nil {
+			return
+		}
 	}
 
 	// session input and output
@@ -1224,7 +1603,7 @@ func sshLogin(args *sshArgs) (ss *sshSession, err error) {
 	}
 
 	// ssh agent forward
-	if !control {
+	if !control && ss.device == nil {
 		sshAgentForward(args, param, ss.client, ss.session)
 	}
 
@@ -1239,9 +1618,13 @@ func sshLogin(args *sshArgs) (ss *sshSession, err error) {
 		err = fmt.Errorf("get terminal size failed: %v", err)
 		return
 	}
-	term := os.Getenv("TERM")
-	if term == "" {
-		term = "xterm-256color"
+	term := getTermName(args, ss.device)
+	if ss.status = newStatusLine(args, param, control); ss.status != nil {
+		height = ss.status.resize(width, height)
+	}
+	ss.watermark = newWatermark(args, param)
+	if ss.device == nil {
+		sendTrzszFilterEnv(args, ss.client, ss.session)
 	}
 	if err = ss.session.RequestPty(term, height, width, ssh.TerminalModes{}); err != nil {
 		err = fmt.Errorf("request pty failed: %v", err)
@@ -25,9 +25,15 @@ SOFTWARE.
 package tssh
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestParseDestination(t *testing.T) {
@@ -67,4 +73,75 @@ func TestParseDestination(t *testing.T) {
 	assertDestEqual("user@fe80::6358:bbae:26f8:7859", "user", "fe80::6358:bbae:26f8:7859", "")
 	assertDestEqual("[fe80::6358:bbae:26f8:7859]:1022", "", "fe80::6358:bbae:26f8:7859", "1022")
 	assertDestEqual("user@[fe80::6358:bbae:26f8:7859]:1022", "user", "fe80::6358:bbae:26f8:7859", "1022")
+
+	assertDestEqual("ssh://dest", "", "dest", "")
+	assertDestEqual("ssh://user@dest:1022", "user", "dest", "1022")
+	assertDestEqual("SSH://user@dest:1022/", "user", "dest", "1022")
+	assertDestEqual("ssh://user;fingerprint=SHA256-abc@dest", "user", "dest", "")
+	assertDestEqual("ssh://first.last%40corp@dest", "first.last@corp", "dest", "")
+	assertDestEqual("ssh://[::1]:1022", "", "::1", "1022")
+	assertDestEqual("ssh://user@[fe80::6358:bbae:26f8:7859]", "user", "fe80::6358:bbae:26f8:7859", "")
+}
+
+func TestCheckPinnedHostKey(t *testing.T) {
+	assert := assert.New(t)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+	key, err := ssh.NewPublicKey(pubKey)
+	assert.Nil(err)
+
+	sha256Fingerprint := ssh.FingerprintSHA256(key)
+	md5Fingerprint := "MD5:" + ssh.FingerprintLegacyMD5(key)
+
+	assert.Nil(checkPinnedHostKey([]string{sha256Fingerprint}, key))
+	assert.Nil(checkPinnedHostKey([]string{sha256Fingerprint[len("SHA256:"):]}, key))
+	assert.Nil(checkPinnedHostKey([]string{strings.ToUpper(md5Fingerprint)}, key))
+	assert.Nil(checkPinnedHostKey([]string{"SHA256:invalid " + sha256Fingerprint}, key))
+	assert.Nil(checkPinnedHostKey([]string{"SHA256:invalid", md5Fingerprint}, key))
+
+	assert.NotNil(checkPinnedHostKey([]string{"SHA256:invalid"}, key))
+	assert.NotNil(checkPinnedHostKey([]string{"MD5:00:11:22"}, key))
+}
+
+func TestSplitKnownHostsFiles(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(splitKnownHostsFiles(""))
+	assert.Equal([]string{"~/.ssh/known_hosts"}, splitKnownHostsFiles("~/.ssh/known_hosts"))
+	assert.Equal([]string{"~/.ssh/known_hosts", "~/.ssh/known_hosts2"},
+		splitKnownHostsFiles("~/.ssh/known_hosts ~/.ssh/known_hosts2"))
+	assert.Equal([]string{"/a", "/b", "/c"}, splitKnownHostsFiles(" /a\t/b   /c "))
+	assert.Equal([]string{"/path with space/known_hosts", "~/.ssh/%h_known_hosts"},
+		splitKnownHostsFiles(`"/path with space/known_hosts" ~/.ssh/%h_known_hosts`))
+	assert.Equal([]string{`C:\Users\name\.ssh\known_hosts`}, splitKnownHostsFiles(`"C:\Users\name\.ssh\known_hosts"`))
+}
+
+func TestExplainConnError(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(explainConnError(nil, true))
+
+	authErr := fmt.Errorf("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain")
+	assert.Equal(authErr, explainConnError(authErr, true))
+
+	assert.Contains(explainConnError(io.EOF, true).Error(), "connection closed by the server")
+	assert.Contains(explainConnError(fmt.Errorf("ssh: handshake failed: EOF"), true).Error(), "connection closed by the server")
+	assert.Contains(explainConnError(fmt.Errorf("read tcp: connection reset by peer"), true).Error(), "connection closed by the server")
+
+	// the proxy command or the jump host may close the connection as well
+	assert.Equal(io.EOF, explainConnError(io.EOF, false))
+}
+
+func TestBatchMode(t *testing.T) {
+	assert := assert.New(t)
+	args := &sshArgs{Option: sshOption{map[string][]string{"batchmode": {"yes"}}}}
+	assert.True(isBatchMode(args))
+	assert.False(isBatchMode(&sshArgs{Option: sshOption{map[string][]string{"batchmode": {"no"}}}}))
+
+	reset := setupBatchMode(args)
+	assert.True(batchMode)
+	assert.Equal(0, getNumberOfPasswordPrompts(args))
+	_, err := readSecret("password: ")
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "BatchMode")
+	reset()
+	assert.False(batchMode)
 }
@@ -0,0 +1,140 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const kOtherLoginUser = "other ..."
+
+func getLoginUsersPath() string {
+	return filepath.Join(userHomeDir, ".ssh", "tssh-users.json")
+}
+
+// loadLoginUsers returns how many times each user is used to log in to each alias.
+func loadLoginUsers() map[string]map[string]int {
+	users := make(map[string]map[string]int)
+	data, err := os.ReadFile(getLoginUsersPath())
+	if err != nil {
+		return users
+	}
+	if err := json.Unmarshal(data, &users); err != nil {
+		debug("parse %s failed: %v", getLoginUsersPath(), err)
+	}
+	return users
+}
+
+// getRecentLoginUsers returns the users of the alias, the most frequently used first.
+func getRecentLoginUsers(users map[string]map[string]int, alias string) []string {
+	counts := users[alias]
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func chooseLoginUser(alias string) string {
+	users := getRecentLoginUsers(loadLoginUsers(), alias)
+	if user := getConfig(alias, "User"); user != "" && !containsString(users, user) {
+		users = append(users, user)
+	}
+	if len(users) > 0 {
+		if user := promptList(fmt.Sprintf("Log in to %s as", alias), "The recent users first", append(users, kOtherLoginUser)); user != kOtherLoginUser {
+			return user
+		}
+	}
+	return promptTextInput(fmt.Sprintf("Log in to %s as", alias), "", "The user to log in as", nil)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyLoginAs overrides the user of the destination by --as, e.g.,
+// `tssh host --as root` reuses the configurations of the host but logs in as root,
+// and `tssh host --as ask` chooses from the users recently used for the host.
+func applyLoginAs(args *sshArgs) error {
+	if args.As == "" {
+		return nil
+	}
+	if args.LoginName != "" {
+		return fmt.Errorf("--as and -l cannot be used at the same time")
+	}
+	if strings.Contains(args.Destination, "@") {
+		return fmt.Errorf("--as cannot be used with user@host")
+	}
+	user := args.As
+	if strings.ToLower(user) == "ask" {
+		if !isTerminal || isBatchMode(args) {
+			return fmt.Errorf("--as ask requires a terminal to choose the user")
+		}
+		user = chooseLoginUser(args.Destination)
+	}
+	if user == "" {
+		return fmt.Errorf("the user of --as is empty")
+	}
+	if !isUserValid(user) {
+		return fmt.Errorf("the user of --as is invalid: %s", user)
+	}
+	args.LoginName = user
+	return nil
+}
+
+// recordLoginUser counts the users specified by --as or -l, for the chooser of `--as ask`.
+func recordLoginUser(args *sshArgs) {
+	if args.LoginName == "" || args.Destination == "" {
+		return
+	}
+	users := loadLoginUsers()
+	if users[args.Destination] == nil {
+		users[args.Destination] = make(map[string]int)
+	}
+	users[args.Destination][args.LoginName]++
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(getLoginUsersPath(), data, 0600); err != nil {
+		debug("write %s failed: %v", getLoginUsersPath(), err)
+	}
+}
@@ -73,11 +73,15 @@ func background(args *sshArgs, dest string) (bool, error) {
 	}
 
 	sleepTime := time.Duration(0)
-	for {
+	for count := 0; ; count++ {
+		cmdEnv := env
+		if monitor && count > 0 {
+			cmdEnv = append(env[:len(env):len(env)], fmt.Sprintf("%s=%d", kReconnectCountEnv, count))
+		}
 		cmd := exec.Cmd{
 			Path:   os.Args[0],
 			Args:   newArgs,
-			Env:    env,
+			Env:    cmdEnv,
 			Stderr: os.Stderr,
 		}
 
@@ -101,12 +105,28 @@ func background(args *sshArgs, dest string) (bool, error) {
 	}
 }
 
+var onExitMutex sync.Mutex
 var onExitFuncs []func()
+var onExitOnce sync.Once
+
+// onExit registers the function to be called before exiting, in the reverse order of registration.
+func onExit(f func()) {
+	onExitMutex.Lock()
+	defer onExitMutex.Unlock()
+	onExitFuncs = append(onExitFuncs, f)
+}
 
+// cleanupOnExit runs only once, since it may be called by the signal handler as well.
 func cleanupOnExit() {
-	for i := len(onExitFuncs) - 1; i >= 0; i-- {
-		onExitFuncs[i]()
-	}
+	onExitOnce.Do(func() {
+		onExitMutex.Lock()
+		funcs := onExitFuncs
+		onExitFuncs = nil
+		onExitMutex.Unlock()
+		for i := len(funcs) - 1; i >= 0; i-- {
+			funcs[i]()
+		}
+	})
 }
 
 var afterLoginFuncs []func()
@@ -120,6 +140,11 @@ func cleanupAfterLogin() {
 var isTerminal bool = isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
 
 func TsshMain() int {
+	// run as the rz / sz shim for ExZmodemOptions
+	if code, quit := execZmodemShim(); quit {
+		return code
+	}
+
 	var args sshArgs
 	parser := arg.MustParse(&args)
 
@@ -140,7 +165,12 @@ func TsshMain() int {
 	}()
 
 	// init user config
-	if err = initUserConfig(args.ConfigFile); err != nil {
+	if err = initUserConfig(args.ConfigFile.values); err != nil {
+		return 1
+	}
+
+	// apply the named profiles
+	if err = applyProfiles(&args); err != nil {
 		return 1
 	}
 
@@ -156,6 +186,11 @@ func TsshMain() int {
 		return code
 	}
 
+	// a host range only works with the batch tools, or as the keywords to choose a host
+	if err = checkHostRangeDest(&args, isTerminal && !isBatchMode(&args)); err != nil {
+		return 4
+	}
+
 	// choose ssh alias
 	dest := ""
 	quit := false
@@ -164,7 +199,16 @@ func TsshMain() int {
 			parser.WriteHelp(os.Stderr)
 			return 3
 		}
+		if isBatchMode(&args) {
+			err = fmt.Errorf("BatchMode is enabled, refuse to choose the destination interactively")
+			return 3
+		}
 		dest, quit, err = chooseAlias("")
+	} else if isBatchMode(&args) {
+		dest = args.Destination
+		if alias := getShortcutAlias(dest); alias != "" {
+			dest = alias
+		}
 	} else {
 		dest, quit, err = predictDestination(args.Destination)
 	}
@@ -190,6 +234,11 @@ func TsshMain() int {
 	args.Destination = dest
 	args.originalDest = dest
 
+	// override the user by --as
+	if err = applyLoginAs(&args); err != nil {
+		return 6
+	}
+
 	// start ssh program
 	if err = sshStart(&args); err != nil {
 		return 6
@@ -205,6 +254,9 @@ func sshStart(args *sshArgs) error {
 	}
 	defer ss.Close()
 
+	// shutdown gracefully on SIGTERM or SIGHUP
+	handleTerminateSignals(ss)
+
 	// stdio forward
 	if args.StdioForward != "" {
 		var wg *sync.WaitGroup
@@ -232,9 +284,20 @@ func sshStart(args *sshArgs) error {
 		}
 	}
 
+	// tint the background color if necessary
+	setBackgroundColor(args, ss)
+
 	// execute remote tools if necessary
 	execRemoteTools(args, ss.client)
 
+	// upload the bootstrap files if necessary
+	bootstrapRemoteFiles(args, ss.client)
+
+	// check whether trzsz is installed if necessary
+	if isTerminal && ss.tty && !args.InstallTrzsz {
+		checkTrzszPresence(args, ss.client)
+	}
+
 	// run command or start shell
 	if ss.cmd != "" {
 		if err := ss.session.Start(ss.cmd); err != nil {
@@ -249,6 +312,12 @@ func sshStart(args *sshArgs) error {
 	// execute expect interactions if necessary
 	execExpectInteractions(args, ss)
 
+	// turn off paging and normalize line endings for network devices
+	wrapDeviceOutput(ss)
+
+	// translate the colors the local terminal can't render
+	setupColorOutput(args, ss)
+
 	// make stdin raw
 	if isTerminal && ss.tty {
 		state, err := makeStdinRaw()
@@ -266,6 +335,7 @@ func sshStart(args *sshArgs) error {
 	// cleanup and wait for exit
 	cleanupAfterLogin()
 	_ = ss.session.Wait()
+	waitBackgroundTransfers(ss)
 	if args.Background {
 		_ = ss.client.Wait()
 	}
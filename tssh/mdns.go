@@ -0,0 +1,178 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const kMdnsService = "_ssh._tcp.local."
+
+const kMdnsDiscoverTimeout = 800 * time.Millisecond
+
+var mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+type mdnsService struct {
+	target string
+	port   uint16
+	source net.IP
+}
+
+// mdnsRecords collects the records of all the responses, as the SRV and A records may be sent separately.
+type mdnsRecords struct {
+	instances []string
+	services  map[string]*mdnsService
+	addrs     map[string]net.IP
+}
+
+func newMdnsQuery() ([]byte, error) {
+	name, err := dnsmessage.NewName(kMdnsService)
+	if err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	return msg.Pack()
+}
+
+func (r *mdnsRecords) parseResponse(buf []byte, source net.IP) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(buf); err != nil {
+		debug("unpack mdns response from %s failed: %v", source, err)
+		return
+	}
+	for _, res := range append(msg.Answers, msg.Additionals...) {
+		name := strings.ToLower(res.Header.Name.String())
+		switch body := res.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name != kMdnsService {
+				continue
+			}
+			instance := strings.ToLower(body.PTR.String())
+			if !containsString(r.instances, instance) {
+				r.instances = append(r.instances, instance)
+			}
+		case *dnsmessage.SRVResource:
+			r.services[name] = &mdnsService{strings.ToLower(body.Target.String()), body.Port, source}
+		case *dnsmessage.AResource:
+			r.addrs[name] = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			if r.addrs[name] == nil {
+				r.addrs[name] = net.IP(body.AAAA[:])
+			}
+		}
+	}
+}
+
+// getHosts converts the discovered services to hosts, the alias is the address which can be logged in directly.
+func (r *mdnsRecords) getHosts() []*sshHost {
+	var hosts []*sshHost
+	for _, instance := range r.instances {
+		service := r.services[instance]
+		if service == nil {
+			continue
+		}
+		ip := r.addrs[service.target]
+		if ip == nil {
+			ip = service.source
+		}
+		if ip == nil {
+			continue
+		}
+		port := strconv.Itoa(int(service.port))
+		alias := ip.String()
+		if port != "22" {
+			alias = joinHostPort(alias, port)
+		}
+		hosts = append(hosts, &sshHost{
+			Alias:       alias,
+			Host:        strings.TrimSuffix(service.target, "."),
+			Port:        port,
+			GroupLabels: "LAN",
+		})
+	}
+	return hosts
+}
+
+// discoverLanHosts discovers the ssh services on the local network via mDNS.
+// It sends a legacy unicast query, so that the responders reply to our port directly.
+func discoverLanHosts(timeout time.Duration) []*sshHost {
+	query, err := newMdnsQuery()
+	if err != nil {
+		debug("new mdns query failed: %v", err)
+		return nil
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		debug("mdns listen failed: %v", err)
+		return nil
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(query, mdnsAddr); err != nil {
+		debug("mdns query failed: %v", err)
+		return nil
+	}
+
+	records := &mdnsRecords{services: make(map[string]*mdnsService), addrs: make(map[string]net.IP)}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 9000)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		records.parseResponse(buf[:n], addr.IP)
+	}
+	hosts := records.getHosts()
+	debug("discovered %d hosts on the local network", len(hosts))
+	return hosts
+}
+
+// appendLanHosts appends the hosts discovered by mDNS if DiscoverLanHosts is enabled in ~/.tssh.conf.
+func appendLanHosts(hosts []*sshHost) []*sshHost {
+	switch strings.ToLower(userConfig.discoverLanHosts) {
+	case "yes", "true":
+	default:
+		return hosts
+	}
+	known := make(map[string]bool)
+	for _, host := range hosts {
+		known[host.Host] = true
+		known[host.Alias] = true
+	}
+	for _, host := range discoverLanHosts(kMdnsDiscoverTimeout) {
+		if known[host.Alias] || known[host.Host] {
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
@@ -0,0 +1,77 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestMdnsRecords(t *testing.T) {
+	assert := assert.New(t)
+	mustName := func(name string) dnsmessage.Name {
+		n, err := dnsmessage.NewName(name)
+		assert.Nil(err)
+		return n
+	}
+	header := func(name string, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: mustName(name), Type: typ, Class: dnsmessage.ClassINET}
+	}
+	pack := func(answers, additionals []dnsmessage.Resource) []byte {
+		msg := dnsmessage.Message{
+			Header:      dnsmessage.Header{Response: true, Authoritative: true},
+			Answers:     answers,
+			Additionals: additionals,
+		}
+		buf, err := msg.Pack()
+		assert.Nil(err)
+		return buf
+	}
+
+	query, err := newMdnsQuery()
+	assert.Nil(err)
+	assert.NotEmpty(query)
+
+	records := &mdnsRecords{services: make(map[string]*mdnsService), addrs: make(map[string]net.IP)}
+	records.parseResponse(pack([]dnsmessage.Resource{
+		{Header: header(kMdnsService, dnsmessage.TypePTR), Body: &dnsmessage.PTRResource{PTR: mustName("pi._ssh._tcp.local.")}},
+	}, []dnsmessage.Resource{
+		{Header: header("pi._ssh._tcp.local.", dnsmessage.TypeSRV), Body: &dnsmessage.SRVResource{Port: 22, Target: mustName("raspberrypi.local.")}},
+		{Header: header("raspberrypi.local.", dnsmessage.TypeA), Body: &dnsmessage.AResource{A: [4]byte{192, 168, 1, 23}}},
+	}), net.IPv4(192, 168, 1, 23))
+	records.parseResponse(pack([]dnsmessage.Resource{
+		{Header: header(kMdnsService, dnsmessage.TypePTR), Body: &dnsmessage.PTRResource{PTR: mustName("vm._ssh._tcp.local.")}},
+		{Header: header("vm._ssh._tcp.local.", dnsmessage.TypeSRV), Body: &dnsmessage.SRVResource{Port: 2222, Target: mustName("vm.local.")}},
+	}, nil), net.IPv4(192, 168, 1, 50))
+	records.parseResponse([]byte("invalid"), net.IPv4(192, 168, 1, 99))
+
+	assert.Equal([]*sshHost{
+		{Alias: "192.168.1.23", Host: "raspberrypi.local", Port: "22", GroupLabels: "LAN"},
+		{Alias: "192.168.1.50:2222", Host: "vm.local", Port: "2222", GroupLabels: "LAN"},
+	}, records.getHosts())
+}
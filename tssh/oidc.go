@@ -0,0 +1,368 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kOidcLoginTimeout = 5 * time.Minute
+
+// oidcConfig signs in to the OIDC based SSH CA, configured by:
+//
+//	ExOidcIssuer https://accounts.example.com
+//	ExOidcClientID tssh
+//	ExOidcClientSecret the (encoded) client secret, optional
+//	ExOidcSignURL https://ca.example.com/ssh/sign
+//
+// The ID token is sent to the sign URL as the one-time token, like step-ca's OIDC provisioner.
+// Or ExOidcCertCommand, which gets the public key from stdin, and prints the certificate to stdout.
+// The private key is generated for each login, and the certificate is kept in memory only.
+type oidcConfig struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	signURL      string
+	certCommand  string
+}
+
+type oidcCert struct {
+	signer *sshSigner
+	expiry time.Time
+}
+
+var oidcCertCache = make(map[string]*oidcCert)
+var oidcCertMutex sync.Mutex
+
+func getOidcConfig(args *sshArgs) (*oidcConfig, error) {
+	cfg := &oidcConfig{
+		issuer:       strings.TrimSuffix(getExOptionConfig(args, "ExOidcIssuer"), "/"),
+		clientID:     getExOptionConfig(args, "ExOidcClientID"),
+		clientSecret: getSecretConfig(args.Destination, "ExOidcClientSecret"),
+		signURL:      getExOptionConfig(args, "ExOidcSignURL"),
+		certCommand:  getExOptionConfig(args, "ExOidcCertCommand"),
+	}
+	if cfg.certCommand != "" {
+		return cfg, nil
+	}
+	if cfg.issuer == "" && cfg.signURL == "" {
+		return nil, nil
+	}
+	if cfg.issuer == "" || cfg.clientID == "" || cfg.signURL == "" {
+		return nil, fmt.Errorf("ExOidcIssuer, ExOidcClientID and ExOidcSignURL are all required")
+	}
+	return cfg, nil
+}
+
+func (c *oidcConfig) cacheKey() string {
+	if c.certCommand != "" {
+		return c.certCommand
+	}
+	return c.issuer + "\n" + c.clientID + "\n" + c.signURL
+}
+
+var openBrowser = func(link string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", link)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	default:
+		cmd = exec.Command("xdg-open", link)
+	}
+	return cmd.Start()
+}
+
+func randomURLString(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func postOidcRequest(link, contentType string, body []byte, result any) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(link, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, result)
+}
+
+func discoverOidcEndpoints(issuer string) (string, string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("get openid configuration failed: %s", resp.Status)
+	}
+	var discovery struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return "", "", err
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return "", "", fmt.Errorf("authorization or token endpoint not found in openid configuration")
+	}
+	return discovery.AuthorizationEndpoint, discovery.TokenEndpoint, nil
+}
+
+// oidcLogin signs in with the authorization code flow and PKCE in the browser, and returns the ID token.
+func oidcLogin(cfg *oidcConfig) (string, error) {
+	if batchMode {
+		return "", fmt.Errorf("BatchMode is enabled, refuse to sign in with the browser")
+	}
+	authEndpoint, tokenEndpoint, err := discoverOidcEndpoints(cfg.issuer)
+	if err != nil {
+		return "", err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr())
+
+	state := randomURLString(16)
+	verifier := randomURLString(32)
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {randomURLString(16)},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	authURL := authEndpoint + "?" + query.Encode()
+	if strings.Contains(authEndpoint, "?") {
+		authURL = authEndpoint + "&" + query.Encode()
+	}
+
+	// only the first callback is taken, the later ones, e.g., a refresh of the page, are not blocked.
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		if e := q.Get("error"); e != "" {
+			fmt.Fprintf(w, "Sign in failed: %s, you can close this window now.", e)
+			select {
+			case errCh <- fmt.Errorf("sign in failed: %s %s", e, q.Get("error_description")):
+			default:
+			}
+			return
+		}
+		fmt.Fprint(w, "Signed in to tssh, you can close this window now.")
+		select {
+		case codeCh <- q.Get("code"):
+		default:
+		}
+	})}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	fmt.Fprintf(os.Stderr, "Sign in with your browser, or open the link manually:\r\n%s\r\n", authURL)
+	if err := openBrowser(authURL); err != nil {
+		debug("open browser failed: %v", err)
+	}
+
+	var code string
+	select {
+	case code = <-codeCh:
+	case err := <-errCh:
+		return "", err
+	case <-time.After(kOidcLoginTimeout):
+		return "", fmt.Errorf("sign in timeout")
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {cfg.clientID},
+		"code_verifier": {verifier},
+	}
+	if cfg.clientSecret != "" {
+		form.Set("client_secret", cfg.clientSecret)
+	}
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := postOidcRequest(tokenEndpoint, "application/x-www-form-urlencoded", []byte(form.Encode()), &token); err != nil {
+		return "", fmt.Errorf("exchange token failed: %v", err)
+	}
+	if token.IDToken == "" {
+		return "", fmt.Errorf("no id_token in the token response")
+	}
+	return token.IDToken, nil
+}
+
+// signOidcCert sends the public key and the ID token to the CA, returns the certificate in wire format.
+func signOidcCert(cfg *oidcConfig, idToken string, pubKey ssh.PublicKey) ([]byte, error) {
+	body, err := json.Marshal(map[string]any{
+		"publicKey": pubKey.Marshal(),
+		"ott":       idToken,
+		"certType":  "user",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Certificate string `json:"crt"`
+	}
+	if err := postOidcRequest(cfg.signURL, "application/json", body, &resp); err != nil {
+		return nil, fmt.Errorf("sign certificate failed: %v", err)
+	}
+	return base64.StdEncoding.DecodeString(resp.Certificate)
+}
+
+func runOidcCertCommand(command string, pubKey ssh.PublicKey) ([]byte, error) {
+	argv, err := splitCommandLine(command)
+	if err != nil || len(argv) == 0 {
+		return nil, fmt.Errorf("split ExOidcCertCommand [%s] failed: %v", command, err)
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(ssh.MarshalAuthorizedKey(pubKey))
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec ExOidcCertCommand failed: %v", err)
+	}
+	cert, _, _, _, err := ssh.ParseAuthorizedKey(output)
+	if err != nil {
+		return nil, fmt.Errorf("parse the certificate from ExOidcCertCommand failed: %v", err)
+	}
+	return cert.Marshal(), nil
+}
+
+func newOidcCert(cfg *oidcConfig) (*oidcCert, error) {
+	_, priKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(priKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var certData []byte
+	if cfg.certCommand != "" {
+		certData, err = runOidcCertCommand(cfg.certCommand, signer.PublicKey())
+	} else {
+		var idToken string
+		if idToken, err = oidcLogin(cfg); err == nil {
+			certData, err = signOidcCert(cfg, idToken, signer.PublicKey())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := ssh.ParsePublicKey(certData)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate failed: %v", err)
+	}
+	cert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("the CA returns a %s key rather than a certificate", pubKey.Type())
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, err
+	}
+	expiry := time.Unix(int64(cert.ValidBefore), 0)
+	if cert.ValidBefore == ssh.CertTimeInfinity {
+		expiry = time.Now().Add(24 * time.Hour)
+	}
+	debug("got the certificate [%s] valid until %s", cert.KeyId, expiry.Format(time.RFC3339))
+	return &oidcCert{&sshSigner{path: "oidc-certificate", pubKey: cert, signer: certSigner}, expiry}, nil
+}
+
+// getOidcCertSigner returns the signer of the short-lived certificate, which is reused for the jump hosts.
+func getOidcCertSigner(args *sshArgs) *sshSigner {
+	cfg, err := getOidcConfig(args)
+	if err != nil {
+		warning("%v", err)
+		return nil
+	}
+	if cfg == nil {
+		return nil
+	}
+	oidcCertMutex.Lock()
+	defer oidcCertMutex.Unlock()
+	key := cfg.cacheKey()
+	if cert := oidcCertCache[key]; cert != nil && time.Until(cert.expiry) > 30*time.Second {
+		return cert.signer
+	}
+	cert, err := newOidcCert(cfg)
+	if err != nil {
+		warning("get the certificate via OIDC failed: %v", err)
+		return nil
+	}
+	oidcCertCache[key] = cert
+	return cert.signer
+}
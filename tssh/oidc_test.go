@@ -0,0 +1,140 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestOidcCertSigner(t *testing.T) {
+	assert := assert.New(t)
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	assert.Nil(err)
+
+	var challenge string
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != "the-code" || base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			http.Error(w, "invalid grant", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": "the-id-token"})
+	})
+	mux.HandleFunc("/ssh/sign", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			PublicKey []byte `json:"publicKey"`
+			OTT       string `json:"ott"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		pubKey, err := ssh.ParsePublicKey(req.PublicKey)
+		if err != nil || req.OTT != "the-id-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		cert := &ssh.Certificate{
+			Key:             pubKey,
+			CertType:        ssh.UserCert,
+			KeyId:           "alice@example.com",
+			ValidPrincipals: []string{"alice"},
+			ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+		}
+		_ = cert.SignCert(rand.Reader, caSigner)
+		_ = json.NewEncoder(w).Encode(map[string]string{"crt": base64.StdEncoding.EncodeToString(cert.Marshal())})
+	})
+
+	originalOpenBrowser := openBrowser
+	defer func() { openBrowser = originalOpenBrowser }()
+	opened := 0
+	openBrowser = func(link string) error {
+		opened++
+		u, err := url.Parse(link)
+		if err != nil {
+			return err
+		}
+		q := u.Query()
+		challenge = q.Get("code_challenge")
+		// the second callback should not block, while the first code is taken
+		for _, code := range []string{"the-code", "another-code"} {
+			resp, err := http.Get(fmt.Sprintf("%s?code=%s&state=%s", q.Get("redirect_uri"), code, q.Get("state")))
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+		}
+		return nil
+	}
+
+	args := &sshArgs{Destination: "oidc-test-host"}
+	for _, option := range []string{"ExOidcIssuer " + server.URL, "ExOidcClientID tssh", "ExOidcSignURL " + server.URL + "/ssh/sign"} {
+		assert.Nil(args.Option.UnmarshalText([]byte(option)))
+	}
+	signer := getOidcCertSigner(args)
+	assert.NotNil(signer)
+	cert, ok := signer.pubKey.(*ssh.Certificate)
+	assert.True(ok)
+	assert.Equal("alice@example.com", cert.KeyId)
+	signature, err := signer.Sign(rand.Reader, []byte("data"))
+	assert.Nil(err)
+	assert.Nil(cert.Key.Verify([]byte("data"), signature))
+
+	assert.Equal(signer, getOidcCertSigner(args))
+	assert.Equal(1, opened)
+
+	batchMode = true
+	defer func() { batchMode = false }()
+	cfg, err := getOidcConfig(args)
+	assert.Nil(err)
+	_, err = oidcLogin(cfg)
+	assert.ErrorContains(err, "BatchMode is enabled")
+	assert.Equal(1, opened)
+
+	_, err = getOidcConfig(&sshArgs{Option: sshOption{map[string][]string{"exoidcissuer": {server.URL}}}})
+	assert.NotNil(err)
+}
@@ -0,0 +1,106 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import "strings"
+
+type escapeState int
+
+const (
+	escapeGround escapeState = iota
+	escapeEsc
+	escapeCsi
+	escapeString
+	escapeStringEsc
+)
+
+// outputTracker follows the escape sequences of the remote output, so that tssh doesn't write
+// its own text in the middle of them, or on the alternate screen of the full-screen programs.
+type outputTracker struct {
+	state          escapeState
+	csi            []byte
+	altScreen      bool
+	bracketedPaste bool
+}
+
+func (t *outputTracker) isGround() bool {
+	return t.state == escapeGround
+}
+
+func (t *outputTracker) track(buf []byte) {
+	for _, c := range buf {
+		switch t.state {
+		case escapeGround:
+			if c == 0x1b {
+				t.state = escapeEsc
+			}
+		case escapeEsc:
+			switch c {
+			case '[':
+				t.state = escapeCsi
+				t.csi = t.csi[:0]
+			case ']', 'P', '_', '^', 'X':
+				t.state = escapeString
+			default:
+				if c < 0x20 || c >= 0x30 {
+					t.state = escapeGround
+				}
+			}
+		case escapeCsi:
+			if c >= 0x40 && c <= 0x7e {
+				t.state = escapeGround
+				t.onCsi(c)
+			} else if len(t.csi) < 32 {
+				t.csi = append(t.csi, c)
+			}
+		case escapeString:
+			if c == 0x07 {
+				t.state = escapeGround
+			} else if c == 0x1b {
+				t.state = escapeStringEsc
+			}
+		case escapeStringEsc:
+			if c == '\\' {
+				t.state = escapeGround
+			} else {
+				t.state = escapeString
+			}
+		}
+	}
+}
+
+func (t *outputTracker) onCsi(final byte) {
+	if final != 'h' && final != 'l' || len(t.csi) == 0 || t.csi[0] != '?' {
+		return
+	}
+	for _, mode := range strings.Split(string(t.csi[1:]), ";") {
+		switch mode {
+		case "47", "1047", "1049":
+			t.altScreen = final == 'h'
+		case "2004":
+			t.bracketedPaste = final == 'h'
+		}
+	}
+}
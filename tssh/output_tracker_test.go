@@ -0,0 +1,56 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputTracker(t *testing.T) {
+	assert := assert.New(t)
+	var tracker outputTracker
+	tracker.track([]byte("\033[1;31mred"))
+	assert.True(tracker.isGround())
+	tracker.track([]byte("\033]0;title"))
+	assert.Equal(escapeString, tracker.state)
+	tracker.track([]byte("\033\\text\033["))
+	assert.Equal(escapeCsi, tracker.state)
+	tracker.track([]byte("?1049h"))
+	assert.True(tracker.isGround())
+	assert.True(tracker.altScreen)
+	tracker.track([]byte("\033]0;bell\007\033[?25;1049l"))
+	assert.True(tracker.isGround())
+	assert.False(tracker.altScreen)
+	tracker.track([]byte("\033[?47h\033(B"))
+	assert.True(tracker.altScreen)
+	assert.True(tracker.isGround())
+	tracker.track([]byte("\033[?2004h"))
+	assert.True(tracker.bracketedPaste)
+	tracker.track([]byte("\033[?1049;2004l"))
+	assert.False(tracker.bracketedPaste)
+	assert.False(tracker.altScreen)
+}
@@ -0,0 +1,273 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	kPasteStart = []byte("\033[200~")
+	kPasteEnd   = []byte("\033[201~")
+)
+
+const (
+	kPasteMinSize      = 64
+	kPastePreviewLines = 3
+	kPastePreviewWidth = 72
+	kPasteRateInterval = 100 * time.Millisecond
+)
+
+// pasteGuard protects the remote shell from the pasted text, e.g.:
+//
+//	Host prod*
+//	    GroupLabels prod
+//	    ExPasteProtect yes
+//	    ExPasteConfirm yes
+//	    ExPasteRate 4096
+//
+// ExPasteProtect wraps the pastes in the bracketed paste if the remote side has enabled it but the local
+// terminal doesn't send it, so the pasted lines won't be run one by one. ExPasteConfirm shows the first
+// lines of the multi-line pastes and asks for a confirmation on the hosts labeled prod. ExPasteRate limits
+// the bytes per second of the pastes which are not bracketed, so the remote input buffer won't overflow.
+//
+// The pastes without the bracketed paste are detected by a large or multi-line input which arrives at once.
+type pasteGuard struct {
+	reader    io.Reader
+	output    io.Writer
+	alias     string
+	bracket   bool
+	confirm   bool
+	rate      int
+	buffer    []byte
+	pending   []byte
+	limited   int
+	throttled bool
+	pasting   bool
+	paste     []byte
+	mutex     sync.Mutex
+	screen    outputTracker
+}
+
+func isUnbracketedPaste(buf []byte) bool {
+	if len(buf) < 2 || buf[0] == 0x1b {
+		return false
+	}
+	return len(buf) >= kPasteMinSize || bytes.ContainsAny(buf[:len(buf)-1], "\r\n")
+}
+
+func splitPasteLines(paste []byte) []string {
+	text := strings.ReplaceAll(strings.ReplaceAll(string(paste), "\r\n", "\n"), "\r", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func getPastePreview(line string) string {
+	line = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '.'
+		}
+		return r
+	}, line)
+	if utf8.RuneCountInString(line) > kPastePreviewWidth {
+		line = string([]rune(line)[:kPastePreviewWidth-3]) + "..."
+	}
+	return line
+}
+
+func (g *pasteGuard) remoteBracketedPaste() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.screen.bracketedPaste
+}
+
+// confirmPaste asks the user whether to send the multi-line paste, any answer other than y is a no.
+func (g *pasteGuard) confirmPaste(lines []string, size int) bool {
+	fmt.Fprintf(g.output, "\r\n\033[0;33mPaste %d lines (%d bytes) to %s:\033[0m\r\n", len(lines), size, g.alias)
+	for i, line := range lines {
+		if i >= kPastePreviewLines {
+			fmt.Fprintf(g.output, "  ...\r\n")
+			break
+		}
+		fmt.Fprintf(g.output, "  | %s\r\n", getPastePreview(line))
+	}
+	fmt.Fprintf(g.output, "\033[0;33mSend the paste? [y/N] \033[0m")
+	answer := make([]byte, 1)
+	for {
+		n, err := g.reader.Read(answer)
+		if n > 0 {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(g.output, "\r\n")
+			return false
+		}
+	}
+	if answer[0] == 'y' || answer[0] == 'Y' {
+		fmt.Fprintf(g.output, "y\r\n")
+		return true
+	}
+	fmt.Fprintf(g.output, "\033[0;33mDiscarded\033[0m\r\n")
+	return false
+}
+
+func (g *pasteGuard) onPaste(paste []byte, bracketed bool) {
+	if g.confirm {
+		if lines := splitPasteLines(paste); len(lines) > 1 && !g.confirmPaste(lines, len(paste)) {
+			return
+		}
+	}
+	if bracketed || g.bracket && g.remoteBracketedPaste() {
+		g.pending = append(g.pending, kPasteStart...)
+		g.pending = append(g.pending, paste...)
+		g.pending = append(g.pending, kPasteEnd...)
+		return
+	}
+	g.pending = append(g.pending, paste...)
+	if g.rate > 0 {
+		g.limited = len(g.pending)
+	}
+}
+
+func (g *pasteGuard) onInput(buf []byte) {
+	if !g.pasting {
+		idx := bytes.Index(buf, kPasteStart)
+		if idx < 0 {
+			if isUnbracketedPaste(buf) {
+				g.onPaste(buf, false)
+			} else {
+				g.pending = append(g.pending, buf...)
+			}
+			return
+		}
+		g.pending = append(g.pending, buf[:idx]...)
+		g.pasting = true
+		g.paste = g.paste[:0]
+		buf = buf[idx+len(kPasteStart):]
+	}
+	g.paste = append(g.paste, buf...)
+	idx := bytes.Index(g.paste, kPasteEnd)
+	if idx < 0 {
+		return
+	}
+	g.pasting = false
+	rest := append([]byte(nil), g.paste[idx+len(kPasteEnd):]...)
+	g.onPaste(g.paste[:idx], true)
+	if len(rest) > 0 {
+		g.onInput(rest)
+	}
+}
+
+func (g *pasteGuard) Read(p []byte) (int, error) {
+	for len(g.pending) == 0 {
+		n, err := g.reader.Read(g.buffer)
+		if n > 0 {
+			g.onInput(g.buffer[:n])
+		}
+		if err != nil {
+			if g.pasting {
+				// the end of the bracketed paste is lost
+				g.pending = append(g.pending, kPasteStart...)
+				g.pending = append(g.pending, g.paste...)
+				g.pasting = false
+			}
+			if len(g.pending) == 0 {
+				return 0, err
+			}
+		}
+	}
+	size := len(p)
+	if g.limited > 0 {
+		chunk := g.rate * int(kPasteRateInterval) / int(time.Second)
+		if chunk < 1 {
+			chunk = 1
+		}
+		if size > chunk {
+			size = chunk
+		}
+		if g.throttled {
+			time.Sleep(kPasteRateInterval)
+		}
+	}
+	n := copy(p[:size], g.pending)
+	g.pending = g.pending[n:]
+	if g.limited > 0 {
+		g.limited -= n
+		if g.limited < 0 {
+			g.limited = 0
+		}
+	}
+	g.throttled = g.limited > 0
+	return n, nil
+}
+
+type pasteOutput struct {
+	reader io.Reader
+	guard  *pasteGuard
+}
+
+func (o *pasteOutput) Read(p []byte) (int, error) {
+	n, err := o.reader.Read(p)
+	if n > 0 {
+		o.guard.mutex.Lock()
+		o.guard.screen.track(p[:n])
+		o.guard.mutex.Unlock()
+	}
+	return n, err
+}
+
+// wrapPasteGuard protects the interactive session from the pastes if any ExPaste option is set.
+func wrapPasteGuard(args *sshArgs, ss *sshSession, stdin io.Reader) io.Reader {
+	guard := &pasteGuard{
+		reader:  stdin,
+		output:  os.Stderr,
+		alias:   args.Destination,
+		bracket: strings.ToLower(getExOptionConfig(args, "ExPasteProtect")) == "yes" && !args.DragFile,
+		buffer:  make([]byte, kStdioBufferSize),
+	}
+	if strings.ToLower(getExOptionConfig(args, "ExPasteConfirm")) == "yes" {
+		guard.confirm = hasGroupLabel(&sshHost{GroupLabels: getGroupLabels(args.Destination)}, []string{kConfirmHostLabel})
+	}
+	if value := getExOptionConfig(args, "ExPasteRate"); value != "" {
+		rate, err := strconv.Atoi(value)
+		if err != nil || rate < 0 {
+			warning("invalid ExPasteRate [%s], should be the bytes per second", value)
+		} else {
+			guard.rate = rate
+		}
+	}
+	if !guard.bracket && !guard.confirm && guard.rate == 0 {
+		return stdin
+	}
+	ss.serverOut = &pasteOutput{ss.serverOut, guard}
+	return guard
+}
@@ -0,0 +1,101 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasteGuard(t *testing.T) {
+	assert := assert.New(t)
+	newGuard := func(chunks ...string) (*pasteGuard, *bytes.Buffer) {
+		var output bytes.Buffer
+		return &pasteGuard{
+			reader: &chunkReader{chunks},
+			output: &output,
+			alias:  "prod1",
+			buffer: make([]byte, 1024),
+		}, &output
+	}
+	readAll := func(guard *pasteGuard) string {
+		buf, err := io.ReadAll(guard)
+		assert.Nil(err)
+		return string(buf)
+	}
+
+	assert.False(isUnbracketedPaste([]byte("a")))
+	assert.False(isUnbracketedPaste([]byte("ls\r")))
+	assert.False(isUnbracketedPaste([]byte("\033[A\033[A")))
+	assert.True(isUnbracketedPaste([]byte("ls\rpwd\r")))
+	assert.True(isUnbracketedPaste([]byte(strings.Repeat("x", kPasteMinSize))))
+	assert.Equal([]string{"a", "b", "", "c"}, splitPasteLines([]byte("a\r\nb\r\rc\n")))
+	assert.Equal("a.b", getPastePreview("a\tb"))
+	assert.Equal(kPastePreviewWidth, len(getPastePreview(strings.Repeat("x", 100))))
+
+	// wrap the unbracketed paste if the remote side has enabled the bracketed paste
+	guard, _ := newGuard("l", "s\r", "echo 1\recho 2\r")
+	guard.bracket = true
+	assert.Equal("ls\recho 1\recho 2\r", readAll(guard))
+	guard, _ = newGuard("l", "s\r", "echo 1\recho 2\r")
+	guard.bracket = true
+	guard.screen.track([]byte("\033[?2004h"))
+	assert.Equal("ls\r\033[200~echo 1\recho 2\r\033[201~", readAll(guard))
+
+	// confirm the multi-line pastes, across the reads
+	guard, output := newGuard("x\033[200~echo 1\r", "echo 2\r\033[20", "1~y", "y", "\r")
+	guard.confirm = true
+	assert.Equal("x\033[200~echo 1\recho 2\r\033[201~y\r", readAll(guard))
+	assert.Contains(output.String(), "Paste 2 lines (14 bytes) to prod1:\033[0m\r\n  | echo 1\r\n  | echo 2\r\n")
+	guard, output = newGuard("\033[200~rm -rf /tmp/a\rrm -rf /\r\033[201~", "n", "ls\r")
+	guard.confirm = true
+	assert.Equal("ls\r", readAll(guard))
+	assert.Contains(output.String(), "Discarded")
+	guard, output = newGuard("\033[200~single line\033[201~")
+	guard.confirm = true
+	assert.Equal("\033[200~single line\033[201~", readAll(guard))
+	assert.Equal("", output.String())
+
+	// limit the rate of the unbracketed paste
+	guard, _ = newGuard("ls\r", strings.Repeat("x", 100)+"\r")
+	guard.rate = 300
+	buf := make([]byte, 1024)
+	n, _ := guard.Read(buf)
+	assert.Equal("ls\r", string(buf[:n]))
+	beginTime := time.Now()
+	var paste []byte
+	for len(paste) < 101 {
+		n, _ = guard.Read(buf)
+		assert.LessOrEqual(n, 30)
+		paste = append(paste, buf[:n]...)
+	}
+	assert.GreaterOrEqual(time.Since(beginTime), 3*kPasteRateInterval)
+	assert.Equal(strings.Repeat("x", 100)+"\r", string(paste))
+}
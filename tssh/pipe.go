@@ -0,0 +1,163 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// pipeMonitor shows the bytes sent to and received from the remote command of --pipe, e.g.:
+//
+//	tssh --pipe host 'zstd -d | psql' < dump.sql.zst
+//
+// The progress is shown on stderr only if it's a terminal, and erased before the remote stderr output.
+type pipeMonitor struct {
+	mutex    sync.Mutex
+	output   io.Writer
+	sent     int64
+	received int64
+	lastSent int64
+	lastRecv int64
+	lastTime time.Time
+	begin    time.Time
+	drawn    bool
+	ticker   *time.Ticker
+	stopped  chan struct{}
+}
+
+func newPipeMonitor(output io.Writer) *pipeMonitor {
+	now := time.Now()
+	m := &pipeMonitor{output: output, begin: now, lastTime: now, stopped: make(chan struct{})}
+	m.ticker = time.NewTicker(kTransferReportInterval)
+	go func() {
+		for {
+			select {
+			case <-m.ticker.C:
+				m.draw(time.Now())
+			case <-m.stopped:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+func formatPipeRate(bytes int64, duration time.Duration) string {
+	if duration <= 0 {
+		return formatTransferBytes(0) + "/s"
+	}
+	return formatTransferBytes(int64(float64(bytes)/duration.Seconds())) + "/s"
+}
+
+func (m *pipeMonitor) draw(now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	duration := now.Sub(m.lastTime)
+	fmt.Fprintf(m.output, "\r\033[Ksent %s (%s), received %s (%s)", formatTransferBytes(m.sent),
+		formatPipeRate(m.sent-m.lastSent, duration), formatTransferBytes(m.received), formatPipeRate(m.received-m.lastRecv, duration))
+	m.lastSent, m.lastRecv, m.lastTime = m.sent, m.received, now
+	m.drawn = true
+}
+
+// clear erases the progress before the remote stderr output.
+func (m *pipeMonitor) clear() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.drawn {
+		fmt.Fprint(m.output, "\r\033[K")
+		m.drawn = false
+	}
+}
+
+// stop shows the total bytes and the average rates.
+func (m *pipeMonitor) stop() {
+	m.ticker.Stop()
+	close(m.stopped)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	duration := time.Since(m.begin)
+	fmt.Fprintf(m.output, "\r\033[Ksent %s (%s), received %s (%s) in %s\r\n", formatTransferBytes(m.sent),
+		formatPipeRate(m.sent, duration), formatTransferBytes(m.received), formatPipeRate(m.received, duration),
+		duration.Round(time.Millisecond))
+}
+
+type pipeReader struct {
+	reader  io.Reader
+	monitor *pipeMonitor
+	counter *int64
+}
+
+func (r *pipeReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.monitor.mutex.Lock()
+		*r.counter += int64(n)
+		r.monitor.mutex.Unlock()
+	}
+	return n, err
+}
+
+type pipeErrReader struct {
+	reader  io.Reader
+	monitor *pipeMonitor
+}
+
+func (r *pipeErrReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.monitor.clear()
+	}
+	return n, err
+}
+
+// checkPipeArgs checks the arguments of --pipe, which requires a remote command without a pty.
+func checkPipeArgs(args *sshArgs, cmd string) error {
+	if args.ForceTTY {
+		return fmt.Errorf("cannot specify -t with --pipe")
+	}
+	if cmd == "" {
+		return fmt.Errorf("--pipe requires a remote command")
+	}
+	return nil
+}
+
+// wrapPipe forwards the stdin and stdout of --pipe as binary data, without any line ending translation.
+func wrapPipe(ss *sshSession, stdin io.Reader, stdout io.WriteCloser) {
+	serverOut, serverErr := ss.serverOut, ss.serverErr
+	if isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+		monitor := newPipeMonitor(os.Stderr)
+		onExit(monitor.stop)
+		stdin = &pipeReader{stdin, monitor, &monitor.sent}
+		serverOut = &pipeReader{serverOut, monitor, &monitor.received}
+		serverErr = &pipeErrReader{serverErr, monitor}
+	}
+	wrapStdIO(stdin, ss.serverIn, serverOut, stdout, serverErr, true)
+}
@@ -0,0 +1,68 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPipeArgs(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(checkPipeArgs(&sshArgs{Pipe: true}, "cat"))
+	assert.NotNil(checkPipeArgs(&sshArgs{Pipe: true}, ""))
+	assert.NotNil(checkPipeArgs(&sshArgs{Pipe: true, ForceTTY: true}, "cat"))
+}
+
+func TestPipeMonitor(t *testing.T) {
+	assert := assert.New(t)
+	var output syncBuffer
+	monitor := newPipeMonitor(&output)
+	monitor.ticker.Stop()
+
+	stdin := &pipeReader{strings.NewReader(strings.Repeat("x", 3000)), monitor, &monitor.sent}
+	data, err := io.ReadAll(stdin)
+	assert.Nil(err)
+	assert.Len(data, 3000)
+	serverOut := &pipeReader{strings.NewReader("result"), monitor, &monitor.received}
+	_, _ = io.ReadAll(serverOut)
+
+	monitor.draw(monitor.lastTime.Add(time.Second))
+	assert.Equal("\r\033[Ksent 2.9 KiB (2.9 KiB/s), received 6 B (6 B/s)", output.String())
+
+	// erase the progress before the remote stderr output
+	serverErr := &pipeErrReader{strings.NewReader("error"), monitor}
+	_, _ = io.ReadAll(serverErr)
+	assert.True(strings.HasSuffix(output.String(), "\r\033[K"))
+	assert.False(monitor.drawn)
+
+	monitor.stop()
+	assert.Contains(output.String(), "sent 2.9 KiB (")
+	assert.True(strings.HasSuffix(output.String(), "\r\n"))
+}
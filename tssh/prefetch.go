@@ -0,0 +1,172 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/skeema/knownhosts"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// privateKeyFile is a private key file read and parsed once, the encrypted one is parsed with the passphrase later.
+type privateKeyFile struct {
+	data     []byte
+	signer   ssh.Signer
+	readErr  error
+	parseErr error
+}
+
+// knownHostsFiles is the known_hosts files parsed once, it's reset after a new host key is added.
+type knownHostsFiles struct {
+	ready    chan struct{}
+	callback knownhosts.HostKeyCallback
+	err      error
+}
+
+var loginCache = struct {
+	sync.Mutex
+	privateKeys  map[string]*privateKeyFile
+	knownHosts   map[string]*knownHostsFiles
+	agentSigners []ssh.Signer
+	agentErr     error
+	agentLoaded  bool
+}{
+	privateKeys: make(map[string]*privateKeyFile),
+	knownHosts:  make(map[string]*knownHostsFiles),
+}
+
+func loadPrivateKey(path string) *privateKeyFile {
+	loginCache.Lock()
+	defer loginCache.Unlock()
+	if key, ok := loginCache.privateKeys[path]; ok {
+		return key
+	}
+	key := &privateKeyFile{}
+	key.data, key.readErr = os.ReadFile(path)
+	if key.readErr == nil {
+		key.signer, key.parseErr = ssh.ParsePrivateKey(key.data)
+	}
+	loginCache.privateKeys[path] = key
+	return key
+}
+
+func loadKnownHosts(files []string) (knownhosts.HostKeyCallback, error) {
+	key := strings.Join(files, "\n")
+	loginCache.Lock()
+	if kh, ok := loginCache.knownHosts[key]; ok {
+		loginCache.Unlock()
+		<-kh.ready
+		return kh.callback, kh.err
+	}
+	kh := &knownHostsFiles{ready: make(chan struct{})}
+	loginCache.knownHosts[key] = kh
+	loginCache.Unlock()
+
+	kh.callback, kh.err = knownhosts.New(files...)
+	close(kh.ready)
+	return kh.callback, kh.err
+}
+
+func resetKnownHosts() {
+	loginCache.Lock()
+	defer loginCache.Unlock()
+	loginCache.knownHosts = make(map[string]*knownHostsFiles)
+}
+
+// getAgentSigners lists the keys of the agent once for all the hops.
+func getAgentSigners(client agent.ExtendedAgent) ([]ssh.Signer, error) {
+	loginCache.Lock()
+	defer loginCache.Unlock()
+	if !loginCache.agentLoaded {
+		loginCache.agentSigners, loginCache.agentErr = client.Signers()
+		loginCache.agentLoaded = true
+	}
+	return loginCache.agentSigners, loginCache.agentErr
+}
+
+func resetAgentSigners() {
+	loginCache.Lock()
+	defer loginCache.Unlock()
+	loginCache.agentSigners, loginCache.agentErr, loginCache.agentLoaded = nil, nil, false
+}
+
+// loginPrefetch is the config of a jump host resolved in the background, with its private keys,
+// agent keys and known_hosts loaded into the loginCache, while the former hops are dialing and handshaking.
+type loginPrefetch struct {
+	done        chan struct{}
+	destination string
+	param       *sshParam
+	err         error
+}
+
+func prefetchLogin(args *sshArgs) *loginPrefetch {
+	prefetch := &loginPrefetch{done: make(chan struct{})}
+	prefetchArgs := *args
+	go func() {
+		defer close(prefetch.done)
+		param, err := getSshParam(&prefetchArgs)
+		prefetch.destination, prefetch.param, prefetch.err = prefetchArgs.Destination, param, err
+		if err != nil {
+			return
+		}
+		args := &prefetchArgs
+		if strings.ToLower(getOptionConfig(args, "PubkeyAuthentication")) != "no" {
+			identities := getIdentityFiles(args, param, debug)
+			if len(identities) == 0 {
+				for _, name := range kDefaultIdentityNames {
+					if path := filepath.Join(userHomeDir, ".ssh", name); isFileExist(path) {
+						identities = append(identities, path)
+					}
+				}
+			}
+			for _, identity := range identities {
+				loadPrivateKey(resolvePath(identity))
+			}
+			if client := getAgentClient(args, param); client != nil {
+				_, _ = getAgentSigners(client)
+			}
+		}
+		if _, files, err := getKnownHostsFiles(args, param, debug); err == nil {
+			_, _ = loadKnownHosts(files)
+		}
+	}()
+	return prefetch
+}
+
+// getSshParam returns the prefetched param if any.
+func (p *loginPrefetch) getSshParam(args *sshArgs) (*sshParam, error) {
+	<-p.done
+	if p.err != nil {
+		return nil, p.err
+	}
+	args.Destination = p.destination
+	param := *p.param
+	return &param, nil
+}
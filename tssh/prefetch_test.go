@@ -0,0 +1,95 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestPrefetchLogin(t *testing.T) {
+	assert := assert.New(t)
+	defer func(config *tsshConfig) { userConfig = config }(userConfig)
+	dir := t.TempDir()
+
+	_, priKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+	block, err := ssh.MarshalPrivateKey(priKey, "")
+	assert.Nil(err)
+	identity := filepath.Join(dir, "id_hop2")
+	assert.Nil(os.WriteFile(identity, pem.EncodeToMemory(block), 0600))
+	knownHosts := filepath.Join(dir, "known_hosts")
+	assert.Nil(os.WriteFile(knownHosts, nil, 0600))
+
+	config := filepath.Join(dir, "config")
+	assert.Nil(os.WriteFile(config, []byte(`
+Host hop2
+    HostName 10.0.0.2
+    Port 2222
+    User admin
+    IdentityFile `+identity+`
+    UserKnownHostsFile `+knownHosts+`
+    GlobalKnownHostsFile none
+    IdentityAgent none
+`), 0600))
+	userConfig = &tsshConfig{configPath: config}
+
+	prefetch := prefetchLogin(&sshArgs{Destination: "root@hop2"})
+	args := &sshArgs{Destination: "root@hop2", prefetch: prefetch}
+	param, err := args.prefetch.getSshParam(args)
+	assert.Nil(err)
+	assert.Equal("hop2", args.Destination)
+	assert.Equal("root", param.user)
+	assert.Equal("10.0.0.2:2222", param.addr)
+
+	// the private key is loaded in the background, and reused by the login
+	key := loadPrivateKey(identity)
+	assert.Nil(key.readErr)
+	assert.Nil(key.parseErr)
+	signer := getSigner("hop2", identity)
+	assert.NotNil(signer)
+	assert.Equal(key.signer.PublicKey().Marshal(), signer.pubKey.Marshal())
+
+	// the known_hosts is parsed once, and parsed again after a new host key is added
+	_, files, err := getKnownHostsFiles(args, param, warning)
+	assert.Nil(err)
+	assert.Equal([]string{knownHosts}, files)
+	kh, err := loadKnownHosts(files)
+	assert.Nil(err)
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 2222}
+	assert.NotNil(kh(param.addr, addr, key.signer.PublicKey()))
+	assert.Nil(writeKnownHost(knownHosts, param.addr, addr, key.signer.PublicKey()))
+	kh, err = loadKnownHosts(files)
+	assert.Nil(err)
+	assert.Nil(kh(param.addr, addr, key.signer.PublicKey()))
+}
@@ -0,0 +1,53 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// applyProfiles appends the options of the named profiles to the command line options.
+// The profiles are defined in ~/.tssh.conf, one option per line, e.g.:
+//
+//	Profile.debug = RequestTTY yes
+//	Profile.debug = LocalForward 8080 127.0.0.1:80
+//
+// The options given by -o take precedence, and the earlier profiles take precedence over the later ones.
+func applyProfiles(args *sshArgs) error {
+	for _, name := range args.Profile.values {
+		options, ok := userConfig.profiles[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("profile [%s] is not defined in ~/.tssh.conf", name)
+		}
+		for _, option := range options {
+			if err := args.Option.UnmarshalText([]byte(option)); err != nil {
+				return fmt.Errorf("profile [%s] has %v", name, err)
+			}
+			debug("apply profile [%s]: %s", name, option)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyProfiles(t *testing.T) {
+	assert := assert.New(t)
+	originalProfiles := userConfig.profiles
+	defer func() { userConfig.profiles = originalProfiles }()
+	userConfig.profiles = map[string][]string{
+		"debug": {"RequestTTY yes", "LocalForward 8080 127.0.0.1:80", "LocalForward=8081 127.0.0.1:81"},
+		"quiet": {"RequestTTY no", "LogLevel quiet"},
+	}
+
+	args := &sshArgs{Profile: multiStr{[]string{"Debug", "quiet"}}}
+	assert.Nil(args.Option.UnmarshalText([]byte("LogLevel=debug")))
+	assert.Nil(applyProfiles(args))
+	assert.Equal("yes", args.Option.get("RequestTTY"))
+	assert.Equal("debug", args.Option.get("LogLevel"))
+	assert.Equal([]string{"8080 127.0.0.1:80", "8081 127.0.0.1:81"}, args.Option.getAll("LocalForward"))
+
+	assert.NotNil(applyProfiles(&sshArgs{Profile: multiStr{[]string{"unknown"}}}))
+}
@@ -542,7 +542,14 @@ func matchHost(h *sshHost, keywords []string) bool {
 	host := strings.ToLower(h.Host)
 	alias := strings.ToLower(h.Alias)
 	labels := strings.ToLower(h.GroupLabels)
-	for _, keyword := range keywords {
+	ranges := expandKeywords(keywords)
+	for i, keyword := range keywords {
+		if ranges[i] != nil {
+			if !matchHostRange(alias, host, ranges[i]) {
+				return false
+			}
+			continue
+		}
 		if !strings.Contains(host, keyword) &&
 			!strings.Contains(alias, keyword) &&
 			!strings.Contains(labels, keyword) {
@@ -552,17 +559,69 @@ func matchHost(h *sshHost, keywords []string) bool {
 	return true
 }
 
+// hostSearcher keeps the lower case search text of each host, and when the input is extended,
+// only the hosts matched by the previous input are searched again, so typing stays responsive with 10k+ hosts.
+type hostSearcher struct {
+	hosts    []*sshHost
+	texts    []string
+	input    string
+	keywords []string
+	ranges   [][]string
+	matched  []bool
+	previous []bool
+}
+
+func newHostSearcher(hosts []*sshHost) *hostSearcher {
+	texts := make([]string, len(hosts))
+	for i, h := range hosts {
+		// a keyword contains no space, so it can't match across the separators
+		texts[i] = strings.ToLower(h.Host + " " + h.Alias + " " + h.GroupLabels)
+	}
+	return &hostSearcher{hosts: hosts, texts: texts}
+}
+
+func (s *hostSearcher) search(input string, index int) bool {
+	if s.matched == nil || input != s.input {
+		s.previous = nil
+		// a host range may match more hosts when it's completed, e.g., web{1 => web{1,2}
+		if s.matched != nil && strings.HasPrefix(input, s.input) && !strings.ContainsAny(input, "{[") {
+			s.previous = s.matched
+		}
+		s.input = input
+		s.keywords = strings.Fields(strings.ToLower(input))
+		s.ranges = expandKeywords(s.keywords)
+		s.matched = make([]bool, len(s.texts))
+	}
+	if s.previous != nil && !s.previous[index] {
+		return false
+	}
+	for i, keyword := range s.keywords {
+		if s.ranges[i] != nil {
+			if !matchHostRange(s.hosts[index].Alias, s.hosts[index].Host, s.ranges[i]) {
+				return false
+			}
+			continue
+		}
+		if !strings.Contains(s.texts[index], keyword) {
+			return false
+		}
+	}
+	s.matched[index] = true
+	return true
+}
+
 func chooseAlias(keywords string) (string, bool, error) {
 	if state, _ := makeStdinRaw(); state != nil {
 		defer resetStdin(state)
 	}
 
-	hosts := getAllHosts()
-
-	searcher := func(input string, index int) bool {
-		return matchHost(hosts[index], strings.Fields(strings.ToLower(input)))
+	hosts := appendLanHosts(getAllHosts())
+	if keywords != "" {
+		hosts = append(hosts, getSuggestedHosts(hosts)...)
 	}
 
+	searcher := newHostSearcher(hosts).search
+
 	theme := getPromptTheme()
 	termMgr := getTerminalManager()
 
@@ -630,11 +689,61 @@ func fastLookupHost(host string) bool {
 	}
 }
 
+// getShortcutAlias returns the alias of the host whose ExShortcut is the dest, e.g., `tssh 1` logs in to web1:
+//
+//	Host web1
+//	    #!! ExShortcut 1
+//
+// The alias named the same as the dest takes precedence over the shortcut.
+func getShortcutAlias(dest string) string {
+	// the host-looking destinations and the configured aliases are not shortcuts, don't load all the hosts for them
+	if dest == "" || strings.ContainsAny(dest, ".:[]@") || isConfiguredAlias(dest) {
+		return ""
+	}
+	var alias string
+	for _, host := range getAllHosts() {
+		if host.Alias == dest {
+			return ""
+		}
+		if host.Shortcut != dest {
+			continue
+		}
+		if alias == "" {
+			alias = host.Alias
+		} else {
+			warning("ExShortcut %s is used by both %s and %s, the former is chosen", dest, alias, host.Alias)
+		}
+	}
+	return alias
+}
+
 func predictDestination(dest string) (string, bool, error) {
+	// choose from the hosts in the range, e.g., web{01..10}
+	if isHostRange(dest) {
+		if _, err := expandHostRange(dest); err != nil {
+			return "", false, err
+		}
+		keywords := []string{dest}
+		for _, host := range getAllHosts() {
+			if matchHost(host, keywords) {
+				return chooseAlias(dest)
+			}
+		}
+		return "", false, fmt.Errorf("no configured host matches %s, use the range with the batch tools instead", dest)
+	}
+
 	if strings.ContainsAny(dest, ".:[]@") {
 		return dest, false, nil
 	}
 
+	if isConfiguredAlias(dest) {
+		return dest, false, nil
+	}
+
+	if alias := getShortcutAlias(dest); alias != "" {
+		return alias, false, nil
+	}
+
 	hosts := getAllHosts()
 	for _, host := range hosts {
 		if host.Alias == dest {
@@ -657,7 +766,17 @@ func predictDestination(dest string) (string, bool, error) {
 		}
 	}
 	if !match {
-		return dest, false, nil
+		for _, host := range getSuggestedHosts(hosts) {
+			if host.Alias == dest {
+				return dest, false, nil
+			}
+			if matchHost(host, keywords) {
+				match = true
+			}
+		}
+	}
+	if !match {
+		return confirmUnknownDestination(dest, hosts)
 	}
 
 	if fastLookupHost(dest) {
@@ -0,0 +1,132 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// promptRule answers the keyboard interactive questions matched by the regex, configured by ExPromptRule, e.g.:
+//
+//	ExPromptRule "(?i)verification code" cmd:oathtool --totp -b JBSWY3DPEHPK3PXP
+//	ExPromptRule ^Token: env:BASTION_TOKEN
+//	ExPromptRule "Select project" text:2
+//	ExPromptRule "(?i)passcode" secret:PasscodeSecret
+//	ExPromptRule "(?i)pin" ask
+//
+// The response sources are `secret:<key>` for the (encoded) secret configuration of the key,
+// `env:<name>` for the environment variable, `cmd:<command>` for the output of the command,
+// `text:<text>` for the literal text, and `ask` for the interactive input.
+// The first matched rule is used, and it's used only once for the same question, like the other configured answers.
+type promptRule struct {
+	regex  *regexp.Regexp
+	source string
+}
+
+func parsePromptRule(value string) (*promptRule, error) {
+	value = strings.TrimSpace(value)
+	var pattern, source string
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		end := strings.IndexByte(value[1:], value[0])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quote")
+		}
+		pattern, source = value[1:end+1], value[end+2:]
+	} else {
+		pattern, source, _ = strings.Cut(value, " ")
+	}
+	source = strings.TrimSpace(source)
+	if pattern == "" || source == "" {
+		return nil, fmt.Errorf("should be <regex> <source>")
+	}
+	kind, _, _ := strings.Cut(source, ":")
+	switch kind {
+	case "secret", "env", "cmd", "text":
+		if !strings.Contains(source, ":") {
+			return nil, fmt.Errorf("unknown source [%s]", source)
+		}
+	case "ask":
+		if source != "ask" {
+			return nil, fmt.Errorf("unknown source [%s]", source)
+		}
+	default:
+		return nil, fmt.Errorf("unknown source [%s], should be secret:, env:, cmd:, text: or ask", source)
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &promptRule{regex, source}, nil
+}
+
+func getPromptRules(args *sshArgs) []*promptRule {
+	var rules []*promptRule
+	for _, value := range getAllExOptionConfig(args, "ExPromptRule") {
+		rule, err := parsePromptRule(value)
+		if err != nil {
+			warning("invalid ExPromptRule [%s]: %v", value, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// answerByPromptRules returns the answer of the first rule which matches the question, and whether a rule matches.
+func answerByPromptRules(args *sshArgs, rules []*promptRule, question string, ask func(string) (string, error)) (string, bool, error) {
+	for _, rule := range rules {
+		if !rule.regex.MatchString(question) {
+			continue
+		}
+		debug("the question '%s' matches the prompt rule: %s", question, rule.regex)
+		kind, value, _ := strings.Cut(rule.source, ":")
+		var answer string
+		switch kind {
+		case "secret":
+			answer = getSecretConfig(args.Destination, value)
+		case "env":
+			answer = os.Getenv(value)
+		case "cmd":
+			answer = getOtpCommandOutput(value)
+		case "text":
+			answer = value
+		case "ask":
+			if ask == nil {
+				return "", false, fmt.Errorf("the prompt rule for question '%s' asks for input, but prompts are disabled", question)
+			}
+			a, err := ask(question)
+			return a, err == nil, err
+		}
+		if answer == "" {
+			warning("the prompt rule [%s] for question '%s' gets an empty answer", rule.source, question)
+			return "", false, nil
+		}
+		return answer, true, nil
+	}
+	return "", false, nil
+}
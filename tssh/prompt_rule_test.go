@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromptRules(t *testing.T) {
+	assert := assert.New(t)
+	for _, value := range []string{"", "^Token:", "^Token: file:/tmp/token", "^Token: ask:me", `"unterminated text:a`, "[ text:a"} {
+		_, err := parsePromptRule(value)
+		assert.NotNil(err, value)
+	}
+
+	args := &sshArgs{}
+	for _, rule := range []string{
+		`"(?i)verification code" text:123456`,
+		"^Token: env:TSSH_TEST_TOKEN",
+		"'Select project' text:2",
+		"(?i)pin ask",
+		"^Empty env:TSSH_TEST_EMPTY",
+		"^Invalid bad:source",
+	} {
+		assert.Nil(args.Option.UnmarshalText([]byte("ExPromptRule " + rule)))
+	}
+	if runtime.GOOS != "windows" {
+		assert.Nil(args.Option.UnmarshalText([]byte("ExPromptRule ^Command cmd:echo from command")))
+	}
+	rules := getPromptRules(args)
+	t.Setenv("TSSH_TEST_TOKEN", "token-value")
+
+	var asked []string
+	ask := func(question string) (string, error) {
+		asked = append(asked, question)
+		return "1234", nil
+	}
+	assertAnswer := func(question, answer string, matched bool) {
+		t.Helper()
+		a, ok, err := answerByPromptRules(args, rules, question, ask)
+		assert.Nil(err)
+		assert.Equal(answer, a)
+		assert.Equal(matched, ok)
+	}
+	assertAnswer("Verification Code: ", "123456", true)
+	assertAnswer("Token: ", "token-value", true)
+	assertAnswer("Select project [1-3]: ", "2", true)
+	assertAnswer("Enter PIN: ", "1234", true)
+	assertAnswer("Empty: ", "", false)
+	assertAnswer("Password: ", "", false)
+	if runtime.GOOS != "windows" {
+		assertAnswer("Command: ", "from command", true)
+	}
+	assert.Equal([]string{"Enter PIN: "}, asked)
+
+	_, _, err := answerByPromptRules(args, rules, "Enter PIN: ", nil)
+	assert.NotNil(err)
+}
@@ -0,0 +1,105 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostSearcher(t *testing.T) {
+	assert := assert.New(t)
+	var hosts []*sshHost
+	for i := 0; i < 10000; i++ {
+		hosts = append(hosts, &sshHost{
+			Alias:       fmt.Sprintf("web%d", i),
+			Host:        fmt.Sprintf("10.%d.%d.%d", i%3, i/256, i%256),
+			GroupLabels: fmt.Sprintf("Group%d Region%d", i%10, i%7),
+		})
+	}
+
+	searcher := newHostSearcher(hosts)
+	for _, input := range []string{"", "w", "web1", "web12", "web12 group2", "web12 group2 region", "web1",
+		"10.1", "REGION3 10.", "web 5.1", "web1 1 x", "p2 r", "web{1", "web{1..12}", "web{1..12} group2",
+		"10.0.[1-3]", "10.0.[1-3]2"} {
+		count := 0
+		for i := range hosts {
+			expected := matchHost(hosts[i], strings.Fields(strings.ToLower(input)))
+			assert.Equal(expected, searcher.search(input, i), "input [%s] host %d", input, i)
+			if expected {
+				count++
+			}
+		}
+		if input == "" {
+			assert.Equal(len(hosts), count)
+		}
+	}
+}
+
+func TestShortcutAlias(t *testing.T) {
+	assert := assert.New(t)
+	defer func(config *tsshConfig) { userConfig = config }(userConfig)
+	config := filepath.Join(t.TempDir(), "config")
+	assert.Nil(os.WriteFile(config, []byte(`
+Host web1
+    HostName 10.0.0.1
+    #!! ExShortcut 1
+Host web2
+    HostName 10.0.0.2
+    #!! ExShortcut w
+Host db
+    #!! ExShortcut w
+Host 2
+    HostName 10.0.0.3
+Host web3
+    #!! ExShortcut 2
+`), 0600))
+	userConfig = &tsshConfig{configPath: config}
+
+	// the configured aliases and the host-looking destinations don't load all the hosts
+	for _, dest := range []string{"web2", "2", "10.0.0.9", "root@web", "web:2222"} {
+		predicted, _, err := predictDestination(dest)
+		assert.Nil(err)
+		assert.Equal(dest, predicted)
+	}
+	assert.Nil(userConfig.allHosts)
+
+	assert.Equal("web1", getShortcutAlias("1"))
+	assert.Equal("web2", getShortcutAlias("w"))
+	assert.Equal("", getShortcutAlias("2"))
+	assert.Equal("", getShortcutAlias("3"))
+	assert.Equal("", getShortcutAlias(""))
+
+	dest, quit, err := predictDestination("1")
+	assert.Nil(err)
+	assert.False(quit)
+	assert.Equal("web1", dest)
+	assert.Equal("1", getAllHosts()[0].Shortcut)
+}
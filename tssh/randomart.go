@@ -0,0 +1,145 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	kRandomArtBase  = 8
+	kRandomArtSizeY = kRandomArtBase + 1
+	kRandomArtSizeX = kRandomArtBase*2 + 1
+	kRandomArtChars = " .o+=*BOX@%&#/^SE"
+)
+
+func getKeyTypeAndBits(key ssh.PublicKey) (string, int) {
+	bits := 0
+	if cryptoKey, ok := key.(ssh.CryptoPublicKey); ok {
+		switch pubKey := cryptoKey.CryptoPublicKey().(type) {
+		case *rsa.PublicKey:
+			bits = pubKey.N.BitLen()
+		case *ecdsa.PublicKey:
+			bits = pubKey.Curve.Params().BitSize
+		}
+	}
+	switch key.Type() {
+	case ssh.KeyAlgoRSA:
+		return "RSA", bits
+	case ssh.KeyAlgoDSA:
+		return "DSA", bits
+	case ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521:
+		return "ECDSA", bits
+	case ssh.KeyAlgoSKECDSA256:
+		return "ECDSA-SK", 256
+	case ssh.KeyAlgoED25519:
+		return "ED25519", 256
+	case ssh.KeyAlgoSKED25519:
+		return "ED25519-SK", 256
+	default:
+		return strings.ToUpper(key.Type()), bits
+	}
+}
+
+// randomArtBorder returns the border line with the title in the middle, the same as openssh.
+func randomArtBorder(title string) string {
+	if len(title) > kRandomArtSizeX-2 {
+		title = title[:kRandomArtSizeX-2]
+	}
+	left := (kRandomArtSizeX - len(title)) / 2
+	right := kRandomArtSizeX - len(title) - left
+	return "+" + strings.Repeat("-", left) + title + strings.Repeat("-", right) + "+"
+}
+
+// fingerprintRandomArt draws the key fingerprint as the "drunken bishop" random art, the same as `ssh-keygen -lv`.
+func fingerprintRandomArt(key ssh.PublicKey, hashAlg string) string {
+	var digest []byte
+	switch hashAlg {
+	case "MD5":
+		sum := md5.Sum(key.Marshal())
+		digest = sum[:]
+	default:
+		hashAlg = "SHA256"
+		sum := sha256.Sum256(key.Marshal())
+		digest = sum[:]
+	}
+
+	var field [kRandomArtSizeX][kRandomArtSizeY]int
+	maxValue := len(kRandomArtChars) - 1
+	x, y := kRandomArtSizeX/2, kRandomArtSizeY/2
+	for _, input := range digest {
+		for i := 0; i < 4; i++ {
+			if input&0x1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if input&0x2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = clampInt(x, 0, kRandomArtSizeX-1)
+			y = clampInt(y, 0, kRandomArtSizeY-1)
+			if field[x][y] < maxValue-2 {
+				field[x][y]++
+			}
+			input >>= 2
+		}
+	}
+	field[kRandomArtSizeX/2][kRandomArtSizeY/2] = maxValue - 1
+	field[x][y] = maxValue
+
+	keyType, bits := getKeyTypeAndBits(key)
+	lines := []string{randomArtBorder(fmt.Sprintf("[%s %d]", keyType, bits))}
+	for y := 0; y < kRandomArtSizeY; y++ {
+		var buf strings.Builder
+		buf.WriteByte('|')
+		for x := 0; x < kRandomArtSizeX; x++ {
+			buf.WriteByte(kRandomArtChars[field[x][y]])
+		}
+		buf.WriteByte('|')
+		lines = append(lines, buf.String())
+	}
+	lines = append(lines, randomArtBorder(fmt.Sprintf("[%s]", hashAlg)))
+	return strings.Join(lines, "\n")
+}
+
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
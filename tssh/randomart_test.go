@@ -0,0 +1,65 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestFingerprintRandomArt(t *testing.T) {
+	assert := assert.New(t)
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAhrfNWicDvApsJittKIFGD2u8o91XuMcfDtfywlgcAx"))
+	assert.Nil(err)
+
+	assert.Equal(""+
+		"+--[ED25519 256]--+\n"+
+		"|ooo      +.      |\n"+
+		"|.. .    o.+      |\n"+
+		"| .  oo.o =.      |\n"+
+		"|.  ..o=.+o+ .    |\n"+
+		"| ...+..=S..+     |\n"+
+		"|  .+ooo..oo.     |\n"+
+		"|  ...+.== *.     |\n"+
+		"|   o. ..=*       |\n"+
+		"| .o  E....       |\n"+
+		"+----[SHA256]-----+", fingerprintRandomArt(key, "SHA256"))
+
+	assert.Equal(""+
+		"+--[ED25519 256]--+\n"+
+		"|           ===o  |\n"+
+		"|            *E.  |\n"+
+		"|            o.o. |\n"+
+		"|         . = o =.|\n"+
+		"|        S = . =o+|\n"+
+		"|         .   ..oo|\n"+
+		"|                .|\n"+
+		"|                 |\n"+
+		"|                 |\n"+
+		"+------[MD5]------+", fingerprintRandomArt(key, "MD5"))
+}
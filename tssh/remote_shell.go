@@ -0,0 +1,64 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wrapRemoteShell runs the command in the shell of ExRemoteShell, or in the login shell of the user
+// if --login-shell is specified, since the PATH set by the profile is missing in the non-login shell, e.g.:
+//
+//	Host web
+//	    #!! ExRemoteShell bash -l
+//
+// The shell should support the -c option, and the -l option for --login-shell.
+// The ${SHELL} is expanded by sh, as the login shell which runs the wrapper may be fish or csh.
+func wrapRemoteShell(args *sshArgs, cmd string) string {
+	if cmd == "" {
+		return cmd
+	}
+	shell := strings.TrimSpace(getExOptionConfig(args, "ExRemoteShell"))
+	if shell == "" && !args.LoginShell {
+		return cmd
+	}
+	if shell == "" {
+		return "exec sh -c " + shellQuote(`exec "${SHELL:-/bin/sh}" -l -c `+shellQuote(cmd))
+	}
+	if args.LoginShell && !isLoginShell(shell) {
+		shell += " -l"
+	}
+	return fmt.Sprintf("exec %s -c %s", shell, shellQuote(cmd))
+}
+
+func isLoginShell(shell string) bool {
+	for _, arg := range strings.Fields(shell)[1:] {
+		if arg == "-l" || arg == "--login" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapRemoteShell(t *testing.T) {
+	assert := assert.New(t)
+	args := &sshArgs{}
+	assert.Equal("", wrapRemoteShell(args, ""))
+	assert.Equal("echo $PATH", wrapRemoteShell(args, "echo $PATH"))
+
+	args.LoginShell = true
+	assert.Equal(`exec sh -c 'exec "${SHELL:-/bin/sh}" -l -c '\''echo $PATH'\'''`, wrapRemoteShell(args, "echo $PATH"))
+	assert.Equal("", wrapRemoteShell(args, ""))
+
+	args = &sshArgs{}
+	assert.Nil(args.Option.UnmarshalText([]byte("ExRemoteShell zsh")))
+	assert.Equal(`exec zsh -c 'echo '\''a b'\'''`, wrapRemoteShell(args, "echo 'a b'"))
+	args.LoginShell = true
+	assert.Equal(`exec zsh -l -c 'echo'`, wrapRemoteShell(args, "echo"))
+
+	args = &sshArgs{LoginShell: true}
+	assert.Nil(args.Option.UnmarshalText([]byte("ExRemoteShell bash --login")))
+	assert.Equal(`exec bash --login -c 'echo'`, wrapRemoteShell(args, "echo"))
+
+	// the wrapped command runs as the original one
+	args = &sshArgs{}
+	assert.Nil(args.Option.UnmarshalText([]byte("ExRemoteShell sh")))
+	output, err := exec.Command("sh", "-c", wrapRemoteShell(args, `printf '%s|' "a b" 'c'"'"'d'`)).Output()
+	assert.Nil(err)
+	assert.Equal("a b|c'd|", string(output))
+
+	// the login shell wrapper expands ${SHELL} by sh
+	t.Setenv("SHELL", "/bin/sh")
+	args = &sshArgs{LoginShell: true}
+	output, err = exec.Command("sh", "-c", wrapRemoteShell(args, `printf '%s|' "a b" 'c'"'"'d'`)).Output()
+	assert.Nil(err)
+	assert.Equal("a b|c'd|", string(output))
+}
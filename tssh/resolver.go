@@ -0,0 +1,235 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const kDefaultDnsCacheTTL = 60 * time.Second
+
+type dnsCacheEntry struct {
+	addrs  []string
+	expire time.Time
+}
+
+var dnsCache = struct {
+	sync.Mutex
+	entries map[string]*dnsCacheEntry
+}{entries: make(map[string]*dnsCacheEntry)}
+
+type dnsResolver struct {
+	servers []string
+	ttl     time.Duration
+}
+
+// getDnsResolver returns nil if neither ExDnsServers nor ExDnsCacheTTL is configured,
+// and then the host will be resolved by the system resolver as usual.
+//
+// ExDnsServers is a list of dns servers separated by spaces or commas, e.g.:
+//
+//	ExDnsServers 223.5.5.5 1.1.1.1:53 https://cloudflare-dns.com/dns-query
+func getDnsResolver(args *sshArgs) *dnsResolver {
+	servers := strings.Fields(strings.ReplaceAll(getExOptionConfig(args, "ExDnsServers"), ",", " "))
+	ttlConfig := getExOptionConfig(args, "ExDnsCacheTTL")
+	if len(servers) == 0 && ttlConfig == "" {
+		return nil
+	}
+	ttl := kDefaultDnsCacheTTL
+	if ttlConfig != "" {
+		seconds, err := strconv.ParseUint(ttlConfig, 10, 32)
+		if err != nil {
+			warning("invalid ExDnsCacheTTL [%s], use the default value %d", ttlConfig, int(kDefaultDnsCacheTTL.Seconds()))
+		} else {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	for i, server := range servers {
+		if !isDohServer(server) {
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				servers[i] = joinHostPort(server, "53")
+			}
+		}
+	}
+	return &dnsResolver{servers: servers, ttl: ttl}
+}
+
+func isDohServer(server string) bool {
+	return strings.HasPrefix(server, "https://") || strings.HasPrefix(server, "http://")
+}
+
+func newServerResolver(server string) *net.Resolver {
+	if isDohServer(server) {
+		return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, url: server}, nil
+		}}
+	}
+	return &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, server)
+	}}
+}
+
+func (r *dnsResolver) lookup(ctx context.Context, host string) ([]string, error) {
+	key := strings.ToLower(host) + "|" + strings.Join(r.servers, ",")
+	if r.ttl > 0 {
+		dnsCache.Lock()
+		entry, ok := dnsCache.entries[key]
+		dnsCache.Unlock()
+		if ok && time.Now().Before(entry.expire) {
+			debug("resolve [%s] from dns cache: %v", host, entry.addrs)
+			return entry.addrs, nil
+		}
+	}
+
+	var addrs []string
+	var err error
+	if len(r.servers) == 0 {
+		addrs, err = net.DefaultResolver.LookupHost(ctx, host)
+	} else {
+		for _, server := range r.servers {
+			addrs, err = newServerResolver(server).LookupHost(ctx, host)
+			if err == nil && len(addrs) > 0 {
+				debug("resolve [%s] by dns server [%s]: %v", host, server, addrs)
+				break
+			}
+			debug("resolve [%s] by dns server [%s] failed: %v", host, server, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address found for host [%s]", host)
+	}
+
+	if r.ttl > 0 {
+		dnsCache.Lock()
+		dnsCache.entries[key] = &dnsCacheEntry{addrs: addrs, expire: time.Now().Add(r.ttl)}
+		dnsCache.Unlock()
+	}
+	return addrs, nil
+}
+
+func (r *dnsResolver) dial(network, addr string, timeout time.Duration) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return net.DialTimeout(network, addr, timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addrs, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve [%s] failed: %v", host, err)
+	}
+	var dialer net.Dialer
+	for _, ip := range addrs {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		debug("dial [%s] (%s) failed: %v", addr, ip, err)
+	}
+	return nil, err
+}
+
+func dialTcpWithResolver(args *sshArgs, addr string, timeout time.Duration) (net.Conn, error) {
+	if resolver := getDnsResolver(args); resolver != nil {
+		return resolver.dial("tcp", addr, timeout)
+	}
+	return net.DialTimeout("tcp", addr, timeout)
+}
+
+// dohConn sends the dns queries over https ( RFC 8484 ).
+// The go resolver writes length prefixed messages to it since it's not a net.PacketConn.
+type dohConn struct {
+	ctx  context.Context
+	url  string
+	wbuf bytes.Buffer
+	rbuf bytes.Buffer
+}
+
+func (c *dohConn) query(msg []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("dns over https [%s] failed: %s", c.url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.wbuf.Write(b)
+	for c.wbuf.Len() >= 2 {
+		size := int(binary.BigEndian.Uint16(c.wbuf.Bytes()[:2]))
+		if c.wbuf.Len() < 2+size {
+			break
+		}
+		c.wbuf.Next(2)
+		msg := make([]byte, size)
+		_, _ = c.wbuf.Read(msg)
+		resp, err := c.query(msg)
+		if err != nil {
+			return 0, err
+		}
+		_ = binary.Write(&c.rbuf, binary.BigEndian, uint16(len(resp)))
+		c.rbuf.Write(resp)
+	}
+	return len(b), nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.rbuf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.rbuf.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return nil }
+func (c *dohConn) RemoteAddr() net.Addr               { return nil }
+func (c *dohConn) SetDeadline(t time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
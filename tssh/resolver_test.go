@@ -0,0 +1,85 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// answerDnsQuery answers the A query with 10.0.0.1 and the other queries with no records.
+func answerDnsQuery(query []byte) []byte {
+	resp := append([]byte(nil), query...)
+	resp[2] |= 0x80 // QR
+	resp[3] = 0x80  // RA, NOERROR
+	pos := 12
+	for query[pos] != 0 {
+		pos += int(query[pos]) + 1
+	}
+	qtype := binary.BigEndian.Uint16(query[pos+1 : pos+3])
+	resp = resp[:pos+5]
+	if qtype != 1 {
+		return resp
+	}
+	binary.BigEndian.PutUint16(resp[6:8], 1) // ANCOUNT
+	resp = append(resp, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 10, 0, 0, 1)
+	return resp
+}
+
+func TestDnsOverHttps(t *testing.T) {
+	assert := assert.New(t)
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(answerDnsQuery(query))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := newServerResolver(server.URL+"/dns-query").LookupHost(ctx, "example.test")
+	assert.Nil(err)
+	assert.Equal([]string{"10.0.0.1"}, addrs)
+
+	resolver := &dnsResolver{servers: []string{server.URL + "/dns-query"}, ttl: time.Minute}
+	addrs, err = resolver.lookup(ctx, "cache.example.test")
+	assert.Nil(err)
+	assert.Equal([]string{"10.0.0.1"}, addrs)
+	queries := count.Load()
+	addrs, err = resolver.lookup(ctx, "CACHE.example.test")
+	assert.Nil(err)
+	assert.Equal([]string{"10.0.0.1"}, addrs)
+	assert.Equal(queries, count.Load())
+}
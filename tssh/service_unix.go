@@ -0,0 +1,114 @@
+//go:build !windows
+
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const kSystemdUnitTemplate = `[Unit]
+Description=tssh tunnel %s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=%q --tunnel run %s
+Restart=always
+RestartSec=10
+
+[Install]
+WantedBy=default.target
+`
+
+func getSystemdUnitPath(name string) string {
+	return filepath.Join(userHomeDir, ".config", "systemd", "user", getServiceName(name)+".service")
+}
+
+func runSystemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("systemctl --user %s failed: %v %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// installService installs the tunnel as a systemd user unit.
+func installService(name, exe string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("only systemd on Linux is supported")
+	}
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return fmt.Errorf("systemctl not found: %v", err)
+	}
+	unitPath := getSystemdUnitPath(name)
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(unitPath, []byte(fmt.Sprintf(kSystemdUnitTemplate, name, exe, name)), 0644); err != nil {
+		return err
+	}
+	debug("systemd unit written to %s", unitPath)
+	if err := runSystemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := runSystemctl("enable", "--now", getServiceName(name)); err != nil {
+		return err
+	}
+
+	// the user units are stopped after logout unless lingering is enabled
+	out, err := exec.Command("loginctl", "show-user", fmt.Sprint(os.Getuid()), "--property=Linger").Output()
+	if err == nil && strings.TrimSpace(string(out)) != "Linger=yes" {
+		toolsWarn("Service", "run `loginctl enable-linger` to keep the service running after logout and start it on boot")
+	}
+	return nil
+}
+
+func uninstallService(name string) error {
+	unitPath := getSystemdUnitPath(name)
+	if _, err := os.Stat(unitPath); err != nil {
+		return fmt.Errorf("%s not found", unitPath)
+	}
+	if err := runSystemctl("disable", "--now", getServiceName(name)); err != nil {
+		return err
+	}
+	if err := os.Remove(unitPath); err != nil {
+		return err
+	}
+	return runSystemctl("daemon-reload")
+}
+
+func runTunnelService(name string) (int, bool) {
+	return 0, false
+}
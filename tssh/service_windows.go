@@ -0,0 +1,139 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// installService installs the tunnel as an automatic Windows service, which runs as
+// the current user, so that it uses the same ~/.ssh/config, ~/.tssh.conf and keys.
+func installService(name, exe string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to the service manager failed, please run as administrator: %v", err)
+	}
+	defer m.Disconnect()
+
+	serviceName := getServiceName(name)
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service already exists")
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		return fmt.Errorf("get current user failed: %v", err)
+	}
+	password := promptPassword(fmt.Sprintf("Windows password of %s", currentUser.Username),
+		"The service runs as the current user, which requires the Windows login password.", nil)
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName:      fmt.Sprintf("tssh tunnel %s", name),
+		Description:      fmt.Sprintf("Keep the tssh tunnel %s in ~/.tssh.conf running", name),
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true,
+		ServiceStartName: currentUser.Username,
+		Password:         password,
+	}, "--tunnel", "run", name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 10 * time.Second}}, 86400); err != nil {
+		warning("set service recovery actions failed: %v", err)
+	}
+	return s.Start()
+}
+
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to the service manager failed, please run as administrator: %v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(getServiceName(name))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	_, _ = s.Control(svc.Stop)
+	return s.Delete()
+}
+
+type tunnelService struct {
+	name string
+}
+
+func (t *tunnelService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	stopCh := make(chan struct{})
+	doneCh := make(chan int, 1)
+	go func() { doneCh <- runTunnel(t.name, stopCh) }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case code := <-doneCh:
+			return false, uint32(code)
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				close(stopCh)
+				return false, uint32(<-doneCh)
+			}
+		}
+	}
+}
+
+// runTunnelService runs the tunnel under the service control manager if it's started as a service.
+func runTunnelService(name string) (int, bool) {
+	if isService, err := svc.IsWindowsService(); err != nil || !isService {
+		return 0, false
+	}
+	// a service has no console, write the logs to the same file as `tssh --tunnel start`
+	logFile, err := os.OpenFile(filepath.Join(getTunnelDir(), name+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err == nil {
+		defer logFile.Close()
+		os.Stdout = logFile
+		os.Stderr = logFile
+	}
+	if err := svc.Run(getServiceName(name), &tunnelService{name}); err != nil {
+		warning("run service [%s] failed: %v", getServiceName(name), err)
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,66 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var lookupSRV = func(ctx context.Context, host string) ([]*net.SRV, error) {
+	_, addrs, err := net.DefaultResolver.LookupSRV(ctx, "ssh", "tcp", host)
+	return addrs, err
+}
+
+// resolveSrvRecord looks up `_ssh._tcp.<host>` if ExSrvLookup is yes, and returns the
+// target and port of the most preferred record, or the host and port as they are.
+// It's only used when the port is not specified by -p, the destination or the config.
+func resolveSrvRecord(args *sshArgs, host, port string) (string, string) {
+	if strings.ToLower(getExOptionConfig(args, "ExSrvLookup")) != "yes" {
+		return host, port
+	}
+	if net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		debug("skip the SRV lookup of [%s]", host)
+		return host, port
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	addrs, err := lookupSRV(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		debug("lookup SRV _ssh._tcp.%s failed: %v", host, err)
+		return host, port
+	}
+	// net.Resolver sorts the records by priority and randomizes by weight
+	target := strings.TrimSuffix(addrs[0].Target, ".")
+	if target == "" {
+		debug("SRV _ssh._tcp.%s has no target", host)
+		return host, port
+	}
+	debug("SRV _ssh._tcp.%s => %s:%d", host, target, addrs[0].Port)
+	return target, strconv.Itoa(int(addrs[0].Port))
+}
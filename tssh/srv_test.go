@@ -0,0 +1,73 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSrvRecord(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options ...string) *sshArgs {
+		args := &sshArgs{}
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return args
+	}
+	defer func(lookup func(context.Context, string) ([]*net.SRV, error)) { lookupSRV = lookup }(lookupSRV)
+	var lookups []string
+	lookupSRV = func(ctx context.Context, host string) ([]*net.SRV, error) {
+		lookups = append(lookups, host)
+		if host == "example.com" {
+			return []*net.SRV{{Target: "ssh1.example.com.", Port: 2222, Priority: 10}, {Target: "ssh2.example.com.", Port: 22, Priority: 20}}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	host, port := resolveSrvRecord(newArgs(), "example.com", "22")
+	assert.Equal("example.com", host)
+	assert.Equal("22", port)
+	assert.Empty(lookups)
+
+	args := newArgs("ExSrvLookup=yes")
+	host, port = resolveSrvRecord(args, "example.com", "22")
+	assert.Equal("ssh1.example.com", host)
+	assert.Equal("2222", port)
+
+	host, port = resolveSrvRecord(args, "missing.example.com", "22")
+	assert.Equal("missing.example.com", host)
+	assert.Equal("22", port)
+
+	host, port = resolveSrvRecord(args, "10.0.0.1", "22")
+	assert.Equal("10.0.0.1", host)
+	assert.Equal("22", port)
+	assert.Equal([]string{"example.com", "missing.example.com"}, lookups)
+}
@@ -0,0 +1,197 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/crypto/ssh"
+)
+
+// statusLine owns the bottom row of the terminal if ExStatusLine is yes. It shows the host, the user
+// and the verified host key, which the remote side can't forge, so a fake prompt inside the session
+// can be told apart. The remote pty is one row shorter, and the scroll region keeps the output above.
+//
+// The remote may still draw on the bottom row or reset the scroll region, so the status line is
+// redrawn shortly after the output pauses, only when the output is not in the middle of a sequence.
+type statusLine struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	text   string
+	width  int
+	height int
+	parser outputTracker
+	timer  *time.Timer
+	closed bool
+}
+
+const kStatusLineRedrawDelay = 100 * time.Millisecond
+
+// getStatusText returns the content of the status line, e.g.,
+// `alice@10.0.0.8:22 (prod-db) | ED25519 SHA256:AbCdEfGh verified`.
+func getStatusText(args *sshArgs, param *sshParam, control bool) string {
+	text := fmt.Sprintf("%s@%s", param.user, joinHostPort(param.host, param.port))
+	if alias := args.originalDest; alias != "" && alias != param.host {
+		text += fmt.Sprintf(" (%s)", alias)
+	}
+	switch {
+	case control:
+		text += " | via the control master"
+	case param.hostKey != nil:
+		fingerprint := ssh.FingerprintSHA256(param.hostKey)
+		if len(fingerprint) > 15 {
+			fingerprint = fingerprint[:15]
+		}
+		keyType := strings.ToUpper(strings.TrimPrefix(param.hostKey.Type(), "ssh-"))
+		text += fmt.Sprintf(" | %s %s verified", keyType, fingerprint)
+	default:
+		text += " | host key not verified"
+	}
+	return text
+}
+
+func newStatusLine(args *sshArgs, param *sshParam, control bool) *statusLine {
+	if strings.ToLower(getExOptionConfig(args, "ExStatusLine")) != "yes" {
+		return nil
+	}
+	s := &statusLine{out: os.Stdout, text: getStatusText(args, param, control)}
+	onExit(s.close)
+	return s
+}
+
+// resize returns the rows of the remote pty.
+func (s *statusLine) resize(width, height int) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.width, s.height = width, height
+	if height < 2 {
+		return height
+	}
+	s.drawLocked()
+	return height - 1
+}
+
+func (s *statusLine) render() string {
+	text := fmt.Sprintf(" tssh | %s ", s.text)
+	if runewidth.StringWidth(text) > s.width {
+		text = runewidth.Truncate(text, s.width, "")
+	}
+	return runewidth.FillRight(text, s.width)
+}
+
+func (s *statusLine) drawLocked() {
+	if s.closed || s.height < 2 || s.width < 1 {
+		return
+	}
+	// save the cursor, keep the output above the bottom row, draw, and restore the cursor
+	fmt.Fprintf(s.out, "\0337\033[1;%dr\033[%d;1H\033[0;7m%s\033[0m\0338", s.height-1, s.height, s.render())
+}
+
+func (s *statusLine) redraw() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.parser.isGround() {
+		s.scheduleLocked()
+		return
+	}
+	s.drawLocked()
+}
+
+func (s *statusLine) scheduleLocked() {
+	if s.closed {
+		return
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(kStatusLineRedrawDelay, s.redraw)
+	} else {
+		s.timer.Reset(kStatusLineRedrawDelay)
+	}
+}
+
+func (s *statusLine) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.height >= 2 {
+		// reset the scroll region, and clear the bottom row
+		fmt.Fprintf(s.out, "\0337\033[r\033[%d;1H\033[2K\0338", s.height)
+	}
+}
+
+type statusWriter struct {
+	writer io.WriteCloser
+	status *statusLine
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.status.mutex.Lock()
+	defer w.status.mutex.Unlock()
+	n, err := w.writer.Write(p)
+	w.status.parser.track(p[:n])
+	w.status.scheduleLocked()
+	return n, err
+}
+
+func (w *statusWriter) Close() error {
+	return w.writer.Close()
+}
+
+// getStdout returns the writer of the remote output, which keeps the status line and the watermark,
+// and translates the colors if enabled.
+func (ss *sshSession) getStdout() io.WriteCloser {
+	var stdout io.WriteCloser = os.Stdout
+	if ss.status != nil {
+		stdout = &statusWriter{stdout, ss.status}
+	}
+	if ss.watermark != nil {
+		ss.watermark.start(stdout)
+		stdout = &watermarkWriter{stdout, ss.watermark}
+	}
+	if ss.colorDepth > 0 {
+		ss.color = &colorWriter{writer: stdout, depth: ss.colorDepth}
+		stdout = ss.color
+	}
+	return stdout
+}
+
+// windowChange tells the remote the new terminal size, without the row of the status line.
+func (ss *sshSession) windowChange(width, height int) {
+	if ss.status != nil {
+		height = ss.status.resize(width, height)
+	}
+	_ = ss.session.WindowChange(height, width)
+}
@@ -0,0 +1,81 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestStatusText(t *testing.T) {
+	assert := assert.New(t)
+	args := &sshArgs{originalDest: "prod-db"}
+	param := &sshParam{user: "alice", host: "10.0.0.8", port: "22"}
+	assert.Equal("alice@10.0.0.8:22 (prod-db) | host key not verified", getStatusText(args, param, false))
+	assert.Equal("alice@10.0.0.8:22 (prod-db) | via the control master", getStatusText(args, param, true))
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+	param.hostKey, err = ssh.NewPublicKey(pub)
+	assert.Nil(err)
+	text := getStatusText(&sshArgs{originalDest: "10.0.0.8"}, param, false)
+	assert.True(strings.HasPrefix(text, "alice@10.0.0.8:22 | ED25519 SHA256:"), text)
+	assert.True(strings.HasSuffix(text, " verified"), text)
+}
+
+func TestStatusLine(t *testing.T) {
+	assert := assert.New(t)
+	var out bytes.Buffer
+	status := &statusLine{out: &out, text: "alice@host:22"}
+	defer status.close()
+
+	assert.Equal(1, status.resize(80, 1))
+	assert.Empty(out.String())
+	assert.Equal(23, status.resize(20, 24))
+	assert.Equal("\0337\033[1;23r\033[24;1H\033[0;7m tssh | alice@host:2\033[0m\0338", out.String())
+
+	status.parser.track([]byte("\033[1;31mred\033["))
+
+	// don't draw in the middle of a sequence
+	out.Reset()
+	status.redraw()
+	assert.Empty(out.String())
+	status.parser.track([]byte("m"))
+	status.redraw()
+	assert.Contains(out.String(), "tssh | alice@host:")
+
+	out.Reset()
+	status.close()
+	assert.Equal("\0337\033[r\033[24;1H\033[2K\0338", out.String())
+	out.Reset()
+	status.redraw()
+	assert.Empty(out.String())
+}
@@ -0,0 +1,274 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// The remote stderr could be tagged or merged into stdout in the non-tty mode, e.g.:
+//
+//	Host *
+//	    ExStderr merge
+//	    ExStderrPrefix [stderr]
+//	    ExStderrColor red
+//
+// ExStderr is `separate` by default, `merge` writes the stderr lines to stdout, and never breaks a line
+// of stdout or stderr, so that the pipelines could process the output line by line. ExStderrPrefix and
+// a space are prepended to each stderr line, and ExStderrColor colors the stderr lines if writing to a terminal.
+// A partial line is written if the rest doesn't arrive in kPartialLineTimeout, e.g., a prompt without a newline.
+
+const kMaxStderrLineSize = 64 * 1024
+
+var stderrColors = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"gray":    "90",
+}
+
+// kPartialLineTimeout is how long to wait for the rest of a line, before the partial line is returned,
+// so that the prompts without a trailing newline, e.g., `Password: ` of sudo, are not held back.
+const kPartialLineTimeout = 100 * time.Millisecond
+
+// lineReader reads the lines of at most kMaxStderrLineSize bytes, in a goroutine so that
+// the partial line could be returned after kPartialLineTimeout without more data.
+type lineReader struct {
+	chunks chan []byte
+	buffer []byte
+	err    error
+}
+
+func newLineReader(reader io.Reader) *lineReader {
+	r := &lineReader{chunks: make(chan []byte, 1)}
+	go func() {
+		buffer := make([]byte, kStdioBufferSize)
+		for {
+			n, err := reader.Read(buffer)
+			if n > 0 {
+				r.chunks <- append([]byte(nil), buffer[:n]...)
+			}
+			if err != nil {
+				r.err = err
+				close(r.chunks)
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// readLine returns a line, or a partial line if it's too long or no more data arrives in time,
+// and the error after all the data is returned.
+func (r *lineReader) readLine() ([]byte, error) {
+	for {
+		if idx := bytes.IndexByte(r.buffer, '\n'); idx >= 0 {
+			line := r.buffer[:idx+1]
+			r.buffer = r.buffer[idx+1:]
+			return line, nil
+		}
+		if len(r.buffer) >= kMaxStderrLineSize {
+			return r.takeBuffer(), nil
+		}
+		chunk, ok, timeout := r.nextChunk()
+		if timeout {
+			return r.takeBuffer(), nil
+		}
+		if !ok {
+			if len(r.buffer) > 0 {
+				return r.takeBuffer(), nil
+			}
+			return nil, r.err
+		}
+		r.buffer = append(r.buffer, chunk...)
+	}
+}
+
+// nextChunk waits for the next chunk, up to kPartialLineTimeout if there is a partial line.
+func (r *lineReader) nextChunk() ([]byte, bool, bool) {
+	if len(r.buffer) == 0 {
+		chunk, ok := <-r.chunks
+		return chunk, ok, false
+	}
+	timer := time.NewTimer(kPartialLineTimeout)
+	defer timer.Stop()
+	select {
+	case chunk, ok := <-r.chunks:
+		return chunk, ok, false
+	case <-timer.C:
+		return nil, true, true
+	}
+}
+
+func (r *lineReader) takeBuffer() []byte {
+	line := r.buffer
+	r.buffer = nil
+	return line
+}
+
+// stderrTagger prepends the prefix and the color to each line.
+type stderrTagger struct {
+	reader  *lineReader
+	prefix  string
+	color   string
+	pending []byte
+	err     error
+	newLine bool
+}
+
+func newStderrTagger(reader io.Reader, prefix, color string) *stderrTagger {
+	return &stderrTagger{reader: newLineReader(reader), prefix: prefix, color: color, newLine: true}
+}
+
+func (t *stderrTagger) tag(line []byte) []byte {
+	var buffer []byte
+	if t.newLine {
+		if t.color != "" {
+			buffer = append(buffer, "\033["+t.color+"m"...)
+		}
+		buffer = append(buffer, t.prefix...)
+	} else if t.color != "" {
+		buffer = append(buffer, "\033["+t.color+"m"...)
+	}
+	t.newLine = len(line) > 0 && line[len(line)-1] == '\n'
+	if t.newLine {
+		line = line[:len(line)-1]
+	}
+	buffer = append(buffer, line...)
+	if t.color != "" {
+		buffer = append(buffer, "\033[0m"...)
+	}
+	if t.newLine {
+		buffer = append(buffer, '\n')
+	}
+	return buffer
+}
+
+func (t *stderrTagger) Read(p []byte) (int, error) {
+	for len(t.pending) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		line, err := t.reader.readLine()
+		if len(line) > 0 {
+			t.pending = t.tag(line)
+		}
+		t.err = err
+	}
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// mergeLines merges the lines of stdout and stderr, a line is never broken by another one.
+func mergeLines(stdout, stderr io.Reader) io.Reader {
+	reader, writer := io.Pipe()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	partial := false
+	forward := func(input io.Reader) {
+		defer wg.Done()
+		lines := newLineReader(input)
+		for {
+			line, err := lines.readLine()
+			if len(line) > 0 {
+				mutex.Lock()
+				if partial {
+					// the last line of the other one ended without a newline
+					line = append([]byte{'\n'}, line...)
+				}
+				_, e := writer.Write(line)
+				partial = line[len(line)-1] != '\n'
+				mutex.Unlock()
+				if e != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go forward(stdout)
+	go forward(stderr)
+	go func() {
+		wg.Wait()
+		writer.Close()
+	}()
+	return reader
+}
+
+// wrapStderr tags or merges the remote stderr in the non-tty mode.
+func wrapStderr(args *sshArgs, ss *sshSession) {
+	if ss.serverErr == nil {
+		return
+	}
+	merge := false
+	switch mode := strings.ToLower(getExOptionConfig(args, "ExStderr")); mode {
+	case "", "separate":
+	case "merge":
+		merge = true
+	default:
+		warning("unknown ExStderr [%s], should be separate or merge", mode)
+	}
+
+	prefix := getExOptionConfig(args, "ExStderrPrefix")
+	if prefix != "" {
+		prefix += " "
+	}
+	color := ""
+	if name := strings.ToLower(getExOptionConfig(args, "ExStderrColor")); name != "" && name != "none" {
+		output := os.Stderr
+		if merge {
+			output = os.Stdout
+		}
+		if code, ok := stderrColors[name]; !ok {
+			warning("unknown ExStderrColor [%s]", name)
+		} else if isatty.IsTerminal(output.Fd()) || isatty.IsCygwinTerminal(output.Fd()) {
+			color = code
+		}
+	}
+
+	if prefix != "" || color != "" {
+		ss.serverErr = newStderrTagger(ss.serverErr, prefix, color)
+	}
+	if merge {
+		ss.serverOut = mergeLines(ss.serverOut, ss.serverErr)
+		ss.serverErr = nil
+	}
+}
@@ -0,0 +1,112 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStderrTagger(t *testing.T) {
+	assert := assert.New(t)
+	tag := func(input, prefix, color string) string {
+		output, err := io.ReadAll(newStderrTagger(&chunkReader{chunks: strings.SplitAfter(input, "|")}, prefix, color))
+		assert.Nil(err)
+		return strings.ReplaceAll(string(output), "|", "")
+	}
+	assert.Equal("[E] line1\n[E] line2\n[E] tail", tag("line1\nli|ne2\nta|il", "[E] ", ""))
+	assert.Equal("\033[31m[E] line1\033[0m\n\033[31m[E] \033[0m\n\033[31m[E] line\033[0m",
+		tag("line1\n\nli|ne", "[E] ", "31"))
+	assert.Equal("", tag("", "[E] ", "31"))
+}
+
+func TestMergeLines(t *testing.T) {
+	assert := assert.New(t)
+	stdout := &chunkReader{chunks: []string{"out1\nou", "t2\n", "out3"}}
+	stderr := &chunkReader{chunks: []string{"er", "r1\nerr2\n"}}
+	output, err := io.ReadAll(mergeLines(stdout, stderr))
+	assert.Nil(err)
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	sort.Strings(lines)
+	assert.Equal([]string{"err1", "err2", "out1", "out2", "out3"}, lines)
+}
+
+func TestPartialLine(t *testing.T) {
+	assert := assert.New(t)
+	// the prompt without a newline is not held back until the rest arrives
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	tagger := newStderrTagger(reader, "[E] ", "")
+	go func() { _, _ = writer.Write([]byte("line1\n[sudo] password: ")) }()
+	buffer := make([]byte, 100)
+	n, err := tagger.Read(buffer)
+	assert.Nil(err)
+	assert.Equal("[E] line1\n", string(buffer[:n]))
+	n, err = tagger.Read(buffer)
+	assert.Nil(err)
+	assert.Equal("[E] [sudo] password: ", string(buffer[:n]))
+
+	// the rest of the partial line is not prefixed again
+	go func() { _, _ = writer.Write([]byte("\nline2\n")) }()
+	n, err = tagger.Read(buffer)
+	assert.Nil(err)
+	assert.Equal("\n", string(buffer[:n]))
+
+	merged := mergeLines(strings.NewReader(""), &chunkReader{chunks: []string{"Password: "}})
+	n, err = merged.Read(buffer)
+	assert.Nil(err)
+	assert.Equal("Password: ", string(buffer[:n]))
+}
+
+func TestWrapStderr(t *testing.T) {
+	assert := assert.New(t)
+	newSession := func(options ...string) (*sshArgs, *sshSession) {
+		var args sshArgs
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return &args, &sshSession{serverOut: strings.NewReader("out\n"), serverErr: strings.NewReader("err\n")}
+	}
+
+	args, ss := newSession()
+	serverErr := ss.serverErr
+	wrapStderr(args, ss)
+	assert.Equal(serverErr, ss.serverErr)
+
+	args, ss = newSession("ExStderrPrefix [stderr]", "ExStderrColor red")
+	wrapStderr(args, ss)
+	output, _ := io.ReadAll(ss.serverErr)
+	assert.Equal("[stderr] err\n", string(output))
+
+	args, ss = newSession("ExStderr merge")
+	wrapStderr(args, ss)
+	assert.Nil(ss.serverErr)
+	output, _ = io.ReadAll(ss.serverOut)
+	assert.ElementsMatch([]string{"out", "err", ""}, strings.Split(string(output), "\n"))
+}
@@ -0,0 +1,257 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// suggestedHost is a destination which is not configured but used or known before.
+type suggestedHost struct {
+	host  *sshHost
+	score float64
+}
+
+// hostSuggester ranks the suggested destinations by frecency,
+// the destinations in the shell history get higher scores when they are used more often and more recently.
+type hostSuggester struct {
+	hosts map[string]*suggestedHost
+	order []string
+	now   time.Time
+}
+
+func newHostSuggester() *hostSuggester {
+	return &hostSuggester{hosts: make(map[string]*suggestedHost), now: time.Now()}
+}
+
+func (s *hostSuggester) add(alias, source string, score float64) {
+	if alias == "" || strings.HasPrefix(alias, "-") {
+		return
+	}
+	if h, ok := s.hosts[alias]; ok {
+		h.score += score
+		if !strings.Contains(" "+h.host.GroupLabels+" ", " "+source+" ") {
+			h.host.GroupLabels += " " + source
+		}
+		return
+	}
+	_, host, port := parseDestination(alias)
+	s.hosts[alias] = &suggestedHost{&sshHost{Alias: alias, Host: host, Port: port, GroupLabels: source}, score}
+	s.order = append(s.order, alias)
+}
+
+// getHosts returns the suggested hosts, the highest score first.
+func (s *hostSuggester) getHosts() []*sshHost {
+	suggested := make([]*suggestedHost, 0, len(s.order))
+	for _, alias := range s.order {
+		suggested = append(suggested, s.hosts[alias])
+	}
+	sort.SliceStable(suggested, func(i, j int) bool { return suggested[i].score > suggested[j].score })
+	hosts := make([]*sshHost, 0, len(suggested))
+	for _, h := range suggested {
+		hosts = append(hosts, h.host)
+	}
+	return hosts
+}
+
+// recencyWeight gives more weight to the recent usage, or to the later lines if there is no timestamp.
+func (s *hostSuggester) recencyWeight(timestamp int64, index, total int) float64 {
+	if timestamp > 0 {
+		age := s.now.Sub(time.Unix(timestamp, 0))
+		switch {
+		case age < 24*time.Hour:
+			return 4
+		case age < 7*24*time.Hour:
+			return 2
+		case age < 30*24*time.Hour:
+			return 1
+		default:
+			return 0.5
+		}
+	}
+	return 0.5 + 1.5*float64(index+1)/float64(total)
+}
+
+// ssh options which take an argument, used to find the destination in the shell history.
+const kSshOptionsWithArg = "BbcDEeFIiJLlmOoPpQRSWw"
+
+func getHistoryDestination(line string) string {
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		name := filepath.Base(field)
+		if name != "ssh" && name != "tssh" && name != "ssh.exe" && name != "tssh.exe" {
+			continue
+		}
+		for j := i + 1; j < len(fields); j++ {
+			arg := fields[j]
+			if !strings.HasPrefix(arg, "-") {
+				return strings.Trim(arg, `'"`)
+			}
+			if strings.HasPrefix(arg, "--") {
+				if !strings.Contains(arg, "=") && j+1 < len(fields) && isLongOptionWithArg(arg) {
+					j++
+				}
+				continue
+			}
+			if len(arg) == 2 && strings.ContainsRune(kSshOptionsWithArg, rune(arg[1])) {
+				j++
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+func isLongOptionWithArg(arg string) bool {
+	switch arg {
+	case "--as", "--profile", "--forwards", "--edit", "--clip-put", "--clip-get", "--group":
+		return true
+	}
+	return false
+}
+
+// addShellHistory adds the destinations of ssh and tssh in bash and zsh history.
+func (s *hostSuggester) addShellHistory(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		var timestamp int64
+		// zsh extended history: `: 1700000000:0;ssh host`
+		if strings.HasPrefix(line, ": ") {
+			if pos := strings.IndexByte(line, ';'); pos > 0 {
+				meta := strings.SplitN(line[2:pos], ":", 2)
+				timestamp, _ = strconv.ParseInt(strings.TrimSpace(meta[0]), 10, 64)
+				line = line[pos+1:]
+			}
+		}
+		if dest := getHistoryDestination(line); dest != "" {
+			s.add(dest, "history", s.recencyWeight(timestamp, i, len(lines)))
+		}
+	}
+}
+
+// addKnownHosts adds the hosts in known_hosts, the hashed hosts are skipped.
+func (s *hostSuggester) addKnownHosts(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "@") {
+			continue
+		}
+		for _, host := range strings.Split(fields[0], ",") {
+			if strings.HasPrefix(host, "|") || strings.ContainsAny(host, "*?!") {
+				continue
+			}
+			if strings.HasPrefix(host, "[") {
+				if pos := strings.Index(host, "]:"); pos > 0 && !strings.Contains(host[1:pos], ":") {
+					host = host[1:pos] + ":" + host[pos+2:]
+				}
+			}
+			s.add(host, "known_hosts", 0.5)
+		}
+	}
+}
+
+func isLocalHostName(name string) bool {
+	name = strings.ToLower(name)
+	return name == "localhost" || name == "broadcasthost" || strings.HasPrefix(name, "ip6-") ||
+		strings.HasPrefix(name, "localhost.") || strings.HasSuffix(name, ".localdomain")
+}
+
+// addEtcHosts adds the host names in /etc/hosts, except the loopback ones.
+func (s *hostSuggester) addEtcHosts(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() {
+			continue
+		}
+		for _, name := range fields[1:] {
+			if !isLocalHostName(name) {
+				s.add(name, "hosts", 0.25)
+			}
+		}
+	}
+}
+
+func getEtcHostsPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// getSuggestedHosts returns the hosts in the shell history, known_hosts and /etc/hosts,
+// which are not configured in ssh config, ranked by frecency.
+func getSuggestedHosts(configured []*sshHost) []*sshHost {
+	s := newHostSuggester()
+	histFiles := []string{resolvePath("~/.bash_history"), resolvePath("~/.zsh_history")}
+	if histFile := os.Getenv("HISTFILE"); histFile != "" && !containsString(histFiles, resolvePath(histFile)) {
+		histFiles = append(histFiles, resolvePath(histFile))
+	}
+	for _, histFile := range histFiles {
+		s.addShellHistory(histFile)
+	}
+	s.addKnownHosts(resolvePath("~/.ssh/known_hosts"))
+	s.addEtcHosts(getEtcHostsPath())
+
+	known := make(map[string]bool)
+	for _, host := range configured {
+		known[host.Alias] = true
+	}
+	var hosts []*sshHost
+	for _, host := range s.getHosts() {
+		if !known[host.Alias] && !isConfiguredAlias(host.Alias) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
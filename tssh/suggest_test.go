@@ -0,0 +1,77 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHistoryDestination(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("host", getHistoryDestination("ssh host"))
+	assert.Equal("user@host", getHistoryDestination("tssh -p 2222 -A user@host ls -l"))
+	assert.Equal("host", getHistoryDestination("/usr/bin/ssh -o ServerAliveInterval=30 -i ~/.ssh/id host"))
+	assert.Equal("host", getHistoryDestination("sudo tssh --as root host"))
+	assert.Equal("host", getHistoryDestination("tssh --dragfile --profile=debug host"))
+	assert.Equal("", getHistoryDestination("ssh -V"))
+	assert.Equal("", getHistoryDestination("ls -l ssh"))
+	assert.Equal("", getHistoryDestination("git push"))
+}
+
+func TestHostSuggester(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	s := newHostSuggester()
+	now := s.now.Unix()
+	s.addShellHistory(writeFile("zsh_history", fmt.Sprintf(": %d:0;ssh old\n: %d:0;ssh recent\n: %d:0;ssh old\n",
+		now-int64(60*24*time.Hour/time.Second), now-60, now-int64(60*24*time.Hour/time.Second))))
+	s.addShellHistory(writeFile("bash_history", "ls\nssh often\nssh often\nssh often\n"))
+	s.addKnownHosts(writeFile("known_hosts", "web1.example.com,10.0.0.1 ssh-ed25519 AAAA\n"+
+		"|1|hash= ssh-ed25519 AAAA\n[db.example.com]:2222 ssh-rsa AAAA\n@cert-authority * ssh-rsa AAAA\nrecent ssh-rsa AAAA\n"))
+	s.addEtcHosts(writeFile("hosts", "127.0.0.1 localhost\n::1 ip6-localhost\n192.168.1.8 nas nas.lan # storage\n"))
+
+	var aliases []string
+	for _, host := range s.getHosts() {
+		aliases = append(aliases, host.Alias)
+	}
+	assert.Equal([]string{"recent", "often", "old", "web1.example.com", "10.0.0.1", "db.example.com:2222", "nas", "nas.lan"}, aliases)
+
+	hosts := s.getHosts()
+	assert.Equal("history known_hosts", hosts[0].GroupLabels)
+	assert.Equal("db.example.com", hosts[5].Host)
+	assert.Equal("2222", hosts[5].Port)
+}
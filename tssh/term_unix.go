@@ -28,6 +28,7 @@ package tssh
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -62,6 +63,14 @@ func resetStdin(s *stdinState) {
 	}
 }
 
+func getStdinReader() io.Reader {
+	return os.Stdin
+}
+
+func isConsoleInputActive() bool {
+	return false
+}
+
 func getTerminalSize() (int, int, error) {
 	width, height, err := term.GetSize(int(os.Stdin.Fd()))
 	if err != nil {
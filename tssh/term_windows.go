@@ -26,12 +26,17 @@ package tssh
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
 
 	"golang.org/x/sys/windows"
 	"golang.org/x/term"
@@ -40,6 +45,8 @@ import (
 type stdinState struct {
 	state    *term.State
 	settings *string
+	mode     *uint32
+	input    *consoleInput
 }
 
 const CP_UTF8 uint32 = 65001
@@ -73,7 +80,7 @@ func enableVirtualTerminal() error {
 	if err := windows.GetConsoleMode(windows.Handle(inHandle), &inMode); err != nil {
 		return err
 	}
-	onExitFuncs = append(onExitFuncs, func() {
+	onExit(func() {
 		windows.SetConsoleMode(windows.Handle(inHandle), inMode)
 	})
 	if err := windows.SetConsoleMode(windows.Handle(inHandle), inMode|windows.ENABLE_VIRTUAL_TERMINAL_INPUT); err != nil {
@@ -87,7 +94,7 @@ func enableVirtualTerminal() error {
 	if err := windows.GetConsoleMode(windows.Handle(outHandle), &outMode); err != nil {
 		return err
 	}
-	onExitFuncs = append(onExitFuncs, func() {
+	onExit(func() {
 		windows.SetConsoleMode(windows.Handle(outHandle), outMode)
 	})
 	if err := windows.SetConsoleMode(windows.Handle(outHandle),
@@ -173,7 +180,7 @@ func setupVirtualTerminal() error {
 	outCP := getConsoleOutputCP()
 	setConsoleCP(CP_UTF8)
 	setConsoleOutputCP(CP_UTF8)
-	onExitFuncs = append(onExitFuncs, func() {
+	onExit(func() {
 		setConsoleCP(inCP)
 		setConsoleOutputCP(outCP)
 	})
@@ -184,7 +191,8 @@ func setupVirtualTerminal() error {
 func makeStdinRaw() (*stdinState, error) {
 	state, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err == nil {
-		return &stdinState{state, nil}, nil
+		mode, input := enableConsoleInput()
+		return &stdinState{state, nil, mode, input}, nil
 	}
 
 	if !sttyExecutable() {
@@ -197,10 +205,18 @@ func makeStdinRaw() (*stdinState, error) {
 	if err := sttyMakeRaw(); err != nil {
 		return nil, fmt.Errorf("stty make raw failed: %v", err)
 	}
-	return &stdinState{nil, &settings}, nil
+	return &stdinState{nil, &settings, nil, nil}, nil
 }
 
 func resetStdin(s *stdinState) {
+	if s.input != nil {
+		s.input.disable()
+		s.input = nil
+	}
+	if s.mode != nil {
+		disableConsoleInput(*s.mode)
+		s.mode = nil
+	}
 	if s.state != nil {
 		_ = term.Restore(int(os.Stdin.Fd()), s.state)
 		s.state = nil
@@ -211,6 +227,145 @@ func resetStdin(s *stdinState) {
 	}
 }
 
+const (
+	kKeyEvent              = 0x0001
+	kWindowBufferSizeEvent = 0x0004
+	kVirtualKeyMenu        = 0x12
+)
+
+var readConsoleInput = kernel32.NewProc("ReadConsoleInputW")
+
+type inputRecord struct {
+	eventType uint16
+	_         uint16
+	event     [16]byte
+}
+
+type keyEventRecord struct {
+	keyDown         int32
+	repeatCount     uint16
+	virtualKeyCode  uint16
+	virtualScanCode uint16
+	unicodeChar     uint16
+	controlKeyState uint32
+}
+
+// consoleInput reads the console input records directly instead of ReadFile, which
+// treats ctrl + z as EOF and drops the window size events. With the virtual terminal
+// input mode, the console itself translates the keys into the VT sequences.
+//
+// It's not a ConPTY, tssh still runs in the attached console. It's only used while stdin
+// is raw, a reader kept by others reads from os.Stdin again after the mode is restored.
+type consoleInput struct {
+	handle   windows.Handle
+	records  [128]inputRecord
+	surplus  []uint16
+	pending  []byte
+	resizeCh chan struct{}
+	disabled atomic.Bool
+}
+
+// activeConsoleInput is the reader of the current raw stdinState, nil if stdin is not raw.
+var activeConsoleInput atomic.Pointer[consoleInput]
+
+func enableConsoleInput() (*uint32, *consoleInput) {
+	handle := windows.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return nil, nil
+	}
+	if readConsoleInput.Find() != nil {
+		return nil, nil
+	}
+	rawMode := (mode | windows.ENABLE_WINDOW_INPUT | windows.ENABLE_VIRTUAL_TERMINAL_INPUT) &^ windows.ENABLE_MOUSE_INPUT
+	if err := windows.SetConsoleMode(handle, rawMode); err != nil {
+		return nil, nil
+	}
+	input := &consoleInput{handle: handle, resizeCh: make(chan struct{}, 1)}
+	activeConsoleInput.Store(input)
+	return &mode, input
+}
+
+func (c *consoleInput) disable() {
+	c.disabled.Store(true)
+	activeConsoleInput.CompareAndSwap(c, nil)
+}
+
+func disableConsoleInput(mode uint32) {
+	_ = windows.SetConsoleMode(windows.Handle(os.Stdin.Fd()), mode)
+}
+
+func (c *consoleInput) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 && c.disabled.Load() {
+		return os.Stdin.Read(p)
+	}
+	for len(c.pending) == 0 {
+		var count uint32
+		result, _, err := readConsoleInput.Call(uintptr(c.handle),
+			uintptr(unsafe.Pointer(&c.records[0])), uintptr(len(c.records)), uintptr(unsafe.Pointer(&count)))
+		if result == 0 {
+			return 0, err
+		}
+		for i := uint32(0); i < count; i++ {
+			c.handleRecord(&c.records[i])
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *consoleInput) handleRecord(record *inputRecord) {
+	switch record.eventType {
+	case kWindowBufferSizeEvent:
+		select {
+		case c.resizeCh <- struct{}{}:
+		default:
+		}
+	case kKeyEvent:
+		key := (*keyEventRecord)(unsafe.Pointer(&record.event[0]))
+		if key.unicodeChar == 0 {
+			return
+		}
+		// the characters entered with alt + numpad come with the key up event of alt
+		if key.keyDown == 0 && key.virtualKeyCode != kVirtualKeyMenu {
+			return
+		}
+		repeat := int(key.repeatCount)
+		if repeat < 1 {
+			repeat = 1
+		}
+		for i := 0; i < repeat; i++ {
+			c.appendChar(key.unicodeChar)
+		}
+	}
+}
+
+func (c *consoleInput) appendChar(char uint16) {
+	if char >= 0xd800 && char < 0xdc00 && len(c.surplus) == 0 { // high surrogate
+		c.surplus = append(c.surplus, char)
+		return
+	}
+	c.surplus = append(c.surplus, char)
+	for _, r := range utf16.Decode(c.surplus) {
+		c.pending = utf8.AppendRune(c.pending, r)
+	}
+	c.surplus = c.surplus[:0]
+}
+
+func getStdinReader() io.Reader {
+	if input := activeConsoleInput.Load(); input != nil {
+		return input
+	}
+	return os.Stdin
+}
+
+// isConsoleInputActive returns false if the console input records can't be read, e.g., in mintty
+// or if enableConsoleInput failed, where ctrl + z is still reported as EOF by os.Stdin.
+func isConsoleInputActive() bool {
+	return activeConsoleInput.Load() != nil
+}
+
 func getTerminalSize() (int, int, error) {
 	handle, err := syscall.GetStdHandle(syscall.STD_OUTPUT_HANDLE)
 	if err != nil {
@@ -229,20 +384,37 @@ func getTerminalSize() (int, int, error) {
 	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1, nil
 }
 
+// onTerminalResize notifies the size only after it stays unchanged for a while,
+// so that dragging the window border doesn't flood the server with window changes.
 func onTerminalResize(setTerminalSize func(int, int)) {
+	var resizeCh <-chan struct{}
+	if input := activeConsoleInput.Load(); input != nil {
+		resizeCh = input.resizeCh
+	}
 	go func() {
 		columns, rows, _ := getTerminalSize()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
 		for {
-			time.Sleep(time.Second)
+			select {
+			case <-ticker.C:
+			case <-resizeCh:
+			}
 			width, height, err := getTerminalSize()
-			if err != nil {
+			if err != nil || (columns == width && rows == height) {
 				continue
 			}
-			if columns != width || rows != height {
-				columns = width
-				rows = height
-				setTerminalSize(width, height)
+			for {
+				time.Sleep(100 * time.Millisecond)
+				w, h, err := getTerminalSize()
+				if err != nil || (w == width && h == height) {
+					break
+				}
+				width, height = w, h
 			}
+			columns = width
+			rows = height
+			setTerminalSize(width, height)
 		}
 	}()
 }
@@ -0,0 +1,74 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newKeyRecord(keyDown bool, virtualKeyCode, char uint16) *inputRecord {
+	record := &inputRecord{eventType: kKeyEvent}
+	key := (*keyEventRecord)(unsafe.Pointer(&record.event[0]))
+	if keyDown {
+		key.keyDown = 1
+	}
+	key.repeatCount = 1
+	key.virtualKeyCode = virtualKeyCode
+	key.unicodeChar = char
+	return record
+}
+
+func TestConsoleInputRecords(t *testing.T) {
+	assert := assert.New(t)
+	input := &consoleInput{resizeCh: make(chan struct{}, 1)}
+
+	// ctrl + z is a key instead of EOF, and the key up events are ignored
+	input.handleRecord(newKeyRecord(true, 'Z', 0x1A))
+	input.handleRecord(newKeyRecord(false, 'Z', 0x1A))
+	input.handleRecord(newKeyRecord(true, 'A', 'a'))
+	assert.Equal([]byte{0x1A, 'a'}, input.pending)
+
+	// the surrogate pair is decoded into one rune, and alt + numpad comes with the key up of alt
+	input.pending = nil
+	input.handleRecord(newKeyRecord(true, 0, 0xd83d))
+	input.handleRecord(newKeyRecord(true, 0, 0xde00))
+	input.handleRecord(newKeyRecord(false, kVirtualKeyMenu, 'é'))
+	assert.Equal("😀é", string(input.pending))
+
+	// the window size events are coalesced
+	input.handleRecord(&inputRecord{eventType: kWindowBufferSizeEvent})
+	input.handleRecord(&inputRecord{eventType: kWindowBufferSizeEvent})
+	assert.Len(input.resizeCh, 1)
+
+	// ctrl + z is reported as EOF by os.Stdin if the console input records are not read
+	assert.False(isConsoleInputActive())
+	activeConsoleInput.Store(input)
+	assert.True(isConsoleInputActive())
+	input.disable()
+	assert.False(isConsoleInputActive())
+}
@@ -0,0 +1,86 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// kTerminateGracePeriod is how long to wait for the main goroutine to exit after the signal.
+const kTerminateGracePeriod = 3 * time.Second
+
+var onTerminateMutex sync.Mutex
+var onTerminateFuncs []func()
+
+// onTerminate registers the function to be called on SIGTERM or SIGHUP before disconnecting.
+func onTerminate(f func()) {
+	onTerminateMutex.Lock()
+	defer onTerminateMutex.Unlock()
+	onTerminateFuncs = append(onTerminateFuncs, f)
+}
+
+// closeOnTerminate closes the forwarded listener on SIGTERM or SIGHUP, so the port or the socket is released.
+func closeOnTerminate(closer io.Closer) {
+	onTerminate(func() { _ = closer.Close() })
+}
+
+func cleanupOnTerminate() {
+	onTerminateMutex.Lock()
+	funcs := onTerminateFuncs
+	onTerminateFuncs = nil
+	onTerminateMutex.Unlock()
+	for i := len(funcs) - 1; i >= 0; i-- {
+		funcs[i]()
+	}
+}
+
+// handleTerminateSignals shuts down the session gracefully on SIGTERM or SIGHUP, e.g., stopped by systemd.
+// It cancels the transfers, closes the forwarded listeners and disconnects, then the main goroutine exits
+// as the session ends. If it's still blocked after the grace period, run onExitFuncs and exit forcibly.
+func handleTerminateSignals(ss *sshSession) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		sig := <-sigCh
+		debug("received signal [%v], terminating", sig)
+		go func() {
+			cleanupOnTerminate()
+			ss.Close()
+		}()
+		time.Sleep(kTerminateGracePeriod)
+		debug("exit forcibly after the grace period")
+		cleanupOnExit()
+		code := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(s)
+		}
+		os.Exit(code)
+	}()
+}
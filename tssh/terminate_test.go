@@ -0,0 +1,106 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanupOnTerminate(t *testing.T) {
+	assert := assert.New(t)
+	defer func(funcs []func()) { onTerminateFuncs = funcs }(onTerminateFuncs)
+	onTerminateFuncs = nil
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	closeOnTerminate(listener)
+	var order []int
+	onTerminate(func() { order = append(order, 1) })
+	onTerminate(func() { order = append(order, 2) })
+
+	cleanupOnTerminate()
+	assert.Equal([]int{2, 1}, order)
+	_, err = listener.Accept()
+	assert.NotNil(err)
+
+	// the functions are called only once
+	cleanupOnTerminate()
+	assert.Equal([]int{2, 1}, order)
+}
+
+func TestCleanupOnExit(t *testing.T) {
+	assert := assert.New(t)
+	defer func(funcs []func()) { onExitFuncs, onExitOnce = funcs, sync.Once{} }(onExitFuncs)
+	onExitFuncs, onExitOnce = nil, sync.Once{}
+
+	var order []int
+	onExit(func() { order = append(order, 1) })
+	onExit(func() { order = append(order, 2) })
+
+	// registered concurrently while the signal handler runs the cleanup
+	var count atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			onExit(func() { count.Add(1) })
+		}()
+	}
+	cleanupOnExit()
+	wg.Wait()
+	assert.Equal([]int{2, 1}, order)
+
+	// the functions are called only once
+	ran := count.Load()
+	cleanupOnExit()
+	assert.Equal([]int{2, 1}, order)
+	assert.Equal(ran, count.Load())
+}
+
+func TestTransferCancelAll(t *testing.T) {
+	assert := assert.New(t)
+	queue := newTransferQueue(func(command string, stdin io.Reader, stdout io.Writer, cancel <-chan struct{}) error {
+		<-cancel
+		return errTransferCancelled
+	})
+	foreground := queue.add(false, "foreground", "remote.txt")
+	background := queue.add(false, "background", "remote.txt")
+	assert.Nil(queue.setBackground(background.id))
+	waitTransfer(t, queue, foreground.id, transferActive)
+
+	queue.cancelAll()
+	for _, job := range queue.snapshot() {
+		assert.Equal(transferCancelled, job.state, job.id)
+	}
+	// nothing left to wait for
+	queue.waitBackground(func(jobs []transferJob) { t.Fatalf("unexpected waiting: %v", jobs) })
+}
@@ -64,6 +64,8 @@ func getDefaultDetailsTemplate() string {
 		switch strings.ToLower(item) {
 		case "alias":
 			addItem("Alias")
+		case "shortcut":
+			addItem("Shortcut")
 		case "host":
 			addItem("Host")
 		case "port":
@@ -94,12 +96,14 @@ func getTinyTheme() *promptTheme {
 		Label: fmt.Sprintf(`{{ "? " | %s }}{{ . | %s }}{{ ":" | %s }}`,
 			getThemeColor("label_icon"), getThemeColor("label_text"), getThemeColor("label_text")),
 		Active: fmt.Sprintf(`{{ "%s" | %s }} {{ if .Selected }}{{ "✔ " | %s }}{{ else }}{{ "  " }}{{ end }}`+
+			`{{ if .Shortcut }}{{ printf "[%%s] " .Shortcut | %s }}{{ end }}`+
 			`{{ .Alias | %s }} ({{ .Host | %s }}){{ "\t" }}{{ .GroupLabels | %s }}`,
-			promptCursorIcon, getThemeColor("cursor_icon"), getThemeColor("active_selected"),
+			promptCursorIcon, getThemeColor("cursor_icon"), getThemeColor("active_selected"), getThemeColor("active_alias"),
 			getThemeColor("active_alias"), getThemeColor("active_host"), getThemeColor("active_group")),
 		Inactive: fmt.Sprintf(`   {{ if .Selected }}{{ "✔ " | %s }}{{ else }}{{ "  " }}{{ end }}`+
+			`{{ if .Shortcut }}{{ printf "[%%s] " .Shortcut | %s }}{{ end }}`+
 			`{{ .Alias | %s }} ({{ .Host | %s }}){{ "\t" }}{{ .GroupLabels | %s }}`,
-			getThemeColor("inactive_selected"),
+			getThemeColor("inactive_selected"), getThemeColor("inactive_alias"),
 			getThemeColor("inactive_alias"), getThemeColor("inactive_host"), getThemeColor("inactive_group")),
 		Details:   getDefaultDetailsTemplate(),
 		Help:      getDefaultHelpTipsTemplate(),
@@ -112,12 +116,14 @@ func getSimpleTheme() *promptTheme {
 		Label: fmt.Sprintf(`{{ "? " | %s }}{{ . | %s }}{{ ":\n" | %s }}`,
 			getThemeColor("label_icon"), getThemeColor("label_text"), getThemeColor("label_text")),
 		Active: fmt.Sprintf(`{{ "%s" | %s }} {{ if .Selected }}{{ "✔ " | %s }}{{ else }}{{ "  " }}{{ end }}`+
+			`{{ if .Shortcut }}{{ printf "[%%s] " .Shortcut | %s }}{{ end }}`+
 			`{{ .Alias | %s }}{{ "\t" }}{{ .Host | %s }}{{ "\t" }}{{ .GroupLabels | %s }}`+
 			`{{ "\n\t\t" }}`, promptCursorIcon, getThemeColor("cursor_icon"), getThemeColor("active_selected"),
-			getThemeColor("active_alias"), getThemeColor("active_host"), getThemeColor("active_group")),
+			getThemeColor("active_alias"), getThemeColor("active_alias"), getThemeColor("active_host"), getThemeColor("active_group")),
 		Inactive: fmt.Sprintf(`   {{ if .Selected }}{{ "✔ " | %s }}{{ else }}{{ "  " }}{{ end }}`+
+			`{{ if .Shortcut }}{{ printf "[%%s] " .Shortcut | %s }}{{ end }}`+
 			`{{ .Alias | %s }}{{ "\t" }}{{ .Host | %s }}{{ "\t" }}{{ .GroupLabels | %s }}`+
-			`{{ "\n\t\t" }}`, getThemeColor("inactive_selected"),
+			`{{ "\n\t\t" }}`, getThemeColor("inactive_selected"), getThemeColor("inactive_alias"),
 			getThemeColor("inactive_alias"), getThemeColor("inactive_host"), getThemeColor("inactive_group")),
 		Details:   getDefaultDetailsTemplate(),
 		Help:      getDefaultHelpTipsTemplate(),
@@ -198,7 +204,11 @@ func (t *tableTheme) renderItems(items []interface{}, idx int) string {
 		if host.Selected {
 			icon = "✔"
 		}
-		data = append(data, []string{icon, host.Alias, host.Host, host.GroupLabels})
+		alias := host.Alias
+		if host.Shortcut != "" {
+			alias = fmt.Sprintf("[%s] %s", host.Shortcut, alias)
+		}
+		data = append(data, []string{icon, alias, host.Host, host.GroupLabels})
 	}
 	tbl := table.New().BorderRow(true).
 		Headers("", "Alias", "Host Name", "Group Labels").Rows(data...).
@@ -229,6 +239,8 @@ func (t *tableTheme) renderDetails(item interface{}) string {
 		switch strings.ToLower(item) {
 		case "alias":
 			addItem("Alias", host.Alias)
+		case "shortcut":
+			addItem("Shortcut", host.Shortcut)
 		case "host":
 			addItem("Host", host.Host)
 		case "port":
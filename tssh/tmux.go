@@ -0,0 +1,100 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const kDefaultTmuxSession = "tssh"
+
+// getTmuxSession returns the session name of tmux, and screen for ExAutoAttach.
+func getTmuxSession(args *sshArgs) string {
+	if args.TmuxSession != "" {
+		return args.TmuxSession
+	}
+	if session := getExOptionConfig(args, "ExTmuxSession"); session != "" {
+		return session
+	}
+	return kDefaultTmuxSession
+}
+
+// isTmuxControlMode returns whether to attach with `tmux -CC`, the tmux windows become native tabs,
+// which is only supported by iTerm2, so the default `auto` enables it in iTerm2 only.
+func isTmuxControlMode(args *sshArgs) bool {
+	switch strings.ToLower(getExOptionConfig(args, "ExTmuxControlMode")) {
+	case "yes", "true":
+		return true
+	case "no", "false":
+		return false
+	default:
+		return os.Getenv("TERM_PROGRAM") == "iTerm.app" && os.Getenv("TMUX") == ""
+	}
+}
+
+// getTmuxCommand returns the remote command which attaches to the tmux session, or creates it if not exists.
+// The tmux window titles are set as the local terminal title if ExTmuxTitles is yes,
+// and falls back to the login shell if tmux is not installed.
+func getTmuxCommand(args *sshArgs, session string) string {
+	tmux := "tmux"
+	if isTmuxControlMode(args) {
+		tmux = "tmux -CC"
+	}
+	attach := fmt.Sprintf("%s new-session -A -s %s", tmux, shellQuote(session))
+	switch strings.ToLower(getExOptionConfig(args, "ExTmuxTitles")) {
+	case "yes", "true":
+		attach += ` \; set-option set-titles on \; set-option set-titles-string '#S:#I:#W - #T'`
+	}
+	return getAttachCommand("tmux", attach)
+}
+
+// getScreenCommand returns the remote command which reattaches to the screen session,
+// detaches it elsewhere first, or creates it if not exists.
+func getScreenCommand(session string) string {
+	return getAttachCommand("screen", fmt.Sprintf("screen -d -R -S %s", shellQuote(session)))
+}
+
+func getAttachCommand(multiplexer, attach string) string {
+	return fmt.Sprintf(`if command -v %s >/dev/null 2>&1; then exec %s; `+
+		`else echo "%s is not installed, fall back to the login shell." >&2; exec "${SHELL:-/bin/sh}" -l; fi`,
+		multiplexer, attach, multiplexer)
+}
+
+// getAutoAttachCommand returns the command to resume the multiplexer session configured by ExAutoAttach,
+// so that the work is not lost when the connection drops.
+func getAutoAttachCommand(args *sshArgs) (string, error) {
+	switch value := strings.ToLower(getExOptionConfig(args, "ExAutoAttach")); value {
+	case "", "no", "none":
+		return "", nil
+	case "tmux":
+		return getTmuxCommand(args, getTmuxSession(args)), nil
+	case "screen":
+		return getScreenCommand(getTmuxSession(args)), nil
+	default:
+		return "", fmt.Errorf("unknown ExAutoAttach [%s], should be one of tmux, screen, no", value)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTmuxCommand(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options ...string) *sshArgs {
+		args := &sshArgs{}
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return args
+	}
+
+	assert.Equal("tssh", getTmuxSession(newArgs()))
+	assert.Equal("work", getTmuxSession(newArgs("ExTmuxSession work")))
+	assert.Equal("dev", getTmuxSession(&sshArgs{TmuxSession: "dev"}))
+
+	cmd := getTmuxCommand(newArgs("ExTmuxControlMode no"), "it's")
+	assert.Contains(cmd, `exec tmux new-session -A -s 'it'\''s';`)
+	assert.NotContains(cmd, "set-titles")
+	cmd = getTmuxCommand(newArgs("ExTmuxControlMode yes", "ExTmuxTitles yes"), "dev")
+	assert.Contains(cmd, `exec tmux -CC new-session -A -s 'dev' \; set-option set-titles on`)
+
+	cmd, err := getAutoAttachCommand(newArgs())
+	assert.Nil(err)
+	assert.Equal("", cmd)
+	cmd, err = getAutoAttachCommand(newArgs("ExAutoAttach no"))
+	assert.Nil(err)
+	assert.Equal("", cmd)
+	cmd, err = getAutoAttachCommand(newArgs("ExAutoAttach tmux", "ExTmuxControlMode no"))
+	assert.Nil(err)
+	assert.Contains(cmd, "exec tmux new-session -A -s 'tssh';")
+	cmd, err = getAutoAttachCommand(newArgs("ExAutoAttach Screen", "ExTmuxSession work"))
+	assert.Nil(err)
+	assert.Contains(cmd, "exec screen -d -R -S 'work';")
+	_, err = getAutoAttachCommand(newArgs("ExAutoAttach byobu"))
+	assert.NotNil(err)
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// falls back to the login shell if tmux is not installed
+	shell := exec.Command("sh", "-c", getTmuxCommand(newArgs("ExTmuxControlMode no"), "dev"))
+	shell.Env = append(os.Environ(), "PATH=/nonexistent", "SHELL=/bin/echo")
+	output, _ := shell.CombinedOutput()
+	assert.True(strings.HasPrefix(string(output), "tmux is not installed"), string(output))
+	assert.Contains(string(output), "-l")
+}
@@ -28,7 +28,9 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"os"
+	"os/user"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 )
@@ -75,6 +77,16 @@ var getHostname = func() string {
 	return hostname
 }
 
+var getLocalUser = func() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
 func expandTokens(str string, args *sshArgs, param *sshParam, tokens string) (string, error) {
 	if !strings.ContainsRune(str, '%') {
 		return str, nil
@@ -120,6 +132,15 @@ func expandTokens(str string, args *sshArgs, param *sshParam, tokens string) (st
 				hostname = hostname[:idx]
 			}
 			buf.WriteString(hostname)
+		case 'd':
+			buf.WriteString(userHomeDir)
+		case 'u':
+			buf.WriteString(getLocalUser())
+		case 'i':
+			buf.WriteString(strconv.Itoa(os.Getuid()))
+		case 'k':
+			// HostKeyAlias is not supported, so it's always the host name
+			buf.WriteString(param.host)
 		case 'C':
 			hashStr := fmt.Sprintf("%s%s%s%s", getHostname(), param.host, param.port, param.user)
 			buf.WriteString(fmt.Sprintf("%x", sha1.Sum([]byte(hashStr))))
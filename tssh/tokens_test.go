@@ -25,6 +25,8 @@ SOFTWARE.
 package tssh
 
 import (
+	"fmt"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,6 +41,11 @@ func TestExpandTokens(t *testing.T) {
 		getHostname = originalGetHostname
 	}()
 	getHostname = func() string { return "myhostname.mydomain.com" }
+	defer func(home string, localUser func() string) {
+		userHomeDir, getLocalUser = home, localUser
+	}(userHomeDir, getLocalUser)
+	userHomeDir = "/home/penny"
+	getLocalUser = func() string { return "tester" }
 
 	args := &sshArgs{
 		Destination: "dest",
@@ -90,7 +97,8 @@ func TestExpandTokens(t *testing.T) {
 	assertControlPath("/A/%C/B", "/A/07f25c03a322b120bcaa54d2dd0a618f2673cb1c/B", "")
 
 	assertControlPath("%j", "%j", "token [%j] in [%j] is not supported")
-	assertControlPath("p_%h_%d", "p_127.0.0.1_%d", "token [%d] in [p_%h_%d] is not supported yet")
+	assertControlPath("%d/%u_%i_%k", fmt.Sprintf("/home/penny/tester_%d_127.0.0.1", os.Getuid()), "")
+	assertControlPath("p_%h_%f", "p_127.0.0.1_%f", "token [%f] in [p_%h_%f] is not supported")
 	assertControlPath("h%", "h%", "[h%] ends with % is invalid")
 }
 
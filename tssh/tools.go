@@ -25,11 +25,14 @@ SOFTWARE.
 package tssh
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -56,15 +59,28 @@ func showCursor(writer io.Writer) {
 }
 
 type toolsProgress struct {
+	mutex         sync.Mutex
 	prefix        string
 	totalSize     int
 	currentStep   int
 	progressTimer *time.Timer
+	spinnerIndex  int
+	stopped       bool
 }
 
+// toolsProgressDisabled hides the progress bars, e.g., in batch mode, where the hosts run in parallel.
+var toolsProgressDisabled atomic.Bool
+
+// newToolsProgress shows the percentage, or the size with a spinner if the total size is unknown, i.e., negative.
 func newToolsProgress(tool, name string, totalSize int) *toolsProgress {
-	hideCursor(os.Stderr)
 	p := &toolsProgress{prefix: fmt.Sprintf("[%s] %s", tool, name), totalSize: totalSize}
+	if toolsProgressDisabled.Load() {
+		p.stopped = true
+		return p
+	}
+	hideCursor(os.Stderr)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	p.progressTimer = time.AfterFunc(100*time.Millisecond, p.showProgress)
 	return p
 }
@@ -74,14 +90,33 @@ func (p *toolsProgress) writeMessage(format string, a ...any) {
 }
 
 func (p *toolsProgress) addStep(delta int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	p.currentStep += delta
-	if p.currentStep >= p.totalSize {
+	if p.totalSize >= 0 && p.currentStep >= p.totalSize && !p.stopped {
 		p.writeMessage("%d%%", 100)
-		p.stopProgress()
+		p.stop()
 	}
 }
 
+// Write implements io.Writer, so that the progress can be updated by io.TeeReader.
+func (p *toolsProgress) Write(buf []byte) (int, error) {
+	p.addStep(len(buf))
+	return len(buf), nil
+}
+
 func (p *toolsProgress) showProgress() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.stopped {
+		return
+	}
+	if p.totalSize < 0 {
+		p.writeMessage("%c %d KB", `|/-\`[p.spinnerIndex%4], p.currentStep/1024)
+		p.spinnerIndex++
+		p.progressTimer = time.AfterFunc(200*time.Millisecond, p.showProgress)
+		return
+	}
 	percentage := int(math.Round(float64(p.currentStep) * 100 / float64(p.totalSize)))
 	if percentage >= 100 {
 		return
@@ -91,11 +126,18 @@ func (p *toolsProgress) showProgress() {
 }
 
 func (p *toolsProgress) stopProgress() {
-	if p.progressTimer == nil {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.stop()
+}
+
+// stop stops the timer, and prevents the running showProgress from arming it again, requires the mutex.
+func (p *toolsProgress) stop() {
+	if p.stopped {
 		return
 	}
+	p.stopped = true
 	p.progressTimer.Stop()
-	p.progressTimer = nil
 	p.writeMessage("\r\n")
 	showCursor(os.Stderr)
 }
@@ -117,6 +159,15 @@ func toolsErrorExit(format string, a ...any) {
 	os.Exit(-1)
 }
 
+// printToolsJSON prints the result of the tool in JSON to stdout for --json, while the messages are on stderr.
+func printToolsJSON(v any) {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		toolsErrorExit("marshal json failed: %v", err)
+	}
+	fmt.Fprintf(os.Stdout, "%s\n", output)
+}
+
 func printToolsHelp(title string) {
 	fmt.Print(lipgloss.NewStyle().Bold(true).Foreground(greenColor).Render(title) + "\r\n")
 	fmt.Print(lipgloss.NewStyle().Faint(true).Render(getText("tools/help")) + "\r\n\r\n")
@@ -454,8 +505,40 @@ func execLocalTools(args *sshArgs) (int, bool) {
 	case args.Ver:
 		fmt.Println(args.Version())
 		return 0, true
+	case args.Explain != "":
+		return execExplain(args)
 	case args.EncSecret:
 		return execEncodeSecret()
+	case args.Vault != "":
+		return execVaultTool(args)
+	case args.Bench:
+		return execBench(args)
+	case args.BenchCiphers:
+		return execBenchmarkCiphers(args)
+	case args.Daemon:
+		return execDaemon(args)
+	case args.Tunnel != "":
+		return execTunnelTool(args)
+	case args.InstallService != "" || args.UninstallSvc != "":
+		return execServiceTool(args)
+	case args.RegisterUrl:
+		return execRegisterUrlHandler()
+	case args.Upgrade:
+		return execUpgrade(args)
+	case args.Edit != "":
+		return execEditTool(args)
+	case args.Script != "":
+		return execScript(args)
+	case args.InstallTrzsz && isBatchHosts(args):
+		return execBatchInstallTrzsz(args)
+	case args.Status:
+		return execStatus(args)
+	case args.History:
+		return execHistory(args)
+	case args.ListHosts:
+		return execListHosts(args)
+	case args.NewHosts != "":
+		return execNewHosts(args)
 	case args.NewHost || len(os.Args) == 1 && isFileNotExistOrEmpty(userConfig.configPath):
 		return execNewHost(args)
 	default:
@@ -468,5 +551,9 @@ func execRemoteTools(args *sshArgs, client *ssh.Client) {
 	switch {
 	case args.InstallTrzsz:
 		execInstallTrzsz(args, client)
+	case args.ClipPut != "":
+		execClipboardPut(args, client)
+	case args.ClipGet != "":
+		execClipboardGet(args, client)
 	}
 }
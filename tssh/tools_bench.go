@@ -0,0 +1,296 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kBenchSize = 32 * 1024 * 1024
+
+// connectTimings records the time of each phase of the connection for --bench.
+type connectTimings struct {
+	dialStart   time.Time
+	dialDone    time.Time
+	kexDone     time.Time
+	hostKeyDone time.Time
+	authDone    time.Time
+}
+
+// wrapHostKeyCallback records the time of the key exchange done, since the host key is checked right after it,
+// and the time of the host key confirmed, so that the interactive prompt is not counted.
+func (t *connectTimings) wrapHostKeyCallback(callback ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		t.kexDone = time.Now()
+		defer func() { t.hostKeyDone = time.Now() }()
+		return callback(hostname, remote, key)
+	}
+}
+
+type benchPhases struct {
+	dns     time.Duration
+	jump    time.Duration
+	tcp     time.Duration
+	kex     time.Duration
+	hostKey time.Duration
+	auth    time.Duration
+}
+
+type benchReport struct {
+	Host            string   `json:"host"`
+	DnsMs           float64  `json:"dns_ms"`
+	JumpMs          float64  `json:"jump_ms"`
+	TcpMs           float64  `json:"tcp_ms"`
+	KexMs           float64  `json:"kex_ms"`
+	AuthMs          float64  `json:"auth_ms"`
+	TotalMs         float64  `json:"total_ms"`
+	UploadMBps      float64  `json:"upload_mbps"`
+	DownloadMBps    float64  `json:"download_mbps"`
+	PtyDownloadMBps float64  `json:"pty_download_mbps"`
+	Hints           []string `json:"hints"`
+}
+
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (t *connectTimings) getPhases(connectStart time.Time) *benchPhases {
+	phases := &benchPhases{
+		jump: t.dialStart.Sub(connectStart),
+		tcp:  t.dialDone.Sub(t.dialStart),
+		auth: t.authDone.Sub(t.dialDone),
+	}
+	if !t.kexDone.IsZero() {
+		phases.kex = t.kexDone.Sub(t.dialDone)
+		phases.hostKey = t.hostKeyDone.Sub(t.kexDone)
+		phases.auth = t.authDone.Sub(t.hostKeyDone)
+	}
+	return phases
+}
+
+// getBenchHints tells which side is likely slow by the phases and the speeds in MB/s.
+func getBenchHints(phases *benchPhases, upload, download, pty float64) []string {
+	var hints []string
+	if phases.dns > 500*time.Millisecond {
+		hints = append(hints, "the dns lookup is slow, check the local resolver or use an IP address")
+	}
+	if phases.tcp > 200*time.Millisecond {
+		hints = append(hints, "the network latency is high, the round trip takes about the tcp connect time")
+	}
+	if phases.kex > 5*phases.tcp && phases.kex > 500*time.Millisecond {
+		hints = append(hints, "the key exchange is slow compared to the network latency, the server may be overloaded")
+	}
+	if phases.auth > 2*time.Second {
+		hints = append(hints, "the authentication is slow, check UseDNS and GSSAPIAuthentication of the server, or the agent")
+	}
+	if download > 0 && upload > 0 && (download > 4*upload || upload > 4*download) {
+		hints = append(hints, "the upload and download speeds differ a lot, the network is likely asymmetric")
+	}
+	if download > 0 && pty > 0 && pty < download/4 {
+		hints = append(hints, "the pty output is much slower than the channel, trzsz may be limited by the server pty or the client")
+	}
+	return hints
+}
+
+func benchDownload(client *ssh.Client, size int64, pty bool) (float64, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, fmt.Errorf("new session failed: %v", err)
+	}
+	defer session.Close()
+	command := fmt.Sprintf("head -c %d /dev/zero", size)
+	expected := size
+	if pty {
+		if err := session.RequestPty("xterm", 24, 80, ssh.TerminalModes{}); err != nil {
+			return 0, fmt.Errorf("request pty failed: %v", err)
+		}
+		// it's not a real trzsz transfer, but trzsz transfers the base64 encoded data through the pty by default
+		command += " | base64"
+		expected = (size + 2) / 3 * 4
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("stdout pipe failed: %v", err)
+	}
+
+	beginTime := time.Now()
+	if err := session.Start(command); err != nil {
+		return 0, fmt.Errorf("start command failed: %v", err)
+	}
+	n, err := io.Copy(io.Discard, stdout)
+	if err != nil && n < expected {
+		return 0, fmt.Errorf("read output failed: %v", err)
+	}
+	if n < expected {
+		return 0, fmt.Errorf("only %d bytes received, the remote server may not support '%s'", n, command)
+	}
+	_ = session.Wait()
+	return float64(size) / 1024 / 1024 / time.Since(beginTime).Seconds(), nil
+}
+
+func benchUpload(client *ssh.Client, size int64) (float64, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, fmt.Errorf("new session failed: %v", err)
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return 0, fmt.Errorf("stdin pipe failed: %v", err)
+	}
+
+	beginTime := time.Now()
+	if err := session.Start("cat > /dev/null"); err != nil {
+		return 0, fmt.Errorf("start command failed: %v", err)
+	}
+	buffer := make([]byte, 32*1024)
+	for written := int64(0); written < size; written += int64(len(buffer)) {
+		if err := writeAll(stdin, buffer); err != nil {
+			return 0, fmt.Errorf("write input failed: %v", err)
+		}
+	}
+	stdin.Close()
+	if err := session.Wait(); err != nil {
+		return 0, fmt.Errorf("wait command failed: %v", err)
+	}
+	return float64(size) / 1024 / 1024 / time.Since(beginTime).Seconds(), nil
+}
+
+// lookupBenchHost returns 0 if the host is an IP address or resolved by the jump host or the proxy command.
+func lookupBenchHost(args *sshArgs) (time.Duration, error) {
+	paramArgs := *args
+	param, err := getSshParam(&paramArgs)
+	if err != nil {
+		return 0, err
+	}
+	if len(param.proxy) > 0 || param.command != "" || net.ParseIP(param.host) != nil {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	beginTime := time.Now()
+	if resolver := getDnsResolver(args); resolver != nil {
+		_, err = resolver.lookup(ctx, param.host)
+	} else {
+		_, err = net.DefaultResolver.LookupHost(ctx, param.host)
+	}
+	return time.Since(beginTime), err
+}
+
+func formatBenchDuration(d time.Duration) string {
+	return d.Round(100 * time.Microsecond).String()
+}
+
+// execBench measures the time of each connection phase and the throughput, e.g., tssh --bench host
+func execBench(args *sshArgs) (int, bool) {
+	if args.Destination == "" {
+		toolsErrorExit("the destination to benchmark is required, e.g., tssh --bench host")
+	}
+
+	benchArgs := *args
+	benchArgs.Option = copySshOption(&args.Option)
+	benchArgs.Option.options["controlpath"] = []string{"none"}
+	timings := &connectTimings{}
+	benchArgs.timings = timings
+
+	dns, err := lookupBenchHost(&benchArgs)
+	if err != nil {
+		toolsErrorExit("dns lookup failed: %v", err)
+	}
+
+	connectStart := time.Now()
+	client, _, _, err := sshConnect(&benchArgs, nil, "")
+	if err != nil {
+		toolsErrorExit("%v", err)
+	}
+	defer client.Close()
+
+	phases := timings.getPhases(connectStart)
+	// the time waiting for the user to confirm the host key is not counted
+	total := time.Since(connectStart) - phases.hostKey
+	phases.dns = dns
+	if phases.dns > 0 {
+		toolsInfo("Bench", "%-16s %s", "dns lookup", formatBenchDuration(phases.dns))
+	}
+	if phases.jump > time.Millisecond {
+		toolsInfo("Bench", "%-16s %s", "jump hosts", formatBenchDuration(phases.jump))
+	}
+	toolsInfo("Bench", "%-16s %s", "tcp connect", formatBenchDuration(phases.tcp))
+	if phases.kex > 0 {
+		toolsInfo("Bench", "%-16s %s", "key exchange", formatBenchDuration(phases.kex))
+	}
+	toolsInfo("Bench", "%-16s %s", "authentication", formatBenchDuration(phases.auth))
+	toolsInfo("Bench", "%-16s %s", "total", formatBenchDuration(total+phases.dns))
+
+	toolsInfo("Bench", "transferring %d MB in each direction", kBenchSize/1024/1024)
+	report := func(name string, speed float64, err error) float64 {
+		if err != nil {
+			toolsWarn("Bench", "%-16s failed: %v", name, err)
+			return 0
+		}
+		toolsInfo("Bench", "%-16s %8.2f MB/s", name, speed)
+		return speed
+	}
+	speed, err := benchUpload(client, kBenchSize)
+	upload := report("channel upload", speed, err)
+	speed, err = benchDownload(client, kBenchSize, false)
+	download := report("channel download", speed, err)
+	speed, err = benchDownload(client, kBenchSize, true)
+	pty := report("pty download", speed, err)
+
+	hints := getBenchHints(phases, upload, download, pty)
+	for _, hint := range hints {
+		toolsWarn("Bench", "%s", hint)
+	}
+	if len(hints) == 0 {
+		toolsSucc("Bench", "no obvious bottleneck is found")
+	}
+
+	if args.JSON {
+		if hints == nil {
+			hints = []string{}
+		}
+		printToolsJSON(&benchReport{
+			Host:            args.Destination,
+			DnsMs:           toMilliseconds(phases.dns),
+			JumpMs:          toMilliseconds(phases.jump),
+			TcpMs:           toMilliseconds(phases.tcp),
+			KexMs:           toMilliseconds(phases.kex),
+			AuthMs:          toMilliseconds(phases.auth),
+			TotalMs:         toMilliseconds(total + phases.dns),
+			UploadMBps:      upload,
+			DownloadMBps:    download,
+			PtyDownloadMBps: pty,
+			Hints:           hints,
+		})
+	}
+	return 0, true
+}
@@ -0,0 +1,88 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestConnectTimings(t *testing.T) {
+	assert := assert.New(t)
+	timings := &connectTimings{}
+	called := false
+	callback := timings.wrapHostKeyCallback(func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		called = true
+		return nil
+	})
+	assert.Nil(callback("host", nil, nil))
+	assert.True(called)
+	assert.False(timings.kexDone.IsZero())
+	assert.False(timings.hostKeyDone.Before(timings.kexDone))
+
+	// the host key prompt is not counted in the authentication
+	start := time.Now()
+	timings = &connectTimings{
+		dialStart:   start.Add(100 * time.Millisecond),
+		dialDone:    start.Add(150 * time.Millisecond),
+		kexDone:     start.Add(250 * time.Millisecond),
+		hostKeyDone: start.Add(5250 * time.Millisecond),
+		authDone:    start.Add(5550 * time.Millisecond),
+	}
+	assert.Equal(&benchPhases{
+		jump:    100 * time.Millisecond,
+		tcp:     50 * time.Millisecond,
+		kex:     100 * time.Millisecond,
+		hostKey: 5 * time.Second,
+		auth:    300 * time.Millisecond,
+	}, timings.getPhases(start))
+
+	timings.kexDone, timings.hostKeyDone = time.Time{}, time.Time{}
+	assert.Equal(&benchPhases{
+		jump: 100 * time.Millisecond,
+		tcp:  50 * time.Millisecond,
+		auth: 5400 * time.Millisecond,
+	}, timings.getPhases(start))
+}
+
+func TestGetBenchHints(t *testing.T) {
+	assert := assert.New(t)
+	phases := &benchPhases{dns: time.Millisecond, tcp: 10 * time.Millisecond, kex: 30 * time.Millisecond, auth: 50 * time.Millisecond}
+	assert.Empty(getBenchHints(phases, 50, 60, 40))
+
+	phases = &benchPhases{dns: time.Second, tcp: 300 * time.Millisecond, kex: 2 * time.Second, auth: 3 * time.Second}
+	hints := getBenchHints(phases, 5, 50, 2)
+	assert.Len(hints, 6)
+	assert.Contains(hints[0], "dns")
+	assert.Contains(hints[1], "network latency")
+	assert.Contains(hints[2], "key exchange")
+	assert.Contains(hints[3], "authentication")
+	assert.Contains(hints[4], "asymmetric")
+	assert.Contains(hints[5], "pty")
+}
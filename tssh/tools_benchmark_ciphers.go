@@ -0,0 +1,87 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+const kBenchmarkCiphersSize = 64 * 1024 * 1024
+
+func copySshOption(option *sshOption) sshOption {
+	options := make(map[string][]string)
+	if option.options != nil {
+		for key, values := range option.options {
+			options[key] = append([]string(nil), values...)
+		}
+	}
+	return sshOption{options}
+}
+
+func benchmarkCipher(args *sshArgs, cipher string) (float64, error) {
+	benchArgs := *args
+	benchArgs.Option = copySshOption(&args.Option)
+	benchArgs.Option.options["ciphers"] = []string{cipher}
+	benchArgs.Option.options["controlpath"] = []string{"none"}
+
+	client, _, _, err := sshConnect(&benchArgs, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	return benchDownload(client, kBenchmarkCiphersSize, false)
+}
+
+func execBenchmarkCiphers(args *sshArgs) (int, bool) {
+	if args.Destination == "" {
+		toolsErrorExit("the destination to benchmark is required, e.g., tssh --benchmark-ciphers host")
+	}
+
+	if hasAESHardwareSupport() {
+		toolsInfo("BenchmarkCiphers", "AES hardware acceleration is available, aes128-gcm@openssh.com is preferred by default")
+	} else {
+		toolsInfo("BenchmarkCiphers", "no AES hardware acceleration, chacha20-poly1305@openssh.com is preferred by default")
+	}
+	toolsInfo("BenchmarkCiphers", "downloading %d MB from %s with each cipher, a new login is required for each one",
+		kBenchmarkCiphersSize/1024/1024, args.Destination)
+
+	ciphers := getDefaultCiphers()
+	bestCipher, bestSpeed := "", 0.0
+	for _, cipher := range ciphers {
+		speed, err := benchmarkCipher(args, cipher)
+		if err != nil {
+			toolsWarn("BenchmarkCiphers", "%-30s failed: %v", cipher, err)
+			continue
+		}
+		toolsInfo("BenchmarkCiphers", "%-30s %8.2f MB/s", cipher, speed)
+		if speed > bestSpeed {
+			bestCipher, bestSpeed = cipher, speed
+		}
+	}
+
+	if bestCipher == "" {
+		return 1, true
+	}
+	toolsSucc("BenchmarkCiphers", "the fastest cipher is %s, you can prefer it by adding the following to ~/.ssh/config:", bestCipher)
+	toolsSucc("BenchmarkCiphers", "Host %s\n    Ciphers ^%s", args.Destination, bestCipher)
+	return 0, true
+}
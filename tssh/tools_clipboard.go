@@ -0,0 +1,167 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const kClipboardMaxSize = 1024 * 1024
+
+type clipboardCommand struct {
+	name string
+	args []string
+}
+
+func getClipboardCommands(paste bool) []clipboardCommand {
+	switch runtime.GOOS {
+	case "darwin":
+		if paste {
+			return []clipboardCommand{{"pbpaste", nil}}
+		}
+		return []clipboardCommand{{"pbcopy", nil}}
+	case "windows":
+		if paste {
+			return []clipboardCommand{{"powershell.exe", []string{"-NoProfile", "-Command", "Get-Clipboard -Raw"}}}
+		}
+		return []clipboardCommand{{"clip.exe", nil}}
+	default:
+		if paste {
+			return []clipboardCommand{{"wl-paste", []string{"--no-newline"}},
+				{"xclip", []string{"-selection", "clipboard", "-o"}}, {"xsel", []string{"--clipboard", "--output"}}}
+		}
+		return []clipboardCommand{{"wl-copy", nil},
+			{"xclip", []string{"-selection", "clipboard"}}, {"xsel", []string{"--clipboard", "--input"}}}
+	}
+}
+
+func findClipboardCommand(paste bool) (*exec.Cmd, error) {
+	var names []string
+	for _, c := range getClipboardCommands(paste) {
+		if _, err := exec.LookPath(c.name); err == nil {
+			return exec.Command(c.name, c.args...), nil
+		}
+		names = append(names, c.name)
+	}
+	return nil, fmt.Errorf("no clipboard command found, tried: %s", strings.Join(names, ", "))
+}
+
+func readClipboard() ([]byte, error) {
+	cmd, err := findClipboardCommand(true)
+	if err != nil {
+		return nil, err
+	}
+	return cmd.Output()
+}
+
+// writeClipboard uses the clipboard commands, or the OSC 52 escape sequence if there is no GUI,
+// e.g., running tssh on a server, which copies to the clipboard of the local terminal.
+func writeClipboard(data []byte) error {
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" && isNoGUI() {
+		if !isTerminal {
+			return fmt.Errorf("no GUI and not a terminal to copy via OSC 52")
+		}
+		_, err := fmt.Fprintf(os.Stderr, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString(data))
+		return err
+	}
+	cmd, err := findClipboardCommand(false)
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = bytes.NewReader(data)
+	return cmd.Run()
+}
+
+// quoteRemotePath quotes the remote path but keeps the leading ~/ to be expanded by the shell.
+func quoteRemotePath(path string) string {
+	if path == "~" {
+		return path
+	}
+	if strings.HasPrefix(path, "~/") {
+		return "~/" + shellQuote(path[2:])
+	}
+	return shellQuote(path)
+}
+
+func execClipboardPut(args *sshArgs, client *ssh.Client) {
+	data, err := readClipboard()
+	if err != nil {
+		toolsWarn("Clipboard", "read the local clipboard failed: %v", err)
+		return
+	}
+	if len(data) == 0 {
+		toolsWarn("Clipboard", "the local clipboard is empty")
+		return
+	}
+	if len(data) > kClipboardMaxSize {
+		toolsWarn("Clipboard", "the clipboard is too large (%d bytes), please use trz instead", len(data))
+		return
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		toolsWarn("Clipboard", "new session failed: %v", err)
+		return
+	}
+	defer session.Close()
+	session.Stdin = bytes.NewReader(data)
+	if output, err := session.CombinedOutput("cat > " + quoteRemotePath(args.ClipPut)); err != nil {
+		toolsWarn("Clipboard", "write %s failed: %v %s", args.ClipPut, err, strings.TrimSpace(string(output)))
+		return
+	}
+	toolsSucc("Clipboard", "%d bytes of the clipboard have been written to %s", len(data), args.ClipPut)
+}
+
+func execClipboardGet(args *sshArgs, client *ssh.Client) {
+	session, err := client.NewSession()
+	if err != nil {
+		toolsWarn("Clipboard", "new session failed: %v", err)
+		return
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	data, err := session.Output(fmt.Sprintf("head -c %d %s", kClipboardMaxSize+1, quoteRemotePath(args.ClipGet)))
+	if err != nil {
+		toolsWarn("Clipboard", "read %s failed: %v %s", args.ClipGet, err, strings.TrimSpace(stderr.String()))
+		return
+	}
+	if len(data) > kClipboardMaxSize {
+		toolsWarn("Clipboard", "%s is too large, please use tsz instead", args.ClipGet)
+		return
+	}
+	if err := writeClipboard(data); err != nil {
+		toolsWarn("Clipboard", "write the local clipboard failed: %v", err)
+		return
+	}
+	toolsSucc("Clipboard", "%d bytes of %s have been copied to the clipboard", len(data), args.ClipGet)
+}
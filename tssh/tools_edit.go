@@ -0,0 +1,275 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// remoteEditor edits a remote file in the local editor, uploads it on every save.
+type remoteEditor struct {
+	client   *ssh.Client
+	dest     string
+	path     string
+	sudo     bool
+	password []byte
+	mutex    sync.Mutex
+	hash     [32]byte
+}
+
+// parseEditTarget accepts `tssh --edit host:/path`, `tssh --edit ssh://host/path` and `tssh --edit /path host`
+func parseEditTarget(args *sshArgs) (string, string, error) {
+	if args.Destination != "" {
+		return args.Destination, args.Edit, nil
+	}
+	if _, _, _, ok := parseSshURL(args.Edit); ok {
+		// ssh://user@host:port/path/to/file
+		rest := args.Edit[len("ssh://"):]
+		if pos := strings.IndexByte(rest, '/'); pos > 0 && pos < len(rest)-1 {
+			return args.Edit[:len("ssh://")+pos], rest[pos:], nil
+		}
+		return "", "", fmt.Errorf("invalid --edit [%s], should be ssh://host/path/to/file", args.Edit)
+	}
+	dest, path, ok := strings.Cut(args.Edit, ":")
+	if !ok || dest == "" || path == "" {
+		return "", "", fmt.Errorf("invalid --edit [%s], should be host:/path/to/file", args.Edit)
+	}
+	return dest, path, nil
+}
+
+func getLocalEditor() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(env); editor != "" {
+			if argv, err := splitCommandLine(editor); err == nil && len(argv) > 0 {
+				return argv
+			}
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad.exe"}
+	}
+	return []string{"vi"}
+}
+
+// kKeepModeScript copies the mode and owner of the target "$f" to the temporary file "$t".
+const kKeepModeScript = `if [ -e "$f" ]; then ` +
+	`chmod "$(stat -c %a "$f" 2>/dev/null || stat -f %Lp "$f")" "$t"; ` +
+	`chown "$(stat -c %u:%g "$f" 2>/dev/null || stat -f %u:%g "$f")" "$t" 2>/dev/null; ` +
+	`else chmod 644 "$t"; fi; `
+
+// getWriteScript writes stdin to a temporary file next to the target, keeps the mode and owner, then renames it.
+func getWriteScript(path string) string {
+	return fmt.Sprintf(`f=%s; t=$(mktemp "$f.tssh.XXXXXX") || exit 1; `+
+		`cat > "$t" || { rm -f "$t"; exit 1; }; `, quoteRemotePath(path)) + kKeepModeScript +
+		`mv -f "$t" "$f" || { rm -f "$t"; exit 1; }`
+}
+
+func (e *remoteEditor) run(script string, stdin []byte) ([]byte, error) {
+	session, err := e.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	command := script
+	if e.sudo {
+		command = "sudo -n sh -c " + shellQuote(script)
+		if e.password != nil {
+			// sudo reads the password line by line, the rest of stdin is left to the command
+			command = "sudo -S -p '' sh -c " + shellQuote(script)
+			stdin = append(append(append([]byte(nil), e.password...), '\n'), stdin...)
+		}
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	session.Stdin = bytes.NewReader(stdin)
+	output, err := session.Output(command)
+	if err != nil {
+		return nil, fmt.Errorf("%v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+func (e *remoteEditor) download() ([]byte, bool, error) {
+	quotedPath := quoteRemotePath(e.path)
+	output, err := e.run(fmt.Sprintf("if [ -e %s ]; then echo exist; cat %s; else echo new; fi", quotedPath, quotedPath), nil)
+	if err != nil && e.sudo && e.password == nil && strings.Contains(err.Error(), "password") {
+		secret, err := readSecret(fmt.Sprintf("[sudo] password on %s: ", e.dest))
+		if err != nil {
+			return nil, false, err
+		}
+		e.password = secret
+		return e.download()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	state, content, _ := bytes.Cut(output, []byte("\n"))
+	return content, string(state) == "new", nil
+}
+
+func (e *remoteEditor) upload(localPath string) (bool, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return false, err
+	}
+	hash := sha256.Sum256(content)
+	if hash == e.hash {
+		return false, nil
+	}
+	if _, err := e.run(getWriteScript(e.path), content); err != nil {
+		return false, err
+	}
+	e.hash = hash
+	return true, nil
+}
+
+// watch uploads the file whenever it's saved, so that the editors which don't exit are supported too.
+func (e *remoteEditor) watch(localPath string, stopCh <-chan struct{}, errCh chan<- error) {
+	stat, _ := os.Stat(localPath)
+	var modTime time.Time
+	if stat != nil {
+		modTime = stat.ModTime()
+	}
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(time.Second):
+		}
+		stat, err := os.Stat(localPath)
+		if err != nil || stat.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = stat.ModTime()
+		if uploaded, err := e.upload(localPath); err != nil {
+			select {
+			case errCh <- err:
+			default:
+			}
+		} else if uploaded {
+			debug("%s uploaded on save", e.path)
+		}
+	}
+}
+
+func execEditTool(args *sshArgs) (int, bool) {
+	if err := editRemoteFile(args); err != nil {
+		toolsErrorExit("%v", err)
+	}
+	return 0, true
+}
+
+// editRemoteFile returns the error instead of exiting, so that the temporary directory is removed by defer.
+func editRemoteFile(args *sshArgs) error {
+	dest, remotePath, err := parseEditTarget(args)
+	if err != nil {
+		return err
+	}
+	editArgs := *args
+	editArgs.Destination = dest
+	editArgs.originalDest = dest
+	client, _, _, err := sshConnect(&editArgs, nil, "")
+	if err != nil {
+		return fmt.Errorf("login to [%s] failed: %v", dest, err)
+	}
+	defer client.Close()
+
+	editor := &remoteEditor{client: client, dest: dest, path: remotePath, sudo: args.Sudo}
+	content, isNew, err := editor.download()
+	if err != nil {
+		return fmt.Errorf("read %s failed: %v", remotePath, err)
+	}
+	editor.hash = sha256.Sum256(content)
+
+	tmpDir, err := os.MkdirTemp("", "tssh-edit-")
+	if err != nil {
+		return fmt.Errorf("create temporary directory failed: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	localPath := filepath.Join(tmpDir, path.Base(remotePath))
+	if err := os.WriteFile(localPath, content, 0600); err != nil {
+		return fmt.Errorf("write %s failed: %v", localPath, err)
+	}
+	if isNew {
+		toolsInfo("Edit", "%s does not exist, it will be created on save", remotePath)
+	}
+
+	stopCh := make(chan struct{})
+	errCh := make(chan error, 1)
+	go editor.watch(localPath, stopCh, errCh)
+
+	argv := append(getLocalEditor(), localPath)
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+	close(stopCh)
+	if runErr != nil {
+		toolsWarn("Edit", "editor %s exited: %v", argv[0], runErr)
+	}
+
+	select {
+	case err := <-errCh:
+		toolsWarn("Edit", "upload on save failed: %v", err)
+	default:
+	}
+	uploaded, err := editor.upload(localPath)
+	if err != nil {
+		return fmt.Errorf("upload %s failed: %v, the local copy is kept in %s", remotePath, err, saveEditBackup(localPath))
+	}
+	if uploaded || editor.hash != sha256.Sum256(content) {
+		toolsSucc("Edit", "%s has been saved", remotePath)
+	} else {
+		toolsInfo("Edit", "%s is not changed", remotePath)
+	}
+	return nil
+}
+
+// saveEditBackup keeps the edited file, since the temporary directory will be removed.
+func saveEditBackup(localPath string) string {
+	file, err := os.CreateTemp("", "tssh-edit-*-"+filepath.Base(localPath))
+	if err != nil {
+		return localPath
+	}
+	defer file.Close()
+	content, _ := os.ReadFile(localPath)
+	_, _ = file.Write(content)
+	return file.Name()
+}
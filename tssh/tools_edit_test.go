@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEditTarget(t *testing.T) {
+	assert := assert.New(t)
+	assertTarget := func(args *sshArgs, dest, path string) {
+		t.Helper()
+		d, p, err := parseEditTarget(args)
+		assert.Nil(err)
+		assert.Equal(dest, d)
+		assert.Equal(path, p)
+	}
+	assertTarget(&sshArgs{Edit: "host:/etc/hosts"}, "host", "/etc/hosts")
+	assertTarget(&sshArgs{Edit: "user@host:~/a:b.txt"}, "user@host", "~/a:b.txt")
+	assertTarget(&sshArgs{Edit: "/etc/hosts", Destination: "host"}, "host", "/etc/hosts")
+	assertTarget(&sshArgs{Edit: "ssh://user@[::1]:2222/etc/hosts"}, "ssh://user@[::1]:2222", "/etc/hosts")
+	for _, edit := range []string{"/etc/hosts", "host:", ":/etc/hosts", "ssh://host", "ssh://host/"} {
+		_, _, err := parseEditTarget(&sshArgs{Edit: edit})
+		assert.NotNil(err)
+	}
+	// the error is returned instead of exiting, so that the deferred cleanups run
+	assert.NotNil(editRemoteFile(&sshArgs{Edit: "host:"}))
+}
+
+func TestEditWriteScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+	assert := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a b.conf")
+	assert.Nil(os.WriteFile(path, []byte("old"), 0640))
+
+	write := func(content string) {
+		t.Helper()
+		cmd := exec.Command("sh", "-c", getWriteScript(path))
+		cmd.Stdin = strings.NewReader(content)
+		output, err := cmd.CombinedOutput()
+		assert.Nil(err, string(output))
+	}
+
+	write("new\n")
+	content, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("new\n", string(content))
+	stat, err := os.Stat(path)
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0640), stat.Mode().Perm())
+
+	path = filepath.Join(dir, "new.conf")
+	write("created")
+	content, err = os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("created", string(content))
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(entries, 2)
+}
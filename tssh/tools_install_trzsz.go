@@ -29,13 +29,19 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -52,8 +58,55 @@ type trzszRelease struct {
 	TagName string `json:"tag_name"`
 }
 
-func getLatestTrzszVersion() (string, error) {
-	resp, err := http.Get("https://api.github.com/repos/trzsz/trzsz-go/releases/latest")
+const kTrzszReleaseURL = "https://github.com/trzsz/trzsz-go/releases/download"
+
+// getTrzszHttpClient returns the http client to download trzsz, configured by ExTrzszDownloadProxy:
+//
+//	ssh: download through the ssh connection, i.e., from the network of the server, including the jump hosts.
+//	socks5://host:port or http://host:port: download through the proxy.
+//
+// By default, the proxy is configured by the environment variables such as HTTPS_PROXY.
+func getTrzszHttpClient(args *sshArgs, client *ssh.Client) (*http.Client, error) {
+	proxy := getExOptionConfig(args, "ExTrzszDownloadProxy")
+	switch {
+	case proxy == "":
+		return http.DefaultClient, nil
+	case strings.ToLower(proxy) == "ssh":
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialWithTimeout(client, network, addr, 10*time.Second)
+			},
+		}}, nil
+	default:
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid ExTrzszDownloadProxy [%s]", proxy)
+		}
+		switch proxyURL.Scheme {
+		case "socks5", "http", "https":
+		default:
+			return nil, fmt.Errorf("unsupported ExTrzszDownloadProxy scheme [%s]", proxyURL.Scheme)
+		}
+		return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}, nil
+	}
+}
+
+// getTrzszReleaseURL returns ExTrzszMirror if configured, the mirror should have the same layout as GitHub releases,
+// e.g., <mirror>/v1.1.7/trzsz_1.1.7_linux_x86_64.tar.gz and <mirror>/v1.1.7/trzsz_1.1.7_checksums.txt,
+// and a plain text <mirror>/latest with the latest version, e.g., 1.1.7, unless --trzsz-version is specified.
+func getTrzszReleaseURL(args *sshArgs) string {
+	if mirror := getExOptionConfig(args, "ExTrzszMirror"); mirror != "" {
+		return strings.TrimRight(mirror, "/")
+	}
+	return kTrzszReleaseURL
+}
+
+// getLatestTrzszVersion returns the latest version from the mirror if configured, otherwise from GitHub.
+func getLatestTrzszVersion(args *sshArgs, httpClient *http.Client) (string, error) {
+	if releaseURL := getTrzszReleaseURL(args); releaseURL != kTrzszReleaseURL {
+		return getMirrorTrzszVersion(httpClient, releaseURL+"/latest")
+	}
+	resp, err := httpClient.Get("https://api.github.com/repos/trzsz/trzsz-go/releases/latest")
 	if err != nil {
 		return "", err
 	}
@@ -72,6 +125,27 @@ func getLatestTrzszVersion() (string, error) {
 	return release.TagName[1:], nil
 }
 
+// getMirrorTrzszVersion reads the latest version from the plain text file of the mirror.
+func getMirrorTrzszVersion(httpClient *http.Client, latestURL string) (string, error) {
+	resp, err := httpClient.Get(latestURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get [%s] http response status code %d", latestURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimPrefix(strings.TrimSpace(string(body)), "v")
+	if version == "" || strings.ContainsAny(version, " \t\r\n/") {
+		return "", fmt.Errorf("invalid version [%s] in [%s]", strings.TrimSpace(string(body)), latestURL)
+	}
+	return version, nil
+}
+
 func checkTrzszVersion(client *ssh.Client, cmd, name, version string) bool {
 	session, err := client.NewSession()
 	if err != nil {
@@ -94,14 +168,90 @@ func checkTrzszExecutable(client *ssh.Client, name, version string) bool {
 	return checkTrzszVersion(client, fmt.Sprintf("$SHELL -l -c '%s -v'", name), name, version)
 }
 
+// isTrzszPathMissing returns true if the installed trz or tsz can't be found in the PATH of the login shell.
+func isTrzszPathMissing(client *ssh.Client, version string) bool {
+	return !checkTrzszExecutable(client, "trz", version) || !checkTrzszExecutable(client, "tsz", version)
+}
+
 func checkTrzszPathEnv(client *ssh.Client, version, path string) {
-	trzExecutable := checkTrzszExecutable(client, "trz", version)
-	tszExecutable := checkTrzszExecutable(client, "tsz", version)
-	if !trzExecutable || !tszExecutable {
+	if isTrzszPathMissing(client, version) {
 		toolsInfo("InstallTrzsz", "you may need to add %s to the PATH environment variable", path)
 	}
 }
 
+func isTrzszInPath(client *ssh.Client) (bool, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-time.After(3 * time.Second):
+			session.Close()
+		case <-done:
+		}
+	}()
+	output, err := session.Output("$SHELL -l -c 'command -v trz && command -v tsz' >/dev/null 2>&1 && echo yes || echo no")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(output)) == "yes", nil
+}
+
+// getTrzszCheckMode returns the ExTrzszCheck of the host, it's no if trzsz is disabled.
+func getTrzszCheckMode(args *sshArgs) string {
+	check := strings.ToLower(getExOptionConfig(args, "ExTrzszCheck"))
+	switch check {
+	case "", "no":
+		return "no"
+	case "hint":
+	case "ask":
+		if batchMode {
+			debug("BatchMode is enabled, skip asking to install trzsz")
+			return "no"
+		}
+	default:
+		warning("unknown ExTrzszCheck [%s], should be one of no, hint, ask", check)
+		return "no"
+	}
+	if strings.ToLower(getExOptionConfig(args, "EnableTrzsz")) == "no" {
+		return "no"
+	}
+	return check
+}
+
+// checkTrzszPresence shows a hint or offers to install trzsz if trz / tsz is not found on the server.
+// It's configured by ExTrzszCheck per host, the value could be:
+//
+//	no: don't check, the default.
+//	hint: show a one-line hint.
+//	ask: ask whether to install trzsz now, which is treated as no in BatchMode.
+func checkTrzszPresence(args *sshArgs, client *ssh.Client) {
+	check := getTrzszCheckMode(args)
+	if check == "no" {
+		return
+	}
+
+	found, err := isTrzszInPath(client)
+	if err != nil {
+		debug("check trzsz presence failed: %v", err)
+		return
+	}
+	if found {
+		return
+	}
+	if check == "hint" {
+		toolsInfo("InstallTrzsz", "trz / tsz not found on the server, install them with: tssh --install-trzsz %s", args.Destination)
+		return
+	}
+	if promptBoolInput("trz / tsz not found on the server, install trzsz now", "set ExTrzszCheck no to suppress the question", false) {
+		execInstallTrzsz(args, client)
+	}
+}
+
 func getRemoteServerOS(client *ssh.Client) (string, error) {
 	session, err := client.NewSession()
 	if err != nil {
@@ -215,22 +365,55 @@ func extractTrzszBinary(gzr io.Reader, version, svrOS, arch string) ([]byte, []b
 	return trz.Bytes(), tsz.Bytes(), nil
 }
 
-func downloadTrzszBinary(version, svrOS, arch string) ([]byte, []byte, error) {
-	url := fmt.Sprintf("https://github.com/trzsz/trzsz-go/releases/download/v%s/trzsz_%s_%s_%s.tar.gz",
-		version, version, svrOS, arch)
-	toolsInfo("InstallTrzsz", "download url: %s", url)
+// getReleaseChecksum returns the sha256 checksum of the package from the checksums file of the release.
+// The releases are not signed, and the checksums file comes from the same place as the package,
+// so the checksum only detects a corrupted or truncated download, it doesn't authenticate the mirror.
+func getReleaseChecksum(httpClient *http.Client, checksumsURL, pkgName string) (string, error) {
+	resp, err := httpClient.Get(checksumsURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get [%s] http response status code %d", checksumsURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return "", err
+	}
+	return findChecksum(body, pkgName)
+}
+
+func findChecksum(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checksum of [%s] not found", name)
+}
 
-	resp, err := http.Get(url)
+func downloadWithProgress(httpClient *http.Client, tool, url string) ([]byte, error) {
+	toolsInfo(tool, "download url: %s", url)
+
+	resp, err := httpClient.Get(url)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("http response status code %d", resp.StatusCode)
+		return nil, fmt.Errorf("http response status code %d", resp.StatusCode)
 	}
 
 	contentLength := int(resp.ContentLength)
-	progress := newToolsProgress("InstallTrzsz", "download percentage", contentLength)
+	if contentLength < 0 {
+		progress := newToolsProgress(tool, "downloaded", -1)
+		defer progress.stopProgress()
+		return io.ReadAll(io.TeeReader(resp.Body, progress))
+	}
+	progress := newToolsProgress(tool, "download percentage", contentLength)
 	defer progress.stopProgress()
 
 	buffer := make([]byte, contentLength)
@@ -241,11 +424,34 @@ func downloadTrzszBinary(version, svrOS, arch string) ([]byte, []byte, error) {
 			maxBufferIdx = contentLength
 		}
 		n, err := resp.Body.Read(buffer[currentStep:maxBufferIdx])
-		if err != nil {
-			return nil, nil, err
-		}
 		currentStep += n
 		progress.addStep(n)
+		if err == io.EOF && currentStep == contentLength {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buffer, nil
+}
+
+func downloadTrzszBinary(httpClient *http.Client, releaseURL, version, svrOS, arch string) ([]byte, []byte, error) {
+	pkgName := fmt.Sprintf("trzsz_%s_%s_%s.tar.gz", version, svrOS, arch)
+	checksumsURL := fmt.Sprintf("%s/v%s/trzsz_%s_checksums.txt", releaseURL, version, version)
+	checksum, err := getReleaseChecksum(httpClient, checksumsURL, pkgName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get checksum failed: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/v%s/%s", releaseURL, version, pkgName)
+	buffer, err := downloadWithProgress(httpClient, "InstallTrzsz", url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if sum := fmt.Sprintf("%x", sha256.Sum256(buffer)); sum != checksum {
+		return nil, nil, fmt.Errorf("checksum mismatch, expected %s, but got %s", checksum, sum)
 	}
 
 	gzr, err := gzip.NewReader(bytes.NewReader(buffer))
@@ -367,68 +573,151 @@ func uploadTrzszBinary(client *ssh.Client, path string, trz, tsz []byte) error {
 	return nil
 }
 
-func execInstallTrzsz(args *sshArgs, client *ssh.Client) {
-	version := args.TrzszVersion
-	if version == "" {
-		var err error
-		if version, err = getLatestTrzszVersion(); err != nil {
-			toolsWarn("InstallTrzsz", "get latest trzsz version failed: %v", err)
-			toolsInfo("InstallTrzsz", "you can specify the version of trzsz through --trzsz-version")
-			return
-		}
+func getTrzszInstallPath(args *sshArgs) string {
+	if args.InstallPath != "" {
+		return args.InstallPath
 	}
+	return "~/.local/bin/"
+}
 
-	installPath := args.InstallPath
-	if installPath == "" {
-		installPath = "~/.local/bin/"
+func getTrzszBinary(args *sshArgs, httpClient *http.Client, version, svrOS, arch string) ([]byte, []byte, error) {
+	if args.TrzszBinPath != "" {
+		trz, tsz, err := readTrzszBinary(resolvePath(args.TrzszBinPath), version, svrOS, arch)
+		if err != nil {
+			return nil, nil, fmt.Errorf("extract installation files failed: %v", err)
+		}
+		return trz, tsz, nil
+	}
+	trz, tsz, err := downloadTrzszBinary(httpClient, getTrzszReleaseURL(args), version, svrOS, arch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("download installation files failed: %v, "+
+			"you can download the release from github and specify it with --trzsz-bin-path", err)
 	}
+	return trz, tsz, nil
+}
 
+// installTrzsz returns true if the specified version of trzsz has been installed already.
+func installTrzsz(client *ssh.Client, version, installPath string,
+	getBinary func(svrOS, arch string) ([]byte, []byte, error)) (bool, error) {
 	trzInstalled := checkInstalledVersion(client, installPath, "trz", version)
 	tszInstalled := checkInstalledVersion(client, installPath, "tsz", version)
 	if trzInstalled && tszInstalled {
-		toolsSucc("InstallTrzsz", "trzsz %s has been installed in %s", version, installPath)
-		checkTrzszPathEnv(client, version, installPath)
-		return
+		return true, nil
 	}
 
 	svrOS, err := getRemoteServerOS(client)
 	if err != nil {
-		toolsWarn("InstallTrzsz", "get remote server operating system failed: %v", err)
-		return
+		return false, fmt.Errorf("get remote server operating system failed: %v", err)
 	}
 
 	arch, err := getRemoteServerArch(client)
 	if err != nil {
-		toolsWarn("InstallTrzsz", "get remote server cpu architecture failed: %v", err)
-		return
+		return false, fmt.Errorf("get remote server cpu architecture failed: %v", err)
 	}
 
 	if err := mkdirInstallPath(client, installPath); err != nil {
-		toolsWarn("InstallTrzsz", "mkdir [%s] failed: %v", installPath, err)
+		return false, fmt.Errorf("mkdir [%s] failed: %v", installPath, err)
+	}
+
+	trz, tsz, err := getBinary(svrOS, arch)
+	if err != nil {
+		return false, err
+	}
+
+	if err := uploadTrzszBinary(client, installPath, trz, tsz); err != nil {
+		return false, fmt.Errorf("upload trzsz binary files failed: %v", err)
+	}
+	return false, nil
+}
+
+func execInstallTrzsz(args *sshArgs, client *ssh.Client) {
+	httpClient, err := getTrzszHttpClient(args, client)
+	if err != nil {
+		toolsWarn("InstallTrzsz", "%v", err)
 		return
 	}
 
-	var trz, tsz []byte
-	if args.TrzszBinPath != "" {
-		trz, tsz, err = readTrzszBinary(args.TrzszBinPath, version, svrOS, arch)
-		if err != nil {
-			toolsWarn("InstallTrzsz", "extract installation files failed: %v", err)
-			return
-		}
-	} else {
-		trz, tsz, err = downloadTrzszBinary(version, svrOS, arch)
-		if err != nil {
-			toolsWarn("InstallTrzsz", "download installation files failed: %v", err)
-			toolsInfo("InstallTrzsz", "you can download the release from github and specify it with --trzsz-bin-path")
+	version := args.TrzszVersion
+	if version == "" {
+		if version, err = getLatestTrzszVersion(args, httpClient); err != nil {
+			toolsWarn("InstallTrzsz", "get latest trzsz version failed: %v", err)
+			toolsInfo("InstallTrzsz", "you can specify the version of trzsz through --trzsz-version")
 			return
 		}
 	}
 
-	if err := uploadTrzszBinary(client, installPath, trz, tsz); err != nil {
-		toolsWarn("InstallTrzsz", "upload trzsz binary files failed: %v", err)
+	installPath := getTrzszInstallPath(args)
+	installed, err := installTrzsz(client, version, installPath, func(svrOS, arch string) ([]byte, []byte, error) {
+		return getTrzszBinary(args, httpClient, version, svrOS, arch)
+	})
+	if err != nil {
+		toolsWarn("InstallTrzsz", "%v", err)
 		return
 	}
 
-	toolsSucc("InstallTrzsz", "trzsz %s installation to %s completed successfully", version, installPath)
+	if installed {
+		toolsSucc("InstallTrzsz", "trzsz %s has been installed in %s", version, installPath)
+	} else {
+		toolsSucc("InstallTrzsz", "trzsz %s installation to %s completed successfully", version, installPath)
+	}
 	checkTrzszPathEnv(client, version, installPath)
 }
+
+func execBatchInstallTrzsz(args *sshArgs) (int, bool) {
+	hosts := getBatchHosts(args)
+	checkBatchHosts(hosts)
+
+	var mutex sync.Mutex
+	version, versionErr := args.TrzszVersion, error(nil)
+	binaries := make(map[string][2][]byte)
+
+	results := runBatch("InstallTrzsz", args, hosts, func(hostArgs *sshArgs, client *ssh.Client, _ *batchResult) (string, error) {
+		httpClient, err := getTrzszHttpClient(hostArgs, client)
+		if err != nil {
+			return "", err
+		}
+
+		mutex.Lock()
+		if version == "" && versionErr == nil {
+			if version, versionErr = getLatestTrzszVersion(args, httpClient); versionErr != nil {
+				versionErr = fmt.Errorf("get latest trzsz version failed: %v, "+
+					"you can specify the version of trzsz through --trzsz-version", versionErr)
+			}
+		}
+		ver, err := version, versionErr
+		mutex.Unlock()
+		if err != nil {
+			return "", err
+		}
+
+		// the installation files are downloaded only once for each platform
+		installPath := getTrzszInstallPath(hostArgs)
+		installed, err := installTrzsz(client, ver, installPath, func(svrOS, arch string) ([]byte, []byte, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			key := svrOS + "_" + arch
+			if binary, ok := binaries[key]; ok {
+				return binary[0], binary[1], nil
+			}
+			trz, tsz, err := getTrzszBinary(hostArgs, httpClient, ver, svrOS, arch)
+			if err != nil {
+				return nil, nil, err
+			}
+			binaries[key] = [2][]byte{trz, tsz}
+			return trz, tsz, nil
+		})
+		if err != nil {
+			return "", err
+		}
+		message := fmt.Sprintf("trzsz %s installed to %s", ver, installPath)
+		if installed {
+			message = fmt.Sprintf("trzsz %s has been installed in %s", ver, installPath)
+		}
+		if isTrzszPathMissing(client, ver) {
+			message += fmt.Sprintf(", you may need to add %s to the PATH environment variable", installPath)
+		}
+		return message, nil
+	})
+
+	return summarizeBatchResults("InstallTrzsz", results), true
+}
@@ -0,0 +1,169 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTrzszPackage(t *testing.T, pkgName string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: pkgName, Typeflag: tar.TypeDir, Mode: 0755}))
+	for _, name := range []string{"trz", "tsz"} {
+		content := []byte(name + " binary")
+		assert.Nil(t, tw.WriteHeader(&tar.Header{Name: pkgName + "/" + name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(content))}))
+		_, err := tw.Write(content)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, tw.Close())
+	assert.Nil(t, gzw.Close())
+	return buf.Bytes()
+}
+
+func TestDownloadTrzszFromMirror(t *testing.T) {
+	assert := assert.New(t)
+	pkg := newTrzszPackage(t, "trzsz_1.0.0_linux_x86_64")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(pkg))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/mirror/v1.0.0/trzsz_1.0.0_checksums.txt":
+			fmt.Fprintf(w, "%s  trzsz_1.0.0_linux_aarch64.tar.gz\n%s  trzsz_1.0.0_linux_x86_64.tar.gz\n", checksum[1:]+"0", checksum)
+		case "/mirror/v1.0.0/trzsz_1.0.0_linux_x86_64.tar.gz", "/mirror/v1.0.0/trzsz_1.0.0_linux_aarch64.tar.gz":
+			_, _ = w.Write(pkg)
+		case "/mirror/latest":
+			fmt.Fprint(w, "v1.0.0\n")
+		case "/chunked":
+			// flushing before the end makes the response chunked without a content length
+			_, _ = w.Write(pkg[:10])
+			w.(http.Flusher).Flush()
+			_, _ = w.Write(pkg[10:])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	args := &sshArgs{Option: sshOption{map[string][]string{"extrzszmirror": {server.URL + "/mirror/"}}}}
+	releaseURL := getTrzszReleaseURL(args)
+	assert.Equal(server.URL+"/mirror", releaseURL)
+	httpClient, err := getTrzszHttpClient(args, nil)
+	assert.Nil(err)
+
+	trz, tsz, err := downloadTrzszBinary(httpClient, releaseURL, "1.0.0", "linux", "x86_64")
+	assert.Nil(err)
+	assert.Equal("trz binary", string(trz))
+	assert.Equal("tsz binary", string(tsz))
+
+	version, err := getLatestTrzszVersion(args, httpClient)
+	assert.Nil(err)
+	assert.Equal("1.0.0", version)
+	_, err = getMirrorTrzszVersion(httpClient, server.URL+"/not_exist/latest")
+	assert.ErrorContains(err, "http response status code 404")
+
+	data, err := downloadWithProgress(httpClient, "Test", server.URL+"/chunked")
+	assert.Nil(err)
+	assert.Equal(pkg, data)
+
+	_, _, err = downloadTrzszBinary(httpClient, releaseURL, "1.0.0", "linux", "aarch64")
+	assert.ErrorContains(err, "checksum mismatch")
+	_, _, err = downloadTrzszBinary(httpClient, releaseURL, "2.0.0", "linux", "x86_64")
+	assert.ErrorContains(err, "get checksum failed")
+
+	for _, proxy := range []string{"socks5://127.0.0.1:1080", "http://127.0.0.1:8080", "ssh"} {
+		_, err = getTrzszHttpClient(&sshArgs{Option: sshOption{map[string][]string{"extrzszdownloadproxy": {proxy}}}}, nil)
+		assert.Nil(err, proxy)
+	}
+	for _, proxy := range []string{"ftp://127.0.0.1", "127.0.0.1:1080"} {
+		_, err = getTrzszHttpClient(&sshArgs{Option: sshOption{map[string][]string{"extrzszdownloadproxy": {proxy}}}}, nil)
+		assert.NotNil(err, proxy)
+	}
+}
+
+func TestToolsProgressStop(t *testing.T) {
+	assert := assert.New(t)
+	progress := newToolsProgress("Test", "downloaded", -1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_, _ = progress.Write(make([]byte, 1024))
+		}
+	}()
+	progress.showProgress()
+	wg.Wait()
+	progress.stopProgress()
+
+	// the timer is not armed again after the progress is stopped
+	timer := progress.progressTimer
+	progress.showProgress()
+	assert.Equal(timer, progress.progressTimer)
+	assert.False(timer.Stop())
+	progress.stopProgress()
+}
+
+func TestToolsProgressDisabled(t *testing.T) {
+	assert := assert.New(t)
+	toolsProgressDisabled.Store(true)
+	defer toolsProgressDisabled.Store(false)
+	progress := newToolsProgress("Test", "upload percentage", 2048)
+	n, err := progress.Write(make([]byte, 1024))
+	assert.Nil(err)
+	assert.Equal(1024, n)
+	progress.addStep(1024)
+	progress.stopProgress()
+	assert.Nil(progress.progressTimer)
+}
+
+func TestTrzszCheckMode(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options map[string][]string) *sshArgs {
+		return &sshArgs{Destination: "test", Option: sshOption{options}}
+	}
+	assert.Equal("no", getTrzszCheckMode(newArgs(nil)))
+	assert.Equal("no", getTrzszCheckMode(newArgs(map[string][]string{"extrzszcheck": {"no"}})))
+	assert.Equal("hint", getTrzszCheckMode(newArgs(map[string][]string{"extrzszcheck": {"Hint"}})))
+	assert.Equal("ask", getTrzszCheckMode(newArgs(map[string][]string{"extrzszcheck": {"ask"}})))
+	assert.Equal("no", getTrzszCheckMode(newArgs(map[string][]string{"extrzszcheck": {"always"}})))
+	assert.Equal("no", getTrzszCheckMode(newArgs(map[string][]string{"extrzszcheck": {"hint"}, "enabletrzsz": {"no"}})))
+
+	// never prompt in BatchMode, but the hint is still shown
+	defer func(mode bool) { batchMode = mode }(batchMode)
+	batchMode = true
+	assert.Equal("no", getTrzszCheckMode(newArgs(map[string][]string{"extrzszcheck": {"ask"}})))
+	assert.Equal("hint", getTrzszCheckMode(newArgs(map[string][]string{"extrzszcheck": {"hint"}})))
+}
@@ -0,0 +1,99 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type listedHost struct {
+	Alias        string   `json:"alias"`
+	Host         string   `json:"host"`
+	Port         string   `json:"port"`
+	User         string   `json:"user,omitempty"`
+	ProxyJump    string   `json:"proxy_jump,omitempty"`
+	ProxyCommand string   `json:"proxy_command,omitempty"`
+	GroupLabels  []string `json:"group_labels,omitempty"`
+	Shortcut     string   `json:"shortcut,omitempty"`
+}
+
+func newListedHost(host *sshHost) *listedHost {
+	listed := &listedHost{
+		Alias:        host.Alias,
+		Host:         host.Host,
+		Port:         host.Port,
+		User:         host.User,
+		ProxyJump:    host.ProxyJump,
+		ProxyCommand: host.ProxyCommand,
+		GroupLabels:  strings.Fields(host.GroupLabels),
+		Shortcut:     host.Shortcut,
+	}
+	if listed.Host == "" {
+		listed.Host = host.Alias
+	}
+	return listed
+}
+
+func printHostsTable(writer io.Writer, hosts []*listedHost) {
+	w := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALIAS\tHOST\tUSER\tPROXY\tLABELS")
+	for _, host := range hosts {
+		proxy := host.ProxyJump
+		if proxy == "" && host.ProxyCommand != "" {
+			proxy = "ProxyCommand"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", host.Alias, joinHostPort(host.Host, host.Port),
+			host.User, proxy, strings.Join(host.GroupLabels, " "))
+	}
+	_ = w.Flush()
+}
+
+// execListHosts prints the configured hosts, filtered by the destination as a group label, an alias pattern or range.
+// Only the configs are read, there is no network or agent access and no prompt, so it works in a sandbox.
+func execListHosts(args *sshArgs) (int, bool) {
+	var hosts []*listedHost
+	for _, host := range getStatusHosts(getAllHosts(), args.Destination) {
+		hosts = append(hosts, newListedHost(host))
+	}
+	if args.JSON {
+		if hosts == nil {
+			hosts = []*listedHost{}
+		}
+		printToolsJSON(hosts)
+		return 0, true
+	}
+	if len(hosts) == 0 {
+		if args.Destination != "" {
+			toolsErrorExit("no configured host matches [%s]", args.Destination)
+		}
+		toolsErrorExit("no host is configured in %s", userConfig.configPath)
+	}
+	printHostsTable(os.Stdout, hosts)
+	return 0, true
+}
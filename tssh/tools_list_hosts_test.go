@@ -0,0 +1,72 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListHosts(t *testing.T) {
+	assert := assert.New(t)
+	defer func(config *tsshConfig) { userConfig = config }(userConfig)
+	config := filepath.Join(t.TempDir(), "config")
+	assert.Nil(os.WriteFile(config, []byte(`
+Host web1
+    HostName 10.0.0.1
+    User deploy
+    #!! GroupLabels prod web
+    #!! ExShortcut 1
+Host db1
+    HostName db1.internal
+    Port 2222
+    ProxyJump bastion
+    #!! GroupLabels prod
+Host bastion
+Host *.example.com
+    User admin
+`), 0600))
+	userConfig = &tsshConfig{configPath: config}
+
+	var hosts []*listedHost
+	for _, host := range getStatusHosts(getAllHosts(), "prod") {
+		hosts = append(hosts, newListedHost(host))
+	}
+	assert.Equal([]*listedHost{
+		{Alias: "web1", Host: "10.0.0.1", Port: "22", User: "deploy", GroupLabels: []string{"prod", "web"}, Shortcut: "1"},
+		{Alias: "db1", Host: "db1.internal", Port: "2222", ProxyJump: "bastion", GroupLabels: []string{"prod"}},
+	}, hosts)
+
+	var output bytes.Buffer
+	printHostsTable(&output, append(hosts, newListedHost(getAllHosts()[2])))
+	assert.Equal("ALIAS    HOST               USER    PROXY    LABELS\n"+
+		"web1     10.0.0.1:22        deploy           prod web\n"+
+		"db1      db1.internal:2222          bastion  prod\n"+
+		"bastion  bastion:22                          \n", output.String())
+}
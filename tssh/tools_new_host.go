@@ -25,15 +25,19 @@ SOFTWARE.
 package tssh
 
 import (
+	"bufio"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 	"unicode"
 
 	"github.com/trzsz/ssh_config"
+	"golang.org/x/crypto/ssh"
 )
 
 type newHostTool struct {
@@ -44,9 +48,139 @@ type newHostTool struct {
 	userName       string
 	password       string
 	existingConfig *ssh_config.Config
+	template       []string
+}
+
+// getIncludeConfigPaths returns the files included by the config recursively.
+func getIncludeConfigPaths(config *ssh_config.Config) []string {
+	var paths []string
+	for _, host := range config.Hosts {
+		for _, node := range host.Nodes {
+			include, ok := node.(*ssh_config.Include)
+			if !ok {
+				continue
+			}
+			files := include.GetFiles()
+			names := make([]string, 0, len(files))
+			for name := range files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				paths = append(paths, name)
+				paths = append(paths, getIncludeConfigPaths(files[name])...)
+			}
+		}
+	}
+	return paths
+}
+
+// splitTemplateOption splits the template option into the key and the value.
+// The option starts with !! is an extended option of tssh, e.g., !!GroupLabels web.
+func splitTemplateOption(option string) (key, value string, extended bool) {
+	option = strings.TrimSpace(option)
+	if strings.HasPrefix(option, "!!") {
+		extended = true
+		option = strings.TrimSpace(option[2:])
+	}
+	idx := strings.IndexAny(option, " \t=")
+	if idx < 0 {
+		return option, "", extended
+	}
+	return option[:idx], strings.TrimSpace(strings.TrimLeft(option[idx:], " \t=")), extended
+}
+
+// getTemplateValue returns the value of the key in the template of the new host.
+func (n *newHostTool) getTemplateValue(key string) string {
+	for _, option := range n.template {
+		if k, value, _ := splitTemplateOption(option); strings.EqualFold(k, key) {
+			return value
+		}
+	}
+	return ""
+}
+
+// isBehindProxy returns whether the new host can only be reached through a jump host.
+func (n *newHostTool) isBehindProxy() bool {
+	return n.getTemplateValue("ProxyJump") != "" || n.getTemplateValue("ProxyCommand") != ""
+}
+
+// checkSshServer checks whether the ssh server is listening on the address, by reading its banner.
+func checkSshServer(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return fmt.Errorf("connect to server failed: %v", err)
+	}
+	return readSshBanner(conn, timeout)
+}
+
+// checkSshServerViaProxy checks the ssh server through the ProxyJump or the ProxyCommand of the template.
+func (n *newHostTool) checkSshServerViaProxy(port string, timeout time.Duration) error {
+	addr := joinHostPort(n.hostName, port)
+	if command := n.getTemplateValue("ProxyCommand"); command != "" {
+		args := &sshArgs{Destination: n.hostAlias}
+		param := &sshParam{host: n.hostName, port: port, user: n.getTemplateValue("User"), addr: addr, command: command}
+		conn, cmd, err := execProxyCommand(args, param)
+		if err != nil {
+			return fmt.Errorf("exec proxy command [%s] failed: %v", cmd, err)
+		}
+		return readSshBanner(conn, timeout)
+	}
+
+	transport, proxies, err := splitProxyTransport(strings.Split(n.getTemplateValue("ProxyJump"), ","))
+	if err != nil {
+		return err
+	}
+	var conn net.Conn
+	if len(proxies) == 0 {
+		conn, err = dialProxyTransport(transport, addr, timeout)
+	} else {
+		var client *ssh.Client
+		if client, err = connectJumpHosts(transport, proxies); err != nil {
+			return fmt.Errorf("connect to jump host failed: %v", err)
+		}
+		conn, err = dialWithTimeout(client, "tcp", addr, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to server through the proxy failed: %v", err)
+	}
+	return readSshBanner(conn, timeout)
+}
+
+// readSshBanner reads the ssh banner from the connection, and closes it.
+func readSshBanner(conn net.Conn, timeout time.Duration) error {
+	defer conn.Close()
+	// the proxy command pipe doesn't support the read deadline
+	defer time.AfterFunc(timeout, func() { conn.Close() }).Stop()
+	reader := bufio.NewReader(conn)
+	// the server may send other lines before the version line
+	for i := 0; i < 10; i++ {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "SSH-") {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read ssh banner failed: %v", err)
+		}
+	}
+	return fmt.Errorf("not a ssh server")
 }
 
 func (n *newHostTool) promptConfigPath() {
+	// choose the config file to write to if the main config includes other files
+	if file, err := os.Open(userConfig.configPath); err == nil {
+		config, err := ssh_config.Decode(file)
+		file.Close()
+		if err == nil {
+			if includes := getIncludeConfigPaths(config); len(includes) > 0 {
+				n.configPath = promptList("ConfigPath", getText("newhost/include"),
+					append([]string{userConfig.configPath}, includes...))
+				n.existingConfig = config
+				return
+			}
+		}
+	}
+
 	n.configPath = promptTextInput("ConfigPath", userConfig.configPath, getText("newhost/config"),
 		&inputValidator{func(path string) error {
 			if path == "" {
@@ -78,6 +212,31 @@ func (n *newHostTool) promptConfigPath() {
 		}})
 }
 
+// promptTemplate chooses a template to apply its options to the new host.
+// The templates are defined in ~/.tssh.conf, one option per line, e.g.:
+//
+//	HostTemplate.web = User deploy
+//	HostTemplate.web = ProxyJump bastion
+//	HostTemplate.web = !!GroupLabels web prod
+func (n *newHostTool) promptTemplate() {
+	if len(userConfig.hostTemplates) == 0 {
+		return
+	}
+	names := make([]string, 0, len(userConfig.hostTemplates))
+	for name := range userConfig.hostTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := []string{"none"}
+	templates := make(map[string][]string, len(names))
+	for _, name := range names {
+		item := fmt.Sprintf("%s: %s", name, strings.Join(userConfig.hostTemplates[name], ", "))
+		items = append(items, item)
+		templates[item] = userConfig.hostTemplates[name]
+	}
+	n.template = templates[promptList("Template", getText("newhost/template"), items)]
+}
+
 func (n *newHostTool) promptHostAlias() {
 	n.hostAlias = promptTextInput("HostAlias", "", getText("newhost/alias"),
 		&inputValidator{func(alias string) error {
@@ -109,6 +268,10 @@ func (n *newHostTool) promptHostName() {
 			if name == "" {
 				return fmt.Errorf("empty host name")
 			}
+			// the host name may be resolved by the jump host only
+			if n.isBehindProxy() {
+				return nil
+			}
 			if _, err := net.LookupHost(name); err != nil {
 				return fmt.Errorf("lookup host failed: %v", err)
 			}
@@ -117,7 +280,11 @@ func (n *newHostTool) promptHostName() {
 }
 
 func (n *newHostTool) promptHostPort() {
-	port, _ := strconv.ParseUint(promptTextInput("HostPort", "22", getText("newhost/port"),
+	defaultPort := n.getTemplateValue("Port")
+	if defaultPort == "" {
+		defaultPort = "22"
+	}
+	port, _ := strconv.ParseUint(promptTextInput("HostPort", defaultPort, getText("newhost/port"),
 		&inputValidator{func(port string) error {
 			if port == "" {
 				return fmt.Errorf("empty host port")
@@ -125,18 +292,16 @@ func (n *newHostTool) promptHostPort() {
 			if _, err := strconv.ParseUint(port, 10, 16); err != nil {
 				return fmt.Errorf("invalid host port: %v", err)
 			}
-			conn, err := net.DialTimeout("tcp", joinHostPort(n.hostName, port), 3*time.Second)
-			if err != nil {
-				return fmt.Errorf("connect to server failed: %v", err)
+			if n.isBehindProxy() {
+				return n.checkSshServerViaProxy(port, 3*time.Second)
 			}
-			defer conn.Close()
-			return nil
+			return checkSshServer(joinHostPort(n.hostName, port), 3*time.Second)
 		}}), 10, 16)
 	n.hostPort = uint16(port)
 }
 
 func (n *newHostTool) promptUserName() {
-	n.userName = promptTextInput("UserName", "", getText("newhost/user"),
+	n.userName = promptTextInput("UserName", n.getTemplateValue("User"), getText("newhost/user"),
 		&inputValidator{func(name string) error {
 			if name == "" {
 				return fmt.Errorf("empty user name")
@@ -162,10 +327,28 @@ func (n *newHostTool) writeHost() {
 Host %s
     HostName %s
     Port %d
-    User %s
-`, n.hostAlias, n.hostName, n.hostPort, n.userName)); err != nil {
+`, n.hostAlias, n.hostName, n.hostPort)); err != nil {
 		toolsErrorExit("write config file failed: %v", err)
 	}
+	if n.userName != "" {
+		if _, err := file.WriteString(fmt.Sprintf("    User %s\n", n.userName)); err != nil {
+			toolsErrorExit("write config file failed: %v", err)
+		}
+	}
+	for _, option := range n.template {
+		key, value, extended := splitTemplateOption(option)
+		switch strings.ToLower(key) {
+		case "hostname", "port", "user":
+			continue
+		}
+		line := fmt.Sprintf("    %s %s\n", key, value)
+		if extended {
+			line = fmt.Sprintf("    #!! %s %s\n", key, value)
+		}
+		if _, err := file.WriteString(line); err != nil {
+			toolsErrorExit("write config file failed: %v", err)
+		}
+	}
 	if n.password != "" {
 		secret, err := encodeSecret([]byte(n.password))
 		if err != nil {
@@ -192,6 +375,8 @@ func execNewHost(args *sshArgs) (int, bool) {
 
 	n.promptConfigPath()
 
+	n.promptTemplate()
+
 	n.promptHostAlias()
 
 	n.promptHostName()
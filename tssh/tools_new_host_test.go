@@ -0,0 +1,113 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trzsz/ssh_config"
+)
+
+func TestGetIncludeConfigPaths(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	web := filepath.Join(dir, "web.conf")
+	db := filepath.Join(dir, "db.conf")
+	nested := filepath.Join(dir, "nested.conf")
+	assert.Nil(os.WriteFile(web, []byte("Host web\n    HostName 10.0.0.1\nInclude "+nested+"\n"), 0600))
+	assert.Nil(os.WriteFile(db, []byte("Host db\n    HostName 10.0.0.2\n"), 0600))
+	assert.Nil(os.WriteFile(nested, []byte("Host nested\n    HostName 10.0.0.3\n"), 0600))
+
+	config, err := ssh_config.Decode(strings.NewReader("Include " + filepath.Join(dir, "*b.conf") + "\n"))
+	assert.Nil(err)
+	assert.Equal([]string{db, web, nested}, getIncludeConfigPaths(config))
+
+	config, err = ssh_config.Decode(strings.NewReader("Host x\n    HostName 10.0.0.4\n"))
+	assert.Nil(err)
+	assert.Empty(getIncludeConfigPaths(config))
+}
+
+func TestHostTemplate(t *testing.T) {
+	assert := assert.New(t)
+	assertOption := func(option, expectedKey, expectedValue string, expectedExtended bool) {
+		t.Helper()
+		key, value, extended := splitTemplateOption(option)
+		assert.Equal(expectedKey, key)
+		assert.Equal(expectedValue, value)
+		assert.Equal(expectedExtended, extended)
+	}
+	assertOption("User deploy", "User", "deploy", false)
+	assertOption(" ProxyJump=bastion ", "ProxyJump", "bastion", false)
+	assertOption("!!GroupLabels web prod", "GroupLabels", "web prod", true)
+	assertOption("!! EnableTrzsz = no", "EnableTrzsz", "no", true)
+	assertOption("ForwardAgent", "ForwardAgent", "", false)
+
+	n := &newHostTool{template: []string{"user deploy", "!!GroupLabels web"}}
+	assert.Equal("deploy", n.getTemplateValue("User"))
+	assert.Equal("web", n.getTemplateValue("GroupLabels"))
+	assert.Equal("", n.getTemplateValue("Port"))
+	assert.False(n.isBehindProxy())
+	n.template = append(n.template, "ProxyJump bastion")
+	assert.True(n.isBehindProxy())
+}
+
+func TestCheckSshServer(t *testing.T) {
+	assert := assert.New(t)
+	serve := func(banner string) string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(err)
+		t.Cleanup(func() { listener.Close() })
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_, _ = conn.Write([]byte(banner))
+		}()
+		return listener.Addr().String()
+	}
+
+	assert.Nil(checkSshServer(serve("SSH-2.0-OpenSSH_9.6\r\n"), time.Second))
+	assert.Nil(checkSshServer(serve("welcome\r\nSSH-2.0-OpenSSH_9.6\r\n"), time.Second))
+	assert.NotNil(checkSshServer(serve("HTTP/1.1 400 Bad Request\r\n"), time.Second))
+
+	// the host behind the proxy is checked through the proxy command
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+	n := &newHostTool{hostName: "10.0.0.1"}
+	n.template = []string{`ProxyCommand sh -c "printf 'SSH-2.0-%h:%p\\r\\n'; cat"`}
+	assert.Nil(n.checkSshServerViaProxy("22", time.Second))
+	n.template = []string{`ProxyCommand sh -c "echo %h:%p; cat"`}
+	assert.NotNil(n.checkSshServerViaProxy("22", 100*time.Millisecond))
+}
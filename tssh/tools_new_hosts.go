@@ -0,0 +1,186 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const kMaxNewHosts = 1024
+
+// getNewHostsAddresses returns the addresses in the CIDR, e.g., 10.1.2.0/28,
+// or in the range, e.g., 10.1.2.10-10.1.2.20, or the single address.
+// The network and broadcast addresses of an IPv4 CIDR are excluded.
+func getNewHostsAddresses(spec string) ([]string, error) {
+	var first, last netip.Addr
+	skipEdges := false
+	if strings.Contains(spec, "/") {
+		prefix, err := netip.ParsePrefix(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR [%s]: %v", spec, err)
+		}
+		prefix = prefix.Masked()
+		first = prefix.Addr()
+		last = first
+		for next := last.Next(); next.IsValid() && prefix.Contains(next); next = next.Next() {
+			last = next
+		}
+		skipEdges = first.Is4() && prefix.Bits() < 31
+	} else if begin, end, ok := strings.Cut(spec, "-"); ok {
+		var err error
+		if first, err = netip.ParseAddr(strings.TrimSpace(begin)); err != nil {
+			return nil, fmt.Errorf("invalid range [%s]: %v", spec, err)
+		}
+		if last, err = netip.ParseAddr(strings.TrimSpace(end)); err != nil {
+			return nil, fmt.Errorf("invalid range [%s]: %v", spec, err)
+		}
+		if first.BitLen() != last.BitLen() || last.Less(first) {
+			return nil, fmt.Errorf("invalid range [%s]: the end is before the beginning", spec)
+		}
+	} else {
+		addr, err := netip.ParseAddr(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address [%s]: %v", spec, err)
+		}
+		first, last = addr, addr
+	}
+
+	if skipEdges {
+		first, last = first.Next(), last.Prev()
+	}
+	var addrs []string
+	for addr := first; addr.IsValid() && !last.Less(addr); addr = addr.Next() {
+		if len(addrs) >= kMaxNewHosts {
+			return nil, fmt.Errorf("too many addresses in [%s], the limit is %d", spec, kMaxNewHosts)
+		}
+		addrs = append(addrs, addr.String())
+	}
+	return addrs, nil
+}
+
+// formatNewHostAlias formats the alias pattern, e.g., web%d or web%02d, with the 1-based index.
+func formatNewHostAlias(pattern string, index int) (string, error) {
+	if strings.Count(pattern, "%")-2*strings.Count(pattern, "%%") != 1 {
+		return "", fmt.Errorf("the alias pattern [%s] should contain one %%d", pattern)
+	}
+	alias := fmt.Sprintf(pattern, index)
+	if strings.Contains(alias, "%!") || strings.ContainsAny(alias, " \t") {
+		return "", fmt.Errorf("invalid alias pattern [%s]: %s", pattern, alias)
+	}
+	return alias, nil
+}
+
+type newHostsResult struct {
+	alias string
+	addr  string
+	err   error
+}
+
+// checkNewHosts checks whether the ssh servers are reachable in parallel, reports each as soon as it's done.
+func checkNewHosts(results []*newHostsResult, port uint16, parallel int, timeout time.Duration) {
+	var printMutex sync.Mutex
+	runInParallel(len(results), parallel, func(i int) {
+		result := results[i]
+		result.err = checkSshServer(joinHostPort(result.addr, strconv.Itoa(int(port))), timeout)
+
+		printMutex.Lock()
+		defer printMutex.Unlock()
+		if result.err != nil {
+			toolsWarn("NewHosts", "%s (%s): unreachable: %v", result.alias, result.addr, result.err)
+		} else {
+			toolsSucc("NewHosts", "%s (%s): reachable", result.alias, result.addr)
+		}
+	})
+}
+
+// execNewHosts adds the hosts named by the pattern for the reachable addresses, e.g.,
+//
+//	tssh --new-hosts 'web%d' 10.1.2.0/28 --user deploy
+func execNewHosts(args *sshArgs) (int, bool) {
+	if args.Destination == "" {
+		toolsErrorExit("usage: tssh --new-hosts 'web%%d' <cidr | begin-end | address> [--user name] [-p port]")
+	}
+	addrs, err := getNewHostsAddresses(args.Destination)
+	if err != nil {
+		toolsErrorExit("%v", err)
+	}
+
+	port := uint16(22)
+	if args.Port > 0 {
+		port = uint16(args.Port)
+	}
+	user := args.HostUser
+	if user == "" {
+		user = args.LoginName
+	}
+
+	var results []*newHostsResult
+	for i, addr := range addrs {
+		alias, err := formatNewHostAlias(args.NewHosts, i+1)
+		if err != nil {
+			toolsErrorExit("%v", err)
+		}
+		if hasConfig(alias, "HostName") {
+			toolsWarn("NewHosts", "%s (%s): skipped since the host alias already exists", alias, addr)
+			continue
+		}
+		results = append(results, &newHostsResult{alias: alias, addr: addr})
+	}
+	toolsInfo("NewHosts", "checking %d addresses on port %d", len(results), port)
+	checkNewHosts(results, port, getBatchParallel(args), 3*time.Second)
+
+	var added []string
+	for _, result := range results {
+		if result.err != nil {
+			continue
+		}
+		n := &newHostTool{
+			configPath: userConfig.configPath,
+			hostAlias:  result.alias,
+			hostName:   result.addr,
+			hostPort:   port,
+			userName:   user,
+		}
+		n.writeHost()
+		added = append(added, result.alias)
+	}
+
+	if len(added) == 0 {
+		toolsWarn("NewHosts", "no reachable host is added to %s", userConfig.configPath)
+		return 1, true
+	}
+	toolsSucc("NewHosts", "%d of %d hosts are added to %s: %s", len(added), len(addrs),
+		userConfig.configPath, strings.Join(added, " "))
+	if len(added) < len(addrs) {
+		fmt.Fprintf(os.Stderr, "run the same command again to add the skipped hosts after they are reachable\r\n")
+	}
+	return 0, true
+}
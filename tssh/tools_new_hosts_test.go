@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNewHostsAddresses(t *testing.T) {
+	assert := assert.New(t)
+	assertAddrs := func(spec string, expected ...string) {
+		t.Helper()
+		addrs, err := getNewHostsAddresses(spec)
+		assert.Nil(err)
+		assert.Equal(expected, addrs)
+	}
+	assertAddrs("10.1.2.0/30", "10.1.2.1", "10.1.2.2")
+	assertAddrs("10.1.2.5/30", "10.1.2.5", "10.1.2.6")
+	assertAddrs("10.1.2.0/31", "10.1.2.0", "10.1.2.1")
+	assertAddrs("10.1.2.3/32", "10.1.2.3")
+	assertAddrs("10.1.2.254-10.1.3.1", "10.1.2.254", "10.1.2.255", "10.1.3.0", "10.1.3.1")
+	assertAddrs("10.1.2.3", "10.1.2.3")
+	assertAddrs("fd00::/127", "fd00::", "fd00::1")
+
+	addrs, err := getNewHostsAddresses("10.1.2.0/28")
+	assert.Nil(err)
+	assert.Len(addrs, 14)
+	assert.Equal("10.1.2.14", addrs[13])
+
+	for _, spec := range []string{"10.1.2.0/33", "10.1.2.5-10.1.2.1", "10.1.2.1-fd00::1", "web", "10.0.0.0/8"} {
+		_, err := getNewHostsAddresses(spec)
+		assert.NotNil(err, spec)
+	}
+}
+
+func TestFormatNewHostAlias(t *testing.T) {
+	assert := assert.New(t)
+	alias, err := formatNewHostAlias("web%d", 3)
+	assert.Nil(err)
+	assert.Equal("web3", alias)
+	alias, err = formatNewHostAlias("100%%-web%02d", 3)
+	assert.Nil(err)
+	assert.Equal("100%-web03", alias)
+
+	for _, pattern := range []string{"web", "web%d-%d", "web%s", "web %d"} {
+		_, err := formatNewHostAlias(pattern, 1)
+		assert.NotNil(err, pattern)
+	}
+}
+
+func TestCheckNewHosts(t *testing.T) {
+	assert := assert.New(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	results := []*newHostsResult{{alias: "lo1", addr: "127.0.0.1"}, {alias: "lo2", addr: "127.0.0.2"}}
+	checkNewHosts(results, uint16(port), 2, time.Second)
+	assert.Nil(results[0].err)
+	assert.NotNil(results[1].err)
+}
@@ -0,0 +1,124 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// getScriptInterpreter returns the interpreter in the shebang line of the script, or sh by default.
+func getScriptInterpreter(script []byte) string {
+	if !bytes.HasPrefix(script, []byte("#!")) {
+		return "sh"
+	}
+	line := script[2:]
+	if idx := bytes.IndexByte(line, '\n'); idx >= 0 {
+		line = line[:idx]
+	}
+	if interpreter := strings.TrimSpace(string(line)); interpreter != "" {
+		return interpreter
+	}
+	return "sh"
+}
+
+// getScriptCommand returns the remote command which saves the script from stdin to a temporary file,
+// and runs it by the interpreter, so that it works even if the temporary directory is mounted noexec.
+// The command is run by sh, as the login shell of the user may be fish or csh.
+func getScriptCommand(script []byte, scriptArgs []string, sudo bool) string {
+	run := getScriptInterpreter(script) + ` "$f"`
+	if sudo {
+		run = "sudo -n " + run
+	}
+	for _, arg := range scriptArgs {
+		run += " " + shellQuote(arg)
+	}
+	return "exec sh -c " + shellQuote(`f=$(mktemp "${TMPDIR:-/tmp}/tssh-script.XXXXXX") || exit 1; `+
+		`if cat > "$f"; then `+run+`; r=$?; else r=1; fi; rm -f "$f"; exit $r`)
+}
+
+func runScript(client *ssh.Client, command string, script []byte, result *batchResult) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("new session failed: %v", err)
+	}
+	defer session.Close()
+	var stdout, stderr bytes.Buffer
+	session.Stdin = bytes.NewReader(script)
+	session.Stdout, session.Stderr = &stdout, &stderr
+
+	err = session.Run(command)
+	result.stdout, result.stderr = stdout.Bytes(), stderr.Bytes()
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		result.exitCode = exitErr.ExitStatus()
+		return "", fmt.Errorf("exit status %d", result.exitCode)
+	}
+	if err != nil {
+		result.exitCode = -1
+		return "", fmt.Errorf("run script failed: %v", err)
+	}
+	return "exit status 0", nil
+}
+
+func printScriptOutput(result *batchResult) {
+	if result.stdout == nil && result.stderr == nil {
+		return
+	}
+	fmt.Fprintf(os.Stdout, "==> %s (exit status %d) <==\n", result.host, result.exitCode)
+	_, _ = os.Stdout.Write(result.stdout)
+	if len(result.stdout) > 0 && !bytes.HasSuffix(result.stdout, []byte("\n")) {
+		fmt.Fprintln(os.Stdout)
+	}
+	_, _ = os.Stderr.Write(result.stderr)
+}
+
+// execScript runs the local script on the hosts in parallel, e.g., tssh --script deploy.sh host1 host2
+func execScript(args *sshArgs) (int, bool) {
+	script, err := os.ReadFile(resolvePath(args.Script))
+	if err != nil {
+		toolsErrorExit("read script failed: %v", err)
+	}
+	hosts := getBatchHosts(args)
+	checkBatchHosts(hosts)
+
+	command := getScriptCommand(script, args.ScriptArg.values, args.Sudo)
+	debug("script command: %s", command)
+	results := runBatch("Script", args, hosts, func(hostArgs *sshArgs, client *ssh.Client, result *batchResult) (string, error) {
+		return runScript(client, command, script, result)
+	})
+
+	if !args.JSON {
+		for _, result := range results {
+			printScriptOutput(result)
+		}
+	}
+	return summarizeBatchResults("Script", results), true
+}
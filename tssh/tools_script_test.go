@@ -0,0 +1,76 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptCommand(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("sh", getScriptInterpreter([]byte("echo hi\n")))
+	assert.Equal("sh", getScriptInterpreter([]byte("#!\necho hi\n")))
+	assert.Equal("/bin/bash -e", getScriptInterpreter([]byte("#!/bin/bash -e\necho hi\n")))
+	assert.Equal("/usr/bin/env python3", getScriptInterpreter([]byte("#! /usr/bin/env python3")))
+
+	assert.Contains(getScriptCommand([]byte("echo hi"), nil, true), `sudo -n sh "$f"`)
+	assert.True(strings.HasPrefix(getScriptCommand([]byte("echo hi"), nil, false), "exec sh -c '"))
+
+	runCommand := func(script string, args ...string) (string, int) {
+		t.Helper()
+		tmpDir := t.TempDir()
+		cmd := exec.Command("sh", "-c", getScriptCommand([]byte(script), args, false))
+		cmd.Env = append(cmd.Environ(), "TMPDIR="+tmpDir)
+		cmd.Stdin = bytes.NewReader([]byte(script))
+		output, err := cmd.Output()
+		entries, _ := os.ReadDir(tmpDir)
+		assert.Empty(entries)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return string(output), exitErr.ExitCode()
+		}
+		assert.Nil(err)
+		return string(output), 0
+	}
+
+	output, code := runCommand("#!/bin/sh\nprintf '%s|' \"$@\"\n", "a b", "c'd", "")
+	assert.Equal("a b|c'd||", output)
+	assert.Equal(0, code)
+
+	output, code = runCommand("echo before\nexit 3\necho after\n")
+	assert.Equal("before\n", output)
+	assert.Equal(3, code)
+
+	output, code = runCommand("#!/bin/sh -e\nfalse\necho unreachable\n")
+	assert.Equal("", output)
+	assert.Equal(1, code)
+}
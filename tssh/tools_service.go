@@ -0,0 +1,64 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"strings"
+)
+
+func getServiceName(name string) string {
+	return "tssh-tunnel-" + name
+}
+
+// execServiceTool installs or uninstalls the named tunnel in ~/.tssh.conf as a service,
+// which runs `tssh --tunnel run <name>` after reboots, even if the user is not logged in.
+func execServiceTool(args *sshArgs) (int, bool) {
+	if args.InstallService != "" {
+		name := strings.ToLower(args.InstallService)
+		if _, err := getTunnelArgs(name); err != nil {
+			toolsErrorExit("%v", err)
+		}
+		if err := os.MkdirAll(getTunnelDir(), 0700); err != nil {
+			toolsErrorExit("create tunnel directory failed: %v", err)
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			toolsErrorExit("get executable failed: %v", err)
+		}
+		if err := installService(name, exe); err != nil {
+			toolsErrorExit("install service [%s] failed: %v", getServiceName(name), err)
+		}
+		toolsSucc("Service", "service [%s] installed and started", getServiceName(name))
+		return 0, true
+	}
+
+	name := strings.ToLower(args.UninstallSvc)
+	if err := uninstallService(name); err != nil {
+		toolsErrorExit("uninstall service [%s] failed: %v", getServiceName(name), err)
+	}
+	toolsSucc("Service", "service [%s] uninstalled", getServiceName(name))
+	return 0, true
+}
@@ -0,0 +1,199 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/trzsz/ssh_config"
+)
+
+const kStatusTimeout = 5 * time.Second
+
+type hostStatus struct {
+	Alias     string `json:"alias"`
+	Host      string `json:"host"`
+	Port      string `json:"port"`
+	Reachable bool   `json:"reachable"`
+	LatencyMs int64  `json:"latency_ms"`
+	Uptime    string `json:"uptime,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// getStatusHosts returns the configured hosts with the group label, or matched by the alias pattern or range.
+func getStatusHosts(hosts []*sshHost, filter string) []*sshHost {
+	if filter == "" {
+		return hosts
+	}
+	var pattern *ssh_config.Pattern
+	if strings.ContainsAny(filter, "*?") {
+		pattern, _ = ssh_config.NewPattern(filter)
+	}
+	expanded, _ := expandHostRange(filter)
+	var matched []*sshHost
+	for _, host := range hosts {
+		if hasGroupLabel(host, []string{filter}) ||
+			pattern != nil && pattern.Regex().MatchString(host.Alias) ||
+			matchHostRange(host.Alias, "", expanded) {
+			matched = append(matched, host)
+		}
+	}
+	return matched
+}
+
+// probeHostStatus checks the host by the level: tcp checks the ssh banner, auth logs in, uptime runs uptime after login.
+// The latency is the time of the ssh banner check, or the time of the login if the host is behind a proxy.
+func probeHostStatus(args *sshArgs, host *sshHost, level string) *hostStatus {
+	status := &hostStatus{Alias: host.Alias, Host: host.Host, Port: host.Port}
+	if status.Host == "" {
+		status.Host = host.Alias
+	}
+
+	proxied := host.ProxyJump != "" || host.ProxyCommand != ""
+	if !proxied {
+		// checked concurrently without the login mutex, so the latency doesn't include the queueing time
+		beginTime := time.Now()
+		err := checkSshServer(joinHostPort(status.Host, status.Port), kStatusTimeout)
+		status.LatencyMs = time.Since(beginTime).Milliseconds()
+		if err != nil {
+			status.Error = err.Error()
+			return status
+		}
+	}
+	if level == "tcp" {
+		if proxied {
+			status.Error = "behind a proxy, use --status-check auth instead"
+			return status
+		}
+		status.Reachable = true
+		return status
+	}
+
+	client, latency, err := loginBatchHost(newBatchArgs(args, host.Alias))
+	if proxied {
+		status.LatencyMs = latency.Milliseconds()
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer client.Close()
+	status.Reachable = true
+	if level == "uptime" {
+		output, err := runBootstrapCommand(client, "uptime", nil)
+		if err != nil {
+			status.Error = fmt.Sprintf("run uptime failed: %v", err)
+			return status
+		}
+		status.Uptime = strings.TrimSpace(string(output))
+	}
+	return status
+}
+
+func printStatusTable(writer io.Writer, results []*hostStatus, uptime bool) {
+	rows := [][]string{{"ALIAS", "HOST", "STATUS", "LATENCY"}}
+	if uptime {
+		rows[0] = append(rows[0], "UPTIME")
+	}
+	for _, result := range results {
+		state, detail := "up", result.Uptime
+		if !result.Reachable {
+			state, detail = "down", result.Error
+		} else if result.Error != "" {
+			detail = result.Error
+		}
+		row := []string{result.Alias, joinHostPort(result.Host, result.Port), state, fmt.Sprintf("%dms", result.LatencyMs)}
+		if uptime || !result.Reachable {
+			row = append(row, detail)
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, 4)
+	for _, row := range rows {
+		for i := 0; i < len(widths); i++ {
+			if len(row[i]) > widths[i] {
+				widths[i] = len(row[i])
+			}
+		}
+	}
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			if i < len(widths) && i < len(row)-1 {
+				fmt.Fprintf(&line, "%-*s  ", widths[i], cell)
+			} else {
+				line.WriteString(cell)
+			}
+		}
+		fmt.Fprintf(writer, "%s\r\n", strings.TrimRight(line.String(), " "))
+	}
+}
+
+// execStatus probes the configured hosts concurrently, e.g., tssh --status web
+func execStatus(args *sshArgs) (int, bool) {
+	level := strings.ToLower(args.StatusCheck)
+	switch level {
+	case "":
+		level = "tcp"
+	case "tcp", "auth", "uptime":
+	default:
+		toolsErrorExit("unknown --status-check [%s], should be tcp, auth or uptime", args.StatusCheck)
+	}
+
+	hosts := getStatusHosts(getAllHosts(), args.Destination)
+	if len(hosts) == 0 {
+		toolsErrorExit("no configured host matches [%s]", args.Destination)
+	}
+	toolsInfo("Status", "probing %d hosts by %s", len(hosts), level)
+
+	results := make([]*hostStatus, len(hosts))
+	runInParallel(len(hosts), getBatchParallel(args), func(i int) {
+		results[i] = probeHostStatus(args, hosts[i], level)
+	})
+
+	if args.JSON {
+		printToolsJSON(results)
+	} else {
+		printStatusTable(os.Stdout, results, level == "uptime")
+	}
+
+	down := 0
+	for _, result := range results {
+		if !result.Reachable {
+			down++
+		}
+	}
+	if down > 0 {
+		toolsWarn("Status", "%d of %d hosts are down", down, len(results))
+		return 1, true
+	}
+	toolsSucc("Status", "all %d hosts are up", len(results))
+	return 0, true
+}
@@ -0,0 +1,117 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGetStatusHosts(t *testing.T) {
+	assert := assert.New(t)
+	hosts := []*sshHost{
+		{Alias: "web01", GroupLabels: "web prod"},
+		{Alias: "web02", GroupLabels: "web"},
+		{Alias: "db01", GroupLabels: "db prod"},
+	}
+	aliases := func(hosts []*sshHost) []string {
+		var result []string
+		for _, host := range hosts {
+			result = append(result, host.Alias)
+		}
+		return result
+	}
+	assert.Equal([]string{"web01", "web02", "db01"}, aliases(getStatusHosts(hosts, "")))
+	assert.Equal([]string{"web01", "db01"}, aliases(getStatusHosts(hosts, "PROD")))
+	assert.Equal([]string{"web01", "db01"}, aliases(getStatusHosts(hosts, "*01")))
+	assert.Equal([]string{"web02"}, aliases(getStatusHosts(hosts, "web{02..03}")))
+	assert.Empty(getStatusHosts(hosts, "web0"))
+}
+
+func TestProbeHostStatus(t *testing.T) {
+	assert := assert.New(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	status := probeHostStatus(&sshArgs{}, &sshHost{Alias: "up", Host: "127.0.0.1", Port: port}, "tcp")
+	assert.True(status.Reachable)
+	assert.Empty(status.Error)
+
+	status = probeHostStatus(&sshArgs{}, &sshHost{Alias: "jump", Host: "10.0.0.1", Port: "22", ProxyJump: "bastion"}, "tcp")
+	assert.False(status.Reachable)
+	assert.Contains(status.Error, "--status-check auth")
+
+	// the latency doesn't include the time waiting for the login mutex
+	defer func(login func(*sshArgs) (*ssh.Client, error)) { batchLogin = login }(batchLogin)
+	batchLogin = func(args *sshArgs) (*ssh.Client, error) {
+		if args.Destination == "jump" {
+			return nil, fmt.Errorf("login to jump failed")
+		}
+		return newFakeSshClient(), nil
+	}
+	batchLoginMutex.Lock()
+	done := make(chan *hostStatus, 2)
+	go func() {
+		done <- probeHostStatus(&sshArgs{}, &sshHost{Alias: "up", Host: "127.0.0.1", Port: port}, "auth")
+	}()
+	go func() {
+		done <- probeHostStatus(&sshArgs{}, &sshHost{Alias: "jump", Host: "10.0.0.1", Port: "22", ProxyJump: "bastion"}, "auth")
+	}()
+	time.Sleep(300 * time.Millisecond)
+	batchLoginMutex.Unlock()
+	for i := 0; i < 2; i++ {
+		status := <-done
+		assert.Less(status.LatencyMs, int64(300))
+		assert.Equal(status.Alias == "up", status.Reachable)
+	}
+
+	var output bytes.Buffer
+	printStatusTable(&output, []*hostStatus{
+		{Alias: "web01", Host: "10.0.0.1", Port: "22", Reachable: true, LatencyMs: 12},
+		{Alias: "db", Host: "10.0.0.2", Port: "2222", LatencyMs: 5000, Error: "timeout"},
+	}, false)
+	assert.Equal("ALIAS  HOST           STATUS  LATENCY\r\n"+
+		"web01  10.0.0.1:22    up      12ms\r\n"+
+		"db     10.0.0.2:2222  down    5000ms   timeout\r\n", output.String())
+}
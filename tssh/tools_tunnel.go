@@ -0,0 +1,414 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/trzsz/go-arg"
+)
+
+const (
+	kTunnelCheckInterval   = 30 * time.Second
+	kTunnelCheckFailures   = 3
+	kTunnelMaxRestartDelay = 60 * time.Second
+)
+
+type tunnelState struct {
+	Pid      int       `json:"pid"`
+	ChildPid int       `json:"child_pid,omitempty"`
+	State    string    `json:"state"`
+	Since    time.Time `json:"since"`
+	Restarts int       `json:"restarts"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func getTunnelDir() string {
+	return filepath.Join(userHomeDir, ".ssh", "tssh-tunnel")
+}
+
+func getTunnelNames() []string {
+	names := make([]string, 0, len(userConfig.tunnels))
+	for name := range userConfig.tunnels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getTunnelArgs returns the arguments of the tunnel defined in ~/.tssh.conf, e.g.:
+//
+//	Tunnel.db = -L 5432:127.0.0.1:5432 -D 1080 dbhost
+func getTunnelArgs(name string) ([]string, error) {
+	value, ok := userConfig.tunnels[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("tunnel [%s] is not defined in ~/.tssh.conf", name)
+	}
+	tunnelArgs, err := splitCommandLine(value)
+	if err != nil {
+		return nil, fmt.Errorf("split tunnel [%s] arguments [%s] failed: %v", name, value, err)
+	}
+	return tunnelArgs, nil
+}
+
+func parseTunnelArgs(tunnelArgs []string) (*sshArgs, error) {
+	var args sshArgs
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.Parse(tunnelArgs); err != nil {
+		return nil, err
+	}
+	if args.Destination == "" {
+		return nil, fmt.Errorf("the destination is required")
+	}
+	return &args, nil
+}
+
+// getTunnelLocalAddr returns the address to connect to the local listener.
+func getTunnelLocalAddr(addr *string) string {
+	if addr == nil || *addr == "" || *addr == "*" || *addr == "0.0.0.0" {
+		return "127.0.0.1"
+	}
+	if *addr == "::" {
+		return "::1"
+	}
+	return *addr
+}
+
+// getTunnelCheckAddrs returns the local listening addresses to check the health of the tunnel.
+func getTunnelCheckAddrs(tunnelArgs []string) ([]string, error) {
+	args, err := parseTunnelArgs(tunnelArgs)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, b := range args.DynamicForward.binds {
+		addrs = append(addrs, joinHostPort(getTunnelLocalAddr(b.addr), strconv.Itoa(b.port)))
+	}
+	for _, f := range args.LocalForward.cfgs {
+		addrs = append(addrs, joinHostPort(getTunnelLocalAddr(f.bindAddr), strconv.Itoa(f.bindPort)))
+	}
+	return addrs, nil
+}
+
+// getTunnelSocksAddr returns the local address of the first -D socks5 proxy of the named tunnel.
+func getTunnelSocksAddr(name string) (string, error) {
+	tunnelArgs, err := getTunnelArgs(name)
+	if err != nil {
+		return "", err
+	}
+	args, err := parseTunnelArgs(tunnelArgs)
+	if err != nil {
+		return "", fmt.Errorf("parse tunnel [%s] arguments failed: %v", name, err)
+	}
+	if len(args.DynamicForward.binds) == 0 {
+		return "", fmt.Errorf("tunnel [%s] has no -D socks5 proxy", name)
+	}
+	b := args.DynamicForward.binds[0]
+	return joinHostPort(getTunnelLocalAddr(b.addr), strconv.Itoa(b.port)), nil
+}
+
+func loadTunnelState(name string) *tunnelState {
+	data, err := os.ReadFile(filepath.Join(getTunnelDir(), name+".json"))
+	if err != nil {
+		return nil
+	}
+	var state tunnelState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil
+	}
+	if !isProcessAlive(state.Pid) {
+		return nil
+	}
+	return &state
+}
+
+func saveTunnelState(name string, state *tunnelState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	path := filepath.Join(getTunnelDir(), name+".json")
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		warning("write tunnel state failed: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		warning("save tunnel state failed: %v", err)
+	}
+}
+
+func checkTunnelHealth(addrs []string) error {
+	for _, addr := range addrs {
+		conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+func startTunnel(name string) error {
+	if state := loadTunnelState(name); state != nil {
+		return fmt.Errorf("tunnel [%s] is already running, pid: %d", name, state.Pid)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(getTunnelDir(), name+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, "--tunnel", "run", name)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	for i := 0; i < 20; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if loadTunnelState(name) != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("tunnel [%s] didn't start, see %s for details", name, logFile.Name())
+}
+
+func stopTunnel(name string) error {
+	state := loadTunnelState(name)
+	if state == nil {
+		return fmt.Errorf("tunnel [%s] is not running", name)
+	}
+	proc, err := os.FindProcess(state.Pid)
+	if err != nil {
+		return err
+	}
+	if err := stopProcess(proc); err != nil {
+		return err
+	}
+	if state.ChildPid > 0 && isProcessAlive(state.ChildPid) {
+		if child, err := os.FindProcess(state.ChildPid); err == nil {
+			_ = stopProcess(child)
+		}
+	}
+	for i := 0; i < 30 && isProcessAlive(state.Pid); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	_ = os.Remove(filepath.Join(getTunnelDir(), name+".json"))
+	return nil
+}
+
+func printTunnelStatus(name string) {
+	state := loadTunnelState(name)
+	if state == nil {
+		fmt.Printf("%-16s stopped\r\n", name)
+		return
+	}
+	status := fmt.Sprintf("%-16s %s since %s, pid: %d, restarts: %d", name, state.State,
+		state.Since.Format("2006-01-02 15:04:05"), state.Pid, state.Restarts)
+	if state.Error != "" {
+		status += ", last error: " + state.Error
+	}
+	fmt.Printf("%s\r\n", status)
+}
+
+// notifyStopSignals returns a channel which is closed on SIGINT or SIGTERM.
+func notifyStopSignals() <-chan struct{} {
+	stopCh := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		signal.Stop(sigCh)
+		close(stopCh)
+	}()
+	return stopCh
+}
+
+// runTunnel supervises the tunnel in the background, restarts it on exit or when the health check fails,
+// until the stopCh is closed.
+func runTunnel(name string, stopCh <-chan struct{}) int {
+	tunnelArgs, err := getTunnelArgs(name)
+	if err != nil {
+		warning("%v", err)
+		return 1
+	}
+	addrs, err := getTunnelCheckAddrs(tunnelArgs)
+	if err != nil {
+		warning("invalid tunnel [%s]: %v", name, err)
+		return 1
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		warning("get executable failed: %v", err)
+		return 1
+	}
+
+	state := &tunnelState{Pid: os.Getpid()}
+	defer os.Remove(filepath.Join(getTunnelDir(), name+".json"))
+	delay := time.Second
+	for {
+		// the tunnel runs in the background, it must not prompt for anything
+		cmd := exec.Command(exe, append([]string{"-N", "-oBatchMode=yes"}, tunnelArgs...)...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		beginTime := time.Now()
+		if err := cmd.Start(); err != nil {
+			warning("start tunnel [%s] failed: %v", name, err)
+			return 1
+		}
+		state.ChildPid, state.State, state.Since = cmd.Process.Pid, "running", beginTime
+		saveTunnelState(name, state)
+		debug("tunnel [%s] started, pid: %d", name, cmd.Process.Pid)
+
+		doneCh := make(chan error, 1)
+		go func() { doneCh <- cmd.Wait() }()
+		ticker := time.NewTicker(kTunnelCheckInterval)
+		failures := 0
+	check:
+		for {
+			select {
+			case err := <-doneCh:
+				state.Error = fmt.Sprintf("exited: %v", err)
+				break check
+			case <-stopCh:
+				ticker.Stop()
+				_ = stopProcess(cmd.Process)
+				<-doneCh
+				return 0
+			case <-ticker.C:
+				if err := checkTunnelHealth(addrs); err != nil {
+					failures++
+					warning("tunnel [%s] health check failed (%d/%d): %v", name, failures, kTunnelCheckFailures, err)
+					if failures >= kTunnelCheckFailures {
+						state.Error = fmt.Sprintf("health check failed: %v", err)
+						_ = stopProcess(cmd.Process)
+						<-doneCh
+						break check
+					}
+				} else {
+					failures = 0
+				}
+			}
+		}
+		ticker.Stop()
+
+		if time.Since(beginTime) > kTunnelMaxRestartDelay {
+			delay = time.Second
+		} else if delay *= 2; delay > kTunnelMaxRestartDelay {
+			delay = kTunnelMaxRestartDelay
+		}
+		warning("tunnel [%s] %s, restart in %v", name, state.Error, delay)
+		state.ChildPid, state.State, state.Since = 0, "restarting", time.Now()
+		state.Restarts++
+		saveTunnelState(name, state)
+		select {
+		case <-stopCh:
+			return 0
+		case <-time.After(delay):
+		}
+	}
+}
+
+func execTunnelTool(args *sshArgs) (int, bool) {
+	action := strings.ToLower(args.Tunnel)
+	name := strings.ToLower(args.Destination)
+
+	switch action {
+	case "list", "status":
+	case "start", "stop", "restart", "run":
+		if name == "" {
+			toolsErrorExit("the tunnel name is required, e.g., tssh --tunnel %s tunnel_name", action)
+		}
+		if _, err := getTunnelArgs(name); err != nil {
+			toolsErrorExit("%v", err)
+		}
+	default:
+		toolsErrorExit("unknown tunnel action [%s], should be one of list, start, stop, restart, status", args.Tunnel)
+	}
+
+	if err := os.MkdirAll(getTunnelDir(), 0700); err != nil {
+		toolsErrorExit("create tunnel directory failed: %v", err)
+	}
+
+	switch action {
+	case "list":
+		for _, name := range getTunnelNames() {
+			fmt.Printf("%-16s %s\r\n", name, userConfig.tunnels[name])
+		}
+	case "status":
+		if name != "" {
+			printTunnelStatus(name)
+			break
+		}
+		for _, name := range getTunnelNames() {
+			printTunnelStatus(name)
+		}
+	case "run":
+		if code, ok := runTunnelService(name); ok {
+			return code, true
+		}
+		return runTunnel(name, notifyStopSignals()), true
+	case "start":
+		if err := startTunnel(name); err != nil {
+			toolsErrorExit("%v", err)
+		}
+		toolsSucc("Tunnel", "tunnel [%s] started", name)
+	case "stop":
+		if err := stopTunnel(name); err != nil {
+			toolsErrorExit("%v", err)
+		}
+		toolsSucc("Tunnel", "tunnel [%s] stopped", name)
+	case "restart":
+		if loadTunnelState(name) != nil {
+			if err := stopTunnel(name); err != nil {
+				toolsErrorExit("%v", err)
+			}
+		}
+		if err := startTunnel(name); err != nil {
+			toolsErrorExit("%v", err)
+		}
+		toolsSucc("Tunnel", "tunnel [%s] restarted", name)
+	}
+	return 0, true
+}
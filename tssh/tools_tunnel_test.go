@@ -0,0 +1,53 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTunnelCheckAddrs(t *testing.T) {
+	assert := assert.New(t)
+	assertCheckAddrs := func(tunnel string, expected []string) {
+		t.Helper()
+		tunnelArgs, err := splitCommandLine(tunnel)
+		assert.Nil(err)
+		addrs, err := getTunnelCheckAddrs(tunnelArgs)
+		assert.Nil(err)
+		assert.Equal(expected, addrs)
+	}
+
+	assertCheckAddrs("host", nil)
+	assertCheckAddrs("-L 5432:db:5432 host", []string{"127.0.0.1:5432"})
+	assertCheckAddrs("-D 1080 -L *:8080:web:80 -L [::1]:8443:web:443 host",
+		[]string{"127.0.0.1:1080", "127.0.0.1:8080", "[::1]:8443"})
+	assertCheckAddrs("-L 192.168.1.2:3306:127.0.0.1:3306 -R 9000:127.0.0.1:9000 host",
+		[]string{"192.168.1.2:3306"})
+
+	_, err := getTunnelCheckAddrs([]string{"-L", "5432:db:5432"})
+	assert.NotNil(err)
+}
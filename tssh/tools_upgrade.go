@@ -0,0 +1,364 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	kTsshReleasesAPI = "https://api.github.com/repos/trzsz/trzsz-ssh/releases"
+	kTsshReleaseURL  = "https://github.com/trzsz/trzsz-ssh/releases/download"
+)
+
+type tsshRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+// getLatestTsshVersion returns the latest version of the channel, stable or beta.
+// The beta channel includes the prereleases.
+func getLatestTsshVersion(httpClient *http.Client, channel string) (string, error) {
+	url := kTsshReleasesAPI + "/latest"
+	if channel == "beta" {
+		url = kTsshReleasesAPI + "?per_page=10"
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("http response status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var releases []tsshRelease
+	if channel == "beta" {
+		if err := json.Unmarshal(body, &releases); err != nil {
+			return "", err
+		}
+	} else {
+		var release tsshRelease
+		if err := json.Unmarshal(body, &release); err != nil {
+			return "", err
+		}
+		releases = append(releases, release)
+	}
+	for _, release := range releases {
+		if !release.Draft && strings.HasPrefix(release.TagName, "v") {
+			return release.TagName[1:], nil
+		}
+	}
+	return "", fmt.Errorf("no release found")
+}
+
+// compareVersion compares the versions like 0.1.18 or 0.1.19-beta.1, the prerelease is less than the release.
+func compareVersion(v1, v2 string) int {
+	split := func(version string) ([]int, string) {
+		version, pre, _ := strings.Cut(version, "-")
+		var numbers []int
+		for _, s := range strings.Split(version, ".") {
+			n, _ := strconv.Atoi(s)
+			numbers = append(numbers, n)
+		}
+		return numbers, pre
+	}
+	n1, pre1 := split(v1)
+	n2, pre2 := split(v2)
+	for i := 0; i < len(n1) || i < len(n2); i++ {
+		var a, b int
+		if i < len(n1) {
+			a = n1[i]
+		}
+		if i < len(n2) {
+			b = n2[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case pre1 == pre2:
+		return 0
+	case pre1 == "":
+		return 1
+	case pre2 == "":
+		return -1
+	default:
+		return comparePrerelease(pre1, pre2)
+	}
+}
+
+// comparePrerelease compares the dot separated identifiers as semver does, but the numbers
+// in the identifiers are compared numerically as well, e.g., rc9 < rc10 and beta.2 < beta.10.
+func comparePrerelease(pre1, pre2 string) int {
+	ids1, ids2 := strings.Split(pre1, "."), strings.Split(pre2, ".")
+	for i := 0; i < len(ids1) && i < len(ids2); i++ {
+		if c := compareNatural(ids1[i], ids2[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(ids1) < len(ids2):
+		return -1
+	case len(ids1) > len(ids2):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareNatural compares the digit runs numerically and the others lexically, the digits are lower.
+func compareNatural(s1, s2 string) int {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	nextRun := func(s string) (string, string) {
+		i := 1
+		for i < len(s) && isDigit(s[i]) == isDigit(s[0]) {
+			i++
+		}
+		return s[:i], s[i:]
+	}
+	for s1 != "" && s2 != "" {
+		var r1, r2 string
+		r1, s1 = nextRun(s1)
+		r2, s2 = nextRun(s2)
+		d1, d2 := isDigit(r1[0]), isDigit(r2[0])
+		switch {
+		case d1 && d2:
+			r1, r2 = strings.TrimLeft(r1, "0"), strings.TrimLeft(r2, "0")
+			if len(r1) != len(r2) {
+				if len(r1) < len(r2) {
+					return -1
+				}
+				return 1
+			}
+		case d1:
+			return -1
+		case d2:
+			return 1
+		}
+		if r1 != r2 {
+			if r1 < r2 {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case s1 == "" && s2 == "":
+		return 0
+	case s1 == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// getTsshPackageName returns the package name like tssh_0.1.18_linux_x86_64.
+func getTsshPackageName(version, goos, goarch string) string {
+	if goos == "darwin" {
+		goos = "macos"
+	}
+	switch goarch {
+	case "amd64":
+		goarch = "x86_64"
+	case "386":
+		goarch = "i386"
+	case "arm64":
+		goarch = "aarch64"
+	}
+	return fmt.Sprintf("tssh_%s_%s_%s", version, goos, goarch)
+}
+
+func extractTsshBinary(data []byte, pkgName, binName string) ([]byte, error) {
+	target := pkgName + "/" + binName
+	if strings.HasSuffix(binName, ".exe") {
+		zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range zipReader.File {
+			if file.Name == target {
+				reader, err := file.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer reader.Close()
+				return io.ReadAll(reader)
+			}
+		}
+		return nil, fmt.Errorf("can't find %s in the package", target)
+	}
+
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+	tarReader := tar.NewReader(gzr)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == target {
+			return io.ReadAll(tarReader)
+		}
+	}
+	return nil, fmt.Errorf("can't find %s in the package", target)
+}
+
+// writeSyncedFile writes the data to the file and flushes it to the disk before closing.
+func writeSyncedFile(path string, data []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// replaceExecutable replaces the executable atomically by renaming.
+// The running executable can't be overwritten on Windows, but it can be renamed to *.old,
+// and it's renamed back if the new executable can't be moved into place.
+func replaceExecutable(path string, binary []byte) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	newPath := path + ".new"
+	if err := writeSyncedFile(newPath, binary, stat.Mode().Perm()); err != nil {
+		_ = os.Remove(newPath)
+		return err
+	}
+	oldPath := ""
+	if runtime.GOOS == "windows" {
+		oldPath = path + ".old"
+		_ = os.Remove(oldPath)
+		if err := os.Rename(path, oldPath); err != nil {
+			_ = os.Remove(newPath)
+			return err
+		}
+	}
+	if err := os.Rename(newPath, path); err != nil {
+		_ = os.Remove(newPath)
+		if oldPath != "" {
+			if e := os.Rename(oldPath, path); e != nil {
+				return fmt.Errorf("%v, and restore %s from %s failed: %v", err, path, oldPath, e)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func execUpgrade(args *sshArgs) (int, bool) {
+	channel := strings.ToLower(args.Channel)
+	switch channel {
+	case "":
+		channel = "stable"
+	case "stable", "beta":
+	default:
+		toolsErrorExit("unknown release channel [%s], should be stable or beta", args.Channel)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		toolsErrorExit("get executable path failed: %v", err)
+	}
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		path = realPath
+	}
+
+	httpClient := http.DefaultClient
+	version, err := getLatestTsshVersion(httpClient, channel)
+	if err != nil {
+		toolsErrorExit("get latest %s version failed: %v", channel, err)
+	}
+	if compareVersion(version, kTsshVersion) <= 0 {
+		toolsSucc("Upgrade", "tssh %s is up to date, the latest %s version is %s", kTsshVersion, channel, version)
+		return 0, true
+	}
+	toolsInfo("Upgrade", "upgrade tssh from %s to %s", kTsshVersion, version)
+
+	pkgName := getTsshPackageName(version, runtime.GOOS, runtime.GOARCH)
+	archive, binName := pkgName+".tar.gz", "tssh"
+	if runtime.GOOS == "windows" {
+		archive, binName = pkgName+".zip", "tssh.exe"
+	}
+	checksumsURL := fmt.Sprintf("%s/v%s/tssh_%s_checksums.txt", kTsshReleaseURL, version, version)
+	checksum, err := getReleaseChecksum(httpClient, checksumsURL, archive)
+	if err != nil {
+		toolsErrorExit("get checksum failed: %v", err)
+	}
+	data, err := downloadWithProgress(httpClient, "Upgrade", fmt.Sprintf("%s/v%s/%s", kTsshReleaseURL, version, archive))
+	if err != nil {
+		toolsErrorExit("download %s failed: %v", archive, err)
+	}
+	if sum := fmt.Sprintf("%x", sha256.Sum256(data)); sum != checksum {
+		toolsErrorExit("checksum mismatch, expected %s, but got %s", checksum, sum)
+	}
+
+	binary, err := extractTsshBinary(data, pkgName, binName)
+	if err != nil {
+		toolsErrorExit("extract %s failed: %v", archive, err)
+	}
+	if err := replaceExecutable(path, binary); err != nil {
+		toolsErrorExit("replace %s failed: %v", path, err)
+	}
+	toolsSucc("Upgrade", "tssh has been upgraded to %s", version)
+	return 0, true
+}
@@ -0,0 +1,64 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgrade(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, compareVersion("0.1.18", "0.1.18"))
+	assert.Equal(1, compareVersion("0.1.19", "0.1.18"))
+	assert.Equal(-1, compareVersion("0.1.9", "0.1.18"))
+	assert.Equal(1, compareVersion("0.2", "0.1.18"))
+	assert.Equal(-1, compareVersion("0.1.19-beta.1", "0.1.19"))
+	assert.Equal(1, compareVersion("0.1.19-beta.1", "0.1.18"))
+	assert.Equal(1, compareVersion("0.1.19-beta.2", "0.1.19-beta.1"))
+	assert.Equal(1, compareVersion("1.2.0-rc10", "1.2.0-rc9"))
+	assert.Equal(1, compareVersion("1.2.0-beta.10", "1.2.0-beta.9"))
+	assert.Equal(1, compareVersion("1.2.0-rc.1", "1.2.0-beta.11"))
+	assert.Equal(1, compareVersion("1.2.0-beta.1", "1.2.0-beta"))
+	assert.Equal(-1, compareVersion("1.2.0-1", "1.2.0-alpha"))
+	assert.Equal(0, compareVersion("1.2.0-rc01", "1.2.0-rc1"))
+
+	assert.Equal("tssh_0.1.18_linux_x86_64", getTsshPackageName("0.1.18", "linux", "amd64"))
+	assert.Equal("tssh_0.1.18_macos_aarch64", getTsshPackageName("0.1.18", "darwin", "arm64"))
+	assert.Equal("tssh_0.1.18_windows_i386", getTsshPackageName("0.1.18", "windows", "386"))
+
+	path := filepath.Join(t.TempDir(), "tssh")
+	assert.Nil(os.WriteFile(path, []byte("old"), 0755))
+	assert.Nil(replaceExecutable(path, []byte("new")))
+	data, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("new", string(data))
+	stat, err := os.Stat(path)
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0755), stat.Mode().Perm())
+}
@@ -0,0 +1,47 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// execRegisterUrlHandler registers tssh as the handler of ssh:// links,
+// the link is opened in a new terminal by running `tssh ssh://user@host:port`.
+func execRegisterUrlHandler() (int, bool) {
+	exe, err := os.Executable()
+	if err != nil {
+		toolsErrorExit("get executable failed: %v", err)
+	}
+	if path, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = path
+	}
+	if err := registerUrlHandler(exe); err != nil {
+		toolsErrorExit("register ssh:// handler failed: %v", err)
+	}
+	toolsSucc("UrlHandler", "tssh has been registered as the handler of ssh:// links")
+	return 0, true
+}
@@ -0,0 +1,125 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func promptVaultPassphrase(label string) []byte {
+	passphrase := promptPassword(label, "", &inputValidator{func(passphrase string) error {
+		if passphrase == "" {
+			return fmt.Errorf("empty passphrase")
+		}
+		return nil
+	}})
+	confirm := promptPassword("Confirm "+strings.ToLower(label[:1])+label[1:], "", &inputValidator{func(confirm string) error {
+		if confirm != passphrase {
+			return fmt.Errorf("passphrase does not match")
+		}
+		return nil
+	}})
+	return []byte(confirm)
+}
+
+func openVaultForTools(path string) *secretVault {
+	var passphrase []byte
+	if !isFileExist(path) {
+		toolsInfo("Vault", "create a new vault: %s", path)
+		passphrase = promptVaultPassphrase("New vault passphrase")
+	} else if passphrase = []byte(os.Getenv("TSSH_VAULT_PASSPHRASE")); len(passphrase) == 0 {
+		passphrase = []byte(promptPassword("Vault passphrase", "", &inputValidator{func(passphrase string) error {
+			if passphrase == "" {
+				return fmt.Errorf("empty passphrase")
+			}
+			if _, err := openVault(path, []byte(passphrase)); err != nil {
+				return err
+			}
+			return nil
+		}}))
+	}
+	vault, err := openVault(path, passphrase)
+	if err != nil {
+		toolsErrorExit("open vault [%s] failed: %v", path, err)
+	}
+	return vault
+}
+
+func execVaultTool(args *sshArgs) (int, bool) {
+	path := getVaultPath()
+	action := strings.ToLower(args.Vault)
+	name := args.Destination
+
+	switch action {
+	case "list", "add", "set", "del", "delete", "passwd":
+	default:
+		toolsErrorExit("unknown vault action [%s], should be one of list, add, del, passwd", args.Vault)
+	}
+	if name == "" && (action == "add" || action == "set" || action == "del" || action == "delete") {
+		toolsErrorExit("the secret name is required, e.g., tssh --vault %s secret_name", action)
+	}
+
+	vault := openVaultForTools(path)
+
+	switch action {
+	case "list":
+		for _, name := range vault.names() {
+			fmt.Printf("%s\r\n", name)
+		}
+		return 0, true
+	case "add", "set":
+		if _, ok := vault.secrets[name]; ok {
+			toolsInfo("Vault", "secret [%s] already exists, it will be replaced", name)
+		}
+		vault.secrets[name] = promptPassword(fmt.Sprintf("Secret for %s", name), "", &inputValidator{func(secret string) error {
+			if secret == "" {
+				return fmt.Errorf("empty secret")
+			}
+			return nil
+		}})
+	case "del", "delete":
+		if _, ok := vault.secrets[name]; !ok {
+			toolsErrorExit("no secret named [%s] in vault", name)
+		}
+		delete(vault.secrets, name)
+	case "passwd":
+		vault.passphrase = promptVaultPassphrase("New vault passphrase")
+	}
+
+	if err := vault.save(); err != nil {
+		toolsErrorExit("save vault [%s] failed: %v", path, err)
+	}
+	toolsSucc("Vault", "vault [%s] has been saved", path)
+	if action == "add" || action == "set" {
+		fmt.Printf("%s%s%s\r\n\r\n",
+			lipgloss.NewStyle().Foreground(greenColor).Render("Reference it in configuration"),
+			lipgloss.NewStyle().Faint(true).Render(": "), "vaultPassword "+name)
+	}
+	return 0, true
+}
@@ -0,0 +1,515 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// commandRunner runs the command on the server with the stdin and stdout, and stops it once cancel is closed.
+type commandRunner func(command string, stdin io.Reader, stdout io.Writer, cancel <-chan struct{}) error
+
+func newSshCommandRunner(client *ssh.Client) commandRunner {
+	return func(command string, stdin io.Reader, stdout io.Writer, cancel <-chan struct{}) error {
+		session, err := client.NewSession()
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+		var stderr bytes.Buffer
+		session.Stdin, session.Stdout, session.Stderr = stdin, stdout, &stderr
+		if err := session.Start(command); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() { done <- session.Wait() }()
+		select {
+		case err = <-done:
+		case <-cancel:
+			session.Close()
+			return errTransferCancelled
+		}
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("%v: %s", err, msg)
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+type transferState int
+
+const (
+	transferPending transferState = iota
+	transferActive
+	transferPaused
+	transferDone
+	transferFailed
+	transferCancelled
+)
+
+func (s transferState) String() string {
+	return [...]string{"pending", "active", "paused", "done", "failed", "cancelled"}[s]
+}
+
+var errTransferCancelled = errors.New("transfer cancelled")
+
+// transferJob is a file to download with `cat` or to upload with `cat >`, queued in the transferQueue.
+type transferJob struct {
+	id         int
+	upload     bool
+	delta      bool
+	local      string
+	remote     string
+	size       int64
+	done       int64
+	state      transferState
+	started    bool
+	background bool
+	deferred   bool
+	rate       int64
+	rateStart  time.Time
+	rateDone   int64
+	err        error
+	cancel     chan struct{}
+}
+
+func (j *transferJob) direction() string {
+	if j.delta {
+		return "sync"
+	}
+	if j.upload {
+		return "put"
+	}
+	return "get"
+}
+
+// transferQueue runs the queued file transfers one by one over the client connection,
+// the transfers could be paused, resumed or cancelled, see transferMenu.
+type transferQueue struct {
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	runner   commandRunner
+	jobs     []*transferJob
+	nextID   int
+	schedule *transferSchedule
+	onFinish func(job transferJob)
+
+	parallel     int
+	parallelSize int64
+}
+
+func newTransferQueue(runner commandRunner) *transferQueue {
+	q := &transferQueue{runner: runner, nextID: 1, parallel: 1}
+	q.cond = sync.NewCond(&q.mutex)
+	go q.serve()
+	return q
+}
+
+// getDownloadPath returns the local path of the download, in the DefaultDownloadPath if not specified.
+func getDownloadPath(remote, local string) string {
+	if local != "" {
+		return local
+	}
+	name := path.Base(strings.TrimRight(remote, "/"))
+	if userConfig.defaultDownloadPath != "" {
+		return filepath.Join(userConfig.defaultDownloadPath, name)
+	}
+	return name
+}
+
+func (q *transferQueue) add(upload bool, local, remote string) *transferJob {
+	return q.addJob(&transferJob{upload: upload, local: local, remote: remote})
+}
+
+// addDelta queues an upload which only sends the changes to the remote file, see deltaUpload.
+func (q *transferQueue) addDelta(local, remote string) *transferJob {
+	return q.addJob(&transferJob{upload: true, delta: true, local: local, remote: remote})
+}
+
+func (q *transferQueue) addJob(job *transferJob) *transferJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if job.upload && job.remote == "" {
+		job.remote = filepath.Base(job.local)
+	} else if !job.upload {
+		job.local = getDownloadPath(job.remote, job.local)
+	}
+	job.id, job.cancel = q.nextID, make(chan struct{})
+	q.nextID++
+	q.jobs = append(q.jobs, job)
+	q.cond.Broadcast()
+	return job
+}
+
+func (q *transferQueue) getJob(id int) *transferJob {
+	for _, job := range q.jobs {
+		if job.id == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (q *transferQueue) pause(id int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job := q.getJob(id)
+	if job == nil || job.state != transferPending && job.state != transferActive {
+		return fmt.Errorf("no pending or active transfer %d", id)
+	}
+	job.state = transferPaused
+	return nil
+}
+
+func (q *transferQueue) resume(id int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job := q.getJob(id)
+	if job == nil || job.state != transferPaused {
+		return fmt.Errorf("no paused transfer %d", id)
+	}
+	if job.started {
+		job.state = transferActive
+	} else {
+		job.state = transferPending
+	}
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *transferQueue) cancel(id int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job := q.getJob(id)
+	if job == nil || job.state >= transferDone {
+		return fmt.Errorf("no unfinished transfer %d", id)
+	}
+	job.state = transferCancelled
+	close(job.cancel)
+	q.cond.Broadcast()
+	return nil
+}
+
+// setBackground marks the job to be continued after the session exits.
+func (q *transferQueue) setBackground(id int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job := q.getJob(id)
+	if job == nil || job.state >= transferDone {
+		return fmt.Errorf("no unfinished transfer %d", id)
+	}
+	job.background = true
+	return nil
+}
+
+// waitBackground cancels the unfinished foreground jobs, and waits for the background ones.
+func (q *transferQueue) waitBackground(onWaiting func(jobs []transferJob)) {
+	q.mutex.Lock()
+	for _, job := range q.jobs {
+		if !job.background && job.state < transferDone {
+			job.state = transferCancelled
+			close(job.cancel)
+		}
+	}
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+	for {
+		var waiting []transferJob
+		for _, job := range q.snapshot() {
+			if job.background && job.state < transferDone {
+				waiting = append(waiting, job)
+			}
+		}
+		if len(waiting) == 0 {
+			return
+		}
+		onWaiting(waiting)
+		time.Sleep(kTransferReportInterval)
+	}
+}
+
+// cancelAll cancels all the unfinished jobs, including the background ones.
+func (q *transferQueue) cancelAll() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, job := range q.jobs {
+		if job.state < transferDone {
+			job.state = transferCancelled
+			close(job.cancel)
+		}
+	}
+	q.cond.Broadcast()
+}
+
+// clear removes the finished jobs.
+func (q *transferQueue) clear() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	jobs := q.jobs[:0]
+	for _, job := range q.jobs {
+		if job.state < transferDone {
+			jobs = append(jobs, job)
+		}
+	}
+	q.jobs = jobs
+}
+
+// snapshot returns the copies of the jobs to be displayed.
+// isBusy returns whether any transfer is pending or running.
+func (q *transferQueue) isBusy() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, job := range q.jobs {
+		if job.state == transferPending || job.state == transferActive {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *transferQueue) snapshot() []transferJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	jobs := make([]transferJob, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+func (q *transferQueue) nextPending() *transferJob {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for {
+		for _, job := range q.jobs {
+			if job.state == transferPending {
+				job.state, job.started = transferActive, true
+				return job
+			}
+		}
+		q.cond.Wait()
+	}
+}
+
+func (q *transferQueue) serve() {
+	for {
+		job := q.nextPending()
+		err := q.transfer(job)
+		q.mutex.Lock()
+		switch {
+		case job.state == transferCancelled:
+		case err != nil:
+			job.state, job.err = transferFailed, err
+		default:
+			job.state = transferDone
+		}
+		q.cond.Broadcast()
+		finished := *job
+		q.mutex.Unlock()
+		if q.onFinish != nil {
+			q.onFinish(finished)
+		}
+	}
+}
+
+// wait blocks while the job is paused, returns an error if it's cancelled.
+func (q *transferQueue) wait(job *transferJob) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for job.state == transferPaused {
+		q.cond.Wait()
+	}
+	if job.state == transferCancelled {
+		return errTransferCancelled
+	}
+	return nil
+}
+
+func (q *transferQueue) progress(job *transferJob, n int) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job.done += int64(n)
+}
+
+type jobReader struct {
+	queue  *transferQueue
+	job    *transferJob
+	reader io.Reader
+}
+
+func (r *jobReader) Read(p []byte) (int, error) {
+	if err := r.queue.throttle(r.job); err != nil {
+		return 0, err
+	}
+	n, err := r.reader.Read(p)
+	r.queue.progress(r.job, n)
+	return n, err
+}
+
+type jobWriter struct {
+	queue  *transferQueue
+	job    *transferJob
+	writer io.Writer
+}
+
+func (w *jobWriter) Write(p []byte) (int, error) {
+	if err := w.queue.throttle(w.job); err != nil {
+		return 0, err
+	}
+	n, err := w.writer.Write(p)
+	w.queue.progress(w.job, n)
+	return n, err
+}
+
+func (q *transferQueue) remoteSize(remote string, cancel <-chan struct{}) (int64, error) {
+	var output bytes.Buffer
+	if err := q.runner("wc -c < "+quoteRemotePath(remote), nil, &output, cancel); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(output.String()), 10, 64)
+}
+
+func (q *transferQueue) transfer(job *transferJob) error {
+	if job.delta {
+		return q.deltaUpload(job)
+	}
+	if job.upload {
+		return q.uploadFile(job)
+	}
+	return q.downloadFile(job)
+}
+
+func (q *transferQueue) uploadFile(job *transferJob) error {
+	file, err := os.Open(job.local)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	q.mutex.Lock()
+	job.size = stat.Size()
+	q.mutex.Unlock()
+	if chunks := q.getTransferChunks(stat.Size()); chunks != nil {
+		return q.parallelUpload(job, file, chunks)
+	}
+	return q.runner(getWriteScript(job.remote), &jobReader{q, job, file}, nil, job.cancel)
+}
+
+func (q *transferQueue) downloadFile(job *transferJob) error {
+	size, err := q.remoteSize(job.remote, job.cancel)
+	if err != nil {
+		if q.isRemoteDir(job.remote, job.cancel) {
+			return q.archiveDownload(job)
+		}
+		return err
+	}
+	q.mutex.Lock()
+	job.size = size
+	q.mutex.Unlock()
+	// download to a temporary file, so that the existing file is kept if failed
+	file, err := os.CreateTemp(filepath.Dir(job.local), filepath.Base(job.local)+".tssh.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if chunks := q.getTransferChunks(size); chunks != nil {
+		err = q.parallelDownload(job, file, chunks)
+	} else {
+		err = q.runner("cat "+quoteRemotePath(job.remote), nil, &jobWriter{q, job, file}, job.cancel)
+	}
+	if err == nil {
+		err = file.Chmod(0644)
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), job.local)
+}
+
+func getTransferEvent(job *transferJob) (string, map[string]string) {
+	event := "transfer_done"
+	envs := map[string]string{
+		"TSSH_TRANSFER_ID":        strconv.Itoa(job.id),
+		"TSSH_TRANSFER_DIRECTION": job.direction(),
+		"TSSH_TRANSFER_LOCAL":     job.local,
+		"TSSH_TRANSFER_REMOTE":    job.remote,
+		"TSSH_TRANSFER_SIZE":      strconv.FormatInt(job.done, 10),
+	}
+	if job.background {
+		envs["TSSH_TRANSFER_BACKGROUND"] = "1"
+	}
+	switch job.state {
+	case transferFailed:
+		event = "transfer_failed"
+		envs["TSSH_TRANSFER_ERROR"] = fmt.Sprintf("%v", job.err)
+	case transferCancelled:
+		event = "transfer_cancelled"
+	}
+	return event, envs
+}
+
+// waitBackgroundTransfers keeps the connection until the background transfers are finished after the session exits.
+func waitBackgroundTransfers(ss *sshSession) {
+	if ss.transfers == nil {
+		return
+	}
+	reported := false
+	ss.transfers.waitBackground(func(jobs []transferJob) {
+		var done, size int64
+		for _, job := range jobs {
+			done += job.done
+			size += job.size
+		}
+		if !reported {
+			fmt.Fprintf(os.Stderr, "\r\nWaiting for %d background transfers, open the transfer menu to cancel them\r\n", len(jobs))
+			reported = true
+		}
+		fmt.Fprintf(os.Stderr, "\r\033[K%d background transfers left: %s / %s", len(jobs), formatTransferBytes(done), formatTransferBytes(size))
+	})
+	if reported {
+		fmt.Fprintf(os.Stderr, "\r\033[KThe background transfers are finished\r\n")
+	}
+}
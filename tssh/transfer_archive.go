@@ -0,0 +1,218 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// isRemoteDir returns whether the remote path is a directory.
+func (q *transferQueue) isRemoteDir(remote string, cancel <-chan struct{}) bool {
+	return q.runner("[ -d "+quoteRemotePath(remote)+" ]", nil, nil, cancel) == nil
+}
+
+// getArchiveScript tars the remote directory, and compresses it by gzip if it exists on the server.
+func getArchiveScript(remote string) string {
+	dir, name := splitArchiveRoot(remote)
+	return fmt.Sprintf(`cd %s || exit 1; `+
+		`command -v tar >/dev/null 2>&1 || { echo "tar not found on the server" >&2; exit 1; }; `+
+		`if command -v gzip >/dev/null 2>&1; then tar -cf - %s | gzip -c; else tar -cf - %s; fi`,
+		quoteRemotePath(dir), shellQuote(name), shellQuote(name))
+}
+
+// splitArchiveRoot returns the directory to run tar in, and the name to be archived.
+func splitArchiveRoot(remote string) (string, string) {
+	remote = strings.TrimRight(remote, "/")
+	switch remote {
+	case "", "~", ".":
+		if remote == "" {
+			remote = "/"
+		}
+		return remote, "."
+	}
+	return path.Dir(remote), path.Base(remote)
+}
+
+// archiveDownload streams the remote directory as a single tar, and extracts it to the local directory.
+// The directory is extracted to a temporary directory first, so nothing is left if the transfer fails.
+func (q *transferQueue) archiveDownload(job *transferJob) error {
+	if _, err := os.Lstat(job.local); err == nil {
+		return fmt.Errorf("%s already exists", job.local)
+	}
+	temp, err := os.MkdirTemp(filepath.Dir(job.local), filepath.Base(job.local)+".tssh.*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temp)
+
+	reader, writer := io.Pipe()
+	extracted := make(chan error, 1)
+	go func() {
+		err := extractArchive(reader, temp)
+		reader.CloseWithError(err)
+		extracted <- err
+	}()
+	err = q.runner(getArchiveScript(job.remote), nil, &jobWriter{q, job, writer}, job.cancel)
+	writer.CloseWithError(err)
+	if e := <-extracted; err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+
+	_, name := splitArchiveRoot(job.remote)
+	source := filepath.Join(temp, filepath.FromSlash(name))
+	if stat, err := os.Lstat(source); err != nil || !stat.IsDir() {
+		return fmt.Errorf("directory %s not found in the archive", name)
+	}
+	return os.Rename(source, job.local)
+}
+
+// extractArchive extracts the tar, which is gzipped or not, into the directory.
+func extractArchive(reader io.Reader, dir string) error {
+	buffered := bufio.NewReaderSize(reader, kStdioBufferSize)
+	var input io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		input = gz
+	}
+	archive := tar.NewReader(input)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read archive failed: %v", err)
+		}
+		if err := extractArchiveEntry(archive, header, dir); err != nil {
+			return err
+		}
+	}
+}
+
+func extractArchiveEntry(archive *tar.Reader, header *tar.Header, dir string) error {
+	name := path.Clean("/" + header.Name)[1:]
+	if name == "" {
+		return nil
+	}
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := checkArchiveParent(dir, target); err != nil {
+		return err
+	}
+	if err := removeArchiveSymlink(target); err != nil {
+		return err
+	}
+	mode := os.FileMode(header.Mode).Perm()
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		return os.Chmod(target, mode|0700)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, archive); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(target, header.ModTime, header.ModTime)
+	case tar.TypeLink:
+		link := filepath.Join(dir, filepath.FromSlash(path.Clean("/" + header.Linkname)[1:]))
+		if err := checkArchiveParent(dir, link); err != nil {
+			return err
+		}
+		return os.Link(link, target)
+	case tar.TypeSymlink:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Symlink(header.Linkname, target); err != nil {
+			if runtime.GOOS == "windows" {
+				warning("skip the symlink %s -> %s: %v", name, header.Linkname, err)
+				return nil
+			}
+			return err
+		}
+		return nil
+	default:
+		debug("skip the archive entry %s of type %c", name, header.Typeflag)
+		return nil
+	}
+}
+
+// removeArchiveSymlink removes the symlink extracted at the target by a former entry,
+// so that the later entry of the same name replaces it rather than writing through it.
+func removeArchiveSymlink(target string) error {
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(target)
+	}
+	return nil
+}
+
+// checkArchiveParent makes sure the entry is not written out of the directory through a symlink.
+func checkArchiveParent(dir, target string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	parent := filepath.Dir(target)
+	for {
+		if real, err := filepath.EvalSymlinks(parent); err == nil {
+			if real != root && !strings.HasPrefix(real, root+string(filepath.Separator)) {
+				return fmt.Errorf("archive entry %s is out of the directory", target)
+			}
+			return nil
+		}
+		if parent == dir || filepath.Dir(parent) == parent {
+			return nil
+		}
+		// not created yet, check the existing ancestor
+		parent = filepath.Dir(parent)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveDownload(t *testing.T) {
+	assert := assert.New(t)
+	if _, err := exec.LookPath("tar"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("tar not found")
+	}
+	remoteDir, localDir := t.TempDir(), t.TempDir()
+	project := filepath.Join(remoteDir, "project")
+	assert.Nil(os.MkdirAll(filepath.Join(project, "node_modules", "a", "lib"), 0755))
+	assert.Nil(os.WriteFile(filepath.Join(project, "package.json"), []byte("{}\n"), 0644))
+	assert.Nil(os.WriteFile(filepath.Join(project, "node_modules", "a", "lib", "index.js"), bytes.Repeat([]byte("x"), 10000), 0644))
+	assert.Nil(os.WriteFile(filepath.Join(project, "run.sh"), []byte("#!/bin/sh\n"), 0755))
+	assert.Nil(os.Symlink("package.json", filepath.Join(project, "link.json")))
+
+	queue := newTransferQueue(newLocalCommandRunner(remoteDir))
+	job := queue.add(false, filepath.Join(localDir, "copy"), "project/")
+	done := waitTransfer(t, queue, job.id, transferDone)
+	assert.Greater(done.done, int64(0))
+
+	data, err := os.ReadFile(filepath.Join(localDir, "copy", "node_modules", "a", "lib", "index.js"))
+	assert.Nil(err)
+	assert.Equal(bytes.Repeat([]byte("x"), 10000), data)
+	data, err = os.ReadFile(filepath.Join(localDir, "copy", "package.json"))
+	assert.Nil(err)
+	assert.Equal("{}\n", string(data))
+	stat, err := os.Stat(filepath.Join(localDir, "copy", "run.sh"))
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0755), stat.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(localDir, "copy", "link.json"))
+	assert.Nil(err)
+	assert.Equal("package.json", link)
+	entries, err := os.ReadDir(localDir)
+	assert.Nil(err)
+	assert.Len(entries, 1)
+
+	// don't merge into the existing directory
+	job = queue.add(false, filepath.Join(localDir, "copy"), "project")
+	failed := waitTransfer(t, queue, job.id, transferFailed)
+	assert.Contains(failed.err.Error(), "already exists")
+}
+
+func TestExtractArchive(t *testing.T) {
+	assert := assert.New(t)
+	newArchive := func(headers ...*tar.Header) *bytes.Buffer {
+		var buffer bytes.Buffer
+		writer := tar.NewWriter(&buffer)
+		for _, header := range headers {
+			assert.Nil(writer.WriteHeader(header))
+			if header.Typeflag == tar.TypeReg {
+				_, err := writer.Write(make([]byte, header.Size))
+				assert.Nil(err)
+			}
+		}
+		assert.Nil(writer.Close())
+		return &buffer
+	}
+
+	dir := t.TempDir()
+	assert.Nil(extractArchive(newArchive(
+		&tar.Header{Name: "../../evil.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3}), dir))
+	assert.True(isFileExist(filepath.Join(dir, "evil.txt")))
+
+	if runtime.GOOS != "windows" {
+		outside := t.TempDir()
+		err := extractArchive(newArchive(
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			&tar.Header{Name: "link/evil.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3}), t.TempDir())
+		assert.NotNil(err)
+		assert.False(isFileExist(filepath.Join(outside, "evil.txt")))
+
+		// the regular file and the directory replace the symlink of the same name, rather than following it
+		victim := filepath.Join(outside, "victim")
+		assert.Nil(os.WriteFile(victim, []byte("safe"), 0600))
+		dir := t.TempDir()
+		assert.Nil(extractArchive(newArchive(
+			&tar.Header{Name: "d/x", Typeflag: tar.TypeSymlink, Linkname: victim},
+			&tar.Header{Name: "d/x", Typeflag: tar.TypeReg, Mode: 0644, Size: 5},
+			&tar.Header{Name: "d/y", Typeflag: tar.TypeSymlink, Linkname: outside},
+			&tar.Header{Name: "d/y", Typeflag: tar.TypeDir, Mode: 0777}), dir))
+		data, err := os.ReadFile(victim)
+		assert.Nil(err)
+		assert.Equal("safe", string(data))
+		info, err := os.Lstat(filepath.Join(dir, "d", "x"))
+		assert.Nil(err)
+		assert.True(info.Mode().IsRegular())
+		info, err = os.Lstat(filepath.Join(dir, "d", "y"))
+		assert.Nil(err)
+		assert.True(info.IsDir())
+		info, err = os.Stat(outside)
+		assert.Nil(err)
+		assert.NotEqual(os.FileMode(0777), info.Mode().Perm())
+	}
+}
+
+func TestSplitArchiveRoot(t *testing.T) {
+	assert := assert.New(t)
+	for remote, expected := range map[string][2]string{
+		"project":      {".", "project"},
+		"a/b/project/": {"a/b", "project"},
+		"/":            {"/", "."},
+		"~":            {"~", "."},
+		"~/project":    {"~", "project"},
+	} {
+		dir, name := splitArchiveRoot(remote)
+		assert.Equal(expected, [2]string{dir, name}, remote)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The delta transfer updates a large remote file like rsync, only the changed blocks cross the wire:
+//
+//  1. The server splits the remote file into blocks by `dd`, and sends the `cksum` and `md5sum` of each one.
+//  2. tssh rolls the POSIX cksum over the local file byte by byte, the windows matching a remote block by
+//     both checksums are referred by the block index, and the other bytes are kept as the literal data.
+//  3. The literal data is uploaded, then the server rebuilds the file from the old blocks and the literal
+//     data by `dd`, `tail` and `head`, checks its md5, and replaces the remote file keeping the mode.
+//
+// No program other than the POSIX tools and `md5sum` ( or `md5` on macOS ) is required on the server.
+
+const (
+	kDeltaMinBlockSize = 64 * 1024
+	kDeltaMaxBlocks    = 2000
+)
+
+// getDeltaBlockSize limits the blocks to kDeltaMaxBlocks, each block is checked by several processes.
+func getDeltaBlockSize(size int64) int64 {
+	block := (size + kDeltaMaxBlocks - 1) / kDeltaMaxBlocks
+	block = (block + kDeltaMinBlockSize - 1) / kDeltaMinBlockSize * kDeltaMinBlockSize
+	if block < kDeltaMinBlockSize {
+		return kDeltaMinBlockSize
+	}
+	return block
+}
+
+var cksumTable = func() (table [256]uint32) {
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}()
+
+func cksumUpdate(crc uint32, c byte) uint32 {
+	return crc<<8 ^ cksumTable[byte(crc>>24)^c]
+}
+
+// cksumFinish appends the length to the crc as the POSIX cksum does.
+func cksumFinish(crc uint32, length int64) uint32 {
+	for ; length > 0; length >>= 8 {
+		crc = cksumUpdate(crc, byte(length))
+	}
+	return ^crc
+}
+
+// rollingCksum is the POSIX cksum of a fixed size window sliding over the data.
+type rollingCksum struct {
+	size   int64
+	crc    uint32
+	remove [256]uint32
+}
+
+func newRollingCksum(size int64) *rollingCksum {
+	r := &rollingCksum{size: size}
+	// the crc is linear, so the contribution of the leaving byte is combined from the bits
+	var bits [8]uint32
+	for k := range bits {
+		crc := cksumUpdate(0, byte(1)<<k)
+		for i := int64(0); i < size; i++ {
+			crc = cksumUpdate(crc, 0)
+		}
+		bits[k] = crc
+	}
+	for c := range r.remove {
+		for k := range bits {
+			if c&(1<<k) != 0 {
+				r.remove[c] ^= bits[k]
+			}
+		}
+	}
+	return r
+}
+
+// roll appends the byte c, and removes the byte out if the window is full.
+func (r *rollingCksum) roll(c, out byte, full bool) {
+	r.crc = cksumUpdate(r.crc, c)
+	if full {
+		r.crc ^= r.remove[out]
+	}
+}
+
+func (r *rollingCksum) sum() uint32 {
+	return cksumFinish(r.crc, r.size)
+}
+
+type deltaBlock struct {
+	index int64
+	md5   string
+}
+
+// deltaOp copies count blocks from the index of the remote file, or count bytes of the literal data if index < 0.
+type deltaOp struct {
+	index int64
+	count int64
+}
+
+type deltaBuilder struct {
+	ops     []deltaOp
+	literal io.Writer
+	size    int64
+}
+
+func (b *deltaBuilder) addLiteral(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := b.literal.Write(data); err != nil {
+		return err
+	}
+	b.size += int64(len(data))
+	if n := len(b.ops); n > 0 && b.ops[n-1].index < 0 {
+		b.ops[n-1].count += int64(len(data))
+	} else {
+		b.ops = append(b.ops, deltaOp{-1, int64(len(data))})
+	}
+	return nil
+}
+
+func (b *deltaBuilder) addBlock(index int64) {
+	if n := len(b.ops); n > 0 && b.ops[n-1].index >= 0 && b.ops[n-1].index+b.ops[n-1].count == index {
+		b.ops[n-1].count++
+	} else {
+		b.ops = append(b.ops, deltaOp{index, 1})
+	}
+}
+
+// getRemoteBlocksScript prints the `cksum` output and the md5 of each full block of the remote file.
+func getRemoteBlocksScript(remote string, blockSize, blocks int64) string {
+	return fmt.Sprintf(`f=%s; t=$(mktemp) || exit 1; trap 'rm -f "$t"' EXIT; i=0; `+
+		`while [ $i -lt %d ]; do `+
+		`dd if="$f" of="$t" bs=%d skip=$i count=1 2>/dev/null || exit 1; `+
+		`c=$(cksum < "$t") || exit 1; m=$(md5sum < "$t" 2>/dev/null || md5 -q < "$t") || exit 1; `+
+		`echo "$c ${m%%%% *}"; i=$((i+1)); done`, quoteRemotePath(remote), blocks, blockSize)
+}
+
+func parseRemoteBlocks(output []byte, blockSize, blocks int64) (map[uint32][]deltaBlock, error) {
+	table := make(map[uint32][]deltaBlock)
+	var index int64
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid block checksum: %s", scanner.Text())
+		}
+		crc, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil || fields[1] != strconv.FormatInt(blockSize, 10) || len(fields[2]) != 32 {
+			return nil, fmt.Errorf("invalid block checksum: %s", scanner.Text())
+		}
+		table[uint32(crc)] = append(table[uint32(crc)], deltaBlock{index, strings.ToLower(fields[2])})
+		index++
+	}
+	if index != blocks {
+		return nil, fmt.Errorf("expect %d block checksums but got %d", blocks, index)
+	}
+	return table, nil
+}
+
+// diffDelta finds the remote blocks in the local data, and writes the unmatched bytes to the literal.
+func diffDelta(reader io.Reader, blockSize int64, table map[uint32][]deltaBlock, literal io.Writer) ([]deltaOp, int64, error) {
+	builder := &deltaBuilder{literal: literal}
+	rolling := newRollingCksum(blockSize)
+	window := make([]byte, blockSize)
+	var pos, filled int64
+	pending := make([]byte, 0, kStdioBufferSize)
+	matchBlock := func() int64 {
+		candidates := table[rolling.sum()]
+		if len(candidates) == 0 {
+			return -1
+		}
+		hash := md5.New()
+		hash.Write(window[pos:])
+		hash.Write(window[:pos])
+		sum := hex.EncodeToString(hash.Sum(nil))
+		for _, block := range candidates {
+			if block.md5 == sum {
+				return block.index
+			}
+		}
+		return -1
+	}
+	buffered := bufio.NewReaderSize(reader, kStdioBufferSize)
+	for {
+		c, err := buffered.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		full := filled == blockSize
+		out := window[pos]
+		if full {
+			if pending = append(pending, out); len(pending) == cap(pending) {
+				if err := builder.addLiteral(pending); err != nil {
+					return nil, 0, err
+				}
+				pending = pending[:0]
+			}
+		} else {
+			filled++
+		}
+		rolling.roll(c, out, full)
+		window[pos] = c
+		pos = (pos + 1) % blockSize
+		if filled < blockSize {
+			continue
+		}
+		if index := matchBlock(); index >= 0 {
+			if err := builder.addLiteral(pending); err != nil {
+				return nil, 0, err
+			}
+			pending = pending[:0]
+			builder.addBlock(index)
+			pos, filled, rolling.crc = 0, 0, 0
+		}
+	}
+	if err := builder.addLiteral(pending); err != nil {
+		return nil, 0, err
+	}
+	// the bytes left in the window, in order
+	if filled == blockSize {
+		if err := builder.addLiteral(window[pos:]); err != nil {
+			return nil, 0, err
+		}
+		if err := builder.addLiteral(window[:pos]); err != nil {
+			return nil, 0, err
+		}
+	} else if err := builder.addLiteral(window[:filled]); err != nil {
+		return nil, 0, err
+	}
+	return builder.ops, builder.size, nil
+}
+
+// getRebuildScript rebuilds the remote file from its blocks and the uploaded literal data, then checks the md5.
+func getRebuildScript(remote, literal string, blockSize int64, ops []deltaOp, sum string) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("set -e\nf=%s; l=%s; t=$(mktemp \"$f.tssh.XXXXXX\")\n",
+		quoteRemotePath(remote), shellQuote(literal)))
+	builder.WriteString("trap 'rm -f \"$t\" \"$l\"' EXIT\n{\n")
+	var offset int64
+	for _, op := range ops {
+		if op.index >= 0 {
+			builder.WriteString(fmt.Sprintf("dd if=\"$f\" bs=%d skip=%d count=%d 2>/dev/null\n", blockSize, op.index, op.count))
+		} else {
+			builder.WriteString(fmt.Sprintf("tail -c +%d \"$l\" | head -c %d\n", offset+1, op.count))
+			offset += op.count
+		}
+	}
+	builder.WriteString("} > \"$t\"\n")
+	builder.WriteString("m=$(md5sum < \"$t\" 2>/dev/null || md5 -q < \"$t\")\n")
+	builder.WriteString(fmt.Sprintf("[ \"${m%%%% *}\" = %s ] || { echo \"the rebuilt file is corrupted\" >&2; exit 1; }\n", sum))
+	builder.WriteString(kKeepModeScript + "\n")
+	builder.WriteString("mv -f \"$t\" \"$f\"\n")
+	return builder.String()
+}
+
+// deltaUpload uploads the changes of the local file to the remote file, or the whole file if it's a new file.
+func (q *transferQueue) deltaUpload(job *transferJob) error {
+	remoteSize, err := q.remoteSize(job.remote, job.cancel)
+	if err != nil || remoteSize < kDeltaMinBlockSize {
+		debug("upload the whole file [%s] since the remote size is %d: %v", job.local, remoteSize, err)
+		return q.uploadFile(job)
+	}
+
+	blockSize := getDeltaBlockSize(remoteSize)
+	blocks := remoteSize / blockSize
+	var output bytes.Buffer
+	if err := q.runner(getRemoteBlocksScript(job.remote, blockSize, blocks), nil, &output, job.cancel); err != nil {
+		return fmt.Errorf("checksum the remote blocks failed: %v", err)
+	}
+	table, err := parseRemoteBlocks(output.Bytes(), blockSize, blocks)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(job.local)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	literal, err := os.CreateTemp("", "tssh-delta-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		literal.Close()
+		os.Remove(literal.Name())
+	}()
+	hash := md5.New()
+	ops, size, err := diffDelta(io.TeeReader(file, hash), blockSize, table, literal)
+	if err != nil {
+		return err
+	}
+	if _, err := literal.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	q.mutex.Lock()
+	job.size = size
+	q.mutex.Unlock()
+	debug("delta upload [%s]: %d bytes of literal data in %d operations", job.local, size, len(ops))
+
+	output.Reset()
+	script := fmt.Sprintf(`f=%s; l=$(mktemp "$f.tssh.XXXXXX") || exit 1; echo "$l"; `+
+		`cat > "$l" || { rm -f "$l"; exit 1; }`, quoteRemotePath(job.remote))
+	if err := q.runner(script, &jobReader{q, job, literal}, &output, job.cancel); err != nil {
+		if path := strings.TrimSpace(output.String()); path != "" {
+			_ = q.runner("rm -f "+shellQuote(path), nil, nil, nil)
+		}
+		return err
+	}
+	script = getRebuildScript(job.remote, strings.TrimSpace(output.String()), blockSize, ops, hex.EncodeToString(hash.Sum(nil)))
+	return q.runner("sh -s", strings.NewReader(script), nil, job.cancel)
+}
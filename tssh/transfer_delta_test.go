@@ -0,0 +1,132 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCksum(t *testing.T) {
+	assert := assert.New(t)
+	if _, err := exec.LookPath("cksum"); err != nil {
+		t.Skip("cksum not found")
+	}
+	for _, data := range []string{"", "a", "hello world\n", strings.Repeat("0123456789", 1000)} {
+		var crc uint32
+		for i := 0; i < len(data); i++ {
+			crc = cksumUpdate(crc, data[i])
+		}
+		cmd := exec.Command("cksum")
+		cmd.Stdin = strings.NewReader(data)
+		output, err := cmd.Output()
+		assert.Nil(err)
+		expected := strconv.FormatUint(uint64(cksumFinish(crc, int64(len(data)))), 10)
+		assert.Equal(expected+" "+strconv.Itoa(len(data)), strings.TrimSpace(string(output)))
+	}
+}
+
+func TestRollingCksum(t *testing.T) {
+	assert := assert.New(t)
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	const size = 64
+	rolling := newRollingCksum(size)
+	for i := range data {
+		var out byte
+		if i >= size {
+			out = data[i-size]
+		}
+		rolling.roll(data[i], out, i >= size)
+		if i+1 < size {
+			continue
+		}
+		var crc uint32
+		for _, c := range data[i+1-size : i+1] {
+			crc = cksumUpdate(crc, c)
+		}
+		assert.Equal(cksumFinish(crc, size), rolling.sum())
+	}
+}
+
+func TestDeltaBlockSize(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(int64(kDeltaMinBlockSize), getDeltaBlockSize(0))
+	assert.Equal(int64(kDeltaMinBlockSize), getDeltaBlockSize(kDeltaMinBlockSize*kDeltaMaxBlocks))
+	assert.Equal(int64(2*kDeltaMinBlockSize), getDeltaBlockSize(kDeltaMinBlockSize*kDeltaMaxBlocks+1))
+}
+
+func TestDeltaUpload(t *testing.T) {
+	assert := assert.New(t)
+	if _, err := exec.LookPath("md5sum"); err != nil {
+		if _, err := exec.LookPath("md5"); err != nil {
+			t.Skip("md5sum not found")
+		}
+	}
+	remoteDir, localDir := t.TempDir(), t.TempDir()
+	random := rand.New(rand.NewSource(1))
+	origin := make([]byte, 20*kDeltaMinBlockSize+1000)
+	random.Read(origin)
+	remotePath := filepath.Join(remoteDir, "image.bin")
+	assert.Nil(os.WriteFile(remotePath, origin, 0600))
+
+	// insert at the beginning, change in the middle, and append to the end
+	changed := append([]byte("inserted"), origin...)
+	random.Read(changed[10*kDeltaMinBlockSize : 10*kDeltaMinBlockSize+100])
+	changed = append(changed, []byte("appended")...)
+	localPath := filepath.Join(localDir, "image.bin")
+	assert.Nil(os.WriteFile(localPath, changed, 0644))
+
+	queue := newTransferQueue(newLocalCommandRunner(remoteDir))
+	job := queue.addDelta(localPath, "image.bin")
+	assert.Equal("sync", job.direction())
+	done := waitTransfer(t, queue, job.id, transferDone)
+	data, err := os.ReadFile(remotePath)
+	assert.Nil(err)
+	assert.True(bytes.Equal(changed, data))
+	assert.Less(done.size, int64(3*kDeltaMinBlockSize))
+	stat, err := os.Stat(remotePath)
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), stat.Mode().Perm())
+	entries, err := os.ReadDir(remoteDir)
+	assert.Nil(err)
+	assert.Len(entries, 1)
+
+	// the whole file is uploaded if the remote file doesn't exist
+	job = queue.addDelta(localPath, "new.bin")
+	done = waitTransfer(t, queue, job.id, transferDone)
+	assert.Equal(int64(len(changed)), done.size)
+	data, err = os.ReadFile(filepath.Join(remoteDir, "new.bin"))
+	assert.Nil(err)
+	assert.True(bytes.Equal(changed, data))
+}
@@ -0,0 +1,341 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const kTransferMenuRefresh = 500 * time.Millisecond
+
+const kTransferMenuHelp = "get <remote> [local] | put|sync <local> [remote] | pause|resume|cancel|bg <id> | clear | stop | Enter to return"
+
+// transferMenu shows the transfer queue on the alternate screen when the ExTransferKey is pressed, e.g.:
+//
+//	Host *
+//	    ExTransferKey ^T^T
+//
+// The files are queued by `get` and `put`, or by `sync` which only uploads the changes to the
+// remote file, and could be paused, resumed or cancelled by the id. A remote directory is tarred
+// on the server and downloaded as a single stream by `get`. `bg` keeps the transfer running
+// after the session exits, until it's finished. `stop` stops the running trz / tsz. The remote
+// output is held back while the menu is shown. The queued transfers could be throttled or
+// deferred by the time of day, see transferSchedule.
+type transferMenu struct {
+	reader    io.Reader
+	output    io.Writer
+	queue     *transferQueue
+	key       []byte
+	matched   int
+	buffer    []byte
+	pending   []byte
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	open      bool
+	line      []byte
+	recent    []byte
+	escapes   outputTracker
+	message   string
+	trzszBusy func() bool
+	trzszStop func()
+}
+
+func newTransferMenu(reader io.Reader, output io.Writer, queue *transferQueue, key []byte) *transferMenu {
+	m := &transferMenu{reader: reader, output: output, queue: queue, key: key, buffer: make([]byte, kStdioBufferSize)}
+	m.cond = sync.NewCond(&m.mutex)
+	return m
+}
+
+// setTrzsz lets the menu show and stop the running trz / tsz.
+func (m *transferMenu) setTrzsz(busy func() bool, stop func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.trzszBusy, m.trzszStop = busy, stop
+}
+
+func (m *transferMenu) Read(p []byte) (int, error) {
+	for len(m.pending) == 0 {
+		n, err := m.reader.Read(m.buffer)
+		for _, c := range m.buffer[:n] {
+			m.onInput(c)
+		}
+		if err != nil {
+			m.pending = append(m.pending, m.key[:m.matched]...)
+			m.matched = 0
+			if len(m.pending) > 0 {
+				break
+			}
+			return 0, err
+		}
+	}
+	n := copy(p, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+func (m *transferMenu) onInput(c byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.open {
+		m.menuInput(c)
+		return
+	}
+	if c != m.key[m.matched] && m.matched > 0 {
+		m.pending = append(m.pending, m.key[:m.matched]...)
+		m.matched = 0
+	}
+	if c == m.key[m.matched] {
+		m.matched++
+		if m.matched == len(m.key) {
+			m.matched = 0
+			m.showLocked()
+		}
+		return
+	}
+	m.pending = append(m.pending, c)
+}
+
+func (m *transferMenu) menuInput(c byte) {
+	m.recent = append(m.recent, c)
+	if len(m.recent) > len(m.key) {
+		m.recent = m.recent[len(m.recent)-len(m.key):]
+	}
+	if bytes.Equal(m.recent, m.key) {
+		m.closeLocked()
+		return
+	}
+	if !m.escapes.isGround() || c == 0x1b {
+		// the arrow keys and so on are ignored
+		m.escapes.track([]byte{c})
+		return
+	}
+	switch c {
+	case '\r', '\n':
+		line := strings.TrimSpace(string(m.line))
+		m.line = m.line[:0]
+		if line == "" {
+			m.closeLocked()
+			return
+		}
+		m.message = m.execute(line)
+	case '\b', 0x7f:
+		if len(m.line) > 0 {
+			m.line = m.line[:len(m.line)-1]
+		}
+	case 0x03: // Ctrl+C returns to the session
+		m.closeLocked()
+		return
+	case 0x15: // Ctrl+U clears the line
+		m.line = m.line[:0]
+	default:
+		if c >= 0x20 {
+			m.line = append(m.line, c)
+		}
+	}
+	m.renderLocked()
+}
+
+func (m *transferMenu) execute(line string) string {
+	words, err := splitCommandLine(line)
+	if err != nil || len(words) == 0 {
+		return fmt.Sprintf("invalid command: %s", line)
+	}
+	argument := func(i int) string {
+		if i < len(words) {
+			return words[i]
+		}
+		return ""
+	}
+	switch command := strings.ToLower(words[0]); command {
+	case "get", "put", "sync":
+		if len(words) < 2 || len(words) > 3 {
+			return fmt.Sprintf("usage: %s <source> [target]", command)
+		}
+		var job *transferJob
+		switch command {
+		case "get":
+			job = m.queue.add(false, resolveHomeDir(argument(2)), words[1])
+		case "sync":
+			job = m.queue.addDelta(resolveHomeDir(words[1]), argument(2))
+		default:
+			job = m.queue.add(true, resolveHomeDir(words[1]), argument(2))
+		}
+		return fmt.Sprintf("transfer %d is queued", job.id)
+	case "pause", "resume", "cancel", "bg":
+		id, err := strconv.Atoi(argument(1))
+		if err != nil || len(words) != 2 {
+			return fmt.Sprintf("usage: %s <id>", command)
+		}
+		switch command {
+		case "pause":
+			err = m.queue.pause(id)
+		case "resume":
+			err = m.queue.resume(id)
+		case "bg":
+			err = m.queue.setBackground(id)
+		default:
+			err = m.queue.cancel(id)
+		}
+		if err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("transfer %d is %s", id, map[string]string{"pause": "paused", "resume": "resumed", "cancel": "cancelled", "bg": "in the background"}[command])
+	case "clear":
+		m.queue.clear()
+		return "the finished transfers are cleared"
+	case "stop":
+		if m.trzszBusy == nil || !m.trzszBusy() {
+			return "no running trz / tsz"
+		}
+		go m.trzszStop()
+		return "stopping the running trz / tsz"
+	default:
+		return kTransferMenuHelp
+	}
+}
+
+func formatTransferProgress(job *transferJob) string {
+	if job.size <= 0 {
+		return formatTransferBytes(job.done)
+	}
+	return fmt.Sprintf("%3d%% %s/%s", job.done*100/job.size, formatTransferBytes(job.done), formatTransferBytes(job.size))
+}
+
+func (m *transferMenu) renderLocked() {
+	var builder strings.Builder
+	builder.WriteString("\033[H\033[2J\033[1mtssh transfers\033[0m\r\n\r\n")
+	jobs := m.queue.snapshot()
+	if len(jobs) == 0 {
+		builder.WriteString("  no transfers\r\n")
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		source, target := job.remote, job.local
+		if job.upload {
+			source, target = job.local, job.remote
+		}
+		background := "  "
+		if job.background {
+			background = "bg"
+		}
+		state := job.state.String()
+		if job.state == transferActive && job.deferred {
+			state = "deferred"
+		}
+		builder.WriteString(fmt.Sprintf("%4d  %-9s %s %s  %-24s %s -> %s", job.id, state, job.direction(),
+			background, formatTransferProgress(job), source, target))
+		if job.err != nil {
+			builder.WriteString(fmt.Sprintf("  \033[0;31m%v\033[0m", job.err))
+		}
+		builder.WriteString("\r\n")
+	}
+	if m.trzszBusy != nil && m.trzszBusy() {
+		builder.WriteString("\r\n  trz / tsz is transferring files, `stop` to stop it\r\n")
+	}
+	builder.WriteString("\r\n\033[0;36m" + kTransferMenuHelp + "\033[0m\r\n")
+	if m.message != "" {
+		builder.WriteString(m.message + "\r\n")
+	}
+	builder.WriteString("> " + string(m.line))
+	fmt.Fprint(m.output, builder.String())
+}
+
+func (m *transferMenu) showLocked() {
+	m.open = true
+	m.line, m.recent, m.message = m.line[:0], m.recent[:0], ""
+	fmt.Fprint(m.output, "\033[?1049h")
+	m.renderLocked()
+	go func() {
+		ticker := time.NewTicker(kTransferMenuRefresh)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.mutex.Lock()
+			if !m.open {
+				m.mutex.Unlock()
+				return
+			}
+			m.renderLocked()
+			m.mutex.Unlock()
+		}
+	}()
+}
+
+func (m *transferMenu) closeLocked() {
+	m.open = false
+	fmt.Fprint(m.output, "\033[?1049l")
+	m.cond.Broadcast()
+}
+
+// holdOutput blocks the remote output while the menu is shown.
+func (m *transferMenu) holdOutput() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for m.open {
+		m.cond.Wait()
+	}
+}
+
+type transferMenuOutput struct {
+	reader io.Reader
+	menu   *transferMenu
+}
+
+func (o *transferMenuOutput) Read(p []byte) (int, error) {
+	o.menu.holdOutput()
+	n, err := o.reader.Read(p)
+	o.menu.holdOutput()
+	return n, err
+}
+
+// wrapTransferMenu enables the transfer queue and its menu if ExTransferKey is set.
+func wrapTransferMenu(args *sshArgs, ss *sshSession, stdin io.Reader) io.Reader {
+	key := getExOptionConfig(args, "ExTransferKey")
+	if key == "" || strings.ToLower(key) == "none" {
+		return stdin
+	}
+	ss.transfers = newTransferQueue(newSshCommandRunner(ss.client))
+	ss.transfers.schedule = getTransferSchedule(args)
+	ss.transfers.parallel, ss.transfers.parallelSize = getTransferParallel(args)
+	ss.transfers.onFinish = func(job transferJob) {
+		event, envs := getTransferEvent(&job)
+		// don't block the queue, the next job starts while a slow hook is running
+		go runEventHook(args, event, envs)
+	}
+	onTerminate(ss.transfers.cancelAll)
+	if ss.idle != nil {
+		ss.idle.addBusy(ss.transfers.isBusy)
+	}
+	ss.transferMenu = newTransferMenu(stdin, os.Stderr, ss.transfers, parseConsoleEscape(key))
+	ss.serverOut = &transferMenuOutput{ss.serverOut, ss.transferMenu}
+	debug("press %s to show the transfer queue", key)
+	return ss.transferMenu
+}
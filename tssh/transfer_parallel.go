@@ -0,0 +1,168 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The large queued transfers could be split into chunks over several sessions, e.g.:
+//
+//	Host *
+//	    ExTransferParallel 4
+//	    ExTransferParallelSize 64M
+//
+// Each chunk runs in its own session, i.e., its own channel with its own window, which is much faster
+// on high-bandwidth and high-latency links. Only the files not smaller than ExTransferParallelSize,
+// 64M by default, are split. The chunks are read by `tail -c` and written by `dd seek` on the server.
+
+const (
+	kTransferMaxParallel       = 16
+	kTransferParallelMinSize   = 64 * 1024 * 1024
+	kTransferParallelChunkUnit = 1024 * 1024
+)
+
+func getTransferParallel(args *sshArgs) (int, int64) {
+	parallel, minSize := 1, int64(kTransferParallelMinSize)
+	if value := getExOptionConfig(args, "ExTransferParallel"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > kTransferMaxParallel {
+			warning("invalid ExTransferParallel [%s], should be 1 to %d", value, kTransferMaxParallel)
+		} else {
+			parallel = n
+		}
+	}
+	if value := getExOptionConfig(args, "ExTransferParallelSize"); value != "" {
+		size, err := parseTransferBytes(value)
+		if err != nil {
+			warning("invalid ExTransferParallelSize [%s]: %v", value, err)
+		} else {
+			minSize = size
+		}
+	}
+	return parallel, minSize
+}
+
+type transferChunk struct {
+	offset int64
+	size   int64
+}
+
+// getTransferChunks splits the size into the chunks aligned to kTransferParallelChunkUnit, nil if not split.
+func (q *transferQueue) getTransferChunks(size int64) []transferChunk {
+	if q.parallel <= 1 || size < q.parallelSize || size < 2*kTransferParallelChunkUnit {
+		return nil
+	}
+	units := (size + kTransferParallelChunkUnit - 1) / kTransferParallelChunkUnit
+	unitsPerChunk := (units + int64(q.parallel) - 1) / int64(q.parallel)
+	chunkSize := unitsPerChunk * kTransferParallelChunkUnit
+	var chunks []transferChunk
+	for offset := int64(0); offset < size; offset += chunkSize {
+		n := chunkSize
+		if offset+n > size {
+			n = size - offset
+		}
+		chunks = append(chunks, transferChunk{offset, n})
+	}
+	return chunks
+}
+
+// runChunks runs the chunks concurrently, and stops the others once one of them fails.
+func (q *transferQueue) runChunks(job *transferJob, chunks []transferChunk,
+	run func(chunk transferChunk, cancel <-chan struct{}) error) error {
+	stop := make(chan struct{})
+	cancel := make(chan struct{})
+	go func() {
+		select {
+		case <-job.cancel:
+		case <-stop:
+		}
+		close(cancel)
+	}()
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	for _, chunk := range chunks {
+		wg.Add(1)
+		go func(chunk transferChunk) {
+			defer wg.Done()
+			if err := run(chunk, cancel); err != nil {
+				once.Do(func() {
+					firstErr = err
+					close(stop)
+				})
+			}
+		}(chunk)
+	}
+	wg.Wait()
+	once.Do(func() { close(stop) })
+	select {
+	case <-job.cancel:
+		return errTransferCancelled
+	default:
+	}
+	return firstErr
+}
+
+// parallelUpload writes the chunks to a temporary file next to the remote file, then renames it.
+func (q *transferQueue) parallelUpload(job *transferJob, file *os.File, chunks []transferChunk) error {
+	var output strings.Builder
+	script := fmt.Sprintf(`f=%s; t=$(mktemp "$f.tssh.XXXXXX") && echo "$t"`, quoteRemotePath(job.remote))
+	if err := q.runner(script, nil, &output, job.cancel); err != nil {
+		return err
+	}
+	temp := shellQuote(strings.TrimSpace(output.String()))
+	err := q.runChunks(job, chunks, func(chunk transferChunk, cancel <-chan struct{}) error {
+		reader := &jobReader{q, job, io.NewSectionReader(file, chunk.offset, chunk.size)}
+		return q.runner(fmt.Sprintf("dd of=%s bs=%d seek=%d conv=notrunc 2>/dev/null", temp, kTransferParallelChunkUnit,
+			chunk.offset/kTransferParallelChunkUnit), reader, nil, cancel)
+	})
+	if err == nil {
+		var size int64
+		for _, chunk := range chunks {
+			size += chunk.size
+		}
+		err = q.runner(fmt.Sprintf(`f=%s; t=%s; [ "$(wc -c < "$t")" -eq %d ] || { echo "incomplete upload" >&2; exit 1; }; `,
+			quoteRemotePath(job.remote), temp, size)+kKeepModeScript+`mv -f "$t" "$f"`, nil, nil, job.cancel)
+	}
+	if err != nil {
+		_ = q.runner("rm -f "+temp, nil, nil, nil)
+	}
+	return err
+}
+
+// parallelDownload reads the chunks into the local file at their offsets.
+func (q *transferQueue) parallelDownload(job *transferJob, file *os.File, chunks []transferChunk) error {
+	return q.runChunks(job, chunks, func(chunk transferChunk, cancel <-chan struct{}) error {
+		writer := &jobWriter{q, job, io.NewOffsetWriter(file, chunk.offset)}
+		return q.runner(fmt.Sprintf("tail -c +%d %s | head -c %d", chunk.offset+1, quoteRemotePath(job.remote), chunk.size),
+			nil, writer, cancel)
+	})
+}
@@ -0,0 +1,99 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferChunks(t *testing.T) {
+	assert := assert.New(t)
+	queue := &transferQueue{parallel: 1}
+	assert.Nil(queue.getTransferChunks(1 << 30))
+
+	queue.parallel, queue.parallelSize = 4, 8*kTransferParallelChunkUnit
+	assert.Nil(queue.getTransferChunks(8*kTransferParallelChunkUnit - 1))
+	assert.Equal([]transferChunk{
+		{0, 3 * kTransferParallelChunkUnit},
+		{3 * kTransferParallelChunkUnit, 3 * kTransferParallelChunkUnit},
+		{6 * kTransferParallelChunkUnit, 2*kTransferParallelChunkUnit + 1},
+	}, queue.getTransferChunks(8*kTransferParallelChunkUnit+1))
+	assert.Len(queue.getTransferChunks(8*kTransferParallelChunkUnit), 4)
+}
+
+func TestTransferParallel(t *testing.T) {
+	assert := assert.New(t)
+	remoteDir, localDir := t.TempDir(), t.TempDir()
+	content := make([]byte, 5*kTransferParallelChunkUnit+123)
+	rand.New(rand.NewSource(1)).Read(content)
+	assert.Nil(os.WriteFile(filepath.Join(remoteDir, "remote.bin"), content, 0644))
+	assert.Nil(os.WriteFile(filepath.Join(localDir, "local.bin"), content, 0644))
+	assert.Nil(os.WriteFile(filepath.Join(remoteDir, "local.bin"), nil, 0600))
+
+	var chunks int32
+	runner := newLocalCommandRunner(remoteDir)
+	queue := newTransferQueue(func(command string, stdin io.Reader, stdout io.Writer, cancel <-chan struct{}) error {
+		if strings.HasPrefix(command, "tail -c") || strings.HasPrefix(command, "dd of=") {
+			atomic.AddInt32(&chunks, 1)
+		}
+		return runner(command, stdin, stdout, cancel)
+	})
+	queue.parallel = 4
+
+	job := queue.add(false, filepath.Join(localDir, "remote.bin"), "remote.bin")
+	done := waitTransfer(t, queue, job.id, transferDone)
+	assert.Equal(int64(len(content)), done.done)
+	data, err := os.ReadFile(filepath.Join(localDir, "remote.bin"))
+	assert.Nil(err)
+	assert.True(bytes.Equal(content, data))
+	assert.Equal(int32(3), atomic.LoadInt32(&chunks))
+
+	job = queue.add(true, filepath.Join(localDir, "local.bin"), "")
+	done = waitTransfer(t, queue, job.id, transferDone)
+	assert.Equal(int64(len(content)), done.done)
+	data, err = os.ReadFile(filepath.Join(remoteDir, "local.bin"))
+	assert.Nil(err)
+	assert.True(bytes.Equal(content, data))
+	assert.Equal(int32(6), atomic.LoadInt32(&chunks))
+	stat, err := os.Stat(filepath.Join(remoteDir, "local.bin"))
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), stat.Mode().Perm())
+	entries, err := os.ReadDir(remoteDir)
+	assert.Nil(err)
+	assert.Len(entries, 2)
+
+	// failed without the remote file
+	job = queue.add(false, filepath.Join(localDir, "x.bin"), "not_exist.bin")
+	waitTransfer(t, queue, job.id, transferFailed)
+}
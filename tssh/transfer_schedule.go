@@ -0,0 +1,230 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transferSchedule throttles or defers the large queued transfers by the time of day, e.g.:
+//
+//	Host *
+//	    ExTransferRate 1M
+//	    ExTransferSchedule 19:00-08:00 unlimited
+//	    ExTransferSchedule 12:00-13:00 0
+//	    ExTransferScheduleSize 100M
+//
+// ExTransferRate is the bytes per second outside the windows of ExTransferSchedule, unlimited by default.
+// The rate 0 defers the transfers until another rate applies. The first matched window is used.
+// Only the transfers not smaller than ExTransferScheduleSize are scheduled, all of them by default.
+//
+// It applies to the transfers queued by the transfer menu only. The trz / tsz transfers are not scheduled,
+// since their sizes are unknown to tssh, and deferring them in the middle would time out the trzsz protocol.
+type transferSchedule struct {
+	rate    int64
+	windows []transferWindow
+	minSize int64
+	now     func() time.Time
+}
+
+// transferWindow is the minutes of the day from begin to end, wraps around midnight if end < begin.
+type transferWindow struct {
+	begin int
+	end   int
+	rate  int64
+}
+
+const kTransferUnlimited int64 = -1
+
+// parseTransferBytes parses the size like 512, 64K, 1M and 2G.
+func parseTransferBytes(value string) (int64, error) {
+	value = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	unit := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			unit = 1024
+		case 'M':
+			unit = 1024 * 1024
+		case 'G':
+			unit = 1024 * 1024 * 1024
+		}
+		if unit > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size [%s]", value)
+	}
+	return size * unit, nil
+}
+
+func parseTransferRate(value string) (int64, error) {
+	switch strings.ToLower(value) {
+	case "unlimited", "none":
+		return kTransferUnlimited, nil
+	}
+	return parseTransferBytes(value)
+}
+
+func parseDayMinutes(value string) (int, error) {
+	hour, minute, ok := strings.Cut(value, ":")
+	h, err1 := strconv.Atoi(hour)
+	m, err2 := strconv.Atoi(minute)
+	if !ok || err1 != nil || err2 != nil || h < 0 || h > 24 || m < 0 || m > 59 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("invalid time [%s]", value)
+	}
+	return h*60 + m, nil
+}
+
+func parseTransferWindow(value string) (transferWindow, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return transferWindow{}, fmt.Errorf("should be like [19:00-08:00 unlimited]")
+	}
+	begin, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return transferWindow{}, fmt.Errorf("invalid window [%s]", fields[0])
+	}
+	var window transferWindow
+	var err error
+	if window.begin, err = parseDayMinutes(begin); err != nil {
+		return transferWindow{}, err
+	}
+	if window.end, err = parseDayMinutes(end); err != nil {
+		return transferWindow{}, err
+	}
+	if window.rate, err = parseTransferRate(fields[1]); err != nil {
+		return transferWindow{}, err
+	}
+	return window, nil
+}
+
+func (w *transferWindow) contains(minutes int) bool {
+	if w.begin <= w.end {
+		return minutes >= w.begin && minutes < w.end
+	}
+	return minutes >= w.begin || minutes < w.end
+}
+
+func getTransferSchedule(args *sshArgs) *transferSchedule {
+	schedule := &transferSchedule{rate: kTransferUnlimited, now: time.Now}
+	if value := getExOptionConfig(args, "ExTransferRate"); value != "" {
+		rate, err := parseTransferRate(value)
+		if err != nil {
+			warning("invalid ExTransferRate [%s]: %v", value, err)
+		} else {
+			schedule.rate = rate
+		}
+	}
+	for _, value := range getAllExOptionConfig(args, "ExTransferSchedule") {
+		window, err := parseTransferWindow(value)
+		if err != nil {
+			warning("invalid ExTransferSchedule [%s]: %v", value, err)
+			continue
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	if value := getExOptionConfig(args, "ExTransferScheduleSize"); value != "" {
+		size, err := parseTransferBytes(value)
+		if err != nil {
+			warning("invalid ExTransferScheduleSize [%s]: %v", value, err)
+		} else {
+			schedule.minSize = size
+		}
+	}
+	if schedule.rate == kTransferUnlimited && len(schedule.windows) == 0 {
+		return nil
+	}
+	return schedule
+}
+
+// getRate returns the bytes per second of the transfer at the moment.
+func (s *transferSchedule) getRate(size int64) int64 {
+	if size < s.minSize {
+		return kTransferUnlimited
+	}
+	now := s.now()
+	minutes := now.Hour()*60 + now.Minute()
+	for i := range s.windows {
+		if s.windows[i].contains(minutes) {
+			return s.windows[i].rate
+		}
+	}
+	return s.rate
+}
+
+// getThrottleDelay returns how long the job should sleep to keep the scheduled rate.
+func (q *transferQueue) getThrottleDelay(job *transferJob) time.Duration {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.schedule == nil {
+		return 0
+	}
+	now := time.Now()
+	rate := q.schedule.getRate(job.size)
+	if rate != job.rate || job.rateStart.IsZero() {
+		job.rate, job.rateStart, job.rateDone = rate, now, job.done
+	}
+	job.deferred = rate == 0
+	switch rate {
+	case kTransferUnlimited:
+		return 0
+	case 0:
+		return kTransferReportInterval
+	}
+	expected := time.Duration(float64(job.done-job.rateDone) / float64(rate) * float64(time.Second))
+	delay := job.rateStart.Add(expected).Sub(now)
+	if delay < -time.Second {
+		// don't burst after being paused or blocked
+		job.rateStart, job.rateDone = now, job.done
+		return 0
+	}
+	return delay
+}
+
+// throttle blocks while the job is paused, deferred or faster than the scheduled rate.
+func (q *transferQueue) throttle(job *transferJob) error {
+	for {
+		if err := q.wait(job); err != nil {
+			return err
+		}
+		delay := q.getThrottleDelay(job)
+		if delay <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-job.cancel:
+			timer.Stop()
+			return errTransferCancelled
+		case <-timer.C:
+		}
+	}
+}
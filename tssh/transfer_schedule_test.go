@@ -0,0 +1,144 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTransferBytes(t *testing.T) {
+	assert := assert.New(t)
+	for value, expected := range map[string]int64{"0": 0, "512": 512, "64K": 64 << 10, "64kb": 64 << 10, "1M": 1 << 20, "2G": 2 << 30} {
+		size, err := parseTransferBytes(value)
+		assert.Nil(err)
+		assert.Equal(expected, size, value)
+	}
+	for _, value := range []string{"", "M", "-1", "1T", "abc"} {
+		_, err := parseTransferBytes(value)
+		assert.NotNil(err, value)
+	}
+}
+
+func TestTransferSchedule(t *testing.T) {
+	assert := assert.New(t)
+	newSchedule := func(options ...string) *transferSchedule {
+		var args sshArgs
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return getTransferSchedule(&args)
+	}
+	assert.Nil(newSchedule())
+	assert.Nil(newSchedule("ExTransferRate unlimited"))
+
+	schedule := newSchedule("ExTransferRate 1M", "ExTransferSchedule 19:00-08:00 unlimited",
+		"ExTransferSchedule 12:00-13:00 0", "ExTransferSchedule 12:00-14:00 64K", "ExTransferScheduleSize 100M")
+	assert.NotNil(schedule)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	schedule.now = func() time.Time { return clock }
+	for at, expected := range map[string]int64{
+		"00:00": kTransferUnlimited,
+		"07:59": kTransferUnlimited,
+		"08:00": 1 << 20,
+		"12:00": 0,
+		"12:59": 0,
+		"13:00": 64 << 10,
+		"14:00": 1 << 20,
+		"18:59": 1 << 20,
+		"19:00": kTransferUnlimited,
+		"23:59": kTransferUnlimited,
+	} {
+		minutes, err := parseDayMinutes(at)
+		assert.Nil(err)
+		clock = time.Date(2024, 1, 1, minutes/60, minutes%60, 0, 0, time.Local)
+		assert.Equal(expected, schedule.getRate(100<<20), at)
+		assert.Equal(kTransferUnlimited, schedule.getRate(100<<20-1), at)
+	}
+
+	for _, value := range []string{"19:00 1M", "19:00-25:00 1M", "19:00-08:00", "7-8 1M", "19:00-08:00 fast"} {
+		_, err := parseTransferWindow(value)
+		assert.NotNil(err, value)
+	}
+}
+
+func TestTransferThrottle(t *testing.T) {
+	assert := assert.New(t)
+	remoteDir, localDir := t.TempDir(), t.TempDir()
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	assert.Nil(os.WriteFile(filepath.Join(remoteDir, "remote.txt"), content, 0644))
+
+	var mutex sync.Mutex
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	schedule := &transferSchedule{rate: 40000, windows: []transferWindow{{begin: 12 * 60, end: 13 * 60, rate: 0}}}
+	schedule.now = func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return clock
+	}
+	setClock := func(hour, minute int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		clock = time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	queue := newTransferQueue(newLocalCommandRunner(remoteDir))
+	queue.schedule = schedule
+	job := queue.add(false, filepath.Join(localDir, "remote.txt"), "remote.txt")
+
+	// deferred in the window
+	for i := 0; i < 500; i++ {
+		if jobs := queue.snapshot(); jobs[0].deferred {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	deferred := queue.snapshot()[0]
+	assert.True(deferred.deferred)
+	assert.Equal(transferActive, deferred.state)
+	assert.Equal(int64(0), deferred.done)
+
+	// throttled to 40000 bytes per second after the window
+	setClock(13, 0)
+	begin := time.Now()
+	waitTransfer(t, queue, job.id, transferDone)
+	assert.GreaterOrEqual(time.Since(begin), 2*time.Second)
+	data, err := os.ReadFile(filepath.Join(localDir, "remote.txt"))
+	assert.Nil(err)
+	assert.Equal(content, data)
+
+	// cancelled while deferred
+	setClock(12, 30)
+	job = queue.add(false, filepath.Join(localDir, "cancelled.txt"), "remote.txt")
+	waitTransfer(t, queue, job.id, transferActive)
+	assert.Nil(queue.cancel(job.id))
+	waitTransfer(t, queue, job.id, transferCancelled)
+}
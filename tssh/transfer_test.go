@@ -0,0 +1,238 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLocalCommandRunner runs the commands by the local shell, as if it's the server.
+func newLocalCommandRunner(dir string) commandRunner {
+	return func(command string, stdin io.Reader, stdout io.Writer, cancel <-chan struct{}) error {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = dir
+		cmd.Stdin, cmd.Stdout = stdin, stdout
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		select {
+		case err := <-done:
+			if err != nil && stderr.Len() > 0 {
+				return &exec.ExitError{Stderr: stderr.Bytes()}
+			}
+			return err
+		case <-cancel:
+			_ = cmd.Process.Kill()
+			return errTransferCancelled
+		}
+	}
+}
+
+func waitTransfer(t *testing.T, queue *transferQueue, id int, state transferState) transferJob {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		for _, job := range queue.snapshot() {
+			if job.id == id && job.state == state {
+				return job
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("transfer %d is not %s: %v", id, state, queue.snapshot())
+	return transferJob{}
+}
+
+func TestTransferQueue(t *testing.T) {
+	assert := assert.New(t)
+	remoteDir, localDir := t.TempDir(), t.TempDir()
+	content := bytes.Repeat([]byte("0123456789\n"), 10000)
+	assert.Nil(os.WriteFile(filepath.Join(remoteDir, "remote.txt"), content, 0644))
+	assert.Nil(os.WriteFile(filepath.Join(localDir, "local.txt"), content[:1000], 0644))
+
+	queue := newTransferQueue(newLocalCommandRunner(remoteDir))
+	localPath := filepath.Join(localDir, "remote.txt")
+	job := queue.add(false, localPath, "remote.txt")
+	done := waitTransfer(t, queue, job.id, transferDone)
+	assert.Equal(int64(len(content)), done.size)
+	assert.Equal(int64(len(content)), done.done)
+	data, err := os.ReadFile(localPath)
+	assert.Nil(err)
+	assert.Equal(content, data)
+
+	job = queue.add(true, filepath.Join(localDir, "local.txt"), "")
+	assert.Equal("local.txt", job.remote)
+	waitTransfer(t, queue, job.id, transferDone)
+	data, err = os.ReadFile(filepath.Join(remoteDir, "local.txt"))
+	assert.Nil(err)
+	assert.Equal(content[:1000], data)
+
+	job = queue.add(false, filepath.Join(localDir, "x"), "not_exist.txt")
+	failed := waitTransfer(t, queue, job.id, transferFailed)
+	assert.NotNil(failed.err)
+	assert.False(isFileExist(filepath.Join(localDir, "x")))
+
+	// pause the pending job, and cancel the paused one
+	blocker := make(chan struct{})
+	queue.runner = func(command string, stdin io.Reader, stdout io.Writer, cancel <-chan struct{}) error {
+		<-blocker
+		return newLocalCommandRunner(remoteDir)(command, stdin, stdout, cancel)
+	}
+	zero := queue.add(false, filepath.Join(localDir, "zero"), "remote.txt")
+	first := queue.add(false, filepath.Join(localDir, "first"), "remote.txt")
+	second := queue.add(false, filepath.Join(localDir, "second"), "remote.txt")
+	assert.Nil(queue.pause(first.id))
+	close(blocker)
+	waitTransfer(t, queue, zero.id, transferDone)
+	waitTransfer(t, queue, second.id, transferDone)
+	waitTransfer(t, queue, first.id, transferPaused)
+	assert.Nil(queue.resume(first.id))
+	waitTransfer(t, queue, first.id, transferDone)
+	assert.NotNil(queue.resume(first.id))
+	assert.NotNil(queue.cancel(first.id))
+
+	blocker = make(chan struct{})
+	fourth := queue.add(false, filepath.Join(localDir, "fourth"), "remote.txt")
+	third := queue.add(false, filepath.Join(localDir, "third"), "remote.txt")
+	assert.Nil(queue.pause(third.id))
+	close(blocker)
+	waitTransfer(t, queue, fourth.id, transferDone)
+	assert.Nil(queue.cancel(third.id))
+	waitTransfer(t, queue, third.id, transferCancelled)
+	assert.False(isFileExist(filepath.Join(localDir, "third")))
+
+	queue.clear()
+	assert.Empty(queue.snapshot())
+}
+
+func TestTransferBackground(t *testing.T) {
+	assert := assert.New(t)
+	remoteDir, localDir := t.TempDir(), t.TempDir()
+	assert.Nil(os.WriteFile(filepath.Join(remoteDir, "remote.txt"), []byte("background\n"), 0644))
+
+	blocker := make(chan struct{})
+	queue := newTransferQueue(func(command string, stdin io.Reader, stdout io.Writer, cancel <-chan struct{}) error {
+		select {
+		case <-blocker:
+		case <-cancel:
+			return errTransferCancelled
+		}
+		return newLocalCommandRunner(remoteDir)(command, stdin, stdout, cancel)
+	})
+	finished := make(chan transferJob, 10)
+	queue.onFinish = func(job transferJob) { finished <- job }
+
+	foreground := queue.add(false, filepath.Join(localDir, "foreground"), "remote.txt")
+	background := queue.add(false, filepath.Join(localDir, "background"), "remote.txt")
+	assert.Nil(queue.setBackground(background.id))
+	assert.NotNil(queue.setBackground(100))
+	waitTransfer(t, queue, foreground.id, transferActive)
+
+	waited := make(chan struct{})
+	waiting := 0
+	go func() {
+		queue.waitBackground(func(jobs []transferJob) {
+			if waiting == 0 {
+				close(blocker)
+			}
+			waiting = len(jobs)
+		})
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait for the background transfers timeout")
+	}
+	assert.Equal(1, waiting)
+
+	snapshot := queue.snapshot()
+	assert.Equal(transferCancelled, snapshot[0].state)
+	assert.Equal(transferDone, snapshot[1].state)
+	data, err := os.ReadFile(filepath.Join(localDir, "background"))
+	assert.Nil(err)
+	assert.Equal("background\n", string(data))
+
+	job := <-finished
+	assert.Equal(foreground.id, job.id)
+	event, envs := getTransferEvent(&job)
+	assert.Equal("transfer_cancelled", event)
+	assert.Equal("", envs["TSSH_TRANSFER_BACKGROUND"])
+
+	job = <-finished
+	event, envs = getTransferEvent(&job)
+	assert.Equal("transfer_done", event)
+	assert.Equal(map[string]string{
+		"TSSH_TRANSFER_ID":         "2",
+		"TSSH_TRANSFER_DIRECTION":  job.direction(),
+		"TSSH_TRANSFER_LOCAL":      filepath.Join(localDir, "background"),
+		"TSSH_TRANSFER_REMOTE":     "remote.txt",
+		"TSSH_TRANSFER_SIZE":       "11",
+		"TSSH_TRANSFER_BACKGROUND": "1",
+	}, envs)
+
+	// run the event hook with the environment variables
+	output := filepath.Join(localDir, "event.txt")
+	var args sshArgs
+	args.Destination = "dest"
+	assert.Nil(args.Option.UnmarshalText([]byte(
+		"ExEventHook sh -c 'echo $TSSH_EVENT $TSSH_HOST $TSSH_TRANSFER_REMOTE > " + output + "'")))
+	runEventHook(&args, event, envs)
+	data, err = os.ReadFile(output)
+	assert.Nil(err)
+	assert.Equal("transfer_done dest remote.txt\n", string(data))
+}
+
+func TestTransferMenu(t *testing.T) {
+	assert := assert.New(t)
+	remoteDir := t.TempDir()
+	assert.Nil(os.WriteFile(filepath.Join(remoteDir, "a.txt"), []byte("abc"), 0644))
+	queue := newTransferQueue(newLocalCommandRunner(remoteDir))
+	var output bytes.Buffer
+	local := filepath.Join(t.TempDir(), "a.txt")
+	input := "ls\x14\x14get a.txt " + local + "\r\x1b[Apause 9\r\r\x14x"
+	menu := newTransferMenu(strings.NewReader(input), &output, queue, parseConsoleEscape("^T^T"))
+	data, err := io.ReadAll(menu)
+	assert.Nil(err)
+	assert.Equal("ls\x14x", string(data))
+	assert.Contains(output.String(), "\033[?1049h")
+	assert.Contains(output.String(), "transfer 1 is queued")
+	assert.Contains(output.String(), "no pending or active transfer 9")
+	assert.Contains(output.String(), "\033[?1049l")
+	waitTransfer(t, queue, 1, transferDone)
+	assert.False(menu.open)
+}
@@ -25,7 +25,6 @@ SOFTWARE.
 package tssh
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"net"
@@ -35,6 +34,7 @@ import (
 	"time"
 
 	"github.com/trzsz/trzsz-go/trzsz"
+	"golang.org/x/crypto/ssh"
 )
 
 func writeAll(dst io.Writer, data []byte) error {
@@ -50,20 +50,28 @@ func writeAll(dst io.Writer, data []byte) error {
 	return nil
 }
 
-func wrapStdIO(serverIn io.WriteCloser, serverOut io.Reader, serverErr io.Reader, tty bool) {
+// wrapStdIO forwards the stdio, the line endings are translated on Windows unless it's tty or binary.
+func wrapStdIO(stdin io.Reader, serverIn io.WriteCloser, serverOut io.Reader, stdout io.WriteCloser, serverErr io.Reader, binary bool) {
 	win := runtime.GOOS == "windows"
 	forwardIO := func(reader io.Reader, writer io.WriteCloser, input bool) {
 		defer writer.Close()
-		buffer := make([]byte, 32*1024)
+		buffer := getStdioBuffer()
+		defer putStdioBuffer(buffer)
+		var converted *[]byte
+		if win && !binary {
+			converted = getStdioBuffer()
+			defer putStdioBuffer(converted)
+		}
+		pendingCR := false
 		for {
-			n, err := reader.Read(buffer)
+			n, err := reader.Read((*buffer)[:kStdioBufferSize])
 			if n > 0 {
-				buf := buffer[:n]
-				if win && !tty {
+				buf := (*buffer)[:n]
+				if converted != nil {
 					if input {
-						buf = bytes.ReplaceAll(buf, []byte("\r\n"), []byte("\n"))
+						buf, pendingCR = convertCrlfToLf((*converted)[:0], buf, pendingCR)
 					} else {
-						buf = bytes.ReplaceAll(buf, []byte("\n"), []byte("\r\n"))
+						buf = convertLfToCrlf((*converted)[:0], buf)
 					}
 				}
 				if err := writeAll(writer, buf); err != nil {
@@ -72,7 +80,12 @@ func wrapStdIO(serverIn io.WriteCloser, serverOut io.Reader, serverErr io.Reader
 				}
 			}
 			if err == io.EOF {
-				if win && tty && input {
+				if pendingCR {
+					pendingCR = false
+					_, _ = writer.Write([]byte{'\r'})
+				}
+				// ReadFile of the raw console returns EOF on ctrl + z, unless the input records are read directly
+				if win && binary && input && !isConsoleInputActive() {
 					_, _ = writer.Write([]byte{0x1A}) // ctrl + z
 					continue
 				}
@@ -85,48 +98,119 @@ func wrapStdIO(serverIn io.WriteCloser, serverOut io.Reader, serverErr io.Reader
 		}
 	}
 	if serverIn != nil {
-		go forwardIO(os.Stdin, serverIn, true)
+		go forwardIO(stdin, serverIn, true)
 	}
 	if serverOut != nil {
-		go forwardIO(serverOut, os.Stdout, false)
+		go forwardIO(serverOut, stdout, false)
 	}
 	if serverErr != nil {
 		go forwardIO(serverErr, os.Stderr, false)
 	}
 }
 
+// kTrzszFilterEnv is sent to the server if tssh handles trz / tsz of the session, so that the tssh
+// running in the session, i.e., manual hopping, works as a relay automatically. A LC_ prefixed name
+// is used since most servers accept it, e.g., `AcceptEnv LANG LC_*` by default on many systems.
+//
+// The value is the version and the client port, e.g., `0.1.22:54321`. The variable outlives the session
+// in tmux or screen, so it's trusted only if the client port matches the one in SSH_CONNECTION.
+const kTrzszFilterEnv = "LC_TRZSZ_SSH"
+
+// isNestedSession returns whether tssh runs in a session of another tssh which handles trz / tsz.
+func isNestedSession() bool {
+	value := os.Getenv(kTrzszFilterEnv)
+	if value == "" {
+		return false
+	}
+	idx := strings.LastIndexByte(value, ':')
+	if idx < 0 {
+		// sent without the client port, e.g., by the old versions or through a jump host
+		return true
+	}
+	fields := strings.Fields(os.Getenv("SSH_CONNECTION"))
+	return len(fields) >= 2 && fields[1] == value[idx+1:]
+}
+
+// getTrzszFilterEnv returns the value of kTrzszFilterEnv, which is bound to the client port of the connection.
+func getTrzszFilterEnv(client *ssh.Client) string {
+	if addr, ok := client.LocalAddr().(*net.TCPAddr); ok && addr.Port != 0 {
+		return fmt.Sprintf("%s:%d", kTsshVersion, addr.Port)
+	}
+	return kTsshVersion
+}
+
+// sendTrzszFilterEnv marks the session as handled by tssh, to be detected by the nested tssh.
+func sendTrzszFilterEnv(args *sshArgs, client *ssh.Client, session *ssh.Session) {
+	if args.ReadOnly || strings.ToLower(getExOptionConfig(args, "EnableTrzsz")) == "no" {
+		return
+	}
+	value := getTrzszFilterEnv(client)
+	if err := session.Setenv(kTrzszFilterEnv, value); err != nil {
+		debug("send env failed: %s = \"%s\"", kTrzszFilterEnv, value)
+	}
+}
+
 func enableTrzsz(args *sshArgs, ss *sshSession) error {
+	stdin := wrapConsoleStdin(args, ss)
+	if isTerminal && ss.tty && !args.ReadOnly {
+		stdin = wrapIdleTimeout(args, ss, stdin)
+		stdin = wrapPasteGuard(args, ss, stdin)
+		stdin = wrapConfirmPatterns(args, ss, stdin)
+		stdin = wrapCommandHistory(args, ss, stdin)
+		stdin = wrapTransferMenu(args, ss, stdin)
+	}
+
+	stdout := ss.getStdout()
+
+	// stream the binary data of --pipe
+	if args.Pipe {
+		wrapPipe(ss, stdin, stdout)
+		return nil
+	}
+
 	// not terminal or not tty
 	if !isTerminal || !ss.tty {
-		wrapStdIO(ss.serverIn, ss.serverOut, ss.serverErr, ss.tty)
+		wrapStderr(args, ss)
+		wrapStdIO(stdin, ss.serverIn, ss.serverOut, stdout, ss.serverErr, ss.tty || args.Binary)
 		return nil
 	}
 
-	// disable trzsz ( trz / tsz )
-	if strings.ToLower(getExOptionConfig(args, "EnableTrzsz")) == "no" {
-		wrapStdIO(ss.serverIn, ss.serverOut, ss.serverErr, ss.tty)
-		onTerminalResize(func(width, height int) { _ = ss.session.WindowChange(height, width) })
+	// disable trzsz ( trz / tsz ), no files could be uploaded to the read-only session
+	if strings.ToLower(getExOptionConfig(args, "EnableTrzsz")) == "no" || args.ReadOnly {
+		wrapStdIO(stdin, ss.serverIn, ss.serverOut, stdout, ss.serverErr, ss.tty)
+		onTerminalResize(ss.windowChange)
 		return nil
 	}
 
 	// support trzsz ( trz / tsz )
 
-	wrapStdIO(nil, nil, ss.serverErr, ss.tty)
+	wrapStdIO(nil, nil, nil, nil, ss.serverErr, ss.tty)
 
 	trzsz.SetAffectedByWindows(false)
 
-	if args.Relay || isNoGUI() {
+	// report the transfers to stderr if the progress bar can't be seen
+	monitor := newTransferMonitor(args)
+	stdin = monitor.wrapInput(stdin)
+	ss.serverOut = monitor.wrapOutput(ss.serverOut)
+
+	if args.Relay || isNoGUI() || isNestedSession() {
+		if !args.Relay && isNestedSession() {
+			debug("run trzsz as a relay since running in a session of tssh")
+		}
+		// the transfer frames pass through the relay to stdout, leave the colors to the outer terminal
+		if ss.color != nil {
+			ss.color.bypass = true
+		}
 		// run as a relay
-		trzszRelay := trzsz.NewTrzszRelay(os.Stdin, os.Stdout, ss.serverIn, ss.serverOut, trzsz.TrzszOptions{
+		trzszRelay := trzsz.NewTrzszRelay(stdin, stdout, ss.serverIn, ss.serverOut, trzsz.TrzszOptions{
 			DetectTraceLog: args.TraceLog,
 		})
 		// reset terminal size on resize
-		onTerminalResize(func(width, height int) { _ = ss.session.WindowChange(height, width) })
+		onTerminalResize(ss.windowChange)
 		// setup tunnel connect
-		trzszRelay.SetTunnelConnector(func(port int) net.Conn {
-			conn, _ := dialWithTimeout(ss.client, "tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
-			return conn
-		})
+		if connector := getTrzszTunnelConnector(args, ss); connector != nil {
+			trzszRelay.SetTunnelConnector(monitor.wrapConnector(connector))
+		}
 		return nil
 	}
 
@@ -135,6 +219,11 @@ func enableTrzsz(args *sshArgs, ss *sshSession) error {
 		return fmt.Errorf("get terminal size failed: %v", err)
 	}
 
+	enableZmodem := args.Zmodem || strings.ToLower(getExOptionConfig(args, "EnableZmodem")) == "yes"
+	if enableZmodem {
+		setupZmodemOptions(args)
+	}
+
 	// create a TrzszFilter to support trzsz ( trz / tsz )
 	//
 	//   os.Stdin  ┌────────┐   os.Stdin   ┌─────────────┐   ServerIn   ┌────────┐
@@ -144,17 +233,38 @@ func enableTrzsz(args *sshArgs, ss *sshSession) error {
 	//   os.Stdout │        │   os.Stdout  └─────────────┘   ServerOut  │        │
 	// ◄───────────│        │◄──────────────────────────────────────────┤        │
 	//   os.Stderr └────────┘                  stderr                   └────────┘
-	trzszFilter := trzsz.NewTrzszFilter(os.Stdin, os.Stdout, ss.serverIn, ss.serverOut, trzsz.TrzszOptions{
+	trzszFilter := trzsz.NewTrzszFilter(stdin, stdout, ss.serverIn, ss.serverOut, trzsz.TrzszOptions{
 		TerminalColumns: int32(width),
 		DetectDragFile:  args.DragFile || strings.ToLower(getExOptionConfig(args, "EnableDragFile")) == "yes",
 		DetectTraceLog:  args.TraceLog,
-		EnableZmodem:    args.Zmodem || strings.ToLower(getExOptionConfig(args, "EnableZmodem")) == "yes",
+		EnableZmodem:    enableZmodem,
 	})
 
 	// reset terminal size on resize
 	onTerminalResize(func(width, height int) {
 		trzszFilter.SetTerminalColumns(int32(width))
-		_ = ss.session.WindowChange(height, width)
+		ss.windowChange(width, height)
+	})
+
+	// don't close or lock the idle session while trz / tsz is running
+	if ss.idle != nil {
+		ss.idle.addBusy(trzszFilter.IsTransferringFiles)
+	}
+
+	// show and stop the running trz / tsz in the transfer menu
+	if ss.transferMenu != nil {
+		ss.transferMenu.setTrzsz(trzszFilter.IsTransferringFiles, func() { trzszFilter.StopTransferringFiles(false) })
+	}
+
+	// stop the running trz / tsz on SIGTERM or SIGHUP, and wait a moment for it to tell the server
+	onTerminate(func() {
+		if !trzszFilter.IsTransferringFiles() {
+			return
+		}
+		trzszFilter.StopTransferringFiles(false)
+		for i := 0; i < 10 && trzszFilter.IsTransferringFiles(); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
 	})
 
 	// setup default paths
@@ -162,10 +272,9 @@ func enableTrzsz(args *sshArgs, ss *sshSession) error {
 	trzszFilter.SetDefaultDownloadPath(userConfig.defaultDownloadPath)
 
 	// setup tunnel connect
-	trzszFilter.SetTunnelConnector(func(port int) net.Conn {
-		conn, _ := dialWithTimeout(ss.client, "tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
-		return conn
-	})
+	if connector := getTrzszTunnelConnector(args, ss); connector != nil {
+		trzszFilter.SetTunnelConnector(monitor.wrapConnector(connector))
+	}
 
 	return nil
 }
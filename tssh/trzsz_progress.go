@@ -0,0 +1,247 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	kTransferReportInterval = time.Second
+	kTransferLogInterval    = 5 * time.Second
+	kTransferIdleTimeout    = 30 * time.Second
+)
+
+var transferTriggerRegexp = regexp.MustCompile(`::TRZSZ:TRANSFER:([SRD]):`)
+
+// transferMonitor reports the bytes of the trz / tsz transfers to stderr, e.g., in the relay mode
+// or with the stdout redirected, where the progress bar of trzsz is not shown. ExTrzszProgress:
+//
+//	auto: report if stdout and stderr are not the same terminal, which is the default.
+//	yes:  always report, no: never report.
+//
+// The transfer starts on the trzsz trigger from the server, and ends when the client exits or
+// fails, or after 30 seconds without any data. The bytes on the wire are counted, including the tunnel.
+type transferMonitor struct {
+	mutex    sync.Mutex
+	output   io.Writer
+	terminal bool
+	active   bool
+	mode     string
+	bytes    int64
+	begin    time.Time
+	last     time.Time
+	reported time.Time
+	ticker   *time.Ticker
+}
+
+func newTransferMonitor(args *sshArgs) *transferMonitor {
+	stdoutTerm := isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+	stderrTerm := isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd())
+	switch value := strings.ToLower(getExOptionConfig(args, "ExTrzszProgress")); value {
+	case "", "auto":
+		if stdoutTerm && stderrTerm {
+			return nil
+		}
+	case "yes":
+	case "no":
+		return nil
+	default:
+		warning("unknown ExTrzszProgress [%s], should be one of auto, yes, no", value)
+		return nil
+	}
+	m := &transferMonitor{output: os.Stderr, terminal: stderrTerm}
+	m.ticker = time.NewTicker(kTransferReportInterval)
+	onExit(m.ticker.Stop)
+	go func() {
+		for range m.ticker.C {
+			m.report(time.Now())
+		}
+	}()
+	return m
+}
+
+func formatTransferBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KMGTPE"
+	i := 0
+	for value >= unit && i < len(suffix)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, suffix[i])
+}
+
+func (m *transferMonitor) describe(now time.Time) string {
+	action := "uploading"
+	if m.mode == "S" {
+		action = "downloading"
+	}
+	speed := ""
+	if elapsed := now.Sub(m.begin).Seconds(); elapsed > 0 {
+		speed = fmt.Sprintf(", %s/s", formatTransferBytes(int64(float64(m.bytes)/elapsed)))
+	}
+	return fmt.Sprintf("trzsz %s: %s in %v%s", action, formatTransferBytes(m.bytes), now.Sub(m.begin).Round(time.Second), speed)
+}
+
+func (m *transferMonitor) report(now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.active {
+		return
+	}
+	if now.Sub(m.last) > kTransferIdleTimeout {
+		m.finishLocked(now, "stalled")
+		return
+	}
+	if m.terminal {
+		fmt.Fprintf(m.output, "\r\033[K%s", m.describe(now))
+	} else if now.Sub(m.reported) >= kTransferLogInterval {
+		fmt.Fprintf(m.output, "%s\n", m.describe(now))
+		m.reported = now
+	}
+}
+
+func (m *transferMonitor) finishLocked(now time.Time, result string) {
+	if m.terminal {
+		fmt.Fprintf(m.output, "\r\033[K")
+	}
+	fmt.Fprintf(m.output, "%s, %s\n", m.describe(now), result)
+	m.active = false
+}
+
+func (m *transferMonitor) onOutput(buf []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	if match := transferTriggerRegexp.FindSubmatch(buf); match != nil {
+		if m.active {
+			m.finishLocked(now, "interrupted")
+		}
+		m.active, m.mode, m.bytes, m.begin, m.reported = true, string(match[1]), 0, now, now
+	}
+	m.countLocked(now, len(buf))
+}
+
+func (m *transferMonitor) onInput(buf []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	m.countLocked(now, len(buf))
+	if !m.active {
+		return
+	}
+	if bytes.Contains(buf, []byte("#EXIT:")) {
+		m.finishLocked(now, "done")
+	} else if bytes.Contains(buf, []byte("#FAIL:")) || bytes.Contains(buf, []byte("#fail:")) {
+		m.finishLocked(now, "failed")
+	}
+}
+
+func (m *transferMonitor) countLocked(now time.Time, n int) {
+	if m.active && n > 0 {
+		m.bytes += int64(n)
+		m.last = now
+	}
+}
+
+func (m *transferMonitor) count(n int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.countLocked(time.Now(), n)
+}
+
+type transferReader struct {
+	reader  io.Reader
+	onBytes func([]byte)
+}
+
+func (r *transferReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.onBytes(p[:n])
+	}
+	return n, err
+}
+
+type transferConn struct {
+	net.Conn
+	monitor *transferMonitor
+}
+
+func (c *transferConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.monitor.count(n)
+	return n, err
+}
+
+func (c *transferConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.monitor.count(n)
+	return n, err
+}
+
+// wrapInput counts the client input, a nil monitor returns the reader as it is.
+func (m *transferMonitor) wrapInput(reader io.Reader) io.Reader {
+	if m == nil {
+		return reader
+	}
+	return &transferReader{reader, m.onInput}
+}
+
+// wrapOutput detects the transfers and counts the server output, a nil monitor returns the reader as it is.
+func (m *transferMonitor) wrapOutput(reader io.Reader) io.Reader {
+	if m == nil {
+		return reader
+	}
+	return &transferReader{reader, m.onOutput}
+}
+
+// wrapConnector counts the tunnel connections, a nil monitor returns the connector as it is.
+func (m *transferMonitor) wrapConnector(connector func(int) net.Conn) func(int) net.Conn {
+	if m == nil {
+		return connector
+	}
+	return func(port int) net.Conn {
+		conn := connector(port)
+		if conn == nil {
+			return nil
+		}
+		return &transferConn{conn, m}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransferMonitor(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("100 B", formatTransferBytes(100))
+	assert.Equal("1.5 KiB", formatTransferBytes(1536))
+	assert.Equal("2.0 GiB", formatTransferBytes(2<<30))
+
+	var output bytes.Buffer
+	monitor := &transferMonitor{output: &output}
+	readAll := func(reader io.Reader) {
+		_, err := io.ReadAll(reader)
+		assert.Nil(err)
+	}
+
+	// nothing is counted before the trigger
+	readAll(monitor.wrapOutput(strings.NewReader("$ ls\r\n")))
+	assert.False(monitor.active)
+	readAll(monitor.wrapOutput(strings.NewReader("::TRZSZ:TRANSFER:S:1.1.6:0123456789\r\n")))
+	assert.True(monitor.active)
+	assert.Equal("S", monitor.mode)
+	readAll(monitor.wrapOutput(strings.NewReader(strings.Repeat("x", 2048))))
+
+	now := monitor.begin.Add(6 * time.Second)
+	monitor.last = now
+	monitor.report(now)
+	assert.True(strings.HasPrefix(output.String(), "trzsz downloading: 2.0 KiB in 6s, "))
+	output.Reset()
+	monitor.report(now.Add(time.Second))
+	assert.Equal("", output.String())
+
+	readAll(monitor.wrapInput(strings.NewReader("#EXIT:xxx\n")))
+	assert.False(monitor.active)
+	assert.True(strings.HasSuffix(output.String(), ", done\n"))
+
+	// the stalled transfer
+	output.Reset()
+	readAll(monitor.wrapOutput(strings.NewReader("::TRZSZ:TRANSFER:R:1.1.6:0123456789\r\n")))
+	assert.Equal("R", monitor.mode)
+	monitor.report(monitor.last.Add(kTransferIdleTimeout + time.Second))
+	assert.False(monitor.active)
+	assert.Contains(output.String(), "trzsz uploading: ")
+	assert.True(strings.HasSuffix(output.String(), ", stalled\n"))
+
+	// a nil monitor wraps nothing
+	var nilMonitor *transferMonitor
+	reader := strings.NewReader("")
+	assert.Equal(reader, nilMonitor.wrapInput(reader))
+}
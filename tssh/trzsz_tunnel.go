@@ -0,0 +1,160 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trzszTunnel configures how trz / tsz connect to the tunnel port listened by the server, e.g.:
+//
+//	Host server1
+//	    ExTrzszTunnel yes             # no: always transfer through the terminal
+//	    ExTrzszTunnelHost ::1         # the address of the server's listener, default: 127.0.0.1
+//	    ExTrzszTunnelPorts 20000-20999 # the ports to connect, others go through the terminal at once
+//	    ExTrzszTunnelTimeout 3s       # the timeout of each connection attempt, default: 1s
+//	    ExTrzszTunnelRetries 2        # the retries after the first attempt fails, default: 0
+//	    ExTrzszTunnelFallback warn    # warn when falling back to the terminal, default: quiet
+//
+// Some servers firewall the loopback listeners, and the transfers silently fall back to the slow mode.
+type trzszTunnel struct {
+	host    string
+	minPort int
+	maxPort int
+	timeout time.Duration
+	retries int
+	warn    bool
+}
+
+func parseTunnelPorts(value string) (int, int, error) {
+	low, high, found := strings.Cut(value, "-")
+	if !found {
+		high = low
+	}
+	minPort, err1 := strconv.Atoi(strings.TrimSpace(low))
+	maxPort, err2 := strconv.Atoi(strings.TrimSpace(high))
+	if err1 != nil || err2 != nil || minPort < 1 || maxPort > 65535 || minPort > maxPort {
+		return 0, 0, fmt.Errorf("invalid ExTrzszTunnelPorts [%s], should be a port or a range like 20000-20999", value)
+	}
+	return minPort, maxPort, nil
+}
+
+// getTrzszTunnel returns the tunnel configuration, nil means the tunnel is disabled.
+func getTrzszTunnel(args *sshArgs) (*trzszTunnel, error) {
+	switch value := strings.ToLower(getExOptionConfig(args, "ExTrzszTunnel")); value {
+	case "", "yes":
+	case "no":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid ExTrzszTunnel [%s], should be yes or no", value)
+	}
+
+	tunnel := &trzszTunnel{host: "127.0.0.1", minPort: 1, maxPort: 65535, timeout: time.Second}
+	if host := getExOptionConfig(args, "ExTrzszTunnelHost"); host != "" {
+		tunnel.host = host
+	}
+	if value := getExOptionConfig(args, "ExTrzszTunnelPorts"); value != "" {
+		var err error
+		if tunnel.minPort, tunnel.maxPort, err = parseTunnelPorts(value); err != nil {
+			return nil, err
+		}
+	}
+	if value := getExOptionConfig(args, "ExTrzszTunnelTimeout"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			if seconds, e := strconv.Atoi(value); e == nil {
+				timeout, err = time.Duration(seconds)*time.Second, nil
+			}
+		}
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid ExTrzszTunnelTimeout [%s]", value)
+		}
+		tunnel.timeout = timeout
+	}
+	if value := getExOptionConfig(args, "ExTrzszTunnelRetries"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return nil, fmt.Errorf("invalid ExTrzszTunnelRetries [%s]", value)
+		}
+		tunnel.retries = retries
+	}
+	switch value := strings.ToLower(getExOptionConfig(args, "ExTrzszTunnelFallback")); value {
+	case "", "quiet":
+	case "warn":
+		tunnel.warn = true
+	default:
+		return nil, fmt.Errorf("invalid ExTrzszTunnelFallback [%s], should be quiet or warn", value)
+	}
+	return tunnel, nil
+}
+
+// connect returns the connection to the tunnel port, or nil to transfer through the terminal.
+func (t *trzszTunnel) connect(port int, dial func(addr string, timeout time.Duration) (net.Conn, error)) net.Conn {
+	if port < t.minPort || port > t.maxPort {
+		t.fallback(fmt.Sprintf("port %d is not in ExTrzszTunnelPorts %d-%d", port, t.minPort, t.maxPort))
+		return nil
+	}
+	addr := joinHostPort(t.host, strconv.Itoa(port))
+	var err error
+	for i := 0; i <= t.retries; i++ {
+		var conn net.Conn
+		if conn, err = dial(addr, t.timeout); err == nil {
+			debug("trzsz tunnel to [%s] connected", addr)
+			return conn
+		}
+		debug("trzsz tunnel to [%s] attempt %d failed: %v", addr, i+1, err)
+	}
+	t.fallback(fmt.Sprintf("connect to [%s] failed: %v", addr, err))
+	return nil
+}
+
+func (t *trzszTunnel) fallback(reason string) {
+	if t.warn {
+		fmt.Fprintf(os.Stderr, "\r\n\033[0;33mtrzsz tunnel %s, transferring through the terminal which is slower\033[0m\r\n", reason)
+	} else {
+		debug("trzsz tunnel %s, transferring through the terminal", reason)
+	}
+}
+
+func getTrzszTunnelConnector(args *sshArgs, ss *sshSession) func(int) net.Conn {
+	tunnel, err := getTrzszTunnel(args)
+	if err != nil {
+		warning("%v", err)
+		return nil
+	}
+	if tunnel == nil {
+		return nil
+	}
+	return func(port int) net.Conn {
+		return tunnel.connect(port, func(addr string, timeout time.Duration) (net.Conn, error) {
+			return dialWithTimeout(ss.client, "tcp", addr, timeout)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrzszTunnel(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options ...string) *sshArgs {
+		args := &sshArgs{}
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return args
+	}
+
+	tunnel, err := getTrzszTunnel(newArgs())
+	assert.Nil(err)
+	assert.Equal(&trzszTunnel{host: "127.0.0.1", minPort: 1, maxPort: 65535, timeout: time.Second}, tunnel)
+	tunnel, err = getTrzszTunnel(newArgs("ExTrzszTunnel no"))
+	assert.Nil(err)
+	assert.Nil(tunnel)
+	tunnel, err = getTrzszTunnel(newArgs("ExTrzszTunnelHost ::1", "ExTrzszTunnelPorts 20000-20999",
+		"ExTrzszTunnelTimeout 3", "ExTrzszTunnelRetries 2", "ExTrzszTunnelFallback warn"))
+	assert.Nil(err)
+	assert.Equal(&trzszTunnel{host: "::1", minPort: 20000, maxPort: 20999, timeout: 3 * time.Second, retries: 2, warn: true}, tunnel)
+	tunnel, err = getTrzszTunnel(newArgs("ExTrzszTunnelPorts 8080", "ExTrzszTunnelTimeout 500ms"))
+	assert.Nil(err)
+	assert.Equal(8080, tunnel.minPort)
+	assert.Equal(8080, tunnel.maxPort)
+	assert.Equal(500*time.Millisecond, tunnel.timeout)
+	for _, option := range []string{"ExTrzszTunnel maybe", "ExTrzszTunnelPorts 2000-1000", "ExTrzszTunnelPorts 0",
+		"ExTrzszTunnelTimeout -1s", "ExTrzszTunnelRetries x", "ExTrzszTunnelFallback stdio"} {
+		_, err = getTrzszTunnel(newArgs(option))
+		assert.NotNil(err, option)
+	}
+
+	var dialed []string
+	failures := 0
+	dial := func(addr string, timeout time.Duration) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if len(dialed) <= failures {
+			return nil, fmt.Errorf("connection refused")
+		}
+		conn, _ := net.Pipe()
+		return conn, nil
+	}
+	tunnel = &trzszTunnel{host: "::1", minPort: 20000, maxPort: 20999, timeout: time.Second, retries: 2}
+	assert.Nil(tunnel.connect(8080, dial))
+	assert.Nil(dialed)
+	failures = 2
+	assert.NotNil(tunnel.connect(20001, dial))
+	assert.Equal([]string{"[::1]:20001", "[::1]:20001", "[::1]:20001"}, dialed)
+	dialed, failures = nil, 3
+	assert.Nil(tunnel.connect(20001, dial))
+	assert.Equal(3, len(dialed))
+}
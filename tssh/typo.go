@@ -0,0 +1,116 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const kMaxSimilarAliases = 5
+
+// editDistance returns the edit distance between a and b, in which swapping
+// two adjacent characters counts as one typo, the same as a substitution.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	last := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && last[j-2]+1 < curr[j] {
+				curr[j] = last[j-2] + 1
+			}
+		}
+		last, prev, curr = prev, curr, last
+	}
+	return prev[len(t)]
+}
+
+// getSimilarAliases returns the aliases which are likely mistyped as the dest, the closest first.
+// One typo is allowed for every 3 characters, e.g., prod-db for prdo-db or prod-bd.
+func getSimilarAliases(dest string, hosts []*sshHost) []string {
+	type similarAlias struct {
+		alias    string
+		distance int
+	}
+	dest = strings.ToLower(dest)
+	maxDistance := len(dest) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	var similar []similarAlias
+	for _, host := range hosts {
+		distance := editDistance(dest, strings.ToLower(host.Alias))
+		if distance <= maxDistance {
+			similar = append(similar, similarAlias{host.Alias, distance})
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool { return similar[i].distance < similar[j].distance })
+	var aliases []string
+	for i := 0; i < len(similar) && i < kMaxSimilarAliases; i++ {
+		aliases = append(aliases, similar[i].alias)
+	}
+	return aliases
+}
+
+// confirmUnknownDestination asks whether the unknown dest is a typo of the similar aliases before
+// resolving it by DNS, so that a lookalike host is not connected to by accident.
+func confirmUnknownDestination(dest string, hosts []*sshHost) (string, bool, error) {
+	if strings.ToLower(dest) == "localhost" {
+		return dest, false, nil
+	}
+	aliases := getSimilarAliases(dest, hosts)
+	if len(aliases) == 0 {
+		return dest, false, nil
+	}
+	if !isTerminal {
+		warning("%s is not a configured alias, did you mean %s?", dest, strings.Join(aliases, ", "))
+		return dest, false, nil
+	}
+	asIs := fmt.Sprintf("%s (connect as is)", dest)
+	choice := promptList(fmt.Sprintf("%s is not a configured alias, did you mean", dest),
+		"The similar aliases first, or resolve it as a host name", append(aliases, asIs))
+	if choice == asIs {
+		return dest, false, nil
+	}
+	return choice, false, nil
+}
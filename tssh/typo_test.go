@@ -0,0 +1,61 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, editDistance("web", "web"))
+	assert.Equal(3, editDistance("", "web"))
+	assert.Equal(1, editDistance("web1", "web2"))
+	assert.Equal(1, editDistance("prdo", "prod"))
+	assert.Equal(2, editDistance("ab", "ba1"))
+	assert.Equal(1, editDistance("prod-db", "prod-db1"))
+	assert.Equal(3, editDistance("kitten", "sitting"))
+	assert.Equal(1, editDistance("主机1", "主机2"))
+}
+
+func TestSimilarAliases(t *testing.T) {
+	assert := assert.New(t)
+	var hosts []*sshHost
+	for _, alias := range []string{"prod-db", "prod-db1", "Prod-Web", "staging", "db"} {
+		hosts = append(hosts, &sshHost{Alias: alias})
+	}
+	assert.Equal([]string{"prod-db", "prod-db1"}, getSimilarAliases("prdo-db", hosts))
+	assert.Equal([]string{"Prod-Web", "prod-db", "prod-db1"}, getSimilarAliases("prod-wbe", hosts))
+	assert.Equal([]string{"staging"}, getSimilarAliases("stagign", hosts))
+	assert.Equal([]string{"db"}, getSimilarAliases("d", hosts))
+	assert.Empty(getSimilarAliases("example", hosts))
+
+	dest, quit, err := confirmUnknownDestination("prdo-db", hosts)
+	assert.Nil(err)
+	assert.False(quit)
+	assert.Equal("prdo-db", dest)
+}
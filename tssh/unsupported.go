@@ -0,0 +1,157 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// kUnsupportedOptions are the OpenSSH options which tssh ignores, with the hints of the alternatives if any.
+var kUnsupportedOptions = map[string]string{
+	"AddKeysToAgent":                   "",
+	"AddressFamily":                    "",
+	"BindAddress":                      "",
+	"BindInterface":                    "",
+	"CanonicalDomains":                 "",
+	"CanonicalizeFallbackLocal":        "",
+	"CanonicalizeHostname":             "",
+	"CanonicalizeMaxDots":              "",
+	"CanonicalizePermittedCNAMEs":      "",
+	"CASignatureAlgorithms":            "",
+	"CertificateFile":                  "",
+	"ChannelTimeout":                   "",
+	"CheckHostIP":                      "",
+	"Compression":                      "",
+	"ConnectionAttempts":               "",
+	"ConnectTimeout":                   "",
+	"EnableEscapeCommandline":          "",
+	"EnableSSHKeysign":                 "",
+	"EscapeChar":                       "",
+	"ExitOnForwardFailure":             "",
+	"FingerprintHash":                  "",
+	"ForkAfterAuthentication":          "use the -f flag instead",
+	"ForwardX11":                       "X11 forwarding is not supported, use ssh -X instead",
+	"ForwardX11Timeout":                "X11 forwarding is not supported, use ssh -X instead",
+	"ForwardX11Trusted":                "X11 forwarding is not supported, use ssh -Y instead",
+	"GSSAPIAuthentication":             "",
+	"GSSAPIDelegateCredentials":        "",
+	"HashKnownHosts":                   "",
+	"HostbasedAcceptedAlgorithms":      "",
+	"HostbasedAuthentication":          "",
+	"HostKeyAlgorithms":                "",
+	"HostKeyAlias":                     "",
+	"IdentitiesOnly":                   "",
+	"IPQoS":                            "",
+	"KexAlgorithms":                    "",
+	"KnownHostsCommand":                "",
+	"MACs":                             "",
+	"NoHostAuthenticationForLocalhost": "",
+	"ObscureKeystrokeTiming":           "",
+	"PKCS11Provider":                   "",
+	"PreferredAuthentications":         "",
+	"ProxyUseFdpass":                   "",
+	"PubkeyAcceptedAlgorithms":         "",
+	"PubkeyAcceptedKeyTypes":           "",
+	"RekeyLimit":                       "",
+	"RequiredRSASize":                  "",
+	"RevokedHostKeys":                  "",
+	"SecurityKeyProvider":              "",
+	"SessionType":                      "use the -N or -s flag instead",
+	"StdinNull":                        "use the -n flag instead",
+	"Tag":                              "",
+	"TCPKeepAlive":                     "use ServerAliveInterval instead",
+	"Tunnel":                           "",
+	"TunnelDevice":                     "",
+	"UpdateHostKeys":                   "",
+	"VerifyHostKeyDNS":                 "",
+	"VisualHostKey":                    "",
+	"XAuthLocation":                    "X11 forwarding is not supported, use ssh -X instead",
+}
+
+var warnedOptions sync.Map
+
+// getUnsupportedOption returns the canonical name of the option if tssh does not support it.
+func getUnsupportedOption(name string) (string, string, bool) {
+	for option, hint := range kUnsupportedOptions {
+		if strings.EqualFold(option, name) {
+			return option, hint, true
+		}
+	}
+	return "", "", false
+}
+
+// isOptionWarningIgnored checks IgnoreUnsupportedOptions in ~/.tssh.conf, e.g.,
+// `IgnoreUnsupportedOptions = ForwardX11, Compression`, or `*` to ignore all.
+func isOptionWarningIgnored(option string) bool {
+	for _, name := range strings.FieldsFunc(userConfig.ignoreUnsupportedOptions, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		if name == "*" || strings.EqualFold(name, option) {
+			return true
+		}
+	}
+	return false
+}
+
+// getUnsupportedOptions returns the sorted unsupported options used by the destination or the -o options.
+func getUnsupportedOptions(args *sshArgs) []string {
+	used := make(map[string]bool)
+	check := func(key string) {
+		if option, _, ok := getUnsupportedOption(key); ok && !isOptionWarningIgnored(option) {
+			used[option] = true
+		}
+	}
+	for key := range args.Option.options {
+		check(key)
+	}
+	userConfig.doLoadConfig()
+	for _, index := range userConfig.getConfigIndexes() {
+		for key := range index.getSettings(args.Destination) {
+			check(key)
+		}
+	}
+	var options []string
+	for option := range used {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	return options
+}
+
+// warnUnsupportedOptions warns each unsupported option once instead of dropping it silently.
+func warnUnsupportedOptions(args *sshArgs) {
+	for _, option := range getUnsupportedOptions(args) {
+		if _, warned := warnedOptions.LoadOrStore(option, true); warned {
+			continue
+		}
+		_, hint, _ := getUnsupportedOption(option)
+		if hint != "" {
+			hint += ", or "
+		}
+		warning("%s is not supported by tssh and is ignored, %sadd it to IgnoreUnsupportedOptions in ~/.tssh.conf to hide this warning", option, hint)
+	}
+}
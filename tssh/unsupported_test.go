@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsupportedOptions(t *testing.T) {
+	assert := assert.New(t)
+	defer func(config *tsshConfig) { userConfig = config }(userConfig)
+	config := filepath.Join(t.TempDir(), "config")
+	assert.Nil(os.WriteFile(config, []byte(`
+Host web1
+    HostName 10.0.0.1
+    ForwardX11 yes
+    compression yes
+Host *
+    ServerAliveInterval 30
+    IdentitiesOnly yes
+`), 0600))
+	userConfig = &tsshConfig{configPath: config}
+
+	option, hint, ok := getUnsupportedOption("forwardx11")
+	assert.True(ok)
+	assert.Equal("ForwardX11", option)
+	assert.NotEmpty(hint)
+	_, _, ok = getUnsupportedOption("ServerAliveInterval")
+	assert.False(ok)
+
+	args := &sshArgs{Destination: "web1", Option: sshOption{map[string][]string{"kexalgorithms": {"curve25519-sha256"}}}}
+	assert.Equal([]string{"Compression", "ForwardX11", "IdentitiesOnly", "KexAlgorithms"}, getUnsupportedOptions(args))
+	assert.Equal([]string{"IdentitiesOnly"}, getUnsupportedOptions(&sshArgs{Destination: "db1"}))
+
+	userConfig.ignoreUnsupportedOptions = "forwardx11, Compression KexAlgorithms"
+	assert.Equal([]string{"IdentitiesOnly"}, getUnsupportedOptions(args))
+	userConfig.ignoreUnsupportedOptions = "*"
+	assert.Empty(getUnsupportedOptions(args))
+
+	// every unsupported option should not be documented as a supported one
+	for option := range kUnsupportedOptions {
+		doc, _ := getOptionDoc(option)
+		assert.Nil(doc, option)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const kUrlHandlerBundleID = "com.trzsz.tssh.urlhandler"
+
+const kLsregisterPath = "/System/Library/Frameworks/CoreServices.framework/Frameworks/LaunchServices.framework/Support/lsregister"
+
+// the ssh:// link is opened in the terminal which runs `tssh --register-url-handler`.
+const kTerminalUrlScript = `on open location theURL
+	tell application "Terminal"
+		activate
+		do script %s & quoted form of theURL
+	end tell
+end open location
+`
+
+const kITermUrlScript = `on open location theURL
+	tell application "iTerm"
+		activate
+		create window with default profile command %s & quoted form of theURL
+	end tell
+end open location
+`
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func runUrlHandlerCommand(name string, args ...string) error {
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v %s", filepath.Base(name), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// registerUrlHandler compiles an AppleScript applet which handles ssh:// links,
+// registers it to LaunchServices, and makes it the default handler of ssh://.
+func registerUrlHandler(exe string) error {
+	template := kTerminalUrlScript
+	if os.Getenv("TERM_PROGRAM") == "iTerm.app" {
+		template = kITermUrlScript
+	}
+	script := fmt.Sprintf(template, appleScriptString(shellQuote(exe)+" "))
+
+	appDir := filepath.Join(userHomeDir, "Applications")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		return err
+	}
+	appPath := filepath.Join(appDir, "tssh URL Handler.app")
+	_ = os.RemoveAll(appPath)
+	scriptFile, err := os.CreateTemp("", "tssh-url-handler-*.applescript")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scriptFile.Name())
+	if _, err := scriptFile.WriteString(script); err != nil {
+		scriptFile.Close()
+		return err
+	}
+	scriptFile.Close()
+	if err := runUrlHandlerCommand("osacompile", "-o", appPath, scriptFile.Name()); err != nil {
+		return err
+	}
+
+	plist := filepath.Join(appPath, "Contents", "Info.plist")
+	for _, args := range [][]string{
+		{"-replace", "CFBundleIdentifier", "-string", kUrlHandlerBundleID},
+		{"-replace", "LSUIElement", "-bool", "true"},
+		{"-replace", "CFBundleURLTypes", "-json", `[{"CFBundleURLName":"SSH","CFBundleURLSchemes":["ssh"]}]`},
+	} {
+		if err := runUrlHandlerCommand("plutil", append(args, plist)...); err != nil {
+			return err
+		}
+	}
+	if err := runUrlHandlerCommand(kLsregisterPath, "-f", appPath); err != nil {
+		return err
+	}
+	return runUrlHandlerCommand("osascript", "-l", "JavaScript", "-e",
+		fmt.Sprintf(`ObjC.import("CoreServices"); $.LSSetDefaultHandlerForURLScheme($("ssh"), $(%q))`, kUrlHandlerBundleID))
+}
@@ -0,0 +1,83 @@
+//go:build !windows && !darwin
+
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const kUrlHandlerDesktopFile = "tssh-url-handler.desktop"
+
+const kUrlHandlerDesktopTemplate = `[Desktop Entry]
+Type=Application
+Name=tssh
+Comment=Open ssh:// links with tssh
+Exec=%s %%u
+Terminal=true
+NoDisplay=true
+MimeType=x-scheme-handler/ssh;
+`
+
+// quoteDesktopExec quotes the program path for the Exec key of the desktop entry.
+func quoteDesktopExec(path string) string {
+	if !strings.ContainsAny(path, " \t\n\"'\\><~|&;$*?#()`") {
+		return path
+	}
+	replacer := strings.NewReplacer(`\`, `\\\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+	return `"` + replacer.Replace(path) + `"`
+}
+
+// registerUrlHandler installs a desktop entry as the default handler of x-scheme-handler/ssh,
+// with Terminal=true, the desktop opens it in the user's preferred terminal.
+func registerUrlHandler(exe string) error {
+	dir := filepath.Join(userHomeDir, ".local", "share", "applications")
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		dir = filepath.Join(dataHome, "applications")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, kUrlHandlerDesktopFile)
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(kUrlHandlerDesktopTemplate, quoteDesktopExec(exe))), 0644); err != nil {
+		return err
+	}
+	debug("desktop entry written to %s", path)
+
+	if _, err := exec.LookPath("update-desktop-database"); err == nil {
+		if err := exec.Command("update-desktop-database", dir).Run(); err != nil {
+			debug("update-desktop-database failed: %v", err)
+		}
+	}
+	if output, err := exec.Command("xdg-mime", "default", kUrlHandlerDesktopFile, "x-scheme-handler/ssh").CombinedOutput(); err != nil {
+		return fmt.Errorf("xdg-mime default failed: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// registerUrlHandler registers the ssh:// protocol for the current user,
+// Windows opens the console program in the user's default terminal application.
+func registerUrlHandler(exe string) error {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, `Software\Classes\ssh`, registry.ALL_ACCESS)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	if err := key.SetStringValue("", "URL:SSH Protocol"); err != nil {
+		return err
+	}
+	if err := key.SetStringValue("URL Protocol", ""); err != nil {
+		return err
+	}
+
+	icon, _, err := registry.CreateKey(key, "DefaultIcon", registry.ALL_ACCESS)
+	if err != nil {
+		return err
+	}
+	defer icon.Close()
+	if err := icon.SetStringValue("", fmt.Sprintf(`"%s",0`, exe)); err != nil {
+		return err
+	}
+
+	command, _, err := registry.CreateKey(key, `shell\open\command`, registry.ALL_ACCESS)
+	if err != nil {
+		return err
+	}
+	defer command.Close()
+	return command.SetStringValue("", fmt.Sprintf(`"%s" "%%1"`, exe))
+}
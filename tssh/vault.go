@@ -0,0 +1,202 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const kVaultVersion = 1
+
+type vaultFile struct {
+	Version int    `json:"version"`
+	Salt    string `json:"salt"`
+	Data    string `json:"data"`
+}
+
+type secretVault struct {
+	path       string
+	passphrase []byte
+	secrets    map[string]string
+}
+
+func getVaultPath() string {
+	if userConfig.vaultPath != "" {
+		return userConfig.vaultPath
+	}
+	return filepath.Join(userHomeDir, ".ssh", "tssh.vault")
+}
+
+func newVaultCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 32768, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	aesCipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(aesCipher)
+}
+
+func openVault(path string, passphrase []byte) (*secretVault, error) {
+	vault := &secretVault{path: path, passphrase: passphrase, secrets: make(map[string]string)}
+	if !isFileExist(path) {
+		return vault, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file vaultFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid vault file: %v", err)
+	}
+	if file.Version != kVaultVersion {
+		return nil, fmt.Errorf("vault version %d is not supported", file.Version)
+	}
+	salt, err := hex.DecodeString(file.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault salt: %v", err)
+	}
+	data, err := hex.DecodeString(file.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault data: %v", err)
+	}
+
+	aesGCM, err := newVaultCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aesGCM.NonceSize()
+	if len(data) < nonceSize {
+		return nil, fmt.Errorf("vault data too short")
+	}
+	plain, err := aesGCM.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("passphrase incorrect")
+	}
+	if err := json.Unmarshal(plain, &vault.secrets); err != nil {
+		return nil, fmt.Errorf("invalid vault secrets: %v", err)
+	}
+	return vault, nil
+}
+
+func (v *secretVault) save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	aesGCM, err := newVaultCipher(v.passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(&vaultFile{
+		Version: kVaultVersion,
+		Salt:    hex.EncodeToString(salt),
+		Data:    hex.EncodeToString(aesGCM.Seal(nonce, nonce, plain, nil)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(v.path); !isFileExist(dir) {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	// write to a temporary file and rename it, so the vault will not be corrupted.
+	tmpPath := v.path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(content, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, v.path)
+}
+
+func (v *secretVault) names() []string {
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getVaultPassphrase() ([]byte, error) {
+	if passphrase := os.Getenv("TSSH_VAULT_PASSPHRASE"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	return readSecret(fmt.Sprintf("Enter passphrase for vault '%s': ", getVaultPath()))
+}
+
+var getVaultSecret = func() func(name string) (string, error) {
+	var once sync.Once
+	var vault *secretVault
+	var vaultErr error
+	return func(name string) (string, error) {
+		once.Do(func() {
+			path := getVaultPath()
+			if !isFileExist(path) {
+				vaultErr = fmt.Errorf("vault [%s] does not exist", path)
+				return
+			}
+			passphrase, err := getVaultPassphrase()
+			if err != nil {
+				vaultErr = err
+				return
+			}
+			vault, vaultErr = openVault(path, passphrase)
+		})
+		if vaultErr != nil {
+			return "", vaultErr
+		}
+		secret, ok := vault.secrets[name]
+		if !ok {
+			return "", fmt.Errorf("no secret named [%s] in vault", name)
+		}
+		return secret, nil
+	}
+}()
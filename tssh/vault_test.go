@@ -0,0 +1,63 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretVault(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "tssh.vault")
+
+	vault, err := openVault(path, []byte("passphrase"))
+	require.Nil(err)
+	assert.Empty(vault.names())
+
+	vault.secrets["db"] = "db-password"
+	vault.secrets["bastion"] = "bastion-password"
+	require.Nil(vault.save())
+
+	vault, err = openVault(path, []byte("passphrase"))
+	require.Nil(err)
+	assert.Equal([]string{"bastion", "db"}, vault.names())
+	assert.Equal("db-password", vault.secrets["db"])
+
+	_, err = openVault(path, []byte("incorrect"))
+	assert.EqualError(err, "passphrase incorrect")
+
+	vault.passphrase = []byte("new passphrase")
+	require.Nil(vault.save())
+	_, err = openVault(path, []byte("passphrase"))
+	assert.NotNil(err)
+	vault, err = openVault(path, []byte("new passphrase"))
+	require.Nil(err)
+	assert.Equal("bastion-password", vault.secrets["bastion"])
+}
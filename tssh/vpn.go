@@ -0,0 +1,349 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The --vpn mode works like sshuttle, e.g.:
+//
+//	tssh --vpn 10.0.0.0/8 --vpn 172.16.0.0/12 --vpn-dns bastion
+//
+// The tcp connections to the subnets are redirected by iptables to a local transparent proxy, which
+// connects to their original destinations through the ssh connection. With --vpn-dns, the dns queries
+// are redirected to a local dns server, which relays them over tcp to the nameserver of the server.
+// The iptables rules are added by sudo if not root, and removed on exit.
+
+type vpnRules struct {
+	chain    string
+	subnets  []*net.IPNet
+	excludes []net.IP
+	port     int
+	dnsPort  int
+}
+
+var dialedIPs struct {
+	sync.Mutex
+	ips []net.IP
+}
+
+// recordDialedAddr records the address connected directly, e.g., the first jump host,
+// which must not be redirected by --vpn, or the ssh connection would go through itself.
+func recordDialedAddr(addr net.Addr) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || tcpAddr.IP == nil || tcpAddr.IP.IsUnspecified() {
+		return
+	}
+	dialedIPs.Lock()
+	defer dialedIPs.Unlock()
+	dialedIPs.ips = appendUniqueIP(dialedIPs.ips, tcpAddr.IP)
+}
+
+func appendUniqueIP(ips []net.IP, ip net.IP) []net.IP {
+	for _, v := range ips {
+		if v.Equal(ip) {
+			return ips
+		}
+	}
+	return append(ips, ip)
+}
+
+// getVpnExcludes returns the addresses connected directly and the resolved addresses of the target.
+func getVpnExcludes(param *sshParam) []net.IP {
+	dialedIPs.Lock()
+	excludes := append([]net.IP(nil), dialedIPs.ips...)
+	dialedIPs.Unlock()
+	if host, _, err := net.SplitHostPort(param.addr); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			excludes = appendUniqueIP(excludes, ip)
+		} else if ips, err := net.LookupIP(host); err == nil {
+			for _, ip := range ips {
+				excludes = appendUniqueIP(excludes, ip)
+			}
+		}
+	}
+	return excludes
+}
+
+func hasIPv6Subnet(subnets []*net.IPNet) bool {
+	for _, subnet := range subnets {
+		if subnet.IP.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func parseVpnSubnets(values []string) ([]*net.IPNet, error) {
+	var subnets []*net.IPNet
+	for _, value := range values {
+		for _, subnet := range strings.Split(value, ",") {
+			if subnet = strings.TrimSpace(subnet); subnet == "" {
+				continue
+			}
+			if !strings.Contains(subnet, "/") {
+				if ip := net.ParseIP(subnet); ip != nil && ip.To4() == nil {
+					subnet += "/128"
+				} else {
+					subnet += "/32"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(subnet)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --vpn subnet [%s]: %v", subnet, err)
+			}
+			subnets = append(subnets, ipNet)
+		}
+	}
+	return subnets, nil
+}
+
+func getIptables(ipv6 bool) string {
+	if ipv6 {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+// getSetupCommands returns the commands to add the rules, the servers are excluded to avoid the loop.
+func (r *vpnRules) getSetupCommands() [][]string {
+	var commands [][]string
+	for _, ipv6 := range []bool{false, true} {
+		var rules, redirects [][]string
+		for _, exclude := range r.excludes {
+			if (exclude.To4() == nil) == ipv6 {
+				rules = append(rules, []string{"-d", exclude.String(), "-j", "RETURN"})
+			}
+		}
+		for _, subnet := range r.subnets {
+			if (subnet.IP.To4() == nil) == ipv6 {
+				redirects = append(redirects, []string{"-d", subnet.String(), "-p", "tcp", "-j", "REDIRECT", "--to-ports", strconv.Itoa(r.port)})
+			}
+		}
+		if r.dnsPort > 0 && !ipv6 {
+			redirects = append(redirects, []string{"-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", strconv.Itoa(r.dnsPort)})
+		}
+		if len(redirects) == 0 {
+			continue
+		}
+		rules = append(rules, redirects...)
+		iptables := getIptables(ipv6)
+		commands = append(commands, []string{iptables, "-t", "nat", "-N", r.chain})
+		for _, rule := range rules {
+			commands = append(commands, append([]string{iptables, "-t", "nat", "-A", r.chain}, rule...))
+		}
+		commands = append(commands, []string{iptables, "-t", "nat", "-I", "OUTPUT", "1", "-j", r.chain})
+	}
+	return commands
+}
+
+// getCleanupCommands returns the commands to remove the rules added by getSetupCommands.
+func (r *vpnRules) getCleanupCommands(setup [][]string) [][]string {
+	var commands [][]string
+	for _, command := range setup {
+		if command[3] == "-N" {
+			iptables := command[0]
+			commands = append(commands,
+				[]string{iptables, "-t", "nat", "-D", "OUTPUT", "-j", r.chain},
+				[]string{iptables, "-t", "nat", "-F", r.chain},
+				[]string{iptables, "-t", "nat", "-X", r.chain})
+		}
+	}
+	return commands
+}
+
+func runVpnCommand(command []string) error {
+	if os.Geteuid() != 0 {
+		command = append([]string{"sudo"}, command...)
+	}
+	debug("vpn run: %s", strings.Join(command, " "))
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout = os.Stdin, os.Stderr
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v %s", strings.Join(command, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// getRemoteNameserver returns the first nameserver in /etc/resolv.conf of the server.
+func getRemoteNameserver(client *ssh.Client) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	output, err := session.Output("cat /etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+	return parseNameserver(output)
+}
+
+func parseNameserver(resolvConf []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(resolvConf))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			return joinHostPort(fields[1], "53"), nil
+		}
+	}
+	return "", fmt.Errorf("no nameserver in /etc/resolv.conf")
+}
+
+// relayDnsQuery sends the udp dns query over tcp, which is prefixed by the two bytes length.
+func relayDnsQuery(dial func(network, addr string) (net.Conn, error), nameserver string, query []byte) ([]byte, error) {
+	conn, err := dial("tcp", nameserver)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	message := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(message, uint16(len(query)))
+	copy(message[2:], query)
+	if _, err := conn.Write(message); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func serveVpnDns(packetConn net.PacketConn, dial func(network, addr string) (net.Conn, error), nameserver string) {
+	defer packetConn.Close()
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := packetConn.ReadFrom(buffer)
+		if err != nil {
+			debug("vpn dns read failed: %v", err)
+			return
+		}
+		query := append([]byte(nil), buffer[:n]...)
+		go func() {
+			response, err := relayDnsQuery(dial, nameserver, query)
+			if err != nil {
+				debug("vpn dns relay to %s failed: %v", nameserver, err)
+				return
+			}
+			_, _ = packetConn.WriteTo(response, addr)
+		}()
+	}
+}
+
+// startVpn starts the transparent proxy and the dns server, and adds the iptables rules.
+// The ip6tables REDIRECT goes to ::1, so the proxy listens on both 127.0.0.1 and ::1 for the IPv6 subnets.
+func startVpn(client *ssh.Client, args *sshArgs, param *sshParam) bool {
+	if !isTransparentProxySupported() {
+		warning("--vpn is only supported on Linux")
+		return false
+	}
+	subnets, err := parseVpnSubnets(args.VPN.values)
+	if err != nil {
+		warning("%v", err)
+		return false
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		warning("vpn listen failed: %v", err)
+		return false
+	}
+	closeOnTerminate(listener)
+	listeners := []net.Listener{listener}
+	rules := &vpnRules{subnets: subnets, port: listener.Addr().(*net.TCPAddr).Port, excludes: getVpnExcludes(param)}
+	rules.chain = fmt.Sprintf("TSSH_VPN_%d", rules.port)
+	if hasIPv6Subnet(subnets) {
+		listener6, err := net.Listen("tcp", fmt.Sprintf("[::1]:%d", rules.port))
+		if err != nil {
+			warning("vpn listen on [::1]:%d failed: %v", rules.port, err)
+			listener.Close()
+			return false
+		}
+		closeOnTerminate(listener6)
+		listeners = append(listeners, listener6)
+	}
+	serveTransparentListeners(client, listeners)
+
+	if args.VpnDNS {
+		dial := func(network, addr string) (net.Conn, error) {
+			return dialWithTimeout(client, network, addr, 10*time.Second)
+		}
+		if nameserver, err := getRemoteNameserver(client); err != nil {
+			warning("vpn dns is disabled since get the nameserver failed: %v", err)
+		} else if packetConn, err := net.ListenPacket("udp4", "127.0.0.1:0"); err != nil {
+			warning("vpn dns listen failed: %v", err)
+		} else {
+			rules.dnsPort = packetConn.LocalAddr().(*net.UDPAddr).Port
+			go serveVpnDns(packetConn, dial, nameserver)
+			debug("vpn dns queries are relayed to %s", nameserver)
+		}
+	}
+
+	setup := rules.getSetupCommands()
+	var done [][]string
+	cleanup := func() {
+		for _, command := range rules.getCleanupCommands(done) {
+			if err := runVpnCommand(command); err != nil {
+				debug("vpn cleanup: %v", err)
+			}
+		}
+	}
+	for _, command := range setup {
+		if err := runVpnCommand(command); err != nil {
+			warning("vpn setup failed: %v", err)
+			cleanup()
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return false
+		}
+		done = append(done, command)
+	}
+	onExit(cleanup)
+	fmt.Fprintf(os.Stderr, "\033[0;36mThe traffic to %s is forwarded through %s\033[0m\r\n",
+		strings.Join(args.VPN.values, ", "), args.Destination)
+	return true
+}
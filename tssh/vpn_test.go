@@ -0,0 +1,143 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVpnRules(t *testing.T) {
+	assert := assert.New(t)
+	subnets, err := parseVpnSubnets([]string{"10.0.0.0/8, 172.16.0.0/12", "192.168.1.1", "fd00::/8"})
+	assert.Nil(err)
+	assert.Equal([]string{"10.0.0.0/8", "172.16.0.0/12", "192.168.1.1/32", "fd00::/8"},
+		[]string{subnets[0].String(), subnets[1].String(), subnets[2].String(), subnets[3].String()})
+	_, err = parseVpnSubnets([]string{"10.0.0.0/33"})
+	assert.NotNil(err)
+
+	rules := &vpnRules{chain: "TSSH_VPN_1234", subnets: subnets[:2], excludes: []net.IP{net.ParseIP("10.1.2.3"), net.ParseIP("fd00::1")}, port: 1234, dnsPort: 5353}
+	setup := rules.getSetupCommands()
+	assert.Equal([][]string{
+		{"iptables", "-t", "nat", "-N", "TSSH_VPN_1234"},
+		{"iptables", "-t", "nat", "-A", "TSSH_VPN_1234", "-d", "10.1.2.3", "-j", "RETURN"},
+		{"iptables", "-t", "nat", "-A", "TSSH_VPN_1234", "-d", "10.0.0.0/8", "-p", "tcp", "-j", "REDIRECT", "--to-ports", "1234"},
+		{"iptables", "-t", "nat", "-A", "TSSH_VPN_1234", "-d", "172.16.0.0/12", "-p", "tcp", "-j", "REDIRECT", "--to-ports", "1234"},
+		{"iptables", "-t", "nat", "-A", "TSSH_VPN_1234", "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", "5353"},
+		{"iptables", "-t", "nat", "-I", "OUTPUT", "1", "-j", "TSSH_VPN_1234"},
+	}, setup)
+	assert.Equal([][]string{
+		{"iptables", "-t", "nat", "-D", "OUTPUT", "-j", "TSSH_VPN_1234"},
+		{"iptables", "-t", "nat", "-F", "TSSH_VPN_1234"},
+		{"iptables", "-t", "nat", "-X", "TSSH_VPN_1234"},
+	}, rules.getCleanupCommands(setup))
+
+	rules = &vpnRules{chain: "TSSH_VPN_1234", subnets: subnets[3:], excludes: []net.IP{net.ParseIP("10.1.2.3"), net.ParseIP("fd00::1")}, port: 1234, dnsPort: 5353}
+	setup = rules.getSetupCommands()
+	assert.Equal([][]string{
+		{"iptables", "-t", "nat", "-N", "TSSH_VPN_1234"},
+		{"iptables", "-t", "nat", "-A", "TSSH_VPN_1234", "-d", "10.1.2.3", "-j", "RETURN"},
+		{"iptables", "-t", "nat", "-A", "TSSH_VPN_1234", "-p", "udp", "--dport", "53", "-j", "REDIRECT", "--to-ports", "5353"},
+		{"iptables", "-t", "nat", "-I", "OUTPUT", "1", "-j", "TSSH_VPN_1234"},
+		{"ip6tables", "-t", "nat", "-N", "TSSH_VPN_1234"},
+		{"ip6tables", "-t", "nat", "-A", "TSSH_VPN_1234", "-d", "fd00::1", "-j", "RETURN"},
+		{"ip6tables", "-t", "nat", "-A", "TSSH_VPN_1234", "-d", "fd00::/8", "-p", "tcp", "-j", "REDIRECT", "--to-ports", "1234"},
+		{"ip6tables", "-t", "nat", "-I", "OUTPUT", "1", "-j", "TSSH_VPN_1234"},
+	}, setup)
+	assert.Len(rules.getCleanupCommands(setup), 6)
+	assert.True(hasIPv6Subnet(subnets))
+	assert.False(hasIPv6Subnet(subnets[:3]))
+
+	// the addresses connected directly and the target are excluded
+	recordDialedAddr(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22})
+	recordDialedAddr(&net.TCPAddr{IP: net.IPv4zero, Port: 0})
+	recordDialedAddr(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222})
+	excludes := getVpnExcludes(&sshParam{addr: "[2001:db8::2]:22"})
+	assert.Equal([]string{"192.0.2.1", "2001:db8::2"}, []string{excludes[0].String(), excludes[1].String()})
+}
+
+func TestParseNameserver(t *testing.T) {
+	assert := assert.New(t)
+	nameserver, err := parseNameserver([]byte("# comment\nsearch example.com\nnameserver 10.0.0.2\nnameserver 10.0.0.3\n"))
+	assert.Nil(err)
+	assert.Equal("10.0.0.2:53", nameserver)
+	nameserver, err = parseNameserver([]byte("nameserver fd00::53\n"))
+	assert.Nil(err)
+	assert.Equal("[fd00::53]:53", nameserver)
+	_, err = parseNameserver([]byte("search example.com\n"))
+	assert.NotNil(err)
+}
+
+func TestVpnDns(t *testing.T) {
+	assert := assert.New(t)
+	// a tcp dns server which responds the reversed query
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				for i, j := 0, len(query)-1; i < j; i, j = i+1, j-1 {
+					query[i], query[j] = query[j], query[i]
+				}
+				_, _ = conn.Write(append(length[:], query...))
+			}()
+		}
+	}()
+
+	packetConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.Nil(err)
+	go serveVpnDns(packetConn, net.Dial, listener.Addr().String())
+	defer packetConn.Close()
+
+	client, err := net.Dial("udp4", packetConn.LocalAddr().String())
+	assert.Nil(err)
+	defer client.Close()
+	_, err = client.Write([]byte("query"))
+	assert.Nil(err)
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, 100)
+	n, err := client.Read(buffer)
+	assert.Nil(err)
+	assert.Equal("yreuq", string(buffer[:n]))
+}
@@ -0,0 +1,174 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watermark prints a local marker with the alias, the host and the time into the scrollback,
+// on connect and every ExWatermark minutes, so the terminal tabs won't be mixed up, e.g.:
+//
+//	Host prod*
+//	    ExWatermark 30
+//
+// `ExWatermark yes` prints it on connect only. The periodic one waits until the output pauses,
+// and is skipped while a full-screen program is on the alternate screen.
+type watermark struct {
+	mutex    sync.Mutex
+	out      io.Writer
+	text     string
+	interval time.Duration
+	parser   outputTracker
+	pending  bool
+	quiet    *time.Timer
+	ticker   *time.Ticker
+	done     chan struct{}
+	closed   bool
+}
+
+const kWatermarkQuietDelay = 300 * time.Millisecond
+
+func getWatermarkText(args *sshArgs, param *sshParam) string {
+	alias := args.originalDest
+	if alias == "" {
+		alias = args.Destination
+	}
+	return fmt.Sprintf("%s | %s@%s", alias, param.user, joinHostPort(param.host, param.port))
+}
+
+func newWatermark(args *sshArgs, param *sshParam) *watermark {
+	value := strings.ToLower(getExOptionConfig(args, "ExWatermark"))
+	w := &watermark{text: getWatermarkText(args, param)}
+	switch value {
+	case "", "no", "0":
+		return nil
+	case "yes":
+	default:
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 0 {
+			warning("invalid ExWatermark [%s], should be yes, no or the minutes", value)
+			return nil
+		}
+		w.interval = time.Duration(minutes) * time.Minute
+	}
+	return w
+}
+
+func (w *watermark) render() string {
+	return fmt.Sprintf("\033[0;1;97;45m >>> %s | %s <<< \033[0m", w.text, time.Now().Format("2006-01-02 15:04:05"))
+}
+
+// start prints the first marker, and then the periodic ones to the writer.
+func (w *watermark) start(out io.Writer) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.out = out
+	fmt.Fprintf(w.out, "%s\r\n", w.render())
+	if w.interval <= 0 {
+		return
+	}
+	w.ticker = time.NewTicker(w.interval)
+	w.done = make(chan struct{})
+	onExit(w.close)
+	go func() {
+		for {
+			select {
+			case <-w.ticker.C:
+				w.mutex.Lock()
+				w.pending = true
+				w.scheduleLocked()
+				w.mutex.Unlock()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+func (w *watermark) scheduleLocked() {
+	if w.closed || !w.pending {
+		return
+	}
+	if w.quiet == nil {
+		w.quiet = time.AfterFunc(kWatermarkQuietDelay, w.onQuiet)
+	} else {
+		w.quiet.Reset(kWatermarkQuietDelay)
+	}
+}
+
+func (w *watermark) onQuiet() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed || !w.pending {
+		return
+	}
+	if !w.parser.isGround() || w.parser.altScreen {
+		// wait for the next pause of the output
+		return
+	}
+	w.pending = false
+	fmt.Fprintf(w.out, "\r\n%s\r\n", w.render())
+}
+
+// close stops the periodic marker, and it's safe to be called more than once.
+func (w *watermark) close() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	if w.ticker != nil {
+		w.ticker.Stop()
+		close(w.done)
+	}
+	if w.quiet != nil {
+		w.quiet.Stop()
+	}
+}
+
+type watermarkWriter struct {
+	writer    io.WriteCloser
+	watermark *watermark
+}
+
+func (w *watermarkWriter) Write(p []byte) (int, error) {
+	w.watermark.mutex.Lock()
+	defer w.watermark.mutex.Unlock()
+	n, err := w.writer.Write(p)
+	w.watermark.parser.track(p[:n])
+	w.watermark.scheduleLocked()
+	return n, err
+}
+
+func (w *watermarkWriter) Close() error {
+	return w.writer.Close()
+}
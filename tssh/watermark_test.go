@@ -0,0 +1,84 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatermark(t *testing.T) {
+	assert := assert.New(t)
+	newArgs := func(options ...string) *sshArgs {
+		args := &sshArgs{Destination: "prod"}
+		for _, option := range options {
+			assert.Nil(args.Option.UnmarshalText([]byte(option)))
+		}
+		return args
+	}
+	param := &sshParam{host: "10.0.0.1", port: "22", user: "root"}
+
+	assert.Nil(newWatermark(newArgs(), param))
+	assert.Nil(newWatermark(newArgs("ExWatermark no"), param))
+	assert.Nil(newWatermark(newArgs("ExWatermark abc"), param))
+	assert.Equal(time.Duration(0), newWatermark(newArgs("ExWatermark yes"), param).interval)
+	assert.Equal(30*time.Minute, newWatermark(newArgs("ExWatermark 30"), param).interval)
+
+	w := newWatermark(newArgs("ExWatermark yes"), param)
+	assert.Equal("prod | root@10.0.0.1:22", w.text)
+	buf := syncBuffer{}
+	w.start(&buf)
+	assert.True(strings.HasPrefix(buf.String(), "\033[0;1;97;45m >>> prod | root@10.0.0.1:22 | "))
+	assert.True(strings.HasSuffix(buf.String(), " <<< \033[0m\r\n"))
+
+	// the periodic marker waits for the output to leave the alternate screen
+	buf = syncBuffer{}
+	writer := &watermarkWriter{&buf, w}
+	_, _ = writer.Write([]byte("\033[?1049hvim"))
+	w.mutex.Lock()
+	w.pending = true
+	w.mutex.Unlock()
+	w.onQuiet()
+	assert.Equal("\033[?1049hvim", buf.String())
+	_, _ = writer.Write([]byte("\033[?1049l$ "))
+	w.onQuiet()
+	assert.True(strings.HasPrefix(buf.String(), "\033[?1049hvim\033[?1049l$ \r\n\033[0;1;97;45m >>> prod"))
+	assert.False(w.pending)
+	w.close()
+
+	// the ticker goroutine exits once closed
+	w = newWatermark(newArgs("ExWatermark 30"), param)
+	w.start(&syncBuffer{})
+	w.close()
+	w.close()
+	select {
+	case <-w.done:
+	default:
+		assert.Fail("the done channel should be closed")
+	}
+}
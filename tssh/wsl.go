@@ -0,0 +1,166 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	kWslAgentAddr         = "wsl:windows-agent"
+	kDefaultWslAgentRelay = "npiperelay.exe -ei -s //./pipe/openssh-ssh-agent"
+	kWslConfPath          = "/etc/wsl.conf"
+	kDefaultWslMountRoot  = "/mnt/"
+)
+
+func isRunningOnWSL() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	return os.Getenv("WSL_DISTRO_NAME") != "" || isFileExist("/proc/sys/fs/binfmt_misc/WSLInterop")
+}
+
+// getWslMountRoot returns the root of the Windows drives configured in the wsl.conf, /mnt/ by default, e.g.:
+//
+//	[automount]
+//	root = /win/
+func getWslMountRoot(confPath string) string {
+	file, err := os.Open(confPath)
+	if err != nil {
+		return kDefaultWslMountRoot
+	}
+	defer file.Close()
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.IndexAny(line, "#;"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "automount" || strings.ToLower(strings.TrimSpace(key)) != "root" {
+			continue
+		}
+		if root := strings.Trim(strings.TrimSpace(value), `"'`); root != "" {
+			if !strings.HasSuffix(root, "/") {
+				root += "/"
+			}
+			return root
+		}
+	}
+	return kDefaultWslMountRoot
+}
+
+// getWslWindowsHome returns the Windows user's home directory in the WSL path format, e.g., /mnt/c/Users/xxx
+func getWslWindowsHome() (string, error) {
+	cmd := exec.Command("cmd.exe", "/c", "echo %USERPROFILE%")
+	if driveC := getWslMountRoot(kWslConfPath) + "c"; isFileExist(driveC) {
+		// avoid the warning of cmd.exe that UNC paths are not supported
+		cmd.Dir = driveC
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("get USERPROFILE via cmd.exe failed: %v", err)
+	}
+	winHome := strings.TrimSpace(string(out))
+	if winHome == "" || strings.Contains(winHome, "%") {
+		return "", fmt.Errorf("USERPROFILE is not set in Windows")
+	}
+	out, err = exec.Command("wslpath", "-u", winHome).Output()
+	if err != nil {
+		return "", fmt.Errorf("wslpath -u %s failed: %v", winHome, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// getWslWindowsConfigPath returns the Windows side ~/.ssh/config if WslWindowsConfig is enabled in ~/.tssh.conf
+func getWslWindowsConfigPath() string {
+	value := userConfig.wslWindowsConfig
+	if value == "" || strings.ToLower(value) == "no" || !isRunningOnWSL() {
+		return ""
+	}
+	if strings.ToLower(value) != "yes" {
+		return resolvePath(value)
+	}
+	winHome, err := getWslWindowsHome()
+	if err != nil {
+		warning("WslWindowsConfig is enabled, but %v", err)
+		return ""
+	}
+	return filepath.Join(winHome, ".ssh", "config")
+}
+
+// isWslWindowsAgentEnabled checks if WslWindowsAgent is enabled in ~/.tssh.conf,
+// the value is yes or the command to relay to the Windows OpenSSH agent named pipe.
+func isWslWindowsAgentEnabled() bool {
+	value := strings.ToLower(userConfig.wslWindowsAgent)
+	return value != "" && value != "no" && isRunningOnWSL()
+}
+
+type wslAgentConn struct {
+	cmdPipe
+	cmd *exec.Cmd
+}
+
+func (c *wslAgentConn) Close() error {
+	err := c.cmdPipe.Close()
+	go func() { _ = c.cmd.Wait() }()
+	return err
+}
+
+func dialWslWindowsAgent() (net.Conn, error) {
+	relay := userConfig.wslWindowsAgent
+	if strings.ToLower(relay) == "yes" {
+		relay = kDefaultWslAgentRelay
+	}
+	argv, err := splitCommandLine(resolveHomeDir(relay))
+	if err != nil || len(argv) == 0 {
+		return nil, fmt.Errorf("split agent relay command [%s] failed: %v", relay, err)
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmdIn, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmdOut, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start agent relay [%s] failed: %v", relay, err)
+	}
+	return &wslAgentConn{cmdPipe{stdin: cmdIn, stdout: cmdOut, addr: kWslAgentAddr}, cmd}, nil
+}
@@ -0,0 +1,75 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWslWindowsAgentRelay(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not found")
+	}
+	assert := assert.New(t)
+	defer func(relay string) { userConfig.wslWindowsAgent = relay }(userConfig.wslWindowsAgent)
+
+	userConfig.wslWindowsAgent = "cat"
+	conn, err := dialWslWindowsAgent()
+	assert.Nil(err)
+	_, err = conn.Write([]byte("agent request"))
+	assert.Nil(err)
+	buf := make([]byte, len("agent request"))
+	_, err = io.ReadFull(conn, buf)
+	assert.Nil(err)
+	assert.Equal("agent request", string(buf))
+	assert.Equal(kWslAgentAddr, conn.RemoteAddr().String())
+	assert.Nil(conn.Close())
+
+	userConfig.wslWindowsAgent = "no"
+	assert.False(isWslWindowsAgentEnabled())
+}
+
+func TestWslMountRoot(t *testing.T) {
+	assert := assert.New(t)
+	confPath := filepath.Join(t.TempDir(), "wsl.conf")
+	assert.Equal("/mnt/", getWslMountRoot(confPath))
+
+	writeConf := func(content string) {
+		t.Helper()
+		assert.Nil(os.WriteFile(confPath, []byte(content), 0644))
+	}
+	writeConf("[boot]\nsystemd=true\n")
+	assert.Equal("/mnt/", getWslMountRoot(confPath))
+	writeConf("[network]\nroot = /net/\n[automount]\nenabled = true\nroot = /win\n")
+	assert.Equal("/win/", getWslMountRoot(confPath))
+	writeConf("# the drives\n[Automount]\nroot=\"/\" # mount at /c\n")
+	assert.Equal("/", getWslMountRoot(confPath))
+}
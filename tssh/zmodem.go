@@ -0,0 +1,220 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	kZmodemOptionsEnv = "TSSH_ZMODEM_OPTIONS"
+	kZmodemShimDirEnv = "TSSH_ZMODEM_SHIM_DIR"
+)
+
+// zmodemOptions tunes the local lrzsz ( rz / sz ) launched by trzsz for the zmodem transfer.
+//
+// The default options of trzsz are "-e -b -B 32768", which some embedded lrzsz builds can't handle.
+// They can be changed by ExZmodemOptions, e.g.:
+//
+//	ExZmodemOptions window=32768 escape=no binary=auto buffer=16384
+type zmodemOptions struct {
+	window int    // sz -w N, the window size, 0 means no windowing
+	buffer int    // rz / sz -B N, the buffer size
+	escape bool   // rz / sz -e, escape all control characters
+	binary string // yes: -b, no: -a, auto: let lrzsz decide
+}
+
+func parseZmodemOptions(value string) (*zmodemOptions, error) {
+	opts := &zmodemOptions{buffer: 32768, escape: true, binary: "yes"}
+	for _, option := range strings.Fields(strings.ReplaceAll(value, ",", " ")) {
+		key, val, ok := strings.Cut(option, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid zmodem option [%s], should be key=value", option)
+		}
+		switch strings.ToLower(key) {
+		case "window", "buffer":
+			size, err := strconv.ParseUint(val, 10, 31)
+			if err != nil {
+				return nil, fmt.Errorf("invalid zmodem option [%s]: %v", option, err)
+			}
+			if strings.ToLower(key) == "window" {
+				opts.window = int(size)
+			} else {
+				opts.buffer = int(size)
+			}
+		case "escape":
+			switch strings.ToLower(val) {
+			case "yes":
+				opts.escape = true
+			case "no":
+				opts.escape = false
+			default:
+				return nil, fmt.Errorf("invalid zmodem option [%s], escape should be yes or no", option)
+			}
+		case "binary":
+			switch strings.ToLower(val) {
+			case "yes", "no", "auto":
+				opts.binary = strings.ToLower(val)
+			default:
+				return nil, fmt.Errorf("invalid zmodem option [%s], binary should be yes, no or auto", option)
+			}
+		default:
+			return nil, fmt.Errorf("unknown zmodem option [%s]", option)
+		}
+	}
+	return opts, nil
+}
+
+func (o *zmodemOptions) getArgs(program string) []string {
+	var args []string
+	if o.escape {
+		args = append(args, "-e")
+	}
+	switch o.binary {
+	case "yes":
+		args = append(args, "-b")
+	case "no":
+		args = append(args, "-a")
+	}
+	if o.buffer > 0 {
+		args = append(args, "-B", strconv.Itoa(o.buffer))
+	}
+	if program == "sz" && o.window > 0 {
+		args = append(args, "-w", strconv.Itoa(o.window))
+	}
+	return args
+}
+
+// replaceZmodemArgs replaces the default options of trzsz with the configured ones.
+func replaceZmodemArgs(program string, args []string, opts *zmodemOptions) []string {
+	var newArgs []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-e", "-b", "-a":
+		case "-B":
+			i++
+		case "--":
+			newArgs = append(append(newArgs, opts.getArgs(program)...), args[i:]...)
+			return newArgs
+		default:
+			if program == "sz" && !strings.HasPrefix(args[i], "-") {
+				newArgs = append(append(newArgs, opts.getArgs(program)...), args[i:]...)
+				return newArgs
+			}
+			newArgs = append(newArgs, args[i])
+		}
+	}
+	return append(newArgs, opts.getArgs(program)...)
+}
+
+// setupZmodemOptions puts the rz / sz shims in front of PATH if ExZmodemOptions is configured.
+// The shims are links to tssh itself, see execZmodemShim.
+func setupZmodemOptions(args *sshArgs) {
+	value := getExOptionConfig(args, "ExZmodemOptions")
+	if value == "" {
+		return
+	}
+	if _, err := parseZmodemOptions(value); err != nil {
+		warning("ExZmodemOptions [%s] is invalid: %v", value, err)
+		return
+	}
+	if runtime.GOOS == "windows" {
+		warning("ExZmodemOptions is not supported on Windows yet")
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		warning("get executable for zmodem failed: %v", err)
+		return
+	}
+	dir, err := os.MkdirTemp("", "tssh-zmodem-")
+	if err != nil {
+		warning("create zmodem shim directory failed: %v", err)
+		return
+	}
+	onExit(func() { _ = os.RemoveAll(dir) })
+	for _, program := range []string{"rz", "sz"} {
+		if err := os.Symlink(exe, filepath.Join(dir, program)); err != nil {
+			warning("create zmodem shim failed: %v", err)
+			return
+		}
+	}
+	_ = os.Setenv(kZmodemOptionsEnv, value)
+	_ = os.Setenv(kZmodemShimDirEnv, dir)
+	_ = os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	debug("zmodem options: %s", value)
+}
+
+func lookPathExcept(program, exceptDir string) (string, error) {
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || dir == exceptDir {
+			continue
+		}
+		path := filepath.Join(dir, program)
+		if stat, err := os.Stat(path); err == nil && !stat.IsDir() && stat.Mode()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in PATH", program)
+}
+
+// execZmodemShim runs the real rz / sz with ExZmodemOptions if tssh is launched as the shim.
+//
+// return true to quit with return code
+func execZmodemShim() (int, bool) {
+	dir := os.Getenv(kZmodemShimDirEnv)
+	program := filepath.Base(os.Args[0])
+	if dir == "" || (program != "rz" && program != "sz") {
+		return 0, false
+	}
+	opts, err := parseZmodemOptions(os.Getenv(kZmodemOptionsEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid zmodem options: %v\n", err)
+		return 1, true
+	}
+	path, err := lookPathExcept(program, dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1, true
+	}
+	cmd := exec.Command(path, replaceZmodemArgs(program, os.Args[1:], opts)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), true
+		}
+		fmt.Fprintf(os.Stderr, "run %s failed: %v\n", path, err)
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,55 @@
+/*
+MIT License
+
+Copyright (c) 2023-2024 The Trzsz SSH Authors.
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tssh
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZmodemOptions(t *testing.T) {
+	assert := assert.New(t)
+	opts, err := parseZmodemOptions("")
+	assert.Nil(err)
+	assert.Equal([]string{"-e", "-b", "-B", "32768"}, opts.getArgs("sz"))
+
+	opts, err = parseZmodemOptions("window=4096, escape=no binary=auto buffer=1024")
+	assert.Nil(err)
+	assert.Equal([]string{"-B", "1024", "-w", "4096"}, opts.getArgs("sz"))
+	assert.Equal([]string{"-B", "1024"}, opts.getArgs("rz"))
+	assert.Equal([]string{"-B", "1024", "-w", "4096", "a.txt", "-b.txt"},
+		replaceZmodemArgs("sz", []string{"-e", "-b", "-B", "32768", "a.txt", "-b.txt"}, opts))
+	assert.Equal([]string{"-E", "-B", "1024"}, replaceZmodemArgs("rz", []string{"-E", "-e", "-b", "-B", "32768"}, opts))
+
+	opts, err = parseZmodemOptions("binary=no buffer=0")
+	assert.Nil(err)
+	assert.Equal([]string{"-E", "-e", "-a"}, replaceZmodemArgs("rz", []string{"-E", "-e", "-b", "-B", "32768"}, opts))
+
+	for _, invalid := range []string{"window", "window=-1", "escape=maybe", "binary=1", "unknown=1"} {
+		_, err = parseZmodemOptions(invalid)
+		assert.NotNil(err, invalid)
+	}
+}